@@ -0,0 +1,172 @@
+// Package apierror is the error model of the HTTP API. Every failure has a
+// stable machine-readable Code, an HTTP status derived from its Kind,
+// optional field-level details, and a message rendered in the caller's
+// language from the catalog in messages.go.
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Kinds of failure. Errors unwrap to one of these, so callers can test them
+// with errors.Is without caring about the specific Code.
+var (
+	ErrInvalid         = errors.New("invalid")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrInternal        = errors.New("internal")
+)
+
+var statuses = map[error]int{
+	ErrInvalid:         http.StatusBadRequest,
+	ErrUnauthorized:    http.StatusUnauthorized,
+	ErrForbidden:       http.StatusForbidden,
+	ErrNotFound:        http.StatusNotFound,
+	ErrConflict:        http.StatusConflict,
+	ErrTooManyRequests: http.StatusTooManyRequests,
+	ErrInternal:        http.StatusInternalServerError,
+}
+
+type Code string
+
+// Error is a failure that is safe to report to API clients.
+type Error struct {
+	Kind   error
+	Code   Code
+	Params map[string]string
+	Fields []FieldError
+	// RetryAfter is sent as the Retry-After header when set.
+	RetryAfter time.Duration
+}
+
+// FieldError points at one invalid request field or query parameter.
+type FieldError struct {
+	Field  string
+	Code   Code
+	Params map[string]string
+}
+
+func newError(kind error, code Code) *Error {
+	return &Error{Kind: kind, Code: code}
+}
+
+func Invalid(code Code) *Error         { return newError(ErrInvalid, code) }
+func Unauthorized(code Code) *Error    { return newError(ErrUnauthorized, code) }
+func Forbidden(code Code) *Error       { return newError(ErrForbidden, code) }
+func NotFound(code Code) *Error        { return newError(ErrNotFound, code) }
+func Conflict(code Code) *Error        { return newError(ErrConflict, code) }
+func TooManyRequests(code Code) *Error { return newError(ErrTooManyRequests, code) }
+func Internal() *Error                 { return newError(ErrInternal, InternalError) }
+
+// Validation reports invalid fields. Its message is the first field's
+// message, so clients that only show "error" still say what is wrong.
+func Validation(fields ...FieldError) *Error {
+	return &Error{Kind: ErrInvalid, Code: ValidationFailed, Fields: fields}
+}
+
+// Field describes a problem with one field. params are key/value pairs
+// substituted into the message, e.g. Field("limit", OutOfRange, "min", "1").
+func Field(field string, code Code, params ...string) FieldError {
+	return FieldError{Field: field, Code: code, Params: pairs(params)}
+}
+
+// With adds message parameters as key/value pairs.
+func (e *Error) With(params ...string) *Error {
+	if e.Params == nil {
+		e.Params = map[string]string{}
+	}
+	for k, v := range pairs(params) {
+		e.Params[k] = v
+	}
+	return e
+}
+
+// After sets RetryAfter, rounded up to whole seconds, and the "seconds"
+// message parameter.
+func (e *Error) After(wait time.Duration) *Error {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	e.RetryAfter = time.Duration(seconds) * time.Second
+	return e.With("seconds", strconv.Itoa(seconds))
+}
+
+func pairs(params []string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	m := make(map[string]string, len(params)/2)
+	for i := 0; i+1 < len(params); i += 2 {
+		m[params[i]] = params[i+1]
+	}
+	return m
+}
+
+func (e *Error) Error() string { return e.Message("en") }
+
+func (e *Error) Unwrap() error { return e.Kind }
+
+func (e *Error) Status() int {
+	if status, ok := statuses[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Message renders the error in locale.
+func (e *Error) Message(locale string) string {
+	if e.Code == ValidationFailed && len(e.Fields) > 0 {
+		return e.Fields[0].Message(locale)
+	}
+	return render(e.Code, locale, e.Params)
+}
+
+func (f FieldError) Message(locale string) string {
+	params := map[string]string{"field": f.Field}
+	for k, v := range f.Params {
+		params[k] = v
+	}
+	return render(f.Code, locale, params)
+}
+
+// Body is the JSON shape of every error response. Error keeps its historic
+// name and type so existing clients can keep showing it.
+type Body struct {
+	Error   string      `json:"error"`
+	Code    Code        `json:"code"`
+	Details []FieldBody `json:"details,omitempty"`
+}
+
+type FieldBody struct {
+	Field   string `json:"field"`
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Body(locale string) Body {
+	body := Body{Error: e.Message(locale), Code: e.Code}
+	for _, f := range e.Fields {
+		body.Details = append(body.Details, FieldBody{Field: f.Field, Code: f.Code, Message: f.Message(locale)})
+	}
+	return body
+}
+
+func render(code Code, locale string, params map[string]string) string {
+	translations, ok := catalog[code]
+	if !ok {
+		return string(code)
+	}
+	message, ok := translations[locale]
+	if !ok {
+		message = translations["en"]
+	}
+	for k, v := range params {
+		message = strings.ReplaceAll(message, "{"+k+"}", v)
+	}
+	return message
+}
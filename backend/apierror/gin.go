@@ -0,0 +1,73 @@
+package apierror
+
+import (
+	"booking-backend/notifications"
+	"encoding/json"
+	"errors"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// Report binding failures by JSON name, e.g. "session_id" rather than
+	// "SessionID".
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "" || name == "-" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// Locale picks the response language from Accept-Language, the same way
+// emails do.
+func Locale(c *gin.Context) string {
+	return notifications.LocaleFromAcceptLanguage(c.GetHeader("Accept-Language"))
+}
+
+// Respond writes err as an error response and aborts the request. Errors that
+// are not *Error are logged and reported as a generic 500 so internal
+// details never reach clients.
+func Respond(c *gin.Context, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("Unhandled error on %s %s: %v", c.Request.Method, c.FullPath(), err)
+		apiErr = Internal()
+	}
+	if apiErr.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(apiErr.RetryAfter/time.Second)))
+	}
+	c.AbortWithStatusJSON(apiErr.Status(), apiErr.Body(Locale(c)))
+}
+
+// FromBinding converts an error from ShouldBindJSON into a validation error.
+func FromBinding(err error) *Error {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			code := InvalidValue
+			if fe.Tag() == "required" {
+				code = Required
+			}
+			fields = append(fields, Field(fe.Field(), code))
+		}
+		return Validation(fields...)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return Validation(Field(typeErr.Field, InvalidValue))
+	}
+	return Invalid(InvalidJSON)
+}
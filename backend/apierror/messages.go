@@ -0,0 +1,366 @@
+package apierror
+
+// General codes.
+const (
+	InvalidJSON         Code = "invalid_json"
+	ValidationFailed    Code = "validation_failed"
+	NotFoundCode        Code = "not_found"
+	ConstraintViolation Code = "constraint_violation"
+	InternalError       Code = "internal_error"
+)
+
+// Field codes, used in FieldError.
+const (
+	Required      Code = "required"
+	InvalidValue  Code = "invalid"
+	InvalidNumber Code = "invalid_number"
+	InvalidBool   Code = "invalid_boolean"
+	InvalidDate   Code = "invalid_date"
+	OutOfRange    Code = "out_of_range"
+	NotAllowed    Code = "not_allowed"
+	TooSmall      Code = "too_small"
+	BeforeStart   Code = "before_start"
+	InvalidCursor Code = "invalid_cursor"
+	PairRequired  Code = "pair_required"
+
+	PasswordTooShort Code = "password_too_short"
+	PasswordTooLong  Code = "password_too_long"
+	PasswordBreached Code = "password_breached"
+	WrongPassword    Code = "wrong_password"
+	WrongCode        Code = "wrong_two_factor_code"
+)
+
+// Authentication and authorization codes.
+const (
+	AuthRequired        Code = "auth_required"
+	InvalidToken        Code = "invalid_token"
+	InvalidCredentials  Code = "invalid_credentials"
+	InvalidRefreshToken Code = "invalid_refresh_token"
+	PermissionDenied    Code = "permission_denied"
+	EmailNotVerified    Code = "email_not_verified"
+	InvalidVerifyToken  Code = "invalid_verification_token"
+	InvalidResetToken   Code = "invalid_reset_token"
+	InvalidInvitation   Code = "invalid_invitation"
+	InvalidOIDCState    Code = "invalid_oidc_state"
+	OIDCLoginFailed     Code = "oidc_login_failed"
+	OIDCEmailRequired   Code = "oidc_email_not_verified"
+	InvalidTwoFactor    Code = "invalid_two_factor_token"
+	TwoFactorRequired   Code = "two_factor_required"
+	TwoFactorNotEnabled Code = "two_factor_not_enabled"
+	TwoFactorEnabled    Code = "two_factor_already_enabled"
+	InvalidAPIKey       Code = "invalid_api_key"
+	LoginThrottled      Code = "login_throttled"
+	ReauthRequired      Code = "reauthentication_required"
+)
+
+// Domain codes.
+const (
+	RestaurantNotFound      Code = "restaurant_not_found"
+	RestaurantNotLinked     Code = "restaurant_not_linked"
+	TimeSlotNotFound        Code = "time_slot_not_found"
+	TimeSlotInUse           Code = "time_slot_in_use"
+	TimeSlotExists          Code = "time_slot_exists"
+	SessionNotFound         Code = "session_not_found"
+	SessionInUse            Code = "session_in_use"
+	SessionExists           Code = "session_exists"
+	MaxGuestsBelowBooked    Code = "max_guests_below_booked"
+	NotEnoughSlots          Code = "not_enough_slots"
+	BookingNotFound         Code = "booking_not_found"
+	BookingAlreadyCancelled Code = "booking_already_cancelled"
+	UserNotFound            Code = "user_not_found"
+	UserAlreadyLinked       Code = "user_already_linked"
+	StaffNotFound           Code = "staff_not_found"
+	InvitationNotFound      Code = "invitation_not_found"
+	OIDCProviderNotFound    Code = "oidc_provider_not_found"
+	APIKeyNotFound          Code = "api_key_not_found"
+	LastAdmin               Code = "last_admin"
+	EmailTaken              Code = "email_taken"
+	NameTaken               Code = "name_taken"
+	InvalidEmail            Code = "invalid_email"
+	PhoneDigitsOnly         Code = "phone_digits_only"
+	PhoneFormat             Code = "phone_format"
+	DateRangeTooLong        Code = "date_range_too_long"
+	OutboxMessageNotFound   Code = "outbox_message_not_found"
+	OutboxAlreadySent       Code = "outbox_already_sent"
+	OutboxNotDue            Code = "outbox_not_due"
+)
+
+// catalog holds the English and Thai text for every code. {name} is replaced
+// with the matching parameter; field errors always have {field}.
+var catalog = map[Code]map[string]string{
+	InvalidJSON: {
+		"en": "Request body must be valid JSON",
+		"th": "ข้อมูลที่ส่งมาต้องอยู่ในรูปแบบ JSON ที่ถูกต้อง",
+	},
+	ValidationFailed: {
+		"en": "Some fields are invalid",
+		"th": "ข้อมูลบางช่องไม่ถูกต้อง",
+	},
+	NotFoundCode: {
+		"en": "Not found",
+		"th": "ไม่พบข้อมูล",
+	},
+	ConstraintViolation: {
+		"en": "Request violates a data constraint",
+		"th": "ข้อมูลขัดกับเงื่อนไขของระบบ",
+	},
+	InternalError: {
+		"en": "Internal server error",
+		"th": "เกิดข้อผิดพลาดภายในระบบ",
+	},
+
+	Required: {
+		"en": "{field} is required",
+		"th": "กรุณาระบุ {field}",
+	},
+	InvalidValue: {
+		"en": "{field} is invalid",
+		"th": "{field} ไม่ถูกต้อง",
+	},
+	InvalidNumber: {
+		"en": "{field} must be a whole number",
+		"th": "{field} ต้องเป็นจำนวนเต็ม",
+	},
+	InvalidBool: {
+		"en": "{field} must be true or false",
+		"th": "{field} ต้องเป็น true หรือ false",
+	},
+	InvalidDate: {
+		"en": "{field} must be a date in YYYY-MM-DD format",
+		"th": "{field} ต้องเป็นวันที่ในรูปแบบ YYYY-MM-DD",
+	},
+	OutOfRange: {
+		"en": "{field} must be between {min} and {max}",
+		"th": "{field} ต้องอยู่ระหว่าง {min} ถึง {max}",
+	},
+	NotAllowed: {
+		"en": "{field} must be one of {allowed}",
+		"th": "{field} ต้องเป็นค่าใดค่าหนึ่งต่อไปนี้: {allowed}",
+	},
+	TooSmall: {
+		"en": "{field} must be at least {min}",
+		"th": "{field} ต้องไม่น้อยกว่า {min}",
+	},
+	BeforeStart: {
+		"en": "{field} must not be before {start}",
+		"th": "{field} ต้องไม่อยู่ก่อน {start}",
+	},
+	InvalidCursor: {
+		"en": "{field} is invalid or was issued for a different sort",
+		"th": "{field} ไม่ถูกต้องหรือใช้กับการเรียงลำดับอื่น",
+	},
+	PairRequired: {
+		"en": "{field} and {other} must be given together",
+		"th": "ต้องระบุ {field} และ {other} พร้อมกัน",
+	},
+	PasswordTooShort: {
+		"en": "{field} must be at least {min} characters",
+		"th": "{field} ต้องมีอย่างน้อย {min} ตัวอักษร",
+	},
+	PasswordTooLong: {
+		"en": "{field} must be at most {max} bytes",
+		"th": "{field} ต้องยาวไม่เกิน {max} ไบต์",
+	},
+	PasswordBreached: {
+		"en": "{field} appears in lists of leaked passwords; choose another",
+		"th": "{field} นี้อยู่ในรายการรหัสผ่านที่รั่วไหล กรุณาเลือกรหัสผ่านอื่น",
+	},
+	WrongPassword: {
+		"en": "{field} is incorrect",
+		"th": "{field} ไม่ถูกต้อง",
+	},
+	WrongCode: {
+		"en": "{field} is incorrect or was already used",
+		"th": "{field} ไม่ถูกต้องหรือถูกใช้ไปแล้ว",
+	},
+
+	AuthRequired: {
+		"en": "Authorization header missing",
+		"th": "กรุณาเข้าสู่ระบบ",
+	},
+	InvalidToken: {
+		"en": "Invalid or expired token",
+		"th": "โทเค็นไม่ถูกต้องหรือหมดอายุ",
+	},
+	InvalidCredentials: {
+		"en": "Invalid credentials",
+		"th": "อีเมลหรือรหัสผ่านไม่ถูกต้อง",
+	},
+	InvalidRefreshToken: {
+		"en": "Refresh token is invalid, expired or revoked; please log in again",
+		"th": "refresh token ไม่ถูกต้อง หมดอายุ หรือถูกยกเลิกแล้ว กรุณาเข้าสู่ระบบใหม่",
+	},
+	PermissionDenied: {
+		"en": "You do not have permission to do this",
+		"th": "คุณไม่มีสิทธิ์ทำรายการนี้",
+	},
+	EmailNotVerified: {
+		"en": "Verify your email address before booking; check your inbox for the link",
+		"th": "กรุณายืนยันอีเมลก่อนทำการจอง โดยกดลิงก์ในอีเมลที่ได้รับ",
+	},
+	InvalidVerifyToken: {
+		"en": "Verification link is invalid, expired or already used",
+		"th": "ลิงก์ยืนยันอีเมลไม่ถูกต้อง หมดอายุ หรือถูกใช้ไปแล้ว",
+	},
+	LoginThrottled: {
+		"en": "Too many failed logins; try again in {seconds} seconds",
+		"th": "เข้าสู่ระบบไม่สำเร็จหลายครั้งเกินไป กรุณาลองใหม่ในอีก {seconds} วินาที",
+	},
+	InvalidResetToken: {
+		"en": "Password reset link is invalid, expired or already used",
+		"th": "ลิงก์ตั้งรหัสผ่านใหม่ไม่ถูกต้อง หมดอายุ หรือถูกใช้ไปแล้ว",
+	},
+	InvalidInvitation: {
+		"en": "Invitation is invalid, expired, revoked or already accepted",
+		"th": "คำเชิญไม่ถูกต้อง หมดอายุ ถูกยกเลิก หรือถูกตอบรับไปแล้ว",
+	},
+	InvalidOIDCState: {
+		"en": "Login link is invalid, expired or already used; start the login again",
+		"th": "ลิงก์เข้าสู่ระบบไม่ถูกต้อง หมดอายุ หรือถูกใช้ไปแล้ว กรุณาเริ่มเข้าสู่ระบบใหม่",
+	},
+	OIDCLoginFailed: {
+		"en": "The login provider did not confirm your login",
+		"th": "ผู้ให้บริการเข้าสู่ระบบไม่ยืนยันการเข้าสู่ระบบของคุณ",
+	},
+	InvalidTwoFactor: {
+		"en": "Two-factor login expired or already finished; log in again",
+		"th": "การยืนยันตัวตนสองขั้นตอนหมดอายุหรือเสร็จสิ้นไปแล้ว กรุณาเข้าสู่ระบบใหม่",
+	},
+	TwoFactorRequired: {
+		"en": "Two-factor authentication is required for your role",
+		"th": "บทบาทของคุณต้องใช้การยืนยันตัวตนสองขั้นตอน",
+	},
+	ReauthRequired: {
+		"en": "Log in again to confirm it is you",
+		"th": "กรุณาเข้าสู่ระบบอีกครั้งเพื่อยืนยันว่าเป็นคุณ",
+	},
+	TwoFactorNotEnabled: {
+		"en": "Two-factor authentication is not set up",
+		"th": "ยังไม่ได้ตั้งค่าการยืนยันตัวตนสองขั้นตอน",
+	},
+	TwoFactorEnabled: {
+		"en": "Two-factor authentication is already enabled",
+		"th": "เปิดใช้การยืนยันตัวตนสองขั้นตอนอยู่แล้ว",
+	},
+	InvalidAPIKey: {
+		"en": "API key is invalid or revoked",
+		"th": "API key ไม่ถูกต้องหรือถูกยกเลิกแล้ว",
+	},
+	OIDCEmailRequired: {
+		"en": "The login provider did not share a verified email address",
+		"th": "ผู้ให้บริการเข้าสู่ระบบไม่ได้ส่งอีเมลที่ยืนยันแล้ว",
+	},
+
+	RestaurantNotFound: {
+		"en": "Restaurant not found",
+		"th": "ไม่พบร้านอาหาร",
+	},
+	RestaurantNotLinked: {
+		"en": "Restaurant not found for this user",
+		"th": "ไม่พบร้านอาหารของผู้ใช้นี้",
+	},
+	TimeSlotNotFound: {
+		"en": "Time slot not found",
+		"th": "ไม่พบช่วงเวลา",
+	},
+	TimeSlotInUse: {
+		"en": "Time slot not found or still used by sessions",
+		"th": "ไม่พบช่วงเวลา หรือช่วงเวลานี้ยังถูกใช้งานอยู่",
+	},
+	TimeSlotExists: {
+		"en": "Time slot name already exists",
+		"th": "มีชื่อช่วงเวลานี้อยู่แล้ว",
+	},
+	SessionNotFound: {
+		"en": "Session not found",
+		"th": "ไม่พบรอบการจอง",
+	},
+	SessionInUse: {
+		"en": "Session not found or still has bookings",
+		"th": "ไม่พบรอบการจอง หรือรอบนี้ยังมีการจองอยู่",
+	},
+	SessionExists: {
+		"en": "A session already exists for this restaurant, date and time slot",
+		"th": "มีรอบการจองของร้าน วันที่ และช่วงเวลานี้อยู่แล้ว",
+	},
+	MaxGuestsBelowBooked: {
+		"en": "Max guests cannot be lower than the guests already booked",
+		"th": "จำนวนที่นั่งสูงสุดต้องไม่น้อยกว่าจำนวนที่ถูกจองไปแล้ว",
+	},
+	NotEnoughSlots: {
+		"en": "Not enough slots",
+		"th": "ที่นั่งไม่เพียงพอ",
+	},
+	BookingNotFound: {
+		"en": "Booking not found",
+		"th": "ไม่พบการจอง",
+	},
+	BookingAlreadyCancelled: {
+		"en": "Booking already cancelled",
+		"th": "การจองนี้ถูกยกเลิกไปแล้ว",
+	},
+	UserNotFound: {
+		"en": "User not found",
+		"th": "ไม่พบผู้ใช้",
+	},
+	StaffNotFound: {
+		"en": "User is not on the staff of this restaurant",
+		"th": "ผู้ใช้นี้ไม่ได้เป็นพนักงานของร้านอาหารนี้",
+	},
+	InvitationNotFound: {
+		"en": "Invitation not found",
+		"th": "ไม่พบคำเชิญ",
+	},
+	APIKeyNotFound: {
+		"en": "API key not found",
+		"th": "ไม่พบ API key",
+	},
+	OIDCProviderNotFound: {
+		"en": "Login provider not found",
+		"th": "ไม่พบผู้ให้บริการเข้าสู่ระบบ",
+	},
+	UserAlreadyLinked: {
+		"en": "User is already linked to this restaurant",
+		"th": "ผู้ใช้นี้ผูกกับร้านอาหารนี้อยู่แล้ว",
+	},
+	LastAdmin: {
+		"en": "The only admin cannot be demoted or deleted",
+		"th": "ไม่สามารถลดสิทธิ์หรือลบผู้ดูแลระบบคนสุดท้ายได้",
+	},
+	EmailTaken: {
+		"en": "Email already registered",
+		"th": "อีเมลนี้ถูกใช้สมัครแล้ว",
+	},
+	NameTaken: {
+		"en": "Name already registered",
+		"th": "ชื่อนี้ถูกใช้สมัครแล้ว",
+	},
+	InvalidEmail: {
+		"en": "Invalid email format",
+		"th": "รูปแบบอีเมลไม่ถูกต้อง",
+	},
+	PhoneDigitsOnly: {
+		"en": "Phone number must contain digits only",
+		"th": "เบอร์โทรต้องเป็นตัวเลขเท่านั้น",
+	},
+	PhoneFormat: {
+		"en": "Phone number must have 10 digits and start with 0",
+		"th": "เบอร์โทรต้องมี 10 หลักและขึ้นต้นด้วย 0",
+	},
+	DateRangeTooLong: {
+		"en": "Search at most {days} days at a time",
+		"th": "ค้นหาได้ครั้งละไม่เกิน {days} วัน",
+	},
+	OutboxMessageNotFound: {
+		"en": "Message not found",
+		"th": "ไม่พบข้อความ",
+	},
+	OutboxAlreadySent: {
+		"en": "Message already sent",
+		"th": "ข้อความนี้ถูกส่งไปแล้ว",
+	},
+	OutboxNotDue: {
+		"en": "Message is waiting for its next attempt and may be being sent; try again later",
+		"th": "ข้อความนี้รอส่งรอบถัดไปและอาจกำลังถูกส่งอยู่ กรุณาลองใหม่ภายหลัง",
+	},
+}
@@ -0,0 +1,304 @@
+// Package apitest boots the full gin engine from routes.RegisterRoutes against
+// a throwaway SQLite database, for end to end tests.
+package apitest
+
+import (
+	"booking-backend/auth"
+	"booking-backend/migrations"
+	"booking-backend/models"
+	"booking-backend/notifications"
+	"booking-backend/outbox"
+	"booking-backend/routes"
+	"booking-backend/utils"
+	"booking-backend/websocket"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	gorillaws "github.com/gorilla/websocket"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const AdminPassword = "admin-password"
+
+// TestSecret signs access tokens in tests.
+const TestSecret = "apitest-jwt-secret-0123456789abcdef"
+
+var startHub sync.Once
+
+type Fixtures struct {
+	Restaurant models.Restaurant
+	Dinner     models.TimeSlot
+	Lunch      models.TimeSlot
+	Admin      models.User
+	Session    models.Session
+}
+
+type Env struct {
+	T          testing.TB
+	DB         *gorm.DB
+	Router     *gin.Engine
+	Server     *httptest.Server
+	Mail       *notifications.MemoryNotifier
+	Dispatcher *outbox.Dispatcher
+	Fixtures   Fixtures
+
+	queries atomic.Int64
+}
+
+// UseTestKeys installs an HS256 key set signing with TestSecret, as a server
+// started with only JWT_SECRET would.
+func UseTestKeys(t testing.TB) {
+	t.Helper()
+	keys, err := auth.LoadKeySet(func(name string) string {
+		if name == "JWT_SECRET" {
+			return TestSecret
+		}
+		return ""
+	})
+	if err != nil {
+		t.Fatalf("load JWT keys: %v", err)
+	}
+	auth.Use(keys)
+}
+
+// Postgres connects to the database in TEST_DATABASE_URL and gives the test a
+// schema of its own, dropped when it ends, for tests of the SQL migrations
+// and their constraints. The test is skipped when the variable is unset.
+func Postgres(t testing.TB) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+	DB, err := utils.OpenDB("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	DB.Logger = logger.Default.LogMode(logger.Silent)
+	sqlDB, err := DB.DB()
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	// One connection keeps the search path for every statement.
+	sqlDB.SetMaxOpenConns(1)
+	schema := fmt.Sprintf("test_%d", time.Now().UnixNano())
+	if err := DB.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+	t.Cleanup(func() {
+		DB.Exec("DROP SCHEMA " + schema + " CASCADE")
+		sqlDB.Close()
+	})
+	if err := DB.Exec("SET search_path TO " + schema).Error; err != nil {
+		t.Fatalf("use schema: %v", err)
+	}
+	return DB
+}
+
+// New creates a fresh database in the test's temp dir, seeds Fixtures and
+// starts an HTTP server. Everything is torn down when the test ends.
+func New(t testing.TB) *Env {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	startHub.Do(func() { go websocket.GlobalHub.Run() })
+	UseTestKeys(t)
+
+	DB, err := utils.OpenDB("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	DB.Logger = logger.Default.LogMode(logger.Silent)
+	if err := migrations.AutoMigrate(DB); err != nil {
+		t.Fatalf("create schema: %v", err)
+	}
+
+	// The fixtures log in with a password alone; two-factor tests set the
+	// roles that require a second factor themselves.
+	if _, ok := os.LookupEnv("TWO_FACTOR_ROLES"); !ok {
+		t.Setenv("TWO_FACTOR_ROLES", "")
+	}
+	router := gin.New()
+	routes.RegisterRoutes(router, DB)
+
+	mail := notifications.NewMemoryNotifier()
+	env := &Env{
+		T:          t,
+		DB:         DB,
+		Router:     router,
+		Server:     httptest.NewServer(router),
+		Mail:       mail,
+		Dispatcher: outbox.NewDispatcher(DB, mail, websocket.GlobalHub),
+	}
+	t.Cleanup(func() {
+		env.Server.CloseClientConnections()
+		env.Server.Close()
+		if sqlDB, err := DB.DB(); err == nil {
+			sqlDB.Close()
+		}
+	})
+	env.countQueries()
+	env.seed()
+	return env
+}
+
+// countQueries counts every statement gorm runs, for CountQueries.
+func (e *Env) countQueries() {
+	count := func(*gorm.DB) { e.queries.Add(1) }
+	callbacks := e.DB.Callback()
+	e.Must(callbacks.Query().After("gorm:query").Register("apitest:count", count))
+	e.Must(callbacks.Row().After("gorm:row").Register("apitest:count", count))
+	e.Must(callbacks.Raw().After("gorm:raw").Register("apitest:count", count))
+	e.Must(callbacks.Create().After("gorm:create").Register("apitest:count", count))
+	e.Must(callbacks.Update().After("gorm:update").Register("apitest:count", count))
+	e.Must(callbacks.Delete().After("gorm:delete").Register("apitest:count", count))
+}
+
+// CountQueries returns how many database statements fn ran.
+func (e *Env) CountQueries(fn func()) int {
+	before := e.queries.Load()
+	fn()
+	return int(e.queries.Load() - before)
+}
+
+func (e *Env) seed() {
+	e.T.Helper()
+	f := &e.Fixtures
+	f.Restaurant = models.Restaurant{Name: "Baan Suan", Location: "Bangkok", IsActive: true}
+	f.Dinner = models.TimeSlot{SlotName: "Dinner"}
+	f.Lunch = models.TimeSlot{SlotName: "Lunch"}
+	hash, _ := bcrypt.GenerateFromPassword([]byte(AdminPassword), bcrypt.MinCost)
+	verified := time.Now()
+	f.Admin = models.User{Name: "Admin", Email: "admin@example.com", Password: string(hash), Role: "super_admin", EmailVerifiedAt: &verified}
+
+	for _, record := range []interface{}{&f.Restaurant, &f.Dinner, &f.Lunch, &f.Admin} {
+		e.Must(e.DB.Create(record).Error)
+	}
+	e.Must(e.DB.Create(&models.UserRestaurant{UserID: f.Admin.ID, RestaurantID: f.Restaurant.ID, Role: "restaurant_manager"}).Error)
+
+	f.Session = models.Session{
+		RestaurantID:   f.Restaurant.ID,
+		TimeSlotID:     f.Dinner.ID,
+		Name:           "Friday dinner",
+		Date:           "2030-01-04",
+		MaxGuests:      10,
+		AvailableSlots: 10,
+		IsAvailable:    true,
+	}
+	e.Must(e.DB.Create(&f.Session).Error)
+}
+
+func (e *Env) Must(err error) {
+	e.T.Helper()
+	if err != nil {
+		e.T.Fatal(err)
+	}
+}
+
+// Do sends a request through the router. body is encoded as JSON unless it
+// is nil; token, when set, is sent as a bearer token.
+func (e *Env) Do(method, path string, body interface{}, token string) *httptest.ResponseRecorder {
+	e.T.Helper()
+	return e.DoWithCookies(method, path, body, token)
+}
+
+// DoWithCookies is Do sending cookies too, such as those set by an earlier
+// response.
+func (e *Env) DoWithCookies(method, path string, body interface{}, token string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	e.T.Helper()
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		e.Must(err)
+		reader = bytes.NewReader(payload)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	e.Router.ServeHTTP(rec, req)
+	return rec
+}
+
+// Expect fails the test unless rec has the wanted status, then decodes the
+// body into out when out is not nil.
+func (e *Env) Expect(rec *httptest.ResponseRecorder, status int, out interface{}) {
+	e.T.Helper()
+	if rec.Code != status {
+		e.T.Fatalf("expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			e.T.Fatalf("decode %s: %v", rec.Body.String(), err)
+		}
+	}
+}
+
+func (e *Env) Login(email, password string) string {
+	e.T.Helper()
+	var body struct {
+		Token string `json:"token"`
+	}
+	e.Expect(e.Do(http.MethodPost, "/api/login", map[string]string{"email": email, "password": password}, ""), http.StatusOK, &body)
+	return body.Token
+}
+
+func (e *Env) AdminToken() string {
+	return e.Login(e.Fixtures.Admin.Email, AdminPassword)
+}
+
+// Token issues an access token for user without going through login, for
+// accounts made with VerifiedUser.
+func (e *Env) Token(user models.User) string {
+	e.T.Helper()
+	token, err := auth.IssueAccessToken(user.ID, user.Email, user.TokenVersion, time.Now(), time.Now())
+	e.Must(err)
+	return token
+}
+
+// VerifiedUser creates a customer account whose email is verified, so it can
+// make bookings with a Token.
+func (e *Env) VerifiedUser(name, email string) models.User {
+	e.T.Helper()
+	verified := time.Now()
+	user := models.User{Name: name, Email: email, Password: "-", Role: "customer", EmailVerifiedAt: &verified}
+	e.Must(e.DB.Create(&user).Error)
+	return user
+}
+
+// Dispatch delivers every due outbox message now instead of waiting for the
+// background dispatcher.
+func (e *Env) Dispatch() int {
+	return e.Dispatcher.DispatchOnce(context.Background())
+}
+
+func (e *Env) DialWebSocket(query string) *gorillaws.Conn {
+	e.T.Helper()
+	url := "ws" + strings.TrimPrefix(e.Server.URL, "http") + "/ws"
+	if query != "" {
+		url += "?" + query
+	}
+	conn, _, err := gorillaws.DefaultDialer.Dial(url, nil)
+	e.Must(err)
+	e.T.Cleanup(func() { conn.Close() })
+	return conn
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync/atomic"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	// MinSecretLength is the shortest HS256 secret accepted, 256 bits as
+	// RFC 7518 requires.
+	MinSecretLength = 32
+	MinRSABits      = 2048
+
+	DefaultIssuer   = "booking-backend"
+	DefaultAudience = "booking-api"
+)
+
+var ErrNotConfigured = errors.New("JWT keys are not configured")
+
+// Key is one signing key. Keys without a private part only verify tokens
+// signed before a rotation.
+type Key struct {
+	ID        string
+	Method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+	public    crypto.PublicKey
+}
+
+func HMACKey(id string, secret []byte) (*Key, error) {
+	if len(secret) < MinSecretLength {
+		return nil, fmt.Errorf("JWT secret %q must be at least %d bytes", id, MinSecretLength)
+	}
+	distinct := map[byte]bool{}
+	for _, b := range secret {
+		distinct[b] = true
+	}
+	if len(distinct) < 8 {
+		return nil, fmt.Errorf("JWT secret %q is too predictable", id)
+	}
+	if id == "" {
+		sum := sha256.Sum256(secret)
+		id = "hs-" + hex.EncodeToString(sum[:4])
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret}, nil
+}
+
+// PrivateKey wraps an Ed25519 or RSA private key, signing with EdDSA or RS256.
+// An empty id is replaced by the key's thumbprint.
+func PrivateKey(id string, private crypto.Signer) (*Key, error) {
+	key, err := PublicKey(id, private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.signKey = private
+	return key, nil
+}
+
+// PublicKey is a verify-only Ed25519 or RSA key.
+func PublicKey(id string, public crypto.PublicKey) (*Key, error) {
+	key := &Key{ID: id, verifyKey: public, public: public}
+	switch pub := public.(type) {
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < MinRSABits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", MinRSABits)
+		}
+		key.Method = jwt.SigningMethodRS256
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", public)
+	}
+	if key.ID == "" {
+		der, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		key.ID = base64.RawURLEncoding.EncodeToString(sum[:12])
+	}
+	return key, nil
+}
+
+// KeySet signs with one key and verifies with any of its keys, so secrets can
+// be rotated without logging everyone out: add the new key as the signer and
+// keep the old one until the tokens it signed have expired.
+type KeySet struct {
+	Issuer   string
+	Audience string
+	signer   *Key
+	keys     map[string]*Key
+	ordered  []*Key
+}
+
+func NewKeySet(issuer, audience string, signer *Key, previous ...*Key) (*KeySet, error) {
+	if signer == nil || signer.signKey == nil {
+		return nil, errors.New("the signing key needs a private part")
+	}
+	ks := &KeySet{Issuer: issuer, Audience: audience, signer: signer, keys: map[string]*Key{}}
+	for _, key := range append([]*Key{signer}, previous...) {
+		if _, ok := ks.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.ID)
+		}
+		ks.keys[key.ID] = key
+		ks.ordered = append(ks.ordered, key)
+	}
+	return ks, nil
+}
+
+func (ks *KeySet) methods() []string {
+	seen := map[string]bool{}
+	var algs []string
+	for _, key := range ks.ordered {
+		if alg := key.Method.Alg(); !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// keyFunc picks the verification key named by the token's kid and refuses
+// tokens whose alg does not match that key, so an HMAC token can never be
+// checked against a public key.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, ok := ks.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if token.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("key %q does not sign %s", kid, token.Method.Alg())
+	}
+	return key.verifyKey, nil
+}
+
+var current atomic.Pointer[KeySet]
+
+// Use makes ks the key set for issuing and verifying tokens.
+func Use(ks *KeySet) { current.Store(ks) }
+
+func keys() (*KeySet, error) {
+	ks := current.Load()
+	if ks == nil {
+		return nil, ErrNotConfigured
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads the key configuration from the environment:
+//
+//	JWT_ALGORITHM        HS256 (default), EdDSA or RS256
+//	JWT_SECRET           HS256 signing secret, at least 32 bytes
+//	JWT_KEY_ID           key id for JWT_SECRET (default: derived from it)
+//	JWT_PRIVATE_KEY_FILE PEM private key for EdDSA or RS256
+//	JWT_PREVIOUS_KEYS    comma separated kid=secret HS256 keys still accepted
+//	JWT_PREVIOUS_PUBLIC_KEY_FILES comma separated PEM public keys still accepted
+//	JWT_ISSUER, JWT_AUDIENCE
+func LoadKeySet(getenv func(string) string) (*KeySet, error) {
+	var signer *Key
+	var err error
+	switch alg := getenv("JWT_ALGORITHM"); alg {
+	case "", "HS256":
+		if getenv("JWT_SECRET") == "" {
+			return nil, errors.New("JWT_SECRET is not set")
+		}
+		signer, err = HMACKey(getenv("JWT_KEY_ID"), []byte(getenv("JWT_SECRET")))
+	case "EdDSA", "RS256":
+		signer, err = loadPrivateKey(getenv("JWT_PRIVATE_KEY_FILE"), getenv("JWT_KEY_ID"))
+		if err == nil && signer.Method.Alg() != alg {
+			err = fmt.Errorf("JWT_PRIVATE_KEY_FILE holds a %s key, not %s", signer.Method.Alg(), alg)
+		}
+	default:
+		err = fmt.Errorf("unsupported JWT_ALGORITHM %q", alg)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []*Key
+	for _, entry := range splitList(getenv("JWT_PREVIOUS_KEYS")) {
+		id, secret, ok := strings.Cut(entry, "=")
+		if !ok || id == "" {
+			return nil, errors.New("JWT_PREVIOUS_KEYS entries must look like kid=secret")
+		}
+		key, err := HMACKey(id, []byte(secret))
+		if err != nil {
+			return nil, err
+		}
+		previous = append(previous, key)
+	}
+	for _, path := range splitList(getenv("JWT_PREVIOUS_PUBLIC_KEY_FILES")) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		public, err := ParsePublicKeyPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		key, err := PublicKey("", public)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		previous = append(previous, key)
+	}
+
+	issuer, audience := getenv("JWT_ISSUER"), getenv("JWT_AUDIENCE")
+	if issuer == "" {
+		issuer = DefaultIssuer
+	}
+	if audience == "" {
+		audience = DefaultAudience
+	}
+	return NewKeySet(issuer, audience, signer, previous...)
+}
+
+func loadPrivateKey(path, id string) (*Key, error) {
+	if path == "" {
+		return nil, errors.New("JWT_PRIVATE_KEY_FILE is not set")
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	private, err := ParsePrivateKeyPEM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return PrivateKey(id, private)
+}
+
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return signer, nil
+}
+
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// JWK is a public key in JSON Web Key form (RFC 7517).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS lists the public keys of the current key set. HMAC secrets are never
+// published, so an HS256-only deployment serves an empty set.
+func JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	ks := current.Load()
+	if ks == nil {
+		return set
+	}
+	for _, key := range ks.ordered {
+		jwk := JWK{KeyID: key.ID, Algorithm: key.Method.Alg(), Use: "sig"}
+		switch pub := key.public.(type) {
+		case ed25519.PublicKey:
+			jwk.KeyType, jwk.Curve = "OKP", "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
@@ -0,0 +1,79 @@
+// Package auth issues and verifies the JWT access tokens sent as bearer
+// tokens. Refresh tokens are opaque and live in the database; see
+// services.AuthService.
+package auth
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// AccessTokenTTL is kept short because access tokens cannot be revoked one by
+// one; logging out revokes the refresh token instead.
+const AccessTokenTTL = 15 * time.Minute
+
+// clockSkew tolerates small clock differences between servers.
+const clockSkew = 30 * time.Second
+
+var ErrInvalidToken = errors.New("invalid token")
+
+type Claims struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	// Version is the user's token version when the token was issued. Bumping
+	// the version revokes every token issued before.
+	Version int `json:"ver"`
+	// AuthTime is when the user last signed in with their credentials.
+	// Tokens issued by a refresh keep the time of the login they continue.
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	jwt.RegisteredClaims
+}
+
+func IssueAccessToken(userID uint, email string, version int, now, authTime time.Time) (string, error) {
+	ks, err := keys()
+	if err != nil {
+		return "", err
+	}
+	claims := Claims{
+		UserID:   userID,
+		Email:    email,
+		Version:  version,
+		AuthTime: jwt.NewNumericDate(authTime),
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    ks.Issuer,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			Audience:  jwt.ClaimStrings{ks.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+		},
+	}
+	token := jwt.NewWithClaims(ks.signer.Method, claims)
+	token.Header["kid"] = ks.signer.ID
+	return token.SignedString(ks.signer.signKey)
+}
+
+// ParseAccessToken verifies raw against the key named by its kid, pinning the
+// algorithm to that key's, and checks issuer, audience and validity period.
+func ParseAccessToken(raw string) (*Claims, error) {
+	ks, err := keys()
+	if err != nil {
+		return nil, err
+	}
+	var claims Claims
+	token, err := jwt.ParseWithClaims(raw, &claims, ks.keyFunc,
+		jwt.WithValidMethods(ks.methods()),
+		jwt.WithIssuer(ks.Issuer),
+		jwt.WithAudience(ks.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil || !token.Valid || claims.UserID == 0 || claims.NotBefore == nil {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// currentUser loads the caller from their access token, responding 401 when
+// there is none or it is invalid.
+func currentUser(c *gin.Context, DB *gorm.DB) (*models.User, bool) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return nil, false
+	}
+	return user, true
+}
+
+// requireOwnEmail is currentUser for routes that address a guest's bookings
+// by email, responding 403 unless the email is the caller's own.
+func requireOwnEmail(c *gin.Context, DB *gorm.DB) (*models.User, bool) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return nil, false
+	}
+	if !strings.EqualFold(c.Param("email"), user.Email) {
+		apierror.Respond(c, apierror.Forbidden(apierror.PermissionDenied))
+		return nil, false
+	}
+	return user, true
+}
+
+// authorize responds 403 unless user holds perm at restaurantID, or globally
+// when restaurantID is 0. Every permission check goes through here so the
+// services.Permission matrix is the single source of truth.
+func authorize(c *gin.Context, svc *services.Services, user *models.User, perm services.Permission, restaurantID uint) bool {
+	if err := svc.Access.Check(user, perm, restaurantID); err != nil {
+		respondError(c, err)
+		return false
+	}
+	return true
+}
+
+// requirePermission is currentUser followed by a check of a global
+// permission.
+func requirePermission(c *gin.Context, DB *gorm.DB, svc *services.Services, perm services.Permission) (*models.User, bool) {
+	user, ok := currentUser(c, DB)
+	if !ok || !authorize(c, svc, user, perm, 0) {
+		return nil, false
+	}
+	return user, true
+}
+
+// requestAPIKey authenticates the API key a request was made with. It returns
+// nil when the bearer token is not an API key, such as a user's access token.
+func requestAPIKey(c *gin.Context, svc *services.Services) (*models.APIKey, bool) {
+	raw := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !strings.HasPrefix(raw, services.APIKeyPrefix) {
+		return nil, true
+	}
+	key, err := svc.APIKeys.Authenticate(raw)
+	if err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	return key, true
+}
+
+// currentPrincipal is currentUser for endpoints partner systems may call
+// with a restaurant API key instead of a user's access token.
+func currentPrincipal(c *gin.Context, DB *gorm.DB, svc *services.Services) (services.Principal, bool) {
+	key, ok := requestAPIKey(c, svc)
+	if !ok {
+		return services.Principal{}, false
+	}
+	if key != nil {
+		return services.Principal{Key: key}, true
+	}
+	user, ok := currentUser(c, DB)
+	return services.Principal{User: user}, ok
+}
+
+// authorizePrincipal is authorize for a user or an API key.
+func authorizePrincipal(c *gin.Context, svc *services.Services, p services.Principal, perm services.Permission, restaurantID uint) bool {
+	if err := svc.Access.CheckPrincipal(p, perm, restaurantID); err != nil {
+		respondError(c, err)
+		return false
+	}
+	return true
+}
+
+// keyRestaurant checks key holds scope and returns its restaurant, which
+// replaces the restaurant filter of listings made with the key. Asking for
+// another restaurant answers 403.
+func keyRestaurant(c *gin.Context, svc *services.Services, key *models.APIKey, scope string, requested *uint) (*uint, bool) {
+	var restaurantID uint
+	if requested != nil {
+		restaurantID = *requested
+	}
+	if err := svc.Access.CheckScope(key, scope, restaurantID); err != nil {
+		respondError(c, err)
+		return nil, false
+	}
+	return &key.RestaurantID, true
+}
+
+func GetRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"roles": services.Roles, "permissions": services.PermissionMatrix()})
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TokenInput struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type EmailInput struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordInput struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+func VerifyEmail(c *gin.Context, svc *services.Services) {
+	var input TokenInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user, err := svc.Accounts.VerifyEmail(input.Token)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Email verified", "user": services.NewUserView(user)})
+}
+
+// ResendVerification answers the same whether or not the email has an
+// account, so it cannot be used to find out who is registered.
+func ResendVerification(c *gin.Context, svc *services.Services) {
+	var input EmailInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := svc.Accounts.ResendVerification(input.Email, apierror.Locale(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists and is not verified yet, a new link is on its way"})
+}
+
+func ForgotPassword(c *gin.Context, svc *services.Services) {
+	var input EmailInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := svc.Accounts.RequestPasswordReset(input.Email, apierror.Locale(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "If the account exists, a reset link is on its way"})
+}
+
+func ResetPassword(c *gin.Context, svc *services.Services) {
+	var input ResetPasswordInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := svc.Accounts.ResetPassword(input.Token, input.Password, apierror.Locale(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password changed; please log in again"})
+}
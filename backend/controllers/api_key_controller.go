@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"booking-backend/models"
+	"booking-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// authorizeAPIKeys returns the restaurant id from the path and the caller
+// once they are known to manage that restaurant's API keys. Keys cannot
+// manage keys.
+func authorizeAPIKeys(c *gin.Context, DB *gorm.DB, svc *services.Services) (uint, *models.User, bool) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return 0, nil, false
+	}
+	restaurantID, ok := parseID(c, "id")
+	if !ok || !authorize(c, svc, user, services.ManageAPIKeys, restaurantID) {
+		return 0, nil, false
+	}
+	return restaurantID, user, true
+}
+
+func GetRestaurantAPIKeys(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, _, ok := authorizeAPIKeys(c, DB, svc)
+	if !ok {
+		return
+	}
+	keys, err := svc.APIKeys.List(restaurantID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// CreateRestaurantAPIKey answers with the key itself, which is not stored
+// and cannot be shown again.
+func CreateRestaurantAPIKey(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeAPIKeys(c, DB, svc)
+	if !ok {
+		return
+	}
+	var input services.CreateAPIKeyInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	key, err := svc.APIKeys.Create(actor, restaurantID, input, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, key)
+}
+
+func RotateRestaurantAPIKey(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeAPIKeys(c, DB, svc)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "key_id")
+	if !ok {
+		return
+	}
+	key, err := svc.APIKeys.Rotate(actor, restaurantID, id, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, key)
+}
+
+func RevokeRestaurantAPIKey(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeAPIKeys(c, DB, svc)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "key_id")
+	if !ok {
+		return
+	}
+	if err := svc.APIKeys.Revoke(actor, restaurantID, id, c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+}
@@ -0,0 +1,26 @@
+package controllers
+
+import (
+	"booking-backend/models"
+	"booking-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetAuditEvents(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	if _, ok := requirePermission(c, DB, svc, services.ManageSystem); !ok {
+		return
+	}
+	var events []models.AuditEvent
+	query := DB.Order("id desc").Limit(100)
+	if eventType := c.Query("type"); eventType != "" {
+		query = query.Where("type = ?", eventType)
+	}
+	if err := query.Find(&events).Error; err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, events)
+}
@@ -1,155 +1,189 @@
 package controllers
 
 import (
+	"booking-backend/apierror"
+	"booking-backend/auth"
 	"booking-backend/models"
-	"errors"
-	"fmt"
+	"booking-backend/services"
 	"net/http"
-	"os"
-	"regexp"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
-	"golang.org/x/crypto/bcrypt"
-	"gopkg.in/gomail.v2"
 	"gorm.io/gorm"
 )
 
-var jwtSecret = []byte(os.Getenv("JWT_SECRET"))
-
-func isValidEmail(email string) bool {
-	re := regexp.MustCompile(`^[\w\.-]+@[\w\.-]+\.[a-zA-Z]{2,}$`)
-	return re.MatchString(email)
-}
-
+// ExtractUserFromToken loads the user a bearer access token was issued to.
+// Tokens issued before the user's sessions were revoked are rejected.
 func ExtractUserFromToken(c *gin.Context, DB *gorm.DB) (*models.User, error) {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
-		return nil, errors.New("Authorization header missing")
-	}
-	tokenString := authHeader
-	if len(tokenString) > 7 && tokenString[:7] == "Bearer " {
-		tokenString = tokenString[7:]
-	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret, nil
-	})
-	if err != nil || !token.Valid {
-		return nil, errors.New("Invalid token")
-	}
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		return nil, errors.New("Invalid token claims")
+		return nil, apierror.Unauthorized(apierror.AuthRequired)
 	}
-	userIDFloat, ok := claims["user_id"].(float64)
-	if !ok {
-		return nil, errors.New("Invalid user_id in token")
+	claims, err := auth.ParseAccessToken(strings.TrimPrefix(authHeader, "Bearer "))
+	if err != nil {
+		return nil, apierror.Unauthorized(apierror.InvalidToken)
 	}
-	userID := uint(userIDFloat)
 	var user models.User
-	if err := DB.First(&user, userID).Error; err != nil {
-		return nil, errors.New("User not found")
+	if err := DB.First(&user, claims.UserID).Error; err != nil || user.TokenVersion != claims.Version {
+		return nil, apierror.Unauthorized(apierror.InvalidToken)
+	}
+	if claims.AuthTime != nil {
+		c.Set(authTimeKey, claims.AuthTime.Time)
 	}
 	return &user, nil
 }
 
+// authTimeKey holds, in the gin context, when the session of the token read
+// by ExtractUserFromToken logged in.
+const authTimeKey = "auth_time"
+
+// authTime is when the caller's session logged in. Tokens issued before
+// sessions recorded it give the zero time, which is never recent.
+func authTime(c *gin.Context) time.Time {
+	at, _ := c.Get(authTimeKey)
+	t, _ := at.(time.Time)
+	return t
+}
+
+// AuthenticateStream lets anonymous guests subscribe to live events, but a
+// supplied token must be valid. EventSource cannot set headers, so the token
+// may also arrive as the "token" query parameter.
+func AuthenticateStream(c *gin.Context, DB *gorm.DB) error {
+	if c.GetHeader("Authorization") == "" {
+		token := c.Query("token")
+		if token == "" {
+			return nil
+		}
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+	}
+	_, err := ExtractUserFromToken(c, DB)
+	return err
+}
+
 func GetUser(c *gin.Context, DB *gorm.DB) {
 	user, err := ExtractUserFromToken(c, DB)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		apierror.Respond(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	c.JSON(http.StatusOK, gin.H{"user": services.NewUserView(user)})
 }
 
-func sendSignupEmail(to string) {
-	m := gomail.NewMessage()
-	m.SetHeader("From", "jkpwork123@gmail.com")
-	m.SetHeader("To", to)
-	m.SetHeader("Subject", "signup complete")
-	m.SetBody("text/plain", "Sign up complete!")
-	d := gomail.NewDialer("smtp.gmail.com", 587, "jkpwork123@gmail.com", os.Getenv("BOOKING_GMAIL_PASSWORD"))
-	err := d.DialAndSend(m)
+func Signup(c *gin.Context, svc *services.Services) {
+	var input services.SignupInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user, err := svc.Users.Signup(input, apierror.Locale(c))
 	if err != nil {
-		fmt.Println("Send email error:", err)
-	} else {
-		fmt.Println("Signup email sent to", to)
+		respondError(c, err)
+		return
 	}
+	c.JSON(http.StatusCreated, gin.H{"message": "Signup successful", "user": services.NewUserView(user)})
+}
+
+type LoginInput struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
 }
 
-func Signup(c *gin.Context, DB *gorm.DB) {
-	type SignupInput struct {
-		Name     string `json:"name" binding:"required"`
-		Email    string `json:"email" binding:"required"`
-		Phone     string `json:"phone" binding:"required"`
-		Password string `json:"password" binding:"required"`
+func Login(c *gin.Context, svc *services.Services) {
+	var input LoginInput
+	if !bindJSON(c, &input) {
+		return
 	}
-	var input SignupInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	user, err := svc.Auth.Authenticate(input.Email, input.Password, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-	if !isValidEmail(input.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email format"})
+	respondLogin(c, svc, user)
+}
+
+// respondLogin answers a passed first login step: with tokens, or with a
+// challenge when the account needs a second factor.
+func respondLogin(c *gin.Context, svc *services.Services, user *models.User) {
+	challenge, err := svc.TwoFactor.Challenge(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	if challenge != nil {
+		c.JSON(http.StatusOK, challenge)
 		return
 	}
-	var existingEmail models.User
-	if err := DB.Where("email = ?", input.Email).First(&existingEmail).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Email already registered"})
+	tokens, err := svc.Auth.Login(user)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-	var existingName models.User
-	if err := DB.Where("name = ?", input.Name).First(&existingName).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Name already registered"})
+	c.JSON(http.StatusOK, LoginResponse{Tokens: *tokens, User: services.NewUserView(user)})
+}
+
+type LoginResponse struct {
+	services.Tokens
+	User services.UserView `json:"user"`
+}
+
+type RefreshInput struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken rotates a refresh token: the one sent stops working and a new
+// pair is returned.
+func RefreshToken(c *gin.Context, svc *services.Services) {
+	var input RefreshInput
+	if !bindJSON(c, &input) {
 		return
 	}
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	_, tokens, err := svc.Auth.Refresh(input.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hash password"})
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+func Logout(c *gin.Context, svc *services.Services) {
+	var input RefreshInput
+	if !bindJSON(c, &input) {
 		return
 	}
-	user := models.User{
-		Name:     input.Name,
-		Email:    input.Email,
-		Phone:     input.Phone,
-		Password: string(hashedPassword),
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	if err := svc.Auth.Logout(input.RefreshToken); err != nil {
+		respondError(c, err)
+		return
 	}
-	DB.Create(&user)
-	go sendSignupEmail(user.Email)
-	c.JSON(http.StatusCreated, gin.H{"message": "Signup successful", "user": user})
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
 }
 
-func Login(c *gin.Context, DB *gorm.DB) {
-	type LoginInput struct {
-		Email    string `json:"email" binding:"required"`
-		Password string `json:"password" binding:"required"`
+// RevokeMySessions signs the current user out on every device.
+func RevokeMySessions(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
 	}
-	var input LoginInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if err := svc.Auth.RevokeAll(user.ID); err != nil {
+		respondError(c, err)
 		return
 	}
-	var user models.User
-	if err := DB.Where("email = ?", input.Email).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
+}
+
+// RevokeUserSessions lets an admin sign a user out everywhere, e.g. after
+// changing their role.
+func RevokeUserSessions(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	if _, ok := requirePermission(c, DB, svc, services.ManageUsers); !ok {
 		return
 	}
-	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+	id, ok := parseID(c, "id")
+	if !ok {
 		return
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": user.ID,
-		"email":    user.Email,
-		"exp":      time.Now().Add(time.Hour * 72).Unix(),
-	})
-	tokenString, err := token.SignedString(jwtSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not generate token"})
+	if err := svc.Auth.RevokeAll(id); err != nil {
+		respondError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, gin.H{"token": tokenString, "user": user})
+	c.JSON(http.StatusOK, gin.H{"message": "All sessions revoked"})
 }
@@ -1,213 +1,227 @@
 package controllers
 
 import (
+	"booking-backend/apierror"
 	"booking-backend/models"
+	"booking-backend/services"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func GetBookings(c *gin.Context, DB *gorm.DB) {
-	var bookings []models.Booking
-	DB.Order("created_at desc").Find(&bookings)
-
-	type BookingWithUserAndRestaurant struct {
-		models.Booking
-		RestaurantName   string      `json:"restaurant_name"`
-	}
-	var result []BookingWithUserAndRestaurant
-	for _, b := range bookings {
-		var session models.Session
-		DB.First(&session, b.SessionID)
-		var restaurant models.Restaurant
-		DB.First(&restaurant, session.RestaurantID)
-		result = append(result, BookingWithUserAndRestaurant{
-			Booking:          b,
-			RestaurantName:   restaurant.Name,
-		})
+func parseID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		apierror.Respond(c, apierror.Validation(apierror.Field(name, apierror.InvalidNumber)))
+		return 0, false
 	}
-	c.JSON(http.StatusOK, result)
+	return uint(id), true
 }
 
-func GetBookingByEmail(c *gin.Context, DB *gorm.DB) {
-    email := c.Param("email")
-	var bookings []models.Booking
-	if err := DB.Where("user_email = ?", email).Order("created_at desc").Find(&bookings).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "No bookings found for this email"})
-        return
-    }
-    c.JSON(http.StatusOK, bookings)
+// bindJSON decodes the request body into input and responds 400 with the
+// offending fields if it does not fit.
+func bindJSON(c *gin.Context, input interface{}) bool {
+	if err := c.ShouldBindJSON(input); err != nil {
+		apierror.Respond(c, apierror.FromBinding(err))
+		return false
+	}
+	return true
 }
 
-func CreateBooking(c *gin.Context, DB *gorm.DB) {
-	var input struct {
-		SessionID      uint   `json:"session_id"`
-		Name           string `json:"name"`
-		Email          string `json:"email"`
-		Phone          *string `json:"phone"`
-		NumberOfGuests int    `json:"number_of_guests"`
-		Notes          string `json:"notes"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-		return
-	}
-	if input.Phone != nil && *input.Phone != "" {
-		phone := *input.Phone
-		for _, ch := range phone {
-			if ch < '0' || ch > '9' {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "เบอร์โทรต้องเป็นตัวเลขเท่านั้น"})
-				return
-			}
-		}
-		if len(phone) != 10 || phone[0] != '0' {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "เบอร์โทรต้องมี 10 หลักและขึ้นต้นด้วย 0"})
-			return
-		}
+// GetBookings lists bookings at the restaurants where the caller may view
+// them, which is every restaurant for super admins and the key's restaurant
+// for API keys.
+func GetBookings(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	principal, ok := currentPrincipal(c, DB, svc)
+	if !ok {
+		return
 	}
-
-	var session models.Session
-	if err := DB.First(&session, input.SessionID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+	restaurantIDs, err := svc.Access.PrincipalRestaurantsWith(principal, services.ViewBookings)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-	if session.AvailableSlots < input.NumberOfGuests {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough slots"})
+	if restaurantIDs != nil && len(restaurantIDs) == 0 {
+		apierror.Respond(c, apierror.Forbidden(apierror.PermissionDenied))
 		return
 	}
 
-	booking := models.Booking{
-		SessionID:      input.SessionID,
-		UserName:       input.Name,
-		UserEmail:      input.Email,
-		UserPhone:      "",
-		BookingDate:    time.Now().Format("2006-01-02"),
-		NumberOfGuests: input.NumberOfGuests,
-		Status:         "confirmed",
-		Notes:          input.Notes,
-		CreatedAt:      time.Now(),
-		UpdatedAt:      time.Now(),
-	}
-	if input.Phone != nil {
-		booking.UserPhone = *input.Phone
-	}
-	result := DB.Create(&booking)
-	if result.Error != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+	q := newListQuery(c)
+	filter := services.BookingFilter{
+		RestaurantID:  q.uint("restaurant_id"),
+		SessionID:     q.uint("session_id"),
+		TimeSlotID:    q.uint("time_slot_id"),
+		Status:        q.oneOf("status", "confirmed", "cancelled"),
+		Email:         c.Query("email"),
+		RestaurantIDs: restaurantIDs,
+	}
+	filter.DateFrom, filter.DateTo = q.dateRange()
+	page := q.page(services.BookingSorts, "-created_at")
+	if !q.ok() {
 		return
 	}
 
-	session.AvailableSlots -= input.NumberOfGuests
-	if session.AvailableSlots == 0 {
-		session.IsAvailable = false
+	result, err := svc.Bookings.List(filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
-	DB.Save(&session)
-
-	c.JSON(http.StatusCreated, gin.H{
-		"message": input.Name + " booked successfully",
-		"booking": booking,
-	})
+	c.JSON(http.StatusOK, result)
 }
 
-func UpdateBooking(c *gin.Context, DB *gorm.DB) {
-	var booking models.Booking
-	if err := DB.First(&booking, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+// GetBookingByEmail lists the bookings made with the caller's account, whose
+// email the path must name.
+func GetBookingByEmail(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, ok := requireOwnEmail(c, DB)
+	if !ok {
 		return
 	}
-
-	oldStatus := booking.Status
-	var input struct {
-		UserName       *string `json:"user_name"`
-		UserEmail      *string `json:"user_email"`
-		UserPhone      *string `json:"user_phone"`
-		NumberOfGuests *int    `json:"number_of_guests"`
-		Status         *string `json:"status"`
-		Notes          *string `json:"notes"`
-	}
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	bookings, err := svc.Bookings.ListForUser(user)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, bookings)
+}
 
-	if input.UserName != nil {
-		booking.UserName = *input.UserName
+// CreateBooking books for the signed in user. Partner systems may book with
+// an API key instead, for guests without an account, at the key's
+// restaurant only.
+func CreateBooking(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	principal, ok := currentPrincipal(c, DB, svc)
+	if !ok {
+		return
 	}
-	if input.UserEmail != nil {
-		booking.UserEmail = *input.UserEmail
+	var input services.CreateBookingInput
+	if !bindJSON(c, &input) {
+		return
 	}
-	if input.UserPhone != nil {
-		booking.UserPhone = *input.UserPhone
+	var booking *models.Booking
+	var err error
+	if principal.Key != nil {
+		var restaurantID uint
+		restaurantID, err = svc.Access.SessionRestaurant(input.SessionID)
+		if err == nil {
+			err = svc.Access.CheckScope(principal.Key, services.ScopeCreateBookings, restaurantID)
+		}
+		if err == nil {
+			booking, err = svc.Bookings.CreateForPartner(input, apierror.Locale(c))
+		}
+	} else {
+		booking, err = svc.Bookings.Create(principal.User, input, apierror.Locale(c))
 	}
-	if input.NumberOfGuests != nil {
-		booking.NumberOfGuests = *input.NumberOfGuests
+	if err != nil {
+		respondError(c, err)
+		return
 	}
-	if input.Status != nil {
-		booking.Status = *input.Status
+	c.JSON(http.StatusCreated, gin.H{
+		"message": input.Name + " booked successfully",
+		"booking": booking,
+	})
+}
+
+func UpdateBooking(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	id, ok := authorizeBooking(c, DB, svc, services.ManageBookings)
+	if !ok {
+		return
 	}
-	if input.Notes != nil {
-		booking.Notes = *input.Notes
+	var input services.UpdateBookingInput
+	if !bindJSON(c, &input) {
+		return
 	}
-
-	if oldStatus != "cancelled" && booking.Status == "cancelled" {
-		var session models.Session
-		if err := DB.First(&session, booking.SessionID).Error; err == nil {
-			session.AvailableSlots += booking.NumberOfGuests
-			session.IsAvailable = true
-			DB.Save(&session)
-		}
+	booking, err := svc.Bookings.Update(id, input)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
-
-	DB.Save(&booking)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Booking updated",
 		"booking": booking,
 	})
 }
 
-func DeleteBooking(c *gin.Context, DB *gorm.DB) {
-	var booking models.Booking
-	if err := DB.First(&booking, c.Param("id")).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+// CheckInBooking marks the guests of a booking as arrived.
+func CheckInBooking(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	id, ok := authorizeBooking(c, DB, svc, services.CheckInBookings)
+	if !ok {
+		return
+	}
+	booking, err := svc.Bookings.CheckIn(id, time.Now())
+	if err != nil {
+		respondError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, gin.H{"message": "Guests checked in", "booking": booking})
+}
 
-	var session models.Session
-	if err := DB.First(&session, booking.SessionID).Error; err == nil {
-		session.AvailableSlots += booking.NumberOfGuests
-		session.IsAvailable = true
-		DB.Save(&session)
+// authorizeBooking returns the id path parameter once the caller is known to
+// hold perm at the restaurant of that booking.
+func authorizeBooking(c *gin.Context, DB *gorm.DB, svc *services.Services, perm services.Permission) (uint, bool) {
+	principal, ok := currentPrincipal(c, DB, svc)
+	if !ok {
+		return 0, false
 	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return 0, false
+	}
+	restaurantID, err := svc.Access.BookingRestaurant(id)
+	if err != nil {
+		respondError(c, err)
+		return 0, false
+	}
+	return id, authorizePrincipal(c, svc, principal, perm, restaurantID)
+}
 
-	DB.Delete(&booking)
+func DeleteBooking(c *gin.Context, svc *services.Services) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	if err := svc.Bookings.Delete(id); err != nil {
+		respondError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Booking deleted"})
 }
 
+// CancelBooking cancels a booking made with the caller's account, whose
+// email the path must name.
+func CancelBooking(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, ok := requireOwnEmail(c, DB)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	booking, err := svc.Bookings.Cancel(id, user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sessions cancelled, seats available again", "booking": booking})
+}
 
-func CancelBooking(c *gin.Context, DB *gorm.DB, hub interface { BroadcastSessionCancelled(string, string) }) {
-    email := c.Param("email")
-    bookingId := c.Param("id")
-    var booking models.Booking
-    if err := DB.Where("id = ? AND user_email = ?", bookingId, email).First(&booking).Error; err != nil {
-        c.JSON(http.StatusNotFound, gin.H{"error": "Booking not found for this email"})
-        return
-    }
-    if booking.Status == "cancelled" {
-        c.JSON(http.StatusBadRequest, gin.H{"error": "Booking already cancelled"})
-        return
-    }
-    booking.Status = "cancelled"
-    DB.Save(&booking)
-
-    var session models.Session
-    if err := DB.First(&session, booking.SessionID).Error; err == nil {
-        session.AvailableSlots += booking.NumberOfGuests
-        session.IsAvailable = true
-        DB.Save(&session)
-        
-        hub.BroadcastSessionCancelled(session.Name, booking.UserName)
-    }
+// CancelBookingByID is CancelBooking without the email in the path, so it
+// does not end up in access logs.
+func CancelBookingByID(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	booking, err := svc.Bookings.Cancel(id, user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"message": "Sessions cancelled, seats available again", "booking": booking})
 }
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"booking-backend/apierror"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+	"gorm.io/gorm"
+)
+
+// constraintErrors gives each constraint from the migrations an error that is
+// safe to show to API clients.
+var constraintErrors = map[string]func() *apierror.Error{
+	"idx_users_email":           func() *apierror.Error { return apierror.Invalid(apierror.EmailTaken) },
+	"tables_restaurant_id_fkey": func() *apierror.Error { return apierror.Invalid(apierror.RestaurantNotFound) },
+	"tables_capacity_check": func() *apierror.Error {
+		return apierror.Validation(apierror.Field("capacity", apierror.TooSmall, "min", "1"))
+	},
+	"tables_status_check": func() *apierror.Error {
+		return apierror.Validation(apierror.Field("status", apierror.NotAllowed, "allowed", "active, inactive"))
+	},
+	"sessions_restaurant_id_fkey": func() *apierror.Error { return apierror.Invalid(apierror.RestaurantNotFound) },
+	"sessions_time_slot_id_fkey":  func() *apierror.Error { return apierror.Invalid(apierror.TimeSlotInUse) },
+	"sessions_max_guests_check": func() *apierror.Error {
+		return apierror.Validation(apierror.Field("max_guests", apierror.TooSmall, "min", "1"))
+	},
+	"sessions_available_slots_check":         func() *apierror.Error { return apierror.Invalid(apierror.NotEnoughSlots) },
+	"sessions_restaurant_date_time_slot_key": func() *apierror.Error { return apierror.Invalid(apierror.SessionExists) },
+	"bookings_session_id_fkey":               func() *apierror.Error { return apierror.Invalid(apierror.SessionInUse) },
+	"bookings_user_id_fkey":                  func() *apierror.Error { return apierror.Invalid(apierror.UserNotFound) },
+	"bookings_number_of_guests_check": func() *apierror.Error {
+		return apierror.Validation(apierror.Field("number_of_guests", apierror.TooSmall, "min", "1"))
+	},
+	"bookings_status_check": func() *apierror.Error {
+		return apierror.Validation(apierror.Field("status", apierror.NotAllowed, "allowed", "confirmed, cancelled"))
+	},
+	"time_slots_slot_name_key":             func() *apierror.Error { return apierror.Invalid(apierror.TimeSlotExists) },
+	"users_restaurant_user_restaurant_key": func() *apierror.Error { return apierror.Invalid(apierror.UserAlreadyLinked) },
+	"users_restaurant_restaurant_id_fkey":  func() *apierror.Error { return apierror.Invalid(apierror.RestaurantNotFound) },
+	"users_restaurant_user_id_fkey":        func() *apierror.Error { return apierror.Invalid(apierror.UserNotFound) },
+	"refresh_tokens_user_id_fkey":          func() *apierror.Error { return apierror.Invalid(apierror.UserNotFound) },
+	"user_tokens_user_id_fkey":             func() *apierror.Error { return apierror.Invalid(apierror.UserNotFound) },
+	"staff_invitations_restaurant_id_fkey": func() *apierror.Error { return apierror.Invalid(apierror.RestaurantNotFound) },
+	"api_keys_restaurant_id_fkey":          func() *apierror.Error { return apierror.Invalid(apierror.RestaurantNotFound) },
+	"restaurants_coordinates_check": func() *apierror.Error {
+		return apierror.Validation(apierror.Field("latitude", apierror.PairRequired, "other", "longitude"))
+	},
+}
+
+// sqliteUniqueConstraints names the Postgres constraint behind the columns
+// SQLite reports for a unique violation, since SQLite does not name them.
+var sqliteUniqueConstraints = map[string]string{
+	"users.email":          "idx_users_email",
+	"time_slots.slot_name": "time_slots_slot_name_key",
+	"sessions.restaurant_id, sessions.date, sessions.time_slot_id": "sessions_restaurant_date_time_slot_key",
+	"users_restaurant.user_id, users_restaurant.restaurant_id":     "users_restaurant_user_restaurant_key",
+}
+
+// violation is a constraint violation reported by either database, with its
+// SQLSTATE code.
+type violation struct {
+	Code       string
+	Constraint string
+	Column     string
+}
+
+// asViolation returns the constraint violation err reports, if any.
+func asViolation(err error) (violation, bool) {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return violation{Code: pgErr.Code, Constraint: pgErr.ConstraintName, Column: pgErr.ColumnName}, true
+	}
+	var sqliteErr sqlite3.Error
+	if !errors.As(err, &sqliteErr) || sqliteErr.Code != sqlite3.ErrConstraint {
+		return violation{}, false
+	}
+	// SQLite explains the violation in the message, such as "UNIQUE
+	// constraint failed: users.email" or "CHECK constraint failed: name".
+	_, detail, _ := strings.Cut(sqliteErr.Error(), "constraint failed: ")
+	switch sqliteErr.ExtendedCode {
+	case sqlite3.ErrConstraintUnique, sqlite3.ErrConstraintPrimaryKey:
+		return violation{Code: "23505", Constraint: sqliteUniqueConstraints[detail]}, true
+	case sqlite3.ErrConstraintForeignKey:
+		return violation{Code: "23503"}, true
+	case sqlite3.ErrConstraintCheck:
+		return violation{Code: "23514", Constraint: detail}, true
+	case sqlite3.ErrConstraintNotNull:
+		_, column, _ := strings.Cut(detail, ".")
+		return violation{Code: "23502", Column: column}, true
+	}
+	return violation{}, false
+}
+
+// respondError writes err as an API error. Service errors keep their code,
+// constraint violations are mapped to 400/409 and anything else becomes a
+// generic 500.
+func respondError(c *gin.Context, err error) {
+	apierror.Respond(c, toAPIError(c, err))
+}
+
+func toAPIError(c *gin.Context, err error) error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) || errors.Is(err, apierror.ErrNotFound) {
+		return apierror.NotFound(apierror.NotFoundCode)
+	}
+
+	v, ok := asViolation(err)
+	if !ok {
+		return err
+	}
+	var kind error
+	switch v.Code {
+	case "23505": // unique_violation
+		kind = apierror.ErrConflict
+	case "23503": // foreign_key_violation
+		kind = apierror.ErrInvalid
+		if c.Request.Method == http.MethodDelete {
+			kind = apierror.ErrConflict
+		}
+	case "23514", "23502": // check_violation, not_null_violation
+		kind = apierror.ErrInvalid
+	default:
+		return err
+	}
+
+	apiErr = apierror.Invalid(apierror.ConstraintViolation)
+	if build, ok := constraintErrors[v.Constraint]; ok {
+		apiErr = build()
+	} else if v.Code == "23502" && v.Column != "" {
+		apiErr = apierror.Validation(apierror.Field(v.Column, apierror.Required))
+	}
+	apiErr.Kind = kind
+	return apiErr
+}
@@ -0,0 +1,60 @@
+package controllers_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/controllers"
+	"booking-backend/migrations"
+	"booking-backend/models"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func apiError(t *testing.T, err error) *apierror.Error {
+	t.Helper()
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/", nil)
+	var apiErr *apierror.Error
+	if !errors.As(controllers.ToAPIError(c, err), &apiErr) {
+		t.Fatalf("expected an API error for %v", err)
+	}
+	return apiErr
+}
+
+func TestSQLiteConstraintViolations(t *testing.T) {
+	env := apitest.New(t)
+
+	taken := env.DB.Create(&models.User{Name: "Someone", Email: env.Fixtures.Admin.Email, Password: "-", Role: "customer"}).Error
+	if apiErr := apiError(t, taken); apiErr.Code != apierror.EmailTaken || apiErr.Kind != apierror.ErrConflict {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+	missing := env.DB.Exec("INSERT INTO api_keys (restaurant_id, prefix, key_hash, scopes) VALUES (1, 'bk_', 'hash', '')").Error
+	apiErr := apiError(t, missing)
+	if apiErr.Kind != apierror.ErrInvalid || len(apiErr.Fields) != 1 || apiErr.Fields[0].Field != "name" {
+		t.Fatalf("unexpected error %+v", apiErr)
+	}
+}
+
+// Every constraint given an API error has to exist in the migrated schema,
+// or its violations fall back to the generic error.
+func TestConstraintErrorsNameMigratedConstraints(t *testing.T) {
+	DB := apitest.Postgres(t)
+	if _, err := migrations.Up(DB); err != nil {
+		t.Fatal(err)
+	}
+	for name := range controllers.ConstraintErrors {
+		var count int64
+		err := DB.Raw(`SELECT (SELECT COUNT(*) FROM pg_constraint WHERE conname = ? AND connamespace = current_schema()::regnamespace)
+			+ (SELECT COUNT(*) FROM pg_indexes WHERE indexname = ? AND schemaname = current_schema())`, name, name).Scan(&count).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+		if count == 0 {
+			t.Errorf("no migration creates %s", name)
+		}
+	}
+}
@@ -0,0 +1,22 @@
+package controllers
+
+import (
+	"booking-backend/auth"
+	"booking-backend/openapi"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func GetOpenAPI(c *gin.Context, spec *openapi.Document) {
+	c.JSON(http.StatusOK, spec)
+}
+
+func GetDocs(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", openapi.DocsPage)
+}
+
+func GetJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, auth.JWKS())
+}
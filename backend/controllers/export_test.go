@@ -0,0 +1,7 @@
+package controllers
+
+// Exported for the tests in controllers_test.
+var (
+	ConstraintErrors = constraintErrors
+	ToAPIError       = toAPIError
+)
@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/pagination"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listQuery reads filter and page parameters for a list endpoint. Invalid
+// parameters are collected and reported together by ok, so handlers can read
+// every parameter and check once.
+type listQuery struct {
+	c      *gin.Context
+	fields []apierror.FieldError
+}
+
+func newListQuery(c *gin.Context) *listQuery {
+	return &listQuery{c: c}
+}
+
+func (q *listQuery) fail(field string, code apierror.Code, params ...string) {
+	q.fields = append(q.fields, apierror.Field(field, code, params...))
+}
+
+func (q *listQuery) uint(name string) *uint {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return nil
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		q.fail(name, apierror.InvalidNumber)
+		return nil
+	}
+	value := uint(id)
+	return &value
+}
+
+func (q *listQuery) bool(name string) *bool {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return nil
+	}
+	value, err := strconv.ParseBool(raw)
+	if err != nil {
+		q.fail(name, apierror.InvalidBool)
+		return nil
+	}
+	return &value
+}
+
+func (q *listQuery) int(name string, fallback int) int {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return fallback
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		q.fail(name, apierror.InvalidNumber)
+		return fallback
+	}
+	return value
+}
+
+func (q *listQuery) float(name string) *float64 {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		q.fail(name, apierror.InvalidValue)
+		return nil
+	}
+	return &value
+}
+
+func (q *listQuery) date(name string) string {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return ""
+	}
+	if _, err := time.Parse("2006-01-02", raw); err != nil {
+		q.fail(name, apierror.InvalidDate)
+		return ""
+	}
+	return raw
+}
+
+// dateRange reads date_from and date_to.
+func (q *listQuery) dateRange() (string, string) {
+	from, to := q.date("date_from"), q.date("date_to")
+	if from != "" && to != "" && from > to {
+		q.fail("date_to", apierror.BeforeStart, "start", "date_from")
+	}
+	return from, to
+}
+
+func (q *listQuery) oneOf(name string, allowed ...string) string {
+	raw := q.c.Query(name)
+	if raw == "" {
+		return ""
+	}
+	for _, value := range allowed {
+		if raw == value {
+			return raw
+		}
+	}
+	q.fail(name, apierror.NotAllowed, "allowed", strings.Join(allowed, ", "))
+	return ""
+}
+
+func (q *listQuery) page(fields pagination.Fields, defaultSort string) pagination.Params {
+	params, err := pagination.Parse(q.c.Request.URL.Query(), fields, defaultSort)
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		q.fields = append(q.fields, apiErr.Fields...)
+	}
+	return params
+}
+
+// ok responds 400 and returns false if any parameter was invalid.
+func (q *listQuery) ok() bool {
+	if len(q.fields) > 0 {
+		apierror.Respond(q.c, apierror.Validation(q.fields...))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,53 @@
+package controllers
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/outbox"
+	"booking-backend/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func GetOutboxMessages(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	if _, ok := requirePermission(c, DB, svc, services.ManageSystem); !ok {
+		return
+	}
+	status := c.DefaultQuery("status", outbox.StatusDead)
+	messages := []models.OutboxMessage{}
+	query := DB.Order("id desc").Limit(100)
+	if status != "all" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&messages).Error; err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, messages)
+}
+
+func RetryOutboxMessage(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	if _, ok := requirePermission(c, DB, svc, services.ManageSystem); !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	msg, err := outbox.Retry(DB, id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = apierror.NotFound(apierror.OutboxMessageNotFound)
+	} else if errors.Is(err, outbox.ErrAlreadySent) {
+		err = apierror.Conflict(apierror.OutboxAlreadySent)
+	} else if errors.Is(err, outbox.ErrNotDue) {
+		err = apierror.Conflict(apierror.OutboxNotDue)
+	}
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Message queued for retry", "outbox_message": msg})
+}
@@ -1,33 +1,188 @@
 package controllers
 
 import (
+	"booking-backend/apierror"
 	"booking-backend/models"
+	"booking-backend/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func GetRestaurants(c *gin.Context, DB *gorm.DB) {
-	var restaurants []models.Restaurant
-	DB.Find(&restaurants)
+func GetRestaurants(c *gin.Context, svc *services.Services) {
+	q := newListQuery(c)
+	filter := services.RestaurantFilter{
+		Name:     c.Query("name"),
+		IsActive: q.bool("is_active"),
+	}
+	page := q.page(services.RestaurantSorts, "id")
+	if !q.ok() {
+		return
+	}
+
+	restaurants, err := svc.Restaurants.List(filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, restaurants)
 }
 
-func GetRestaurantByUserId(c *gin.Context, DB *gorm.DB) {
-	userId := c.Param("id")
-	
-	var userRestaurant models.UserRestaurant
-	if err := DB.Where("user_id = ?", userId).First(&userRestaurant).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found for this user"})
+func GetRestaurantByUserId(c *gin.Context, svc *services.Services) {
+	userID, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	restaurant, err := svc.Restaurants.ForUser(userID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": restaurant.ID, "name": restaurant.Name})
+}
+
+func CreateRestaurant(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	if _, ok := requirePermission(c, DB, svc, services.ManageRestaurants); !ok {
+		return
+	}
+	var input services.CreateRestaurantInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	restaurant, err := svc.Restaurants.Create(input)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, restaurant)
+}
+
+// authorizeStaff returns the restaurant id from the path and the caller once
+// they are known to manage that restaurant's staff.
+func authorizeStaff(c *gin.Context, DB *gorm.DB, svc *services.Services) (uint, *models.User, bool) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return 0, nil, false
+	}
+	restaurantID, ok := parseID(c, "id")
+	if !ok || !authorize(c, svc, user, services.ManageStaff, restaurantID) {
+		return 0, nil, false
+	}
+	return restaurantID, user, true
+}
+
+func GetRestaurantStaff(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, _, ok := authorizeStaff(c, DB, svc)
+	if !ok {
+		return
+	}
+	staff, err := svc.Staff.List(restaurantID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// AssignRestaurantStaff makes a user a manager or staff member of the
+// restaurant. Managers may assign staff; only super admins assign managers.
+func AssignRestaurantStaff(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeStaff(c, DB, svc)
+	if !ok {
+		return
+	}
+	userID, ok := parseID(c, "user_id")
+	if !ok {
+		return
+	}
+	var input services.AssignStaffInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	member, err := svc.Staff.Assign(actor, restaurantID, userID, input.Role, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, member)
+}
+
+func RemoveRestaurantStaff(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeStaff(c, DB, svc)
+	if !ok {
+		return
+	}
+	userID, ok := parseID(c, "user_id")
+	if !ok {
+		return
+	}
+	if err := svc.Staff.Remove(actor, restaurantID, userID, c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Staff member removed"})
+}
+
+func GetRestaurantInvitations(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, _, ok := authorizeStaff(c, DB, svc)
+	if !ok {
+		return
+	}
+	invitations, err := svc.Staff.Invitations(restaurantID)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, invitations)
+}
+
+// InviteRestaurantStaff mails an invitation to join the restaurant. Like
+// assignments, only super admins invite managers.
+func InviteRestaurantStaff(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeStaff(c, DB, svc)
+	if !ok {
+		return
+	}
+	var input services.InviteStaffInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	invitation, err := svc.Staff.Invite(actor, restaurantID, input, c.ClientIP(), apierror.Locale(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, invitation)
+}
+
+func RevokeRestaurantInvitation(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	restaurantID, actor, ok := authorizeStaff(c, DB, svc)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "invitation_id")
+	if !ok {
+		return
+	}
+	if err := svc.Staff.RevokeInvitation(actor, restaurantID, id, c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// AcceptInvitation is public: the invitation token proves access to the
+// invited mailbox. The new staff member then signs in as usual.
+func AcceptInvitation(c *gin.Context, svc *services.Services) {
+	var input services.AcceptInvitationInput
+	if !bindJSON(c, &input) {
 		return
 	}
-	
-	var restaurant models.Restaurant
-	if err := DB.First(&restaurant, userRestaurant.RestaurantID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+	user, err := svc.Staff.AcceptInvitation(input, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-	
-	c.JSON(http.StatusOK, gin.H{ "id": restaurant.ID,"name": restaurant.Name})
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation accepted", "user": services.NewUserView(user)})
 }
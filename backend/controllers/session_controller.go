@@ -1,153 +1,167 @@
 package controllers
 
 import (
-	"booking-backend/models"
+	"booking-backend/apierror"
+	"booking-backend/services"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-type UpdateSessionInput struct {
-	TimeSlotID   uint   `json:"time_slot_id"`
-	Name         string `json:"name"`
-	Date         string `json:"date"`
-	MaxGuests    int    `json:"max_guests"`
-}
-
-
-type SessionModel struct {
-	ID             uint            `json:"id"`
-	RestaurantID   uint            `json:"restaurant_id"`
-	Date           string          `json:"date"`
-	TimeSlotID     uint            `json:"time_slot_id"`
-	Name 		  	string          `json:"name"`
-	MaxGuests      int             `json:"max_guests"`
-	AvailableSlots int             `json:"available_slots"`
-	IsAvailable    bool            `json:"is_available"`
-	TimeSlot       models.TimeSlot `json:"time_slot"`
-	Bookings       []models.Booking `json:"bookings"`
-	RestaurantData models.Restaurant `json:"restaurant_data"`
-}
+type CreateSessionInput = services.CreateSessionInput
 
-func GetSessions(c *gin.Context, DB *gorm.DB) {
-	var sessions []models.Session
-	restaurantID := c.Query("restaurant_id")
-	query := DB.Preload("TimeSlot").Order("created_at desc")
-	if restaurantID != "" {
-		query = query.Where("restaurant_id = ?", restaurantID)
-	}
-	query.Find(&sessions)
+type UpdateSessionInput = services.UpdateSessionInput
 
-	var result []SessionModel
-	for _, sess := range sessions {
-		var bookings []models.Booking
-		DB.Where("session_id = ?", sess.ID).Find(&bookings)
+type SessionModel = services.SessionDetails
 
-		var restaurant models.Restaurant
-		DB.Where("id = ?", sess.RestaurantID).First(&restaurant)
+// GetSessions is public; with an API key it lists the sessions of the key's
+// restaurant.
+func GetSessions(c *gin.Context, svc *services.Services) {
+	key, ok := requestAPIKey(c, svc)
+	if !ok {
+		return
+	}
+	q := newListQuery(c)
+	filter := services.SessionFilter{
+		RestaurantID:    q.uint("restaurant_id"),
+		TimeSlotID:      q.uint("time_slot_id"),
+		HasAvailability: q.bool("has_availability"),
+	}
+	filter.DateFrom, filter.DateTo = q.dateRange()
+	page := q.page(services.SessionSorts, "-created_at")
+	if !q.ok() {
+		return
+	}
+	if key != nil {
+		if filter.RestaurantID, ok = keyRestaurant(c, svc, key, services.ScopeReadSessions, filter.RestaurantID); !ok {
+			return
+		}
+	}
 
-		result = append(result, SessionModel{
-			ID:             sess.ID,
-			RestaurantID:   sess.RestaurantID,
-			Date:           sess.Date,
-			TimeSlotID:     sess.TimeSlotID,
-			Name:           sess.Name,
-			MaxGuests:      sess.MaxGuests,
-			AvailableSlots: sess.AvailableSlots,
-			IsAvailable:    sess.IsAvailable,
-			TimeSlot:       sess.TimeSlot,
-			Bookings:       bookings,
-			RestaurantData: restaurant,
-		})
+	result, err := svc.Sessions.List(filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
 	}
 	c.JSON(http.StatusOK, result)
 }
 
-type CreateSessionInput struct {
-	RestaurantID uint   `json:"restaurant_id" binding:"required"`
-	TimeSlotID   uint   `json:"time_slot_id" binding:"required"`
-	Name         string `json:"name" binding:"required"`
-	Date         string `json:"date" binding:"required"`
-	MaxGuests    int    `json:"max_guests" binding:"required"`
-}
-
-func CreateSession(c *gin.Context, DB *gorm.DB) {
-	var input CreateSessionInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+// SearchSessions finds sessions with room for a party. date is shorthand for
+// a one-day range; lat and lng must be given together. Like GetSessions, an
+// API key limits the search to its restaurant.
+func SearchSessions(c *gin.Context, svc *services.Services) {
+	key, ok := requestAPIKey(c, svc)
+	if !ok {
 		return
 	}
-
-	var restaurant models.Restaurant
-	if err := DB.First(&restaurant, input.RestaurantID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Restaurant not found"})
+	q := newListQuery(c)
+	query := services.AvailabilityQuery{
+		PartySize:    q.int("party_size", 1),
+		TimeSlotID:   q.uint("time_slot_id"),
+		RestaurantID: q.uint("restaurant_id"),
+		Limit:        q.int("limit", services.DefaultSearchLimit),
+	}
+	if date := q.date("date"); date != "" {
+		query.DateFrom, query.DateTo = date, date
+	} else {
+		query.DateFrom, query.DateTo = q.dateRange()
+	}
+	lat, lng := q.float("lat"), q.float("lng")
+	if radius := q.float("radius_km"); radius != nil {
+		query.RadiusKm = *radius
+	}
+	if (lat == nil) != (lng == nil) {
+		q.fail("lat", apierror.PairRequired, "other", "lng")
+	}
+	if lat != nil && lng != nil {
+		query.Near = &services.GeoPoint{Lat: *lat, Lng: *lng}
+	}
+	if !q.ok() {
 		return
 	}
+	if key != nil {
+		if query.RestaurantID, ok = keyRestaurant(c, svc, key, services.ScopeReadSessions, query.RestaurantID); !ok {
+			return
+		}
+	}
 
-	var timeSlot models.TimeSlot
-	if err := DB.First(&timeSlot, input.TimeSlotID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Time slot not found"})
+	result, err := svc.Sessions.Search(query, time.Now())
+	if err != nil {
+		respondError(c, err)
 		return
 	}
+	c.JSON(http.StatusOK, result)
+}
 
-
-	session := models.Session{
-		RestaurantID:   input.RestaurantID,
-		TimeSlotID:     input.TimeSlotID,
-		Name:           input.Name,
-		Date:           input.Date,
-		MaxGuests:      input.MaxGuests,
-		AvailableSlots: input.MaxGuests,
-		IsAvailable:    true,
+func CreateSession(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return
 	}
-
-	if err := DB.Create(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+	var input CreateSessionInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if !authorize(c, svc, user, services.ManageSessions, input.RestaurantID) {
+		return
+	}
+	session, err := svc.Sessions.Create(input)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-
-	DB.Preload("TimeSlot").Preload("Restaurant").First(&session, session.ID)
-
 	c.JSON(http.StatusCreated, gin.H{
 		"message": "Session created successfully",
 		"session": session,
 	})
 }
 
-func UpdateSession(c *gin.Context, DB *gorm.DB) {
-	id := c.Param("id")
-	var session models.Session
-	if err := DB.First(&session, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+func UpdateSession(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	id, ok := authorizeSession(c, DB, svc)
+	if !ok {
 		return
 	}
-
 	var input UpdateSessionInput
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &input) {
+		return
+	}
+	session, err := svc.Sessions.Update(id, input)
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-
-	session.TimeSlotID = input.TimeSlotID
-	session.Name = input.Name
-	session.Date = input.Date
-	session.MaxGuests = input.MaxGuests
-	DB.Save(&session)
-
 	c.JSON(http.StatusOK, gin.H{"message": "Session updated successfully", "session": session})
 }
 
-func DeleteSession(c *gin.Context, DB *gorm.DB) {
-	id := c.Param("id")
-	var session models.Session
-	if err := DB.First(&session, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+func DeleteSession(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	id, ok := authorizeSession(c, DB, svc)
+	if !ok {
 		return
 	}
-	if err := DB.Delete(&session).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete session"})
+	if err := svc.Sessions.Delete(id); err != nil {
+		respondError(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully"})
 }
+
+// authorizeSession returns the id path parameter once the caller is known to
+// manage sessions at the restaurant that session belongs to.
+func authorizeSession(c *gin.Context, DB *gorm.DB, svc *services.Services) (uint, bool) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return 0, false
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return 0, false
+	}
+	restaurantID, err := svc.Access.SessionRestaurant(id)
+	if err != nil {
+		respondError(c, err)
+		return 0, false
+	}
+	return id, authorize(c, svc, user, services.ManageSessions, restaurantID)
+}
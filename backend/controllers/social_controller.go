@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"booking-backend/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcBindingCookie holds the binding of the social login this browser
+// started, which the callback has to present with the state.
+const oidcBindingCookie = "oidc_binding"
+
+type OIDCAuthorizeInput struct {
+	Provider string `json:"provider" binding:"required"`
+}
+
+type OIDCCallbackInput struct {
+	State string `json:"state" binding:"required"`
+	Code  string `json:"code" binding:"required"`
+}
+
+func GetLoginProviders(c *gin.Context, svc *services.Services) {
+	c.JSON(http.StatusOK, gin.H{"providers": svc.Social.List()})
+}
+
+// StartSocialLogin returns the provider URL the browser should visit. The
+// provider sends it back to the frontend callback page with code and state.
+func StartSocialLogin(c *gin.Context, svc *services.Services) {
+	var input OIDCAuthorizeInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	started, err := svc.Social.Start(c.Request.Context(), input.Provider)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	setBindingCookie(c, started.Binding, int(services.OIDCLoginTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"authorization_url": started.AuthorizationURL})
+}
+
+// FinishSocialLogin redeems the code from the provider and logs the user in
+// like a password login, including its second factor.
+func FinishSocialLogin(c *gin.Context, svc *services.Services) {
+	var input OIDCCallbackInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	binding, _ := c.Cookie(oidcBindingCookie)
+	user, err := svc.Social.Finish(c.Request.Context(), input.State, binding, input.Code, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	setBindingCookie(c, "", -1)
+	respondLogin(c, svc, user)
+}
+
+// setBindingCookie keeps the binding away from scripts. The frontend is
+// served from the same site as the API, so Lax still sends it with the
+// callback request.
+func setBindingCookie(c *gin.Context, binding string, maxAge int) {
+	secure := c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, binding, maxAge, "/api", "", secure, true)
+}
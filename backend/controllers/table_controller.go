@@ -2,16 +2,65 @@ package controllers
 
 import (
 	"booking-backend/models"
+	"booking-backend/pagination"
+	"booking-backend/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+var TableSorts = pagination.Fields{
+	"id":           {Column: "id", IDColumn: "id", Kind: pagination.Int},
+	"table_number": {Column: "table_number", IDColumn: "id", Kind: pagination.String},
+	"capacity":     {Column: "capacity", IDColumn: "id", Kind: pagination.Int},
+	"created_at":   {Column: "created_at", IDColumn: "id", Kind: pagination.Time},
+}
+
+func tableKey(t models.Table, field string) (interface{}, uint) {
+	switch field {
+	case "table_number":
+		return t.TableNumber, t.ID
+	case "capacity":
+		return t.Capacity, t.ID
+	case "created_at":
+		return t.CreatedAt, t.ID
+	}
+	return t.ID, t.ID
+}
+
 func GetTables(c *gin.Context, DB *gorm.DB) {
+	q := newListQuery(c)
+	restaurantID := q.uint("restaurant_id")
+	status := q.oneOf("status", "active", "inactive")
+	minCapacity := q.uint("min_capacity")
+	page := q.page(TableSorts, "id")
+	if !q.ok() {
+		return
+	}
+
+	query := DB.Model(&models.Table{})
+	if restaurantID != nil {
+		query = query.Where("restaurant_id = ?", *restaurantID)
+	}
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if minCapacity != nil {
+		query = query.Where("capacity >= ?", *minCapacity)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		respondError(c, err)
+		return
+	}
 	var tables []models.Table
-	DB.Preload("Restaurant").Find(&tables)
-	c.JSON(http.StatusOK, tables)
+	if err := page.Apply(query).Preload("Restaurant").Find(&tables).Error; err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pagination.NewPage(tables, total, page, tableKey))
 }
 
 func GetRestaurantTables(c *gin.Context, DB *gorm.DB) {
@@ -20,12 +69,21 @@ func GetRestaurantTables(c *gin.Context, DB *gorm.DB) {
 	c.JSON(http.StatusOK, tables)
 }
 
-func CreateTable(c *gin.Context, DB *gorm.DB) {
+func CreateTable(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, ok := currentUser(c, DB)
+	if !ok {
+		return
+	}
 	var table models.Table
-	if err := c.ShouldBindJSON(&table); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	if !bindJSON(c, &table) {
+		return
+	}
+	if !authorize(c, svc, user, services.ManageTables, table.RestaurantID) {
+		return
+	}
+	if err := DB.Create(&table).Error; err != nil {
+		respondError(c, err)
 		return
 	}
-	DB.Create(&table)
 	c.JSON(http.StatusCreated, table)
 }
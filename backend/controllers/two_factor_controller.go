@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TwoFactorTokenInput struct {
+	Token string `json:"two_factor_token" binding:"required"`
+}
+
+type TwoFactorLoginInput struct {
+	Token string `json:"two_factor_token" binding:"required"`
+	// Code is the current code from the authenticator app, or a recovery
+	// code.
+	Code string `json:"code" binding:"required"`
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorLoginResponse carries the recovery codes when the login enrolled
+// the user in two-factor authentication.
+type TwoFactorLoginResponse struct {
+	LoginResponse
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func SetupTwoFactorLogin(c *gin.Context, svc *services.Services) {
+	var input TwoFactorTokenInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	setup, err := svc.TwoFactor.SetupForLogin(input.Token)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+// CompleteTwoFactorLogin finishes a login that answered with a challenge and
+// issues the tokens.
+func CompleteTwoFactorLogin(c *gin.Context, svc *services.Services) {
+	var input TwoFactorLoginInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user, codes, err := svc.TwoFactor.CompleteLogin(input.Token, input.Code, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	tokens, err := svc.Auth.Login(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, TwoFactorLoginResponse{
+		LoginResponse: LoginResponse{Tokens: *tokens, User: services.NewUserView(user)},
+		RecoveryCodes: codes,
+	})
+}
+
+func SetupTwoFactor(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	setup, err := svc.TwoFactor.Setup(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, setup)
+}
+
+func EnableTwoFactor(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	var input TwoFactorCodeInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	codes, err := svc.TwoFactor.Enable(user, input.Code, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func RegenerateRecoveryCodes(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	var input TwoFactorCodeInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	codes, err := svc.TwoFactor.RegenerateRecoveryCodes(user, input.Code, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+}
+
+func DisableTwoFactor(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	var input TwoFactorCodeInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := svc.TwoFactor.Disable(user, input.Code, c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+}
+
+// ResetUserTwoFactor is for users who lost their authenticator and recovery
+// codes; it also signs them out everywhere.
+func ResetUserTwoFactor(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	admin, ok := requirePermission(c, DB, svc, services.ManageUsers)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	user, err := svc.TwoFactor.Reset(admin, id, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, services.NewUserView(user))
+}
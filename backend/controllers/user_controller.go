@@ -1,28 +1,140 @@
 package controllers
 
 import (
-	"booking-backend/models"
+	"booking-backend/apierror"
+	"booking-backend/services"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
-func GetUsers(c *gin.Context, DB *gorm.DB) {
-	var users []models.User
-	DB.Find(&users)
+type RoleInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type DeleteAccountInput struct {
+	// Password is required of accounts that have one.
+	Password string `json:"password"`
+}
+
+func GetUsers(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	if _, ok := requirePermission(c, DB, svc, services.ManageUsers); !ok {
+		return
+	}
+	q := newListQuery(c)
+	filter := services.UserFilter{
+		Email: c.Query("email"),
+		Role:  q.oneOf("role", services.Roles...),
+	}
+	page := q.page(services.UserSorts, "id")
+	if !q.ok() {
+		return
+	}
+
+	users, err := svc.Users.List(filter, page)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
 	c.JSON(http.StatusOK, users)
 }
 
-func CreateUser(c *gin.Context, DB *gorm.DB) {
-	var user models.User
-	if err := c.ShouldBindJSON(&user); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+func CreateUser(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	admin, ok := requirePermission(c, DB, svc, services.ManageUsers)
+	if !ok {
+		return
+	}
+	var input services.CreateUserInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user, err := svc.Users.Create(admin, input, c.ClientIP(), apierror.Locale(c))
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, services.NewUserView(user))
+}
+
+// UpdateUserRole grants or revokes super admin; restaurant roles are given
+// through staff assignments. Every change is written to the audit log.
+func UpdateUserRole(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	admin, ok := requirePermission(c, DB, svc, services.ManageUsers)
+	if !ok {
+		return
+	}
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	var input RoleInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user, err := svc.Users.SetRole(admin, id, input.Role, c.ClientIP())
+	if err != nil {
+		respondError(c, err)
 		return
 	}
-	if user.Role == "" {
-		user.Role = "user"
+	c.JSON(http.StatusOK, services.NewUserView(user))
+}
+
+func UpdateProfile(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	var input services.ProfileInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	user, err = svc.Users.UpdateProfile(user, input)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user": services.NewUserView(user)})
+}
+
+// ChangePassword signs the user out everywhere and answers with new tokens
+// for the device that made the change.
+func ChangePassword(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	var input services.ChangePasswordInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := svc.Users.ChangePassword(user, input, authTime(c), c.ClientIP(), apierror.Locale(c)); err != nil {
+		respondError(c, err)
+		return
+	}
+	tokens, err := svc.Auth.Login(user)
+	if err != nil {
+		respondError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, LoginResponse{Tokens: *tokens, User: services.NewUserView(user)})
+}
+
+func DeleteAccount(c *gin.Context, DB *gorm.DB, svc *services.Services) {
+	user, err := ExtractUserFromToken(c, DB)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	var input DeleteAccountInput
+	if !bindJSON(c, &input) {
+		return
+	}
+	if err := svc.Users.Delete(user, input.Password, authTime(c), c.ClientIP()); err != nil {
+		respondError(c, err)
+		return
 	}
-	DB.Create(&user)
-	c.JSON(http.StatusCreated, user)
+	c.JSON(http.StatusOK, gin.H{"message": "Account deleted"})
 }
@@ -0,0 +1,69 @@
+package jobs
+
+import (
+	"booking-backend/models"
+	"booking-backend/notifications"
+	"booking-backend/outbox"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var bangkok = time.FixedZone("Asia/Bangkok", 7*60*60)
+
+// StartBookingReminders checks every interval for confirmed bookings whose
+// session is tomorrow and emails the guest once.
+func StartBookingReminders(DB *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for now := range ticker.C {
+			if sent := SendBookingReminders(DB, now); sent > 0 {
+				log.Printf("Queued %d booking reminders", sent)
+			}
+		}
+	}()
+}
+
+func SendBookingReminders(DB *gorm.DB, now time.Time) int {
+	tomorrow := now.In(bangkok).AddDate(0, 0, 1).Format("2006-01-02")
+
+	var bookings []models.Booking
+	err := DB.Joins("JOIN sessions ON sessions.id = bookings.session_id").
+		Where("sessions.date = ? AND bookings.status = ? AND bookings.reminder_sent_at IS NULL", tomorrow, "confirmed").
+		Find(&bookings).Error
+	if err != nil {
+		log.Printf("Failed to load bookings for reminders: %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, booking := range bookings {
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			// Claim the reminder so a second instance cannot queue it too.
+			claim := tx.Model(&models.Booking{}).
+				Where("id = ? AND reminder_sent_at IS NULL", booking.ID).
+				Update("reminder_sent_at", now)
+			if claim.Error != nil || claim.RowsAffected == 0 {
+				return claim.Error
+			}
+
+			var session models.Session
+			if err := tx.Preload("TimeSlot").First(&session, booking.SessionID).Error; err != nil {
+				return err
+			}
+			var restaurant models.Restaurant
+			tx.First(&restaurant, session.RestaurantID)
+			if err := outbox.EnqueueEmail(tx, "booking_reminder", booking.Locale, booking.UserEmail, notifications.NewBookingDetails(booking, session, restaurant)); err != nil {
+				return err
+			}
+			sent++
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to queue reminder for booking %d: %v", booking.ID, err)
+		}
+	}
+	return sent
+}
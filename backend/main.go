@@ -1,25 +1,86 @@
 package main
 
 import (
+	"booking-backend/auth"
+	"booking-backend/jobs"
+	"booking-backend/migrations"
+	"booking-backend/notifications"
+	"booking-backend/outbox"
 	"booking-backend/routes"
 	"booking-backend/utils"
 	"booking-backend/websocket"
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 )
 
 func main() {
 	DB := utils.InitDB()
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		runMigrate(DB, os.Args[2:])
+		return
+	}
+	keys, err := auth.LoadKeySet(os.Getenv)
+	if err != nil {
+		log.Fatal("Invalid JWT configuration: ", err)
+	}
+	auth.Use(keys)
+	if DB.Dialector.Name() == "sqlite" {
+		if err := migrations.AutoMigrate(DB); err != nil {
+			log.Fatal("Failed to create SQLite schema: ", err)
+		}
+	} else if pending, err := migrations.Pending(DB); err != nil {
+		log.Printf("Could not check migrations: %v", err)
+	} else if pending > 0 {
+		log.Printf("%d database migrations pending, run `go run main.go migrate up`", pending)
+	}
 	r := gin.Default()
+	// Client IPs drive login throttling, so X-Forwarded-For is only believed
+	// from the proxies listed in TRUSTED_PROXIES.
+	var proxies []string
+	if list := utils.GetEnv("TRUSTED_PROXIES", ""); list != "" {
+		proxies = strings.Split(list, ",")
+	}
+	if err := r.SetTrustedProxies(proxies); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES: ", err)
+	}
 
 	go websocket.GlobalHub.Run()
+	jobs.StartBookingReminders(DB, 15*time.Minute)
+
+	dispatchCtx, stopDispatcher := context.WithCancel(context.Background())
+	dispatcherDone := make(chan struct{})
+	dispatcher := outbox.NewDispatcher(DB, notifications.NewFromEnv(), websocket.GlobalHub)
+	go func() {
+		dispatcher.Run(dispatchCtx)
+		close(dispatcherDone)
+	}()
 
+	// Only the frontend at APP_URL may send cookies, which social login
+	// needs; browsers ignore credentials allowed for any origin.
+	appOrigin := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:3000"), "/")
 	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		c.Writer.Header().Add("Vary", "Origin")
+		if origin := c.GetHeader("Origin"); origin != "" && origin == appOrigin {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", origin)
+			c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+		} else {
+			c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
+		}
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Deprecation, Sunset, Link")
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
 			return
@@ -30,7 +91,75 @@ func main() {
 	routes.RegisterRoutes(r, DB)
 
 	port := utils.GetEnv("PORT", "8080")
-	log.Printf("Server running on port %s", port)
-	r.Run(":" + port)
+	server := &http.Server{Addr: ":" + port, Handler: r}
+	go func() {
+		log.Printf("Server running on port %s", port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal("Server error:", err)
+		}
+	}()
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	log.Println("Shutting down server...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Printf("Server shutdown error: %v", err)
+	}
+	stopDispatcher()
+	<-dispatcherDone
 }
 
+func runMigrate(DB *gorm.DB, args []string) {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+
+	switch command {
+	case "up":
+		ran, err := migrations.Up(DB)
+		for _, m := range ran {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+		if len(ran) == 0 {
+			fmt.Println("database is up to date")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				log.Fatal("usage: migrate down [steps]")
+			}
+			steps = n
+		}
+		reverted, err := migrations.Down(DB, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			log.Fatal("Migration failed: ", err)
+		}
+	case "status":
+		list, err := migrations.GetStatus(DB)
+		if err != nil {
+			log.Fatal("Could not read migration status: ", err)
+		}
+		for _, status := range list {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%-30s %s\n", status.Version, status.Name, applied)
+		}
+	default:
+		log.Fatal("usage: migrate up | down [steps] | status")
+	}
+}
@@ -0,0 +1,53 @@
+package migrations_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/migrations"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+// The test suite runs on SQLite with AutoMigrate, so the SQL files are only
+// checked here, against TEST_DATABASE_URL.
+func TestMigrationsUpAndDown(t *testing.T) {
+	DB := apitest.Postgres(t)
+	all, err := migrations.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	up := func() {
+		t.Helper()
+		ran, err := migrations.Up(DB)
+		if err != nil || len(ran) != len(all) {
+			t.Fatalf("expected %d migrations to run, ran %d: %v", len(all), len(ran), err)
+		}
+		if pending, err := migrations.Pending(DB); err != nil || pending != 0 {
+			t.Fatalf("expected nothing pending, got %d: %v", pending, err)
+		}
+	}
+	up()
+	for _, model := range migrations.Models {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(model); err != nil {
+			t.Fatal(err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DBName != "" && !DB.Migrator().HasColumn(model, field.DBName) {
+				t.Errorf("%s.%s is mapped but no migration creates it", stmt.Schema.Table, field.DBName)
+			}
+		}
+	}
+
+	reverted, err := migrations.Down(DB, len(all))
+	if err != nil || len(reverted) != len(all) {
+		t.Fatalf("expected %d migrations to revert, reverted %d: %v", len(all), len(reverted), err)
+	}
+	for _, model := range migrations.Models {
+		if DB.Migrator().HasTable(model) {
+			t.Errorf("%T is left after reverting every migration", model)
+		}
+	}
+	up()
+}
@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS outbox_messages;
+DROP TABLE IF EXISTS users_restaurant;
+DROP TABLE IF EXISTS bookings;
+DROP TABLE IF EXISTS sessions;
+DROP TABLE IF EXISTS users;
+DROP TABLE IF EXISTS tables;
+DROP TABLE IF EXISTS time_slots;
+DROP TABLE IF EXISTS restaurants;
+DROP TYPE IF EXISTS user_role;
@@ -0,0 +1,107 @@
+-- Reproduces the schema the models package expected before migrations were
+-- tracked. IF NOT EXISTS lets databases created by hand adopt it unchanged.
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'user_role') THEN
+        CREATE TYPE user_role AS ENUM ('admin', 'user');
+    END IF;
+END
+$$;
+
+CREATE TABLE IF NOT EXISTS restaurants (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    location    TEXT NOT NULL,
+    description TEXT,
+    phone       TEXT,
+    email       TEXT,
+    is_active   BOOLEAN DEFAULT TRUE
+);
+
+CREATE TABLE IF NOT EXISTS time_slots (
+    id         BIGSERIAL PRIMARY KEY,
+    slot_name  TEXT NOT NULL,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS tables (
+    id            BIGSERIAL PRIMARY KEY,
+    restaurant_id BIGINT NOT NULL,
+    table_number  TEXT NOT NULL,
+    capacity      INTEGER NOT NULL,
+    status        TEXT DEFAULT 'active',
+    created_at    TIMESTAMPTZ DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS users (
+    id         BIGSERIAL PRIMARY KEY,
+    name       TEXT NOT NULL,
+    email      TEXT NOT NULL,
+    password   TEXT NOT NULL,
+    phone      TEXT,
+    role       user_role NOT NULL DEFAULT 'user',
+    created_at TIMESTAMPTZ DEFAULT NOW(),
+    updated_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email ON users (email);
+
+CREATE TABLE IF NOT EXISTS sessions (
+    id              BIGSERIAL PRIMARY KEY,
+    restaurant_id   BIGINT NOT NULL,
+    date            DATE NOT NULL,
+    time_slot_id    BIGINT NOT NULL,
+    name            TEXT,
+    max_guests      INTEGER NOT NULL,
+    available_slots INTEGER NOT NULL,
+    is_available    BOOLEAN DEFAULT TRUE,
+    created_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS bookings (
+    id               BIGSERIAL PRIMARY KEY,
+    session_id       BIGINT NOT NULL,
+    user_id          BIGINT DEFAULT NULL,
+    user_name        TEXT,
+    user_email       TEXT,
+    user_phone       TEXT,
+    booking_date     DATE NOT NULL,
+    number_of_guests INTEGER NOT NULL,
+    status           TEXT DEFAULT 'confirmed',
+    notes            TEXT,
+    locale           TEXT DEFAULT 'th',
+    reminder_sent_at TIMESTAMPTZ,
+    created_at       TIMESTAMPTZ DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- Columns added after the first hand-made databases.
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS locale TEXT DEFAULT 'th';
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS reminder_sent_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS users_restaurant (
+    id            BIGSERIAL PRIMARY KEY,
+    restaurant_id BIGINT NOT NULL,
+    user_id       BIGINT NOT NULL,
+    created_at    TIMESTAMPTZ DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS outbox_messages (
+    id              BIGSERIAL PRIMARY KEY,
+    kind            TEXT NOT NULL,
+    topic           TEXT NOT NULL,
+    payload         TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'pending',
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error      TEXT,
+    sent_at         TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_status ON outbox_messages (status);
+CREATE INDEX IF NOT EXISTS idx_outbox_messages_next_attempt_at ON outbox_messages (next_attempt_at);
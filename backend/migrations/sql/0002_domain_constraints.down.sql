@@ -0,0 +1,25 @@
+ALTER TABLE users_restaurant DROP CONSTRAINT IF EXISTS users_restaurant_user_restaurant_key;
+ALTER TABLE sessions DROP CONSTRAINT IF EXISTS sessions_restaurant_date_time_slot_key;
+ALTER TABLE time_slots DROP CONSTRAINT IF EXISTS time_slots_slot_name_key;
+
+ALTER TABLE outbox_messages DROP CONSTRAINT IF EXISTS outbox_messages_status_check;
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_status_check,
+    DROP CONSTRAINT IF EXISTS bookings_number_of_guests_check;
+ALTER TABLE sessions
+    DROP CONSTRAINT IF EXISTS sessions_available_slots_check,
+    DROP CONSTRAINT IF EXISTS sessions_max_guests_check;
+ALTER TABLE tables
+    DROP CONSTRAINT IF EXISTS tables_status_check,
+    DROP CONSTRAINT IF EXISTS tables_capacity_check;
+
+ALTER TABLE users_restaurant
+    DROP CONSTRAINT IF EXISTS users_restaurant_user_id_fkey,
+    DROP CONSTRAINT IF EXISTS users_restaurant_restaurant_id_fkey;
+ALTER TABLE bookings
+    DROP CONSTRAINT IF EXISTS bookings_user_id_fkey,
+    DROP CONSTRAINT IF EXISTS bookings_session_id_fkey;
+ALTER TABLE sessions
+    DROP CONSTRAINT IF EXISTS sessions_time_slot_id_fkey,
+    DROP CONSTRAINT IF EXISTS sessions_restaurant_id_fkey;
+ALTER TABLE tables DROP CONSTRAINT IF EXISTS tables_restaurant_id_fkey;
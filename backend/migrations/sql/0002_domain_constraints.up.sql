@@ -0,0 +1,51 @@
+-- Foreign keys. Deleting a restaurant removes its tables, sessions and staff
+-- links; a session or time slot that still has bookings/sessions cannot be
+-- deleted, so booking history is never lost silently. Deleting a user keeps
+-- their bookings as guest bookings.
+ALTER TABLE tables
+    ADD CONSTRAINT tables_restaurant_id_fkey
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants (id) ON DELETE CASCADE;
+
+ALTER TABLE sessions
+    ADD CONSTRAINT sessions_restaurant_id_fkey
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants (id) ON DELETE CASCADE,
+    ADD CONSTRAINT sessions_time_slot_id_fkey
+    FOREIGN KEY (time_slot_id) REFERENCES time_slots (id) ON DELETE RESTRICT;
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_session_id_fkey
+    FOREIGN KEY (session_id) REFERENCES sessions (id) ON DELETE RESTRICT,
+    ADD CONSTRAINT bookings_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE SET NULL;
+
+ALTER TABLE users_restaurant
+    ADD CONSTRAINT users_restaurant_restaurant_id_fkey
+    FOREIGN KEY (restaurant_id) REFERENCES restaurants (id) ON DELETE CASCADE,
+    ADD CONSTRAINT users_restaurant_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE;
+
+-- Checks.
+ALTER TABLE tables
+    ADD CONSTRAINT tables_capacity_check CHECK (capacity > 0),
+    ADD CONSTRAINT tables_status_check CHECK (status IN ('active', 'inactive'));
+
+ALTER TABLE sessions
+    ADD CONSTRAINT sessions_max_guests_check CHECK (max_guests > 0),
+    ADD CONSTRAINT sessions_available_slots_check CHECK (available_slots >= 0 AND available_slots <= max_guests);
+
+ALTER TABLE bookings
+    ADD CONSTRAINT bookings_number_of_guests_check CHECK (number_of_guests > 0),
+    ADD CONSTRAINT bookings_status_check CHECK (status IN ('confirmed', 'cancelled'));
+
+ALTER TABLE outbox_messages
+    ADD CONSTRAINT outbox_messages_status_check CHECK (status IN ('pending', 'sent', 'dead'));
+
+-- Uniqueness.
+ALTER TABLE time_slots
+    ADD CONSTRAINT time_slots_slot_name_key UNIQUE (slot_name);
+
+ALTER TABLE sessions
+    ADD CONSTRAINT sessions_restaurant_date_time_slot_key UNIQUE (restaurant_id, date, time_slot_id);
+
+ALTER TABLE users_restaurant
+    ADD CONSTRAINT users_restaurant_user_restaurant_key UNIQUE (user_id, restaurant_id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_sessions_restaurant_id;
+DROP INDEX IF EXISTS idx_bookings_created_at;
+DROP INDEX IF EXISTS idx_bookings_session_id;
@@ -0,0 +1,5 @@
+-- Postgres does not index foreign keys on its own. These back the batched
+-- lookups behind GET /api/sessions and GET /api/bookings.
+CREATE INDEX IF NOT EXISTS idx_bookings_session_id ON bookings (session_id);
+CREATE INDEX IF NOT EXISTS idx_bookings_created_at ON bookings (created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_sessions_restaurant_id ON sessions (restaurant_id, created_at DESC);
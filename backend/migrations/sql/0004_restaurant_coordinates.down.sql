@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_sessions_date;
+DROP INDEX IF EXISTS idx_restaurants_coordinates;
+ALTER TABLE restaurants DROP CONSTRAINT IF EXISTS restaurants_coordinates_check;
+ALTER TABLE restaurants DROP COLUMN IF EXISTS longitude;
+ALTER TABLE restaurants DROP COLUMN IF EXISTS latitude;
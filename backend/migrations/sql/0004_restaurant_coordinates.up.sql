@@ -0,0 +1,13 @@
+-- Coordinates let guests search for sessions near them. Both are optional;
+-- restaurants without them never match a location search.
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE restaurants ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+ALTER TABLE restaurants
+    ADD CONSTRAINT restaurants_coordinates_check CHECK (
+        (latitude IS NULL AND longitude IS NULL)
+        OR (latitude BETWEEN -90 AND 90 AND longitude BETWEEN -180 AND 180)
+    );
+
+CREATE INDEX IF NOT EXISTS idx_restaurants_coordinates ON restaurants (latitude, longitude);
+CREATE INDEX IF NOT EXISTS idx_sessions_date ON sessions (date);
@@ -0,0 +1,20 @@
+-- Failed login counters per account and per client IP, and an audit trail of
+-- security events such as lockouts.
+CREATE TABLE IF NOT EXISTS login_throttles (
+    key             TEXT PRIMARY KEY,
+    failures        INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until    TIMESTAMPTZ
+);
+
+CREATE TABLE IF NOT EXISTS audit_events (
+    id         BIGSERIAL PRIMARY KEY,
+    type       TEXT NOT NULL,
+    user_id    BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    subject    TEXT NOT NULL,
+    ip         TEXT,
+    details    TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_events_type ON audit_events (type);
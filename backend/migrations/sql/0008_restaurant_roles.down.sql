@@ -0,0 +1,18 @@
+ALTER TABLE bookings DROP COLUMN IF EXISTS checked_in_at;
+
+ALTER TABLE users_restaurant DROP CONSTRAINT IF EXISTS users_restaurant_role_check;
+ALTER TABLE users_restaurant DROP COLUMN IF EXISTS role;
+
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_type WHERE typname = 'user_role') THEN
+        CREATE TYPE user_role AS ENUM ('admin', 'user');
+    END IF;
+END
+$$;
+
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_role_check;
+ALTER TABLE users ALTER COLUMN role DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN role TYPE user_role
+    USING (CASE WHEN role IN ('super_admin', 'restaurant_manager') THEN 'admin' ELSE 'user' END)::user_role;
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'user';
@@ -0,0 +1,17 @@
+-- Roles become text with a check instead of the two-value enum. Former admins
+-- keep every power they had as super admins, their restaurant links make
+-- them managers there, and everyone else is a customer. Staff and managers
+-- hold their role per restaurant in users_restaurant.
+ALTER TABLE users ALTER COLUMN role DROP DEFAULT;
+ALTER TABLE users ALTER COLUMN role TYPE TEXT
+    USING (CASE role::text WHEN 'admin' THEN 'super_admin' ELSE 'customer' END);
+ALTER TABLE users ALTER COLUMN role SET DEFAULT 'customer';
+ALTER TABLE users
+    ADD CONSTRAINT users_role_check CHECK (role IN ('super_admin', 'restaurant_manager', 'staff', 'customer'));
+DROP TYPE IF EXISTS user_role;
+
+ALTER TABLE users_restaurant ADD COLUMN IF NOT EXISTS role TEXT NOT NULL DEFAULT 'restaurant_manager';
+ALTER TABLE users_restaurant
+    ADD CONSTRAINT users_restaurant_role_check CHECK (role IN ('restaurant_manager', 'staff'));
+
+ALTER TABLE bookings ADD COLUMN IF NOT EXISTS checked_in_at TIMESTAMPTZ;
@@ -0,0 +1,18 @@
+-- Invitations mailed to future staff. The token is stored hashed; an
+-- invitation is pending until it is accepted, revoked or expires.
+CREATE TABLE IF NOT EXISTS staff_invitations (
+    id            BIGSERIAL PRIMARY KEY,
+    restaurant_id BIGINT NOT NULL REFERENCES restaurants (id) ON DELETE CASCADE,
+    email         TEXT NOT NULL,
+    role          TEXT NOT NULL,
+    token_hash    TEXT NOT NULL,
+    invited_by_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    accepted_at   TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT staff_invitations_role_check CHECK (role IN ('restaurant_manager', 'staff'))
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_staff_invitations_token_hash ON staff_invitations (token_hash);
+CREATE INDEX IF NOT EXISTS idx_staff_invitations_restaurant_id ON staff_invitations (restaurant_id);
@@ -0,0 +1,30 @@
+-- Social login through OpenID Connect providers. A login in progress keeps
+-- its hashed state, nonce and PKCE verifier until the provider redirects
+-- back, and the hash of a value kept in the browser's cookie so that only
+-- the browser that started it can finish it; identities link users to their
+-- provider accounts.
+CREATE TABLE IF NOT EXISTS oidc_logins (
+    id            BIGSERIAL PRIMARY KEY,
+    provider      TEXT NOT NULL,
+    state_hash    TEXT NOT NULL,
+    binding_hash  TEXT NOT NULL,
+    nonce         TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    used_at       TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_oidc_logins_state_hash ON oidc_logins (state_hash);
+
+CREATE TABLE IF NOT EXISTS user_identities (
+    id         BIGSERIAL PRIMARY KEY,
+    user_id    BIGINT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider   TEXT NOT NULL,
+    subject    TEXT NOT NULL,
+    email      TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_identities_provider_subject ON user_identities (provider, subject);
+CREATE INDEX IF NOT EXISTS idx_user_identities_user_id ON user_identities (user_id);
@@ -0,0 +1,22 @@
+-- Keys partner systems use instead of a user login. Each key belongs to one
+-- restaurant; the key itself is stored hashed and scopes are space separated.
+-- A rotated key's previous hash stays valid until previous_expires_at.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id            BIGSERIAL PRIMARY KEY,
+    restaurant_id BIGINT NOT NULL REFERENCES restaurants (id) ON DELETE CASCADE,
+    name          TEXT NOT NULL,
+    prefix        TEXT NOT NULL,
+    key_hash      TEXT NOT NULL,
+    previous_hash TEXT NOT NULL DEFAULT '',
+    previous_expires_at TIMESTAMPTZ,
+    scopes        TEXT NOT NULL,
+    created_by_id BIGINT REFERENCES users (id) ON DELETE SET NULL,
+    last_used_at  TIMESTAMPTZ,
+    rotated_at    TIMESTAMPTZ,
+    revoked_at    TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_api_keys_key_hash ON api_keys (key_hash);
+CREATE INDEX IF NOT EXISTS idx_api_keys_restaurant_id ON api_keys (restaurant_id);
+CREATE INDEX IF NOT EXISTS idx_api_keys_previous_hash ON api_keys (previous_hash);
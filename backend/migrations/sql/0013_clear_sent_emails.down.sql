@@ -0,0 +1,2 @@
+-- The cleared payloads cannot be restored.
+SELECT 1;
@@ -0,0 +1,3 @@
+-- Sent emails no longer keep their rendered text, which holds the raw tokens
+-- of verification, reset and invitation links. Clear what was kept so far.
+UPDATE outbox_messages SET payload = '' WHERE kind = 'email' AND status = 'sent';
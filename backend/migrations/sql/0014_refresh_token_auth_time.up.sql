@@ -0,0 +1,10 @@
+-- Refresh tokens remember when their login was made, so access tokens from a
+-- refresh can tell how recently the user signed in. Existing tokens take the
+-- creation time of the first token of their family.
+ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS authenticated_at TIMESTAMPTZ;
+
+UPDATE refresh_tokens AS r SET authenticated_at = (
+    SELECT MIN(f.created_at) FROM refresh_tokens AS f WHERE f.family_id = r.family_id
+);
+
+ALTER TABLE refresh_tokens ALTER COLUMN authenticated_at SET NOT NULL;
@@ -10,6 +10,8 @@ type Restaurant struct {
 	Phone       string    `json:"phone"`
 	Email       string    `json:"email"`
 	IsActive    bool      `json:"is_active" gorm:"default:true"`
+	Latitude    *float64  `json:"latitude"`
+	Longitude   *float64  `json:"longitude"`
 }
 
 type TimeSlot struct {
@@ -33,9 +35,14 @@ type User struct {
 	ID        uint      `json:"id" gorm:"primaryKey"`
 	Name      string    `json:"name" gorm:"not null"`
 	Email     string    `json:"email" gorm:"uniqueIndex;not null"`
-	Password  string    `json:"password,omitempty" gorm:"not null"`
+	Password  string    `json:"-" gorm:"not null"`
 	Phone     string    `json:"phone"`
-	Role      string    `json:"role" gorm:"type:enum('admin','user');default:'user';not null"`
+	Role      string    `json:"role" gorm:"type:text;default:'customer';not null"`
+	TokenVersion int    `json:"-" gorm:"default:0;not null"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TwoFactorSecret string `json:"-" gorm:"type:text;default:'';not null"`
+	TwoFactorEnabledAt *time.Time `json:"two_factor_enabled_at"`
+	TwoFactorLastStep int64 `json:"-" gorm:"default:0;not null"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
@@ -49,6 +56,7 @@ type Session struct {
 	MaxGuests      int        `json:"max_guests" gorm:"not null"`
 	AvailableSlots int        `json:"available_slots" gorm:"not null"`
 	IsAvailable    bool       `json:"is_available" gorm:"default:true"`
+	CreatedAt      time.Time  `json:"created_at"`
 	TimeSlot       TimeSlot   `json:"time_slot,omitempty" gorm:"foreignKey:TimeSlotID"`
 }
 
@@ -63,6 +71,9 @@ type Booking struct {
 	NumberOfGuests int       `json:"number_of_guests" gorm:"not null"`
 	Status         string    `json:"status" gorm:"default:'confirmed'"`
 	Notes          string    `json:"notes"`
+	Locale         string    `json:"locale" gorm:"type:text;default:'th'"`
+	ReminderSentAt *time.Time `json:"reminder_sent_at"`
+	CheckedInAt    *time.Time `json:"checked_in_at"`
 	CreatedAt      time.Time `json:"created_at"`
 	UpdatedAt      time.Time `json:"updated_at"`
 }
@@ -71,6 +82,7 @@ type UserRestaurant struct {
 	ID           uint      `json:"id" gorm:"primaryKey"`
 	RestaurantID uint      `json:"restaurant_id" gorm:"not null"`
 	UserID       uint      `json:"user_id" gorm:"not null"`
+	Role         string    `json:"role" gorm:"type:text;default:'restaurant_manager';not null"`
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Restaurant   Restaurant `json:"restaurant,omitempty" gorm:"foreignKey:RestaurantID"`
@@ -80,3 +92,175 @@ type UserRestaurant struct {
 func (UserRestaurant) TableName() string {
 	return "users_restaurant"
 }
+
+type OutboxMessage struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	Kind          string     `json:"kind" gorm:"type:text;not null"`
+	Topic         string     `json:"topic" gorm:"type:text;not null"`
+	Payload       string     `json:"-" gorm:"type:text;not null"`
+	Status        string     `json:"status" gorm:"type:text;default:'pending';not null;index"`
+	Attempts      int        `json:"attempts" gorm:"default:0;not null"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"not null;index"`
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+func (OutboxMessage) TableName() string {
+	return "outbox_messages"
+}
+
+// RefreshToken is one link in a chain of rotated refresh tokens. Tokens from
+// the same login share a FamilyID, so reuse of a rotated token can revoke the
+// whole chain. Only the SHA-256 of the token is stored.
+type RefreshToken struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	UserID    uint   `json:"user_id" gorm:"not null;index"`
+	FamilyID  string `json:"family_id" gorm:"type:text;not null;index"`
+	TokenHash string `json:"-" gorm:"type:text;not null;uniqueIndex"`
+	// AuthenticatedAt is when the login the token belongs to was made; it
+	// is copied from token to token as they are rotated.
+	AuthenticatedAt time.Time  `json:"authenticated_at" gorm:"not null"`
+	ExpiresAt       time.Time  `json:"expires_at" gorm:"not null"`
+	RevokedAt       *time.Time `json:"revoked_at"`
+	ReplacedByID    *uint      `json:"replaced_by_id"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
+
+// UserToken is a single-use token mailed to a user, to verify their email
+// address or reset their password. Only the SHA-256 of the token is stored.
+type UserToken struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Purpose   string     `json:"purpose" gorm:"type:text;not null"`
+	TokenHash string     `json:"-" gorm:"type:text;not null;uniqueIndex"`
+	ExpiresAt time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (UserToken) TableName() string {
+	return "user_tokens"
+}
+
+// LoginThrottle counts recent failed logins for one key, "account:<email>" or
+// "ip:<address>".
+type LoginThrottle struct {
+	Key           string     `json:"key" gorm:"type:text;primaryKey"`
+	Failures      int        `json:"failures" gorm:"not null;default:0"`
+	LastFailureAt time.Time  `json:"last_failure_at" gorm:"not null"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
+
+// AuditEvent records a security relevant event, such as an account lockout.
+type AuditEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Type      string    `json:"type" gorm:"type:text;not null;index"`
+	UserID    *uint     `json:"user_id"`
+	Subject   string    `json:"subject" gorm:"type:text;not null"`
+	IP        string    `json:"ip" gorm:"type:text"`
+	Details   string    `json:"details" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (AuditEvent) TableName() string {
+	return "audit_events"
+}
+
+// StaffInvitation invites an email address to work at a restaurant in a
+// role. Only the SHA-256 of the mailed token is stored.
+type StaffInvitation struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	RestaurantID uint       `json:"restaurant_id" gorm:"not null;index"`
+	Email        string     `json:"email" gorm:"type:text;not null"`
+	Role         string     `json:"role" gorm:"type:text;not null"`
+	TokenHash    string     `json:"-" gorm:"type:text;not null;uniqueIndex"`
+	InvitedByID  *uint      `json:"invited_by_id"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	AcceptedAt   *time.Time `json:"accepted_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (StaffInvitation) TableName() string {
+	return "staff_invitations"
+}
+
+// OIDCLogin is a social login in progress: the state sent to the provider,
+// stored hashed, with the nonce and PKCE verifier needed to finish it.
+type OIDCLogin struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	Provider     string     `json:"provider" gorm:"type:text;not null"`
+	StateHash    string     `json:"-" gorm:"type:text;not null;uniqueIndex"`
+	BindingHash  string     `json:"-" gorm:"type:text;not null"`
+	Nonce        string     `json:"-" gorm:"type:text;not null"`
+	CodeVerifier string     `json:"-" gorm:"type:text;not null"`
+	ExpiresAt    time.Time  `json:"expires_at" gorm:"not null"`
+	UsedAt       *time.Time `json:"used_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (OIDCLogin) TableName() string {
+	return "oidc_logins"
+}
+
+// UserIdentity links a user to their account at an OpenID Connect provider.
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Provider  string    `json:"provider" gorm:"type:text;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Subject   string    `json:"subject" gorm:"type:text;not null;uniqueIndex:idx_user_identities_provider_subject"`
+	Email     string    `json:"email" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// RecoveryCode is a single-use code that stands in for a TOTP code when the
+// authenticator is lost. Only the SHA-256 of the code is stored.
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash  string     `json:"-" gorm:"type:text;not null"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (RecoveryCode) TableName() string {
+	return "recovery_codes"
+}
+
+// APIKey lets a partner system act for one restaurant within its scopes,
+// which are stored space separated. Only the SHA-256 of the key is stored;
+// Prefix is kept so the key can be recognised in lists. After a rotation the
+// previous key keeps working until PreviousExpiresAt.
+type APIKey struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	RestaurantID uint       `json:"restaurant_id" gorm:"not null;index"`
+	Name         string     `json:"name" gorm:"type:text;not null"`
+	Prefix       string     `json:"prefix" gorm:"type:text;not null"`
+	KeyHash      string     `json:"-" gorm:"type:text;not null;uniqueIndex"`
+	PreviousHash string     `json:"-" gorm:"type:text;not null;default:'';index"`
+	PreviousExpiresAt *time.Time `json:"previous_expires_at"`
+	Scopes       string     `json:"scopes" gorm:"type:text;not null"`
+	CreatedByID  *uint      `json:"created_by_id"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RotatedAt    *time.Time `json:"rotated_at"`
+	RevokedAt    *time.Time `json:"revoked_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (APIKey) TableName() string {
+	return "api_keys"
+}
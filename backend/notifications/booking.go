@@ -0,0 +1,28 @@
+package notifications
+
+import "booking-backend/models"
+
+// BookingDetails is the data every booking lifecycle template renders.
+type BookingDetails struct {
+	BookingID      uint
+	Name           string
+	RestaurantName string
+	SessionName    string
+	Date           string
+	TimeSlot       string
+	Guests         int
+	Notes          string
+}
+
+func NewBookingDetails(booking models.Booking, session models.Session, restaurant models.Restaurant) BookingDetails {
+	return BookingDetails{
+		BookingID:      booking.ID,
+		Name:           booking.UserName,
+		RestaurantName: restaurant.Name,
+		SessionName:    session.Name,
+		Date:           session.Date,
+		TimeSlot:       session.TimeSlot.SlotName,
+		Guests:         booking.NumberOfGuests,
+		Notes:          booking.Notes,
+	}
+}
@@ -0,0 +1,40 @@
+package notifications
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync/atomic"
+	"time"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+// FileNotifier writes each message to its own file in a directory, so local
+// development can read outgoing mail without an SMTP server.
+type FileNotifier struct {
+	dir     string
+	counter atomic.Uint64
+}
+
+func NewFileNotifier(dir string) *FileNotifier {
+	return &FileNotifier{dir: dir}
+}
+
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(n.dir, 0o755); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%s-%03d-%s.eml",
+		time.Now().Format("20060102-150405"),
+		n.counter.Add(1)%1000,
+		unsafeFileChars.ReplaceAllString(msg.To, "_"),
+	)
+	body := fmt.Sprintf("To: %s\nSubject: %s\n\n%s\n", msg.To, msg.Subject, msg.Text)
+	if msg.HTML != "" {
+		body += "\n--- text/html ---\n" + msg.HTML + "\n"
+	}
+	return os.WriteFile(filepath.Join(n.dir, name), []byte(body), 0o644)
+}
@@ -0,0 +1,29 @@
+package notifications
+
+import (
+	"context"
+	"sync"
+)
+
+// MemoryNotifier keeps every message it is given, for tests.
+type MemoryNotifier struct {
+	mutex    sync.Mutex
+	messages []Message
+}
+
+func NewMemoryNotifier() *MemoryNotifier {
+	return &MemoryNotifier{}
+}
+
+func (n *MemoryNotifier) Send(ctx context.Context, msg Message) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func (n *MemoryNotifier) Messages() []Message {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return append([]Message(nil), n.messages...)
+}
@@ -0,0 +1,46 @@
+package notifications
+
+import (
+	"context"
+	"log"
+	"strconv"
+
+	"booking-backend/utils"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Notifier delivers a rendered message. Implementations must be safe for
+// concurrent use.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewFromEnv picks the delivery backend. SMTP is used when SMTP_HOST is set;
+// otherwise messages are written to NOTIFY_SINK_DIR (default tmp/mail) so
+// local development never needs real credentials.
+func NewFromEnv() Notifier {
+	host := utils.GetEnv("SMTP_HOST", "")
+	if host == "" {
+		dir := utils.GetEnv("NOTIFY_SINK_DIR", "tmp/mail")
+		log.Printf("SMTP_HOST not set, writing emails to %s", dir)
+		return NewFileNotifier(dir)
+	}
+
+	port, err := strconv.Atoi(utils.GetEnv("SMTP_PORT", "587"))
+	if err != nil {
+		log.Fatal("Invalid SMTP_PORT:", err)
+	}
+	return NewSMTPNotifier(SMTPConfig{
+		Host:     host,
+		Port:     port,
+		Username: utils.GetEnv("SMTP_USERNAME", ""),
+		Password: utils.GetEnv("SMTP_PASSWORD", ""),
+		From:     utils.GetEnv("SMTP_FROM", utils.GetEnv("SMTP_USERNAME", "")),
+	})
+}
@@ -0,0 +1,108 @@
+package notifications_test
+
+import (
+	"booking-backend/notifications"
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpServer accepts connections on a local port and hands each to serve.
+func smtpServer(t *testing.T, serve func(net.Conn)) notifications.SMTPConfig {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				serve(conn)
+			}()
+		}
+	}()
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	return notifications.SMTPConfig{Host: host, Port: portNumber, From: "Baan Suan <noreply@example.com>"}
+}
+
+func TestSMTPSendDeliversTheMessage(t *testing.T) {
+	received := make(chan string, 1)
+	config := smtpServer(t, func(conn net.Conn) {
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 mail.example.com ready")
+		var envelope []string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(command, "EHLO"):
+				reply("250 mail.example.com")
+			case strings.HasPrefix(command, "MAIL"), strings.HasPrefix(command, "RCPT"):
+				envelope = append(envelope, strings.TrimSpace(line))
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 go ahead")
+				var body strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					body.WriteString(line)
+				}
+				received <- strings.Join(envelope, "\n") + "\n" + body.String()
+				reply("250 queued")
+			case command == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	})
+
+	err := notifications.NewSMTPNotifier(config).Send(context.Background(), notifications.Message{
+		To: "guest@example.com", Subject: "Booking confirmed", Text: "See you on Friday",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := <-received
+	for _, want := range []string{"MAIL FROM:<noreply@example.com>", "RCPT TO:<guest@example.com>", "Subject: Booking confirmed", "See you on Friday"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected %q in %q", want, got)
+		}
+	}
+}
+
+func TestSMTPSendGivesUpWithItsContext(t *testing.T) {
+	// The server accepts but never greets.
+	config := smtpServer(t, func(conn net.Conn) { conn.Read(make([]byte, 1)) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	err := notifications.NewSMTPNotifier(config).Send(ctx, notifications.Message{To: "guest@example.com", Subject: "Hi", Text: "Hi"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline to be exceeded, got %v", err)
+	}
+	if elapsed := time.Since(started); elapsed > 2*time.Second {
+		t.Fatalf("send took %v past its deadline", elapsed)
+	}
+}
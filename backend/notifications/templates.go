@@ -0,0 +1,82 @@
+package notifications
+
+import (
+	"bytes"
+	"embed"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+const DefaultLocale = "th"
+
+var supportedLocales = []string{"th", "en"}
+
+type localeTemplates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+var templates = loadTemplates()
+
+func loadTemplates() map[string]localeTemplates {
+	sets := make(map[string]localeTemplates)
+	for _, locale := range supportedLocales {
+		dir := "templates/" + locale
+		sets[locale] = localeTemplates{
+			text: texttemplate.Must(texttemplate.ParseFS(templateFS, dir+"/*.txt")),
+			html: htmltemplate.Must(htmltemplate.ParseFS(templateFS, dir+"/*.html")),
+		}
+	}
+	return sets
+}
+
+// LocaleFromAcceptLanguage returns the first supported language listed in an
+// Accept-Language header, or DefaultLocale.
+func LocaleFromAcceptLanguage(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		tag = strings.SplitN(tag, "-", 2)[0]
+		for _, locale := range supportedLocales {
+			if tag == locale {
+				return locale
+			}
+		}
+	}
+	return DefaultLocale
+}
+
+// Render builds a message from templates/<locale>/<name>.txt and .html. The
+// text template must also define a "<name>.subject" block. Unknown locales
+// fall back to DefaultLocale.
+func Render(name, locale, to string, data interface{}) (Message, error) {
+	msg := Message{To: to}
+	set, ok := templates[locale]
+	if !ok {
+		set = templates[DefaultLocale]
+	}
+
+	var subject bytes.Buffer
+	if err := set.text.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return msg, err
+	}
+	msg.Subject = strings.TrimSpace(subject.String())
+
+	var text bytes.Buffer
+	if err := set.text.ExecuteTemplate(&text, name+".txt", data); err != nil {
+		return msg, err
+	}
+	msg.Text = strings.TrimSpace(text.String())
+
+	if set.html.Lookup(name+".html") != nil {
+		var html bytes.Buffer
+		if err := set.html.ExecuteTemplate(&html, name+".html", data); err != nil {
+			return msg, err
+		}
+		msg.HTML = html.String()
+	}
+	return msg, nil
+}
@@ -0,0 +1,81 @@
+package oidc
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// wellKnown fills in the issuer and display name of providers everyone uses.
+var wellKnown = map[string]struct {
+	displayName string
+	issuer      string
+	trustEmail  bool
+}{
+	"google": {displayName: "Google", issuer: "https://accounts.google.com"},
+	"line":   {displayName: "LINE", issuer: "https://access.line.me", trustEmail: true},
+}
+
+// ProvidersFromEnv reads the providers named in OIDC_PROVIDERS:
+//
+//	OIDC_PROVIDERS               comma separated names, e.g. google,line
+//	OIDC_<NAME>_CLIENT_ID        required
+//	OIDC_<NAME>_CLIENT_SECRET    required
+//	OIDC_<NAME>_ISSUER           required unless the provider is well known
+//	OIDC_<NAME>_DISPLAY_NAME     label for login buttons
+//	OIDC_<NAME>_SCOPES           default "openid email profile"
+//	OIDC_<NAME>_TRUST_EMAIL      true to accept emails without email_verified
+//	OIDC_REDIRECT_URL            frontend callback page (default APP_URL/oauth-callback)
+func ProvidersFromEnv(getenv func(string) string) (map[string]*Provider, error) {
+	providers := map[string]*Provider{}
+	redirect := getenv("OIDC_REDIRECT_URL")
+	if redirect == "" {
+		base := getenv("APP_URL")
+		if base == "" {
+			base = "http://localhost:3000"
+		}
+		redirect = strings.TrimRight(base, "/") + "/oauth-callback"
+	}
+	for _, name := range strings.Split(getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		setting := func(key, fallback string) string {
+			if value := getenv(prefix + key); value != "" {
+				return value
+			}
+			return fallback
+		}
+		known := wellKnown[name]
+		provider := &Provider{
+			Name:         name,
+			DisplayName:  setting("DISPLAY_NAME", known.displayName),
+			Issuer:       setting("ISSUER", known.issuer),
+			ClientID:     setting("CLIENT_ID", ""),
+			ClientSecret: setting("CLIENT_SECRET", ""),
+			RedirectURL:  redirect,
+			Scopes:       strings.Fields(setting("SCOPES", "openid email profile")),
+			TrustEmail:   setting("TRUST_EMAIL", fmt.Sprint(known.trustEmail)) == "true",
+		}
+		if provider.DisplayName == "" {
+			provider.DisplayName = name
+		}
+		if provider.Issuer == "" || provider.ClientID == "" || provider.ClientSecret == "" {
+			return nil, fmt.Errorf("%sISSUER, %sCLIENT_ID and %sCLIENT_SECRET are required", prefix, prefix, prefix)
+		}
+		providers[name] = provider
+	}
+	return providers, nil
+}
+
+// Names returns the provider names in order.
+func Names(providers map[string]*Provider) []string {
+	names := make([]string, 0, len(providers))
+	for name := range providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,244 @@
+// Package openapi builds an OpenAPI 3 document from route descriptions and
+// the Go types the handlers bind and return, so the published schema follows
+// the code instead of being maintained by hand.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DocsPage is a Swagger UI page that loads the spec from ./openapi.json.
+//
+//go:embed docs.html
+var DocsPage []byte
+
+// Object describes a JSON object response by example, e.g.
+// Object{"message": "", "booking": models.Booking{}}. Each value's type
+// becomes the schema of its property.
+type Object map[string]interface{}
+
+// Param is a query parameter.
+type Param struct {
+	Name        string
+	Type        string // "string", "integer", "number" or "boolean"; default "string"
+	Format      string
+	Enum        []string
+	Description string
+	Required    bool
+}
+
+// Operation documents one route. Path uses gin syntax (/sessions/:id); its
+// parameters are documented automatically.
+type Operation struct {
+	Method      string
+	Path        string
+	Tag         string
+	Summary     string
+	Description string
+	Auth        bool
+	// APIKey is the scope a restaurant API key needs to call the route; keys
+	// are not accepted when it is empty.
+	APIKey     string
+	Deprecated bool
+	Query      []Param
+	Body       interface{}
+	Status     int // success status, default 200
+	Response   interface{}
+	// ContentType overrides application/json for non-JSON responses.
+	ContentType string
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Document struct {
+	OpenAPI    string                          `json:"openapi"`
+	Info       Info                            `json:"info"`
+	Paths      map[string]map[string]operation `json:"paths"`
+	Components components                      `json:"components"`
+}
+
+type components struct {
+	Schemas         map[string]*Schema     `json:"schemas"`
+	SecuritySchemes map[string]interface{} `json:"securitySchemes"`
+}
+
+type operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Deprecated  bool                  `json:"deprecated,omitempty"`
+	Parameters  []parameter           `json:"parameters,omitempty"`
+	RequestBody *requestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type requestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]mediaType `json:"content"`
+}
+
+type response struct {
+	Description string               `json:"description"`
+	Content     map[string]mediaType `json:"content,omitempty"`
+}
+
+type mediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+// errorSchema is the component every operation's error responses refer to.
+const errorSchema = "Error"
+
+// Build describes ops as an OpenAPI 3.0 document. errorBody is the type every
+// failed request returns.
+func Build(info Info, errorBody interface{}, ops []Operation) *Document {
+	g := newGenerator()
+	errRef := g.named(errorSchema, errorBody)
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]map[string]operation{},
+		Components: components{
+			Schemas: g.schemas,
+			SecuritySchemes: map[string]interface{}{
+				"bearerAuth": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+				"apiKeyAuth": map[string]string{"type": "http", "scheme": "bearer", "bearerFormat": "API key"},
+			},
+		},
+	}
+
+	for _, op := range ops {
+		path, pathParams := convertPath(op.Path)
+		o := operation{
+			Summary:     op.Summary,
+			Description: op.Description,
+			OperationID: operationID(op.Method, op.Path),
+			Deprecated:  op.Deprecated,
+			Responses:   map[string]response{},
+		}
+		if op.Tag != "" {
+			o.Tags = []string{op.Tag}
+		}
+		for _, name := range pathParams {
+			schema := &Schema{Type: "string"}
+			if name == "id" || strings.HasSuffix(name, "_id") {
+				schema = &Schema{Type: "integer", Minimum: ptr(0)}
+			}
+			o.Parameters = append(o.Parameters, parameter{Name: name, In: "path", Required: true, Schema: schema})
+		}
+		for _, p := range op.Query {
+			schema := &Schema{Type: p.Type, Format: p.Format, Enum: p.Enum}
+			if schema.Type == "" {
+				schema.Type = "string"
+			}
+			o.Parameters = append(o.Parameters, parameter{Name: p.Name, In: "query", Description: p.Description, Required: p.Required, Schema: schema})
+		}
+		if op.Body != nil {
+			o.RequestBody = &requestBody{Required: true, Content: map[string]mediaType{
+				"application/json": {Schema: g.schema(op.Body)},
+			}}
+		}
+		if op.Auth {
+			o.Security = []map[string][]string{{"bearerAuth": {}}}
+		}
+		if op.APIKey != "" {
+			if !op.Auth {
+				// An empty requirement keeps the route open to anonymous callers.
+				o.Security = []map[string][]string{{}}
+			}
+			o.Security = append(o.Security, map[string][]string{"apiKeyAuth": {}})
+			o.Description = strings.TrimSpace(o.Description + " API keys need the " + op.APIKey + " scope.")
+		}
+
+		status := op.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := response{Description: http.StatusText(status)}
+		if op.Response != nil {
+			contentType := op.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			success.Content = map[string]mediaType{contentType: {Schema: g.schema(op.Response)}}
+		}
+		o.Responses[strconv.Itoa(status)] = success
+		o.Responses["default"] = response{
+			Description: "Error",
+			Content:     map[string]mediaType{"application/json": {Schema: errRef}},
+		}
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]operation{}
+		}
+		doc.Paths[path][strings.ToLower(op.Method)] = o
+	}
+	return doc
+}
+
+// Has reports whether the document describes method on a gin-style path.
+func (d *Document) Has(method, ginPath string) bool {
+	path, _ := convertPath(ginPath)
+	_, ok := d.Paths[path][strings.ToLower(method)]
+	return ok
+}
+
+// Operations lists "METHOD /path" for every documented operation, sorted.
+func (d *Document) Operations() []string {
+	var ops []string
+	for path, methods := range d.Paths {
+		for method := range methods {
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	sort.Strings(ops)
+	return ops
+}
+
+// convertPath turns /sessions/:id into /sessions/{id} and returns the
+// parameter names in order.
+func convertPath(ginPath string) (string, []string) {
+	segments := strings.Split(ginPath, "/")
+	var names []string
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			names = append(names, s[1:])
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/"), names
+}
+
+// operationID derives a stable id such as getSessionsById.
+func operationID(method, ginPath string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, s := range strings.Split(ginPath, "/") {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			s = s[1:]
+			b.WriteString("By")
+		}
+		for _, word := range strings.FieldsFunc(s, func(r rune) bool { return r == '-' || r == '_' || r == '.' }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
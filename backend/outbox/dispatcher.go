@@ -0,0 +1,192 @@
+package outbox
+
+import (
+	"booking-backend/models"
+	"booking-backend/notifications"
+	"booking-backend/utils"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	baseBackoff = 30 * time.Second
+	maxBackoff  = time.Hour
+	sendTimeout = 30 * time.Second
+	// claimLease is how long a claimed batch is left alone by other
+	// dispatchers while it is being delivered.
+	claimLease = 10 * time.Minute
+)
+
+type Publisher interface {
+	Publish(data map[string]interface{})
+}
+
+type Dispatcher struct {
+	DB          *gorm.DB
+	Notifier    notifications.Notifier
+	Publisher   Publisher
+	Interval    time.Duration
+	BatchSize   int
+	MaxAttempts int
+}
+
+func NewDispatcher(DB *gorm.DB, notifier notifications.Notifier, publisher Publisher) *Dispatcher {
+	maxAttempts, err := strconv.Atoi(utils.GetEnv("OUTBOX_MAX_ATTEMPTS", "8"))
+	if err != nil || maxAttempts <= 0 {
+		maxAttempts = 8
+	}
+	return &Dispatcher{
+		DB:          DB,
+		Notifier:    notifier,
+		Publisher:   publisher,
+		Interval:    time.Second,
+		BatchSize:   50,
+		MaxAttempts: maxAttempts,
+	}
+}
+
+// Backoff is the delay before retry number attempts+1: 30s doubling up to an
+// hour.
+func Backoff(attempts int) time.Duration {
+	delay := baseBackoff
+	for i := 1; i < attempts && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxBackoff {
+		delay = maxBackoff
+	}
+	return delay
+}
+
+// Run polls for due messages until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.DispatchOnce(ctx)
+		}
+	}
+}
+
+// DispatchOnce delivers one batch of due messages and returns how many were
+// sent. The batch is claimed in a short transaction and delivered outside
+// it, each result saved on its own, so a slow mail server holds no locks.
+func (d *Dispatcher) DispatchOnce(ctx context.Context) int {
+	batch, lease, err := d.claim()
+	if err != nil {
+		log.Printf("Outbox dispatch failed: %v", err)
+		return 0
+	}
+	sent := 0
+	for i := range batch {
+		// Only start deliveries that can finish within the lease; the rest
+		// go back for the next round.
+		if ctx.Err() != nil || time.Now().Add(sendTimeout).After(lease) {
+			d.release(batch[i:])
+			break
+		}
+		msg := &batch[i]
+		if err := d.deliver(ctx, msg); err != nil {
+			d.markFailed(msg, err)
+		} else {
+			now := time.Now()
+			msg.Status = StatusSent
+			msg.SentAt = &now
+			msg.LastError = ""
+			// Emails carry one-time links, whose tokens are only stored
+			// hashed everywhere else.
+			if msg.Kind == KindEmail {
+				msg.Payload = ""
+			}
+			sent++
+		}
+		if err := d.DB.Save(msg).Error; err != nil {
+			log.Printf("Outbox message %d: saving the result failed: %v", msg.ID, err)
+		}
+	}
+	return sent
+}
+
+// claim picks a batch of due messages and moves their next attempt to the
+// end of the lease, so they are no longer due for other dispatchers. On
+// Postgres the rows are locked with SKIP LOCKED while they are claimed, so
+// several instances can dispatch side by side. Messages whose dispatcher
+// dies are retried once the lease ends.
+func (d *Dispatcher) claim() ([]models.OutboxMessage, time.Time, error) {
+	var batch []models.OutboxMessage
+	lease := time.Now().Add(claimLease)
+	err := d.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Where("status = ? AND next_attempt_at <= ?", StatusPending, time.Now()).
+			Order("id").Limit(d.BatchSize)
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"})
+		}
+		if err := query.Find(&batch).Error; err != nil || len(batch) == 0 {
+			return err
+		}
+		ids := make([]uint, len(batch))
+		for i := range batch {
+			ids[i] = batch[i].ID
+			batch[i].NextAttemptAt = lease
+		}
+		return tx.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Update("next_attempt_at", lease).Error
+	})
+	return batch, lease, err
+}
+
+// release makes claimed messages due again without counting an attempt.
+func (d *Dispatcher) release(batch []models.OutboxMessage) {
+	ids := make([]uint, len(batch))
+	for i := range batch {
+		ids[i] = batch[i].ID
+	}
+	err := d.DB.Model(&models.OutboxMessage{}).Where("id IN ?", ids).Update("next_attempt_at", time.Now()).Error
+	if err != nil {
+		log.Printf("Outbox dispatch failed to release %d messages: %v", len(ids), err)
+	}
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, msg *models.OutboxMessage) error {
+	switch msg.Kind {
+	case KindEmail:
+		var email notifications.Message
+		if err := json.Unmarshal([]byte(msg.Payload), &email); err != nil {
+			return err
+		}
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		defer cancel()
+		return d.Notifier.Send(sendCtx, email)
+	case KindEvent:
+		var data map[string]interface{}
+		if err := json.Unmarshal([]byte(msg.Payload), &data); err != nil {
+			return err
+		}
+		d.Publisher.Publish(data)
+		return nil
+	default:
+		return fmt.Errorf("unknown outbox kind %q", msg.Kind)
+	}
+}
+
+func (d *Dispatcher) markFailed(msg *models.OutboxMessage, err error) {
+	msg.Attempts++
+	msg.LastError = err.Error()
+	if msg.Attempts >= d.MaxAttempts {
+		msg.Status = StatusDead
+		log.Printf("Outbox message %d (%s %s) dead-lettered after %d attempts: %v", msg.ID, msg.Kind, msg.Topic, msg.Attempts, err)
+		return
+	}
+	msg.NextAttemptAt = time.Now().Add(Backoff(msg.Attempts))
+	log.Printf("Outbox message %d (%s %s) failed, retrying in %s: %v", msg.ID, msg.Kind, msg.Topic, Backoff(msg.Attempts), err)
+}
@@ -0,0 +1,78 @@
+package outbox_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/notifications"
+	"booking-backend/outbox"
+	"context"
+	"errors"
+	"testing"
+)
+
+// slowNotifier lets a second dispatcher run while a message is being
+// delivered, as another instance would while the mail server is slow.
+type slowNotifier struct {
+	other       *outbox.Dispatcher
+	sent        []notifications.Message
+	sentByOther int
+}
+
+func (n *slowNotifier) Send(ctx context.Context, msg notifications.Message) error {
+	n.sentByOther += n.other.DispatchOnce(ctx)
+	n.sent = append(n.sent, msg)
+	return nil
+}
+
+func TestMessagesAreDeliveredOutsideTheClaim(t *testing.T) {
+	env := apitest.New(t)
+	for _, to := range []string{"a@example.com", "b@example.com"} {
+		data := map[string]string{"Name": "A", "Email": to}
+		env.Must(outbox.EnqueueEmail(env.DB, "password_changed", "en", to, data))
+	}
+
+	notifier := &slowNotifier{other: outbox.NewDispatcher(env.DB, notifications.NewMemoryNotifier(), nil)}
+	dispatcher := outbox.NewDispatcher(env.DB, notifier, nil)
+	if sent := dispatcher.DispatchOnce(context.Background()); sent != 2 || len(notifier.sent) != 2 {
+		t.Fatalf("expected both messages to be sent, sent %d", sent)
+	}
+	if notifier.sentByOther != 0 {
+		t.Fatalf("claimed messages should be left to their dispatcher, another sent %d", notifier.sentByOther)
+	}
+	var pending int64
+	env.Must(env.DB.Model(&models.OutboxMessage{}).Where("status = ?", outbox.StatusPending).Count(&pending).Error)
+	if pending != 0 {
+		t.Fatalf("expected every message to be saved as sent, %d pending", pending)
+	}
+}
+
+// retryingNotifier asks for a retry of the message it is delivering, as an
+// admin might while the mail server is slow.
+type retryingNotifier struct {
+	env *apitest.Env
+	id  uint
+	err error
+}
+
+func (n *retryingNotifier) Send(ctx context.Context, msg notifications.Message) error {
+	_, n.err = outbox.Retry(n.env.DB, n.id)
+	return nil
+}
+
+func TestClaimedMessagesCannotBeRetried(t *testing.T) {
+	env := apitest.New(t)
+	env.Must(outbox.EnqueueEmail(env.DB, "password_changed", "en", "a@example.com", map[string]string{"Name": "A", "Email": "a@example.com"}))
+	var msg models.OutboxMessage
+	env.Must(env.DB.First(&msg).Error)
+
+	notifier := &retryingNotifier{env: env, id: msg.ID}
+	if sent := outbox.NewDispatcher(env.DB, notifier, nil).DispatchOnce(context.Background()); sent != 1 {
+		t.Fatalf("expected the message to be sent, sent %d", sent)
+	}
+	if !errors.Is(notifier.err, outbox.ErrNotDue) {
+		t.Fatalf("a message being delivered was retried: %v", notifier.err)
+	}
+	if _, err := outbox.Retry(env.DB, msg.ID); !errors.Is(err, outbox.ErrAlreadySent) {
+		t.Fatalf("expected the message to be sent, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package outbox
+
+import (
+	"booking-backend/models"
+	"booking-backend/notifications"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	KindEmail = "email"
+	KindEvent = "event"
+
+	StatusPending = "pending"
+	StatusSent    = "sent"
+	StatusDead    = "dead"
+)
+
+// EnqueueEmail renders an email and stores it for the dispatcher. Pass the
+// transaction that makes the change the email describes, so either both are
+// committed or neither is.
+func EnqueueEmail(tx *gorm.DB, template, locale, to string, data interface{}) error {
+	if to == "" {
+		return nil
+	}
+	msg, err := notifications.Render(template, locale, to, data)
+	if err != nil {
+		return err
+	}
+	return enqueue(tx, KindEmail, template, msg)
+}
+
+// EnqueueEvent stores a hub event for the dispatcher to broadcast.
+func EnqueueEvent(tx *gorm.DB, data map[string]interface{}) error {
+	eventType, _ := data["type"].(string)
+	return enqueue(tx, KindEvent, eventType, data)
+}
+
+func enqueue(tx *gorm.DB, kind, topic string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.OutboxMessage{
+		Kind:          kind,
+		Topic:         topic,
+		Payload:       string(body),
+		Status:        StatusPending,
+		NextAttemptAt: time.Now(),
+	}).Error
+}
+
+// ErrAlreadySent is returned by Retry for a message that was delivered.
+var ErrAlreadySent = errors.New("message already sent")
+
+// ErrNotDue is returned by Retry for a pending message that is not due yet:
+// a dispatcher may have claimed it and be delivering it now.
+var ErrNotDue = errors.New("message not due yet")
+
+// Retry puts a failed or dead-lettered message back in the queue with a fresh
+// attempt budget. Pending messages are only retried once due, so a message
+// whose claim has not expired is not sent twice.
+func Retry(DB *gorm.DB, id uint) (*models.OutboxMessage, error) {
+	var msg models.OutboxMessage
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		query := tx
+		if tx.Dialector.Name() == "postgres" {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		if err := query.First(&msg, id).Error; err != nil {
+			return err
+		}
+		now := time.Now()
+		if msg.Status == StatusSent {
+			return ErrAlreadySent
+		}
+		if msg.Status == StatusPending && msg.NextAttemptAt.After(now) {
+			return ErrNotDue
+		}
+		msg.Status = StatusPending
+		msg.Attempts = 0
+		msg.NextAttemptAt = now
+		return tx.Save(&msg).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
@@ -0,0 +1,80 @@
+package pagination
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Slice applies p to rows held in memory the way Apply does to a query: it
+// orders them, skips everything up to the cursor and keeps one row more than
+// the page size.
+func Slice[T any](rows []T, p Params, key Key[T]) []T {
+	name := strings.TrimPrefix(p.Sort, "-")
+	less := func(a, b T) bool {
+		av, aid := key(a, name)
+		bv, bid := key(b, name)
+		if c := compare(av, bv); c != 0 {
+			return (c < 0) != p.Desc
+		}
+		return (aid < bid) != p.Desc
+	}
+
+	sorted := append([]T(nil), rows...)
+	sort.SliceStable(sorted, func(i, j int) bool { return less(sorted[i], sorted[j]) })
+
+	if p.After != nil {
+		after, _ := p.After.value(p.Field.Kind)
+		start := sort.Search(len(sorted), func(i int) bool {
+			v, id := key(sorted[i], name)
+			if c := compare(v, after); c != 0 {
+				return (c > 0) != p.Desc
+			}
+			return (id > p.After.ID) != p.Desc
+		})
+		sorted = sorted[start:]
+	}
+	if len(sorted) > p.Limit+1 {
+		sorted = sorted[:p.Limit+1]
+	}
+	return sorted
+}
+
+// compare orders two sort values of the same kind. Integers of any width and
+// values that went through JSON compare equal when they hold the same number.
+func compare(a, b interface{}) int {
+	switch av := a.(type) {
+	case time.Time:
+		bv, _ := b.(time.Time)
+		return av.Compare(bv)
+	case string:
+		bv, _ := b.(string)
+		return strings.Compare(av, bv)
+	}
+	ai, bi := toInt(a), toInt(b)
+	switch {
+	case ai < bi:
+		return -1
+	case ai > bi:
+		return 1
+	}
+	return 0
+}
+
+func toInt(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	case uint:
+		return int64(n)
+	case float64:
+		return int64(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	}
+	return 0
+}
@@ -0,0 +1,221 @@
+// Package pagination implements keyset (cursor) pagination for list
+// endpoints. A request names one sort field from an allow-list; rows are
+// ordered by that field and then by id, and the cursor carries both values of
+// the last row returned so the next page starts right after it.
+package pagination
+
+import (
+	"booking-backend/apierror"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 50
+	MaxLimit     = 200
+)
+
+type Kind int
+
+const (
+	String Kind = iota
+	Int
+	Time
+	// Date is a DATE column. Drivers scan it as a timestamp, so cursor values
+	// are cut to YYYY-MM-DD to compare correctly with the column.
+	Date
+)
+
+// Field is a sortable column. Column is the SQL expression to order by and
+// IDColumn the matching primary key, which breaks ties.
+type Field struct {
+	Column   string
+	IDColumn string
+	Kind     Kind
+}
+
+// Fields is the allow-list of sort fields for one endpoint, keyed by the name
+// clients use in ?sort=.
+type Fields map[string]Field
+
+func (f Fields) names() []string {
+	names := make([]string, 0, len(f))
+	for name := range f {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Params is a parsed page request.
+type Params struct {
+	Limit int
+	Sort  string
+	Field Field
+	Desc  bool
+	After *Cursor
+}
+
+// Cursor points at the last row of the previous page.
+type Cursor struct {
+	Sort  string          `json:"s"`
+	Value json.RawMessage `json:"v"`
+	ID    uint            `json:"id"`
+}
+
+// Parse reads limit, cursor and sort from query. sort is a field name from
+// fields, prefixed with "-" for descending order; defaultSort is used when it
+// is missing. A cursor is only valid with the sort it was issued for.
+func Parse(query url.Values, fields Fields, defaultSort string) (Params, error) {
+	p := Params{Limit: DefaultLimit}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > MaxLimit {
+			return p, apierror.Validation(apierror.Field("limit", apierror.OutOfRange, "min", "1", "max", strconv.Itoa(MaxLimit)))
+		}
+		p.Limit = limit
+	}
+
+	p.Sort = query.Get("sort")
+	if p.Sort == "" {
+		p.Sort = defaultSort
+	}
+	name := strings.TrimPrefix(p.Sort, "-")
+	p.Desc = name != p.Sort
+	field, ok := fields[name]
+	if !ok {
+		return p, apierror.Validation(apierror.Field("sort", apierror.NotAllowed, "allowed", strings.Join(sortedNames(fields), ", ")))
+	}
+	p.Field = field
+
+	if raw := query.Get("cursor"); raw != "" {
+		cursor, err := decodeCursor(raw)
+		if err != nil || cursor.Sort != p.Sort {
+			return p, apierror.Validation(apierror.Field("cursor", apierror.InvalidCursor))
+		}
+		if _, err := cursor.value(field.Kind); err != nil {
+			return p, apierror.Validation(apierror.Field("cursor", apierror.InvalidCursor))
+		}
+		p.After = cursor
+	}
+	return p, nil
+}
+
+func sortedNames(fields Fields) []string {
+	names := fields.names()
+	sort.Strings(names)
+	return names
+}
+
+func decodeCursor(raw string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, err
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+func (c Cursor) encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// value decodes the cursor's sort value into the Go type for kind.
+func (c Cursor) value(kind Kind) (interface{}, error) {
+	switch kind {
+	case Int:
+		var v int64
+		err := json.Unmarshal(c.Value, &v)
+		return v, err
+	case Time:
+		var v time.Time
+		err := json.Unmarshal(c.Value, &v)
+		return v, err
+	default:
+		var v string
+		err := json.Unmarshal(c.Value, &v)
+		return v, err
+	}
+}
+
+// Apply adds the cursor condition, ordering and limit to query. It fetches
+// one row more than the page size so Trim can tell whether there is a next
+// page.
+func (p Params) Apply(query *gorm.DB) *gorm.DB {
+	direction, op := "ASC", ">"
+	if p.Desc {
+		direction, op = "DESC", "<"
+	}
+	column, idColumn := p.Field.Column, p.Field.IDColumn
+	if p.After != nil {
+		value, _ := p.After.value(p.Field.Kind)
+		query = query.Where(
+			fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", column, op, column, idColumn, op),
+			value, value, p.After.ID,
+		)
+	}
+	return query.Order(column + " " + direction).Order(idColumn + " " + direction).Limit(p.Limit + 1)
+}
+
+// Info is the pagination block of a list response.
+type Info struct {
+	Limit      int    `json:"limit"`
+	Total      int64  `json:"total"`
+	Sort       string `json:"sort"`
+	HasMore    bool   `json:"has_more"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// Page is the envelope every list endpoint responds with.
+type Page[T any] struct {
+	Data       []T  `json:"data"`
+	Pagination Info `json:"pagination"`
+}
+
+// Key returns the sort value and id of a row, for building the next cursor.
+type Key[T any] func(row T, sort string) (interface{}, uint)
+
+// Trim cuts rows fetched by Apply down to the page size and describes the
+// page. total is the number of rows matching the filters, across all pages.
+func Trim[T any](rows []T, total int64, p Params, key Key[T]) ([]T, Info) {
+	info := Info{Limit: p.Limit, Total: total, Sort: p.Sort}
+	if rows == nil {
+		rows = []T{}
+	}
+	if len(rows) > p.Limit {
+		rows = rows[:p.Limit]
+		value, id := key(rows[len(rows)-1], strings.TrimPrefix(p.Sort, "-"))
+		if date, ok := value.(string); ok && p.Field.Kind == Date && len(date) > 10 {
+			value = date[:10]
+		}
+		raw, _ := json.Marshal(value)
+		info.HasMore = true
+		info.NextCursor = Cursor{Sort: p.Sort, Value: raw, ID: id}.encode()
+	}
+	return rows, info
+}
+
+// NewPage trims rows and wraps them in the response envelope.
+func NewPage[T any](rows []T, total int64, p Params, key Key[T]) Page[T] {
+	data, info := Trim(rows, total, p, key)
+	return Page[T]{Data: data, Pagination: info}
+}
+
+// First returns params for the first page of limit rows ordered by sort, for
+// callers inside the server that do not take paging input from a request.
+func First(fields Fields, sort string, limit int) Params {
+	name := strings.TrimPrefix(sort, "-")
+	return Params{Limit: limit, Sort: sort, Field: fields[name], Desc: name != sort}
+}
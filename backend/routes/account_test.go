@@ -0,0 +1,150 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var mailedLink = regexp.MustCompile(`https?://\S+\?token=\S+`)
+
+// mailedToken dispatches the outbox and returns the token in the link of the
+// last email sent to address.
+func mailedToken(env *apitest.Env, address string) string {
+	env.T.Helper()
+	env.Dispatch()
+	mail := env.Mail.Messages()
+	for i := len(mail) - 1; i >= 0; i-- {
+		if mail[i].To != address {
+			continue
+		}
+		link, err := url.Parse(mailedLink.FindString(mail[i].Text))
+		if err != nil || link.Query().Get("token") == "" {
+			env.T.Fatalf("no link in %q", mail[i].Text)
+		}
+		return link.Query().Get("token")
+	}
+	env.T.Fatalf("no mail to %s in %+v", address, mail)
+	return ""
+}
+
+func TestBookingRequiresVerifiedEmail(t *testing.T) {
+	env := apitest.New(t)
+	signup := map[string]string{"name": "Somchai", "email": "somchai@example.com", "phone": "0812345678", "password": "mango-sticky-rice"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/signup", signup, ""), http.StatusCreated, nil)
+	first := mailedToken(env, "somchai@example.com")
+
+	booking := map[string]interface{}{
+		"session_id": env.Fixtures.Session.ID, "name": "Somchai", "email": "somchai@example.com", "number_of_guests": 2,
+	}
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", booking, ""), http.StatusUnauthorized, nil)
+	token := env.Login("somchai@example.com", "mango-sticky-rice")
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", booking, token), http.StatusForbidden, &body)
+	if body.Code != apierror.EmailNotVerified {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	// Naming a verified account in the body does not book as its owner.
+	other := env.VerifiedUser("Malee", "malee@example.com")
+	booking["email"] = other.Email
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", booking, token), http.StatusForbidden, nil)
+
+	// A resent link replaces the one from the signup email.
+	env.Expect(env.Do(http.MethodPost, "/api/v1/email/verify/resend", map[string]string{"email": "somchai@example.com"}, ""), http.StatusAccepted, nil)
+	second := mailedToken(env, "somchai@example.com")
+	env.Expect(env.Do(http.MethodPost, "/api/v1/email/verify", map[string]string{"token": first}, ""), http.StatusBadRequest, &body)
+	if body.Code != apierror.InvalidVerifyToken {
+		t.Fatalf("unexpected error %+v", body)
+	}
+
+	env.Expect(env.Do(http.MethodPost, "/api/v1/email/verify", map[string]string{"token": second}, ""), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/email/verify", map[string]string{"token": second}, ""), http.StatusBadRequest, nil)
+	var created struct {
+		Booking models.Booking `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", booking, token), http.StatusCreated, &created)
+	if created.Booking.UserEmail != "somchai@example.com" || created.Booking.UserID == nil || *created.Booking.UserID == other.ID {
+		t.Fatalf("the booking should belong to the caller, got %+v", created.Booking)
+	}
+
+	before := len(env.Mail.Messages())
+	env.Expect(env.Do(http.MethodPost, "/api/v1/email/verify/resend", map[string]string{"email": "somchai@example.com"}, ""), http.StatusAccepted, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/email/verify/resend", map[string]string{"email": "nobody@example.com"}, ""), http.StatusAccepted, nil)
+	if env.Dispatch(); len(env.Mail.Messages()) != before+1 {
+		t.Fatalf("only the booking confirmation should have been sent, got %+v", env.Mail.Messages()[before:])
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	env := apitest.New(t)
+	admin := env.Fixtures.Admin
+	session := login(env, admin.Email, apitest.AdminPassword)
+	if rec := env.Do(http.MethodGet, "/api/v1/admin/outbox", nil, session.AccessToken); rec.Body.String() != "[]" {
+		t.Fatalf("expected an empty list of dead messages, got %s", rec.Body.String())
+	}
+
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/forgot", map[string]string{"email": "nobody@example.com"}, ""), http.StatusAccepted, nil)
+	if env.Dispatch(); len(env.Mail.Messages()) != 0 {
+		t.Fatal("unknown emails must not get a reset link")
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/forgot", map[string]string{"email": admin.Email}, ""), http.StatusAccepted, nil)
+	token := mailedToken(env, admin.Email)
+
+	// The sent email, and its link, are not kept in the outbox.
+	rec := env.Do(http.MethodGet, "/api/v1/admin/outbox?status=all", nil, session.AccessToken)
+	env.Expect(rec, http.StatusOK, nil)
+	if strings.Contains(rec.Body.String(), token) || strings.Contains(rec.Body.String(), "payload") {
+		t.Fatalf("the outbox listing shows message contents: %s", rec.Body.String())
+	}
+	var kept int64
+	env.Must(env.DB.Model(&models.OutboxMessage{}).Where("payload LIKE ?", "%"+token+"%").Count(&kept).Error)
+	if kept != 0 {
+		t.Fatalf("the reset link is still stored in %d outbox messages", kept)
+	}
+
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/reset", map[string]string{"token": "forged", "password": "new-password"}, ""), http.StatusBadRequest, &body)
+	if body.Code != apierror.InvalidResetToken {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/reset", map[string]string{"token": token, "password": "new-password"}, ""), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/reset", map[string]string{"token": token, "password": "other-password"}, ""), http.StatusBadRequest, nil)
+
+	// Existing sessions end with the old password.
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, session.AccessToken), http.StatusUnauthorized, nil)
+	refresh(env, session.RefreshToken, http.StatusUnauthorized)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": admin.Email, "password": apitest.AdminPassword}, ""), http.StatusUnauthorized, nil)
+	login(env, admin.Email, "new-password")
+
+	env.Dispatch()
+	if mail := env.Mail.Messages(); mail[len(mail)-1].Subject != "รหัสผ่านของคุณถูกเปลี่ยนแล้ว" {
+		t.Fatalf("expected a password changed notice, got %+v", mail[len(mail)-1])
+	}
+}
+
+// A reset link proves the email like a verification link does, so it claims
+// an unverified account: a second factor set up by whoever registered the
+// email first is removed.
+func TestPasswordResetClaimsUnverifiedAccounts(t *testing.T) {
+	env := apitest.New(t)
+	dao, _ := signUp(env, "Dao", "dao@example.com")
+	env.Must(env.DB.Model(&models.User{}).Where("id = ?", dao.ID).
+		Updates(map[string]interface{}{"two_factor_secret": "JBSWY3DPEHPK3PXP", "two_factor_enabled_at": time.Now()}).Error)
+
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/forgot", map[string]string{"email": "dao@example.com"}, ""), http.StatusAccepted, nil)
+	token := mailedToken(env, "dao@example.com")
+	env.Expect(env.Do(http.MethodPost, "/api/v1/password/reset", map[string]string{"token": token, "password": "tom-yum-goong"}, ""), http.StatusOK, nil)
+
+	var claimed models.User
+	env.Must(env.DB.First(&claimed, dao.ID).Error)
+	if claimed.EmailVerifiedAt == nil || claimed.TwoFactorEnabledAt != nil || claimed.TwoFactorSecret != "" {
+		t.Fatalf("expected a verified account without two-factor, got %+v", claimed)
+	}
+	login(env, "dao@example.com", "tom-yum-goong")
+}
@@ -0,0 +1,118 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPartnersBookWithAPIKeys(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+	admin := env.AdminToken()
+	keys := fmt.Sprintf("/api/v1/restaurants/%d/api-keys", f.Restaurant.ID)
+
+	var other models.Restaurant
+	env.Expect(env.Do(http.MethodPost, "/api/v1/restaurants", map[string]string{"name": "Baan Rim Nam", "location": "Chiang Mai"}, admin), http.StatusCreated, &other)
+	otherSession := models.Session{RestaurantID: other.ID, TimeSlotID: f.Lunch.ID, Name: "Sunday lunch", Date: "2030-01-06", MaxGuests: 6, AvailableSlots: 6, IsAvailable: true}
+	env.Must(env.DB.Create(&otherSession).Error)
+
+	manager, managerToken := signUp(env, "Pim", "pim@example.com")
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/restaurants/%d/staff/%d", f.Restaurant.ID, manager.ID), map[string]string{"role": "restaurant_manager"}, admin), http.StatusOK, nil)
+	_, customer := signUp(env, "Dao", "dao@example.com")
+
+	concierge := map[string]interface{}{"name": "Hotel concierge", "scopes": []string{"bookings:create", "sessions:read", "bookings:create"}}
+	env.Expect(env.Do(http.MethodPost, keys, concierge, customer), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPost, keys, map[string]interface{}{"name": "POS", "scopes": []string{"everything"}}, managerToken), http.StatusBadRequest, nil)
+	var issued services.IssuedAPIKey
+	env.Expect(env.Do(http.MethodPost, keys, concierge, managerToken), http.StatusCreated, &issued)
+	if !strings.HasPrefix(issued.Key, services.APIKeyPrefix) || !strings.HasPrefix(issued.Key, issued.Prefix) ||
+		strings.Join(issued.Scopes, " ") != "sessions:read bookings:create" {
+		t.Fatalf("unexpected key %+v", issued)
+	}
+
+	var sessions pagination.Page[services.SessionDetails]
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, issued.Key), http.StatusOK, &sessions)
+	if len(sessions.Data) != 1 || sessions.Data[0].ID != f.Session.ID {
+		t.Fatalf("a key should only see its restaurant, got %+v", sessions.Data)
+	}
+	env.Expect(env.Do(http.MethodGet, fmt.Sprintf("/api/v1/sessions?restaurant_id=%d", other.ID), nil, issued.Key), http.StatusForbidden, nil)
+
+	// Hotel guests need no account; the partner vouches for them.
+	guest := map[string]interface{}{"session_id": f.Session.ID, "name": "Hotel guest", "email": "room-204@example.com", "number_of_guests": 2}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", guest, ""), http.StatusUnauthorized, nil)
+	var created struct {
+		Booking models.Booking `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", guest, issued.Key), http.StatusCreated, &created)
+	// Nor are partner bookings attached to an account that shares the email.
+	guest["email"] = "dao@example.com"
+	var unlinked struct {
+		Booking models.Booking `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", guest, issued.Key), http.StatusCreated, &unlinked)
+	if unlinked.Booking.UserID != nil {
+		t.Fatalf("partner bookings should not be linked to accounts, got %+v", unlinked.Booking)
+	}
+	guest["session_id"] = otherSession.ID
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", guest, issued.Key), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, issued.Key), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, issued.Key), http.StatusUnauthorized, nil)
+
+	var pos services.IssuedAPIKey
+	env.Expect(env.Do(http.MethodPost, keys, map[string]interface{}{"name": "POS", "scopes": []string{"bookings:manage"}}, managerToken), http.StatusCreated, &pos)
+	var bookings pagination.Page[services.BookingWithRestaurant]
+	env.Expect(env.Do(http.MethodGet, fmt.Sprintf("/api/v1/bookings?sort=id&email=%s", created.Booking.UserEmail), nil, pos.Key), http.StatusOK, &bookings)
+	if len(bookings.Data) != 1 || bookings.Data[0].ID != created.Booking.ID {
+		t.Fatalf("unexpected bookings %+v", bookings.Data)
+	}
+	env.Expect(env.Do(http.MethodPost, fmt.Sprintf("/api/v1/bookings/%d/check-in", created.Booking.ID), nil, pos.Key), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, pos.Key), http.StatusForbidden, nil)
+
+	var listed []services.APIKeyView
+	env.Expect(env.Do(http.MethodGet, keys, nil, managerToken), http.StatusOK, &listed)
+	if len(listed) != 2 || listed[0].LastUsedAt == nil || listed[0].Prefix != issued.Prefix {
+		t.Fatalf("unexpected keys %+v", listed)
+	}
+
+	// After a rotation the old key works until the grace period ends.
+	var rotated services.IssuedAPIKey
+	env.Expect(env.Do(http.MethodPost, fmt.Sprintf("%s/%d/rotate", keys, issued.ID), nil, managerToken), http.StatusOK, &rotated)
+	if rotated.ID != issued.ID || rotated.Key == issued.Key || rotated.RotatedAt == nil ||
+		rotated.PreviousExpiresAt == nil || rotated.PreviousExpiresAt.Sub(*rotated.RotatedAt) != services.DefaultAPIKeyRotationGrace {
+		t.Fatalf("unexpected rotation %+v", rotated)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, issued.Key), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, rotated.Key), http.StatusOK, nil)
+	var audit models.AuditEvent
+	env.Must(env.DB.Where("type = ?", services.AuditAPIKeyRotated).First(&audit).Error)
+	if !strings.Contains(audit.Details, "previous key valid until") {
+		t.Fatalf("the grace period should be audited, got %q", audit.Details)
+	}
+	env.Must(env.DB.Model(&models.APIKey{}).Where("id = ?", issued.ID).Update("previous_expires_at", time.Now().Add(-time.Minute)).Error)
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, issued.Key), http.StatusUnauthorized, &body)
+	if body.Code != apierror.InvalidAPIKey {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	// Rotating again retires the key replaced first, whatever its grace.
+	var again services.IssuedAPIKey
+	env.Expect(env.Do(http.MethodPost, fmt.Sprintf("%s/%d/rotate", keys, issued.ID), nil, managerToken), http.StatusOK, &again)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, issued.Key), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, rotated.Key), http.StatusOK, nil)
+	rotated = again
+	env.Expect(env.Do(http.MethodDelete, fmt.Sprintf("/api/v1/restaurants/%d/api-keys/%d", other.ID, issued.ID), nil, admin), http.StatusNotFound, nil)
+	env.Expect(env.Do(http.MethodDelete, fmt.Sprintf("%s/%d", keys, issued.ID), nil, managerToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/sessions", nil, rotated.Key), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, keys, nil, managerToken), http.StatusOK, &listed)
+	if len(listed) != 1 || listed[0].ID != pos.ID {
+		t.Fatalf("revoked keys should not be listed, got %+v", listed)
+	}
+}
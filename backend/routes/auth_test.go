@@ -0,0 +1,116 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func login(env *apitest.Env, email, password string) services.Tokens {
+	env.T.Helper()
+	var tokens services.Tokens
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": email, "password": password}, ""), http.StatusOK, &tokens)
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		env.T.Fatalf("expected both tokens, got %+v", tokens)
+	}
+	return tokens
+}
+
+func refresh(env *apitest.Env, token string, status int) services.Tokens {
+	env.T.Helper()
+	var tokens services.Tokens
+	env.Expect(env.Do(http.MethodPost, "/api/v1/token/refresh", map[string]string{"refresh_token": token}, ""), status, &tokens)
+	return tokens
+}
+
+func TestRefreshTokensRotateAndDetectReuse(t *testing.T) {
+	env := apitest.New(t)
+	first := login(env, env.Fixtures.Admin.Email, apitest.AdminPassword)
+
+	second := refresh(env, first.RefreshToken, http.StatusOK)
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh should rotate the refresh token")
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, second.AccessToken), http.StatusOK, nil)
+
+	// Replaying the rotated token revokes the whole login, including the
+	// token issued in its place.
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/token/refresh", map[string]string{"refresh_token": first.RefreshToken}, ""), http.StatusUnauthorized, &body)
+	if body.Code != apierror.InvalidRefreshToken {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	refresh(env, second.RefreshToken, http.StatusUnauthorized)
+
+	other := login(env, env.Fixtures.Admin.Email, apitest.AdminPassword)
+	refresh(env, other.RefreshToken, http.StatusOK)
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	env := apitest.New(t)
+	tokens := login(env, env.Fixtures.Admin.Email, apitest.AdminPassword)
+
+	env.Expect(env.Do(http.MethodPost, "/api/v1/logout", map[string]string{"refresh_token": tokens.RefreshToken}, ""), http.StatusOK, nil)
+	refresh(env, tokens.RefreshToken, http.StatusUnauthorized)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/logout", map[string]string{"refresh_token": "unknown"}, ""), http.StatusOK, nil)
+}
+
+func TestRevokeAllSessions(t *testing.T) {
+	env := apitest.New(t)
+	signup := map[string]string{"name": "Somchai", "email": "somchai@example.com", "phone": "0812345678", "password": "mango-sticky-rice"}
+	var created struct {
+		User struct {
+			ID uint `json:"id"`
+		} `json:"user"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/signup", signup, ""), http.StatusCreated, &created)
+
+	laptop := login(env, "somchai@example.com", "mango-sticky-rice")
+	phone := login(env, "somchai@example.com", "mango-sticky-rice")
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/revoke-sessions", nil, laptop.AccessToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, phone.AccessToken), http.StatusUnauthorized, nil)
+	refresh(env, phone.RefreshToken, http.StatusUnauthorized)
+
+	again := login(env, "somchai@example.com", "mango-sticky-rice")
+	path := fmt.Sprintf("/api/v1/users/%d/revoke-sessions", created.User.ID)
+	env.Expect(env.Do(http.MethodPost, path, nil, again.AccessToken), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPost, path, nil, env.AdminToken()), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, again.AccessToken), http.StatusUnauthorized, nil)
+}
+
+func TestRepeatedFailedLoginsAreThrottled(t *testing.T) {
+	env := apitest.New(t)
+	admin := env.Fixtures.Admin
+	wrong := map[string]string{"email": "target@example.com", "password": "guess"}
+	for i := 0; i < services.AccountThrottle.FreeAttempts+1; i++ {
+		env.Expect(env.Do(http.MethodPost, "/api/v1/login", wrong, ""), http.StatusUnauthorized, nil)
+	}
+	rec := env.Do(http.MethodPost, "/api/v1/login", wrong, "")
+	var body apierror.Body
+	env.Expect(rec, http.StatusTooManyRequests, &body)
+	if body.Code != apierror.LoginThrottled || rec.Header().Get("Retry-After") != "1" {
+		t.Fatalf("unexpected throttle response %+v %v", body, rec.Header())
+	}
+	// Other accounts are unaffected.
+	login(env, admin.Email, apitest.AdminPassword)
+
+	var events []models.AuditEvent
+	env.Expect(env.Do(http.MethodGet, "/api/v1/admin/audit-events?type=login.locked", nil, env.AdminToken()), http.StatusOK, &events)
+	if len(events) != 0 {
+		t.Fatalf("no lockout yet, got %+v", events)
+	}
+}
+
+func TestSignupEnforcesPasswordPolicy(t *testing.T) {
+	env := apitest.New(t)
+	signup := map[string]string{"name": "Somchai", "email": "somchai@example.com", "phone": "0812345678", "password": "password123"}
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/signup", signup, ""), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Code != apierror.PasswordBreached {
+		t.Fatalf("unexpected error %+v", body)
+	}
+}
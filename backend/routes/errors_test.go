@@ -0,0 +1,82 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func doLocalized(env *apitest.Env, method, path, body, language string) apierror.Body {
+	return doLocalizedAs(env, method, path, body, language, "")
+}
+
+func doLocalizedAs(env *apitest.Env, method, path, body, language, token string) apierror.Body {
+	env.T.Helper()
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	if language != "" {
+		req.Header.Set("Accept-Language", language)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	env.Router.ServeHTTP(rec, req)
+	var out apierror.Body
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		env.T.Fatalf("%s %s: %v (%s)", method, path, err, rec.Body.String())
+	}
+	return out
+}
+
+func TestValidationErrorsListEveryField(t *testing.T) {
+	env := apitest.New(t)
+
+	body := doLocalized(env, http.MethodGet, "/api/sessions?limit=0&date_from=tomorrow&has_availability=maybe", "", "en-US")
+	if body.Code != apierror.ValidationFailed || len(body.Details) != 3 {
+		t.Fatalf("unexpected body %+v", body)
+	}
+	codes := map[string]apierror.Code{}
+	for _, d := range body.Details {
+		codes[d.Field] = d.Code
+	}
+	if codes["has_availability"] != apierror.InvalidBool || codes["date_from"] != apierror.InvalidDate || codes["limit"] != apierror.OutOfRange {
+		t.Fatalf("unexpected details %+v", body.Details)
+	}
+	if body.Error != body.Details[0].Message || body.Details[2].Message != "limit must be between 1 and 200" {
+		t.Fatalf("unexpected messages %+v", body)
+	}
+
+	guest := env.Token(env.VerifiedUser("Guest", "guest@example.com"))
+	body = doLocalizedAs(env, http.MethodPost, "/api/bookings", `{"session_id": "one"}`, "en", guest)
+	if body.Code != apierror.ValidationFailed || len(body.Details) != 1 || body.Details[0].Field != "session_id" {
+		t.Fatalf("unexpected binding error %+v", body)
+	}
+	body = doLocalizedAs(env, http.MethodPost, "/api/bookings", `{`, "en", guest)
+	if body.Code != apierror.InvalidJSON {
+		t.Fatalf("expected invalid_json, got %+v", body)
+	}
+}
+
+func TestErrorMessagesFollowAcceptLanguage(t *testing.T) {
+	env := apitest.New(t)
+
+	admin := env.AdminToken()
+	en := doLocalizedAs(env, http.MethodPut, "/api/sessions/999999", `{"max_guests": 4}`, "en", admin)
+	th := doLocalizedAs(env, http.MethodPut, "/api/sessions/999999", `{"max_guests": 4}`, "", admin)
+	if en.Code != apierror.SessionNotFound || th.Code != en.Code {
+		t.Fatalf("unexpected codes %q and %q", en.Code, th.Code)
+	}
+	if en.Error != "Session not found" || th.Error != "ไม่พบรอบการจอง" {
+		t.Fatalf("unexpected messages %q and %q", en.Error, th.Error)
+	}
+
+	body := doLocalized(env, http.MethodGet, "/api/user", "", "th-TH,th;q=0.9")
+	if body.Code != apierror.AuthRequired || body.Error != "กรุณาเข้าสู่ระบบ" {
+		t.Fatalf("unexpected auth error %+v", body)
+	}
+}
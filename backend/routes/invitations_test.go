@@ -0,0 +1,115 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestStaffInvitations(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+	admin := env.AdminToken()
+	invitations := fmt.Sprintf("/api/v1/restaurants/%d/invitations", f.Restaurant.ID)
+
+	manager, managerToken := signUp(env, "Pim", "pim@example.com")
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/restaurants/%d/staff/%d", f.Restaurant.ID, manager.ID), map[string]string{"role": "restaurant_manager"}, admin), http.StatusOK, nil)
+	_, customer := signUp(env, "Dao", "dao@example.com")
+
+	invite := map[string]string{"email": "chai@example.com", "role": "staff"}
+	env.Expect(env.Do(http.MethodPost, invitations, invite, customer), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPost, invitations, map[string]string{"email": "chai@example.com", "role": "restaurant_manager"}, managerToken), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPost, invitations, map[string]string{"email": "pim@example.com", "role": "staff"}, managerToken), http.StatusConflict, nil)
+	env.Expect(env.Do(http.MethodPost, invitations, invite, managerToken), http.StatusCreated, nil)
+	first := mailedToken(env, "chai@example.com")
+	var invitation models.StaffInvitation
+	env.Expect(env.Do(http.MethodPost, invitations, invite, managerToken), http.StatusCreated, &invitation)
+	second := mailedToken(env, "chai@example.com")
+	if invitation.Email != "chai@example.com" || invitation.Role != services.RoleStaff || first == second {
+		t.Fatalf("unexpected invitation %+v", invitation)
+	}
+	var pending []models.StaffInvitation
+	env.Expect(env.Do(http.MethodGet, invitations, nil, managerToken), http.StatusOK, &pending)
+	if len(pending) != 1 || pending[0].ID != invitation.ID {
+		t.Fatalf("the second invitation should replace the first, got %+v", pending)
+	}
+
+	var body apierror.Body
+	accept := map[string]string{"token": first, "name": "Chai", "password": "green-curry-paste"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", accept, ""), http.StatusBadRequest, &body)
+	if body.Code != apierror.InvalidInvitation {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	accept["token"], accept["password"] = second, ""
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", accept, ""), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Field != "password" {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	accept["password"] = "green-curry-paste"
+	var accepted struct {
+		User services.UserView `json:"user"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", accept, ""), http.StatusOK, &accepted)
+	if accepted.User.Role != services.RoleStaff || accepted.User.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected user %+v", accepted.User)
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", accept, ""), http.StatusBadRequest, nil)
+	staffToken := env.Login("chai@example.com", "green-curry-paste")
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, staffToken), http.StatusOK, nil)
+
+	// Revoked invitations cannot be accepted, and an existing account whose
+	// email was never verified is claimed with a new password, signing out
+	// whoever registered it.
+	env.Expect(env.Do(http.MethodPost, invitations, map[string]string{"email": "dao@example.com", "role": "staff"}, managerToken), http.StatusCreated, &invitation)
+	revoked := mailedToken(env, "dao@example.com")
+	path := fmt.Sprintf("%s/%d", invitations, invitation.ID)
+	env.Expect(env.Do(http.MethodDelete, path, nil, managerToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodDelete, path, nil, managerToken), http.StatusNotFound, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", map[string]string{"token": revoked}, ""), http.StatusBadRequest, nil)
+	env.Expect(env.Do(http.MethodPost, invitations, map[string]string{"email": "dao@example.com", "role": "staff"}, managerToken), http.StatusCreated, nil)
+	claim := map[string]string{"token": mailedToken(env, "dao@example.com")}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", claim, ""), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Field != "password" {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	claim["password"] = "tom-yum-goong"
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", claim, ""), http.StatusOK, &accepted)
+	if accepted.User.Role != services.RoleStaff || accepted.User.EmailVerifiedAt == nil {
+		t.Fatalf("unexpected user %+v", accepted.User)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, customer), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": "dao@example.com", "password": "mango-sticky-rice"}, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, env.Login("dao@example.com", "tom-yum-goong")), http.StatusOK, nil)
+
+	// Verified accounts join without a new password.
+	somchai := env.VerifiedUser("Somchai", "somchai@example.com")
+	env.Expect(env.Do(http.MethodPost, invitations, map[string]string{"email": "somchai@example.com", "role": "staff"}, managerToken), http.StatusCreated, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", map[string]string{"token": mailedToken(env, "somchai@example.com")}, ""), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, env.Token(somchai)), http.StatusOK, nil)
+
+	var members []services.StaffMember
+	env.Expect(env.Do(http.MethodGet, fmt.Sprintf("/api/v1/restaurants/%d/staff", f.Restaurant.ID), nil, managerToken), http.StatusOK, &members)
+	if len(members) != 5 {
+		t.Fatalf("expected admin, manager and three staff, got %+v", members)
+	}
+	env.Expect(env.Do(http.MethodGet, invitations, nil, managerToken), http.StatusOK, &pending)
+	if len(pending) != 0 {
+		t.Fatalf("expected no pending invitations, got %+v", pending)
+	}
+}
+
+func TestExpiredInvitationsCannotBeAccepted(t *testing.T) {
+	env := apitest.New(t)
+	invitations := fmt.Sprintf("/api/v1/restaurants/%d/invitations", env.Fixtures.Restaurant.ID)
+	env.Expect(env.Do(http.MethodPost, invitations, map[string]string{"email": "chai@example.com", "role": "staff"}, env.AdminToken()), http.StatusCreated, nil)
+	token := mailedToken(env, "chai@example.com")
+	env.Must(env.DB.Exec("UPDATE staff_invitations SET expires_at = ?", time.Now().Add(-time.Minute)).Error)
+
+	accept := map[string]string{"token": token, "name": "Chai", "password": "green-curry-paste"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/invitations/accept", accept, ""), http.StatusBadRequest, nil)
+}
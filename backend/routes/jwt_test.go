@@ -0,0 +1,163 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/auth"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func tokenKeyID(t *testing.T, raw string) string {
+	t.Helper()
+	token, _, err := jwt.NewParser().ParseUnverified(raw, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	kid, _ := token.Header["kid"].(string)
+	return kid
+}
+
+func TestAccessTokensPinAlgorithmAndClaims(t *testing.T) {
+	env := apitest.New(t)
+	kid := tokenKeyID(t, login(env, env.Fixtures.Admin.Email, apitest.AdminPassword).AccessToken)
+	now := time.Now()
+	valid := jwt.MapClaims{
+		"user_id": env.Fixtures.Admin.ID, "email": env.Fixtures.Admin.Email, "ver": 0,
+		"iss": auth.DefaultIssuer, "aud": auth.DefaultAudience,
+		"iat": now.Unix(), "nbf": now.Unix(), "exp": now.Add(time.Minute).Unix(),
+	}
+	with := func(key string, value interface{}) jwt.MapClaims {
+		claims := jwt.MapClaims{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		if value == nil {
+			delete(claims, key)
+		} else {
+			claims[key] = value
+		}
+		return claims
+	}
+	sign := func(method jwt.SigningMethod, kid string, claims jwt.MapClaims, key interface{}) string {
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		raw, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return raw
+	}
+	secret := []byte(apitest.TestSecret)
+
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, sign(jwt.SigningMethodHS256, kid, valid, secret)), http.StatusOK, nil)
+
+	rejected := map[string]string{
+		"alg none":        sign(jwt.SigningMethodNone, kid, valid, jwt.UnsafeAllowNoneSignatureType),
+		"other hmac alg":  sign(jwt.SigningMethodHS512, kid, valid, secret),
+		"missing kid":     sign(jwt.SigningMethodHS256, "", valid, secret),
+		"unknown kid":     sign(jwt.SigningMethodHS256, "retired", valid, secret),
+		"wrong secret":    sign(jwt.SigningMethodHS256, kid, valid, []byte(strings.Repeat("0123456789", 4))),
+		"wrong issuer":    sign(jwt.SigningMethodHS256, kid, with("iss", "someone-else"), secret),
+		"wrong audience":  sign(jwt.SigningMethodHS256, kid, with("aud", "other-api"), secret),
+		"no audience":     sign(jwt.SigningMethodHS256, kid, with("aud", nil), secret),
+		"not yet valid":   sign(jwt.SigningMethodHS256, kid, with("nbf", now.Add(time.Hour).Unix()), secret),
+		"missing nbf":     sign(jwt.SigningMethodHS256, kid, with("nbf", nil), secret),
+		"expired":         sign(jwt.SigningMethodHS256, kid, with("exp", now.Add(-time.Hour).Unix()), secret),
+		"missing expires": sign(jwt.SigningMethodHS256, kid, with("exp", nil), secret),
+	}
+	for name, token := range rejected {
+		if rec := env.Do(http.MethodGet, "/api/v1/user", nil, token); rec.Code != http.StatusUnauthorized {
+			t.Errorf("%s: got %d, want 401", name, rec.Code)
+		}
+	}
+}
+
+func TestKeyRotationKeepsEarlierTokensValid(t *testing.T) {
+	env := apitest.New(t)
+	old := login(env, env.Fixtures.Admin.Email, apitest.AdminPassword)
+	oldKid := tokenKeyID(t, old.AccessToken)
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, err := auth.PrivateKey("", private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	previous, err := auth.HMACKey(oldKid, []byte(apitest.TestSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := auth.NewKeySet(auth.DefaultIssuer, auth.DefaultAudience, next, previous)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Use(rotated)
+
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, old.AccessToken), http.StatusOK, nil)
+	fresh := login(env, env.Fixtures.Admin.Email, apitest.AdminPassword)
+	if kid := tokenKeyID(t, fresh.AccessToken); kid != next.ID {
+		t.Fatalf("new tokens should be signed by the new key, got kid %q", kid)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, fresh.AccessToken), http.StatusOK, nil)
+
+	// An HS256 token keyed with the public key must not pass as EdDSA.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"user_id": env.Fixtures.Admin.ID})
+	forged.Header["kid"] = next.ID
+	raw, _ := forged.SignedString([]byte(public))
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, raw), http.StatusUnauthorized, nil)
+
+	var jwks auth.JWKSet
+	env.Expect(env.Do(http.MethodGet, "/.well-known/jwks.json", nil, ""), http.StatusOK, &jwks)
+	if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != next.ID || jwks.Keys[0].KeyType != "OKP" || jwks.Keys[0].X == "" {
+		t.Fatalf("the JWKS should publish only the public Ed25519 key, got %+v", jwks)
+	}
+
+	retired, err := auth.NewKeySet(auth.DefaultIssuer, auth.DefaultAudience, next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth.Use(retired)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, old.AccessToken), http.StatusUnauthorized, nil)
+}
+
+func TestJWKSIsEmptyForSharedSecrets(t *testing.T) {
+	env := apitest.New(t)
+	var jwks auth.JWKSet
+	env.Expect(env.Do(http.MethodGet, "/.well-known/jwks.json", nil, ""), http.StatusOK, &jwks)
+	if jwks.Keys == nil || len(jwks.Keys) != 0 {
+		t.Fatalf("HMAC secrets must not be published, got %+v", jwks)
+	}
+}
+
+func TestLoadKeySetRejectsWeakConfiguration(t *testing.T) {
+	cases := map[string]map[string]string{
+		"missing secret":    {},
+		"short secret":      {"JWT_SECRET": "changeme"},
+		"repetitive secret": {"JWT_SECRET": strings.Repeat("ab", 32)},
+		"unknown algorithm": {"JWT_SECRET": apitest.TestSecret, "JWT_ALGORITHM": "none"},
+		"missing key file":  {"JWT_ALGORITHM": "EdDSA"},
+		"weak previous key": {"JWT_SECRET": apitest.TestSecret, "JWT_PREVIOUS_KEYS": "old=short"},
+		"duplicate key id":  {"JWT_SECRET": apitest.TestSecret, "JWT_KEY_ID": "k", "JWT_PREVIOUS_KEYS": "k=" + apitest.TestSecret},
+	}
+	for name, env := range cases {
+		if _, err := auth.LoadKeySet(func(key string) string { return env[key] }); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	keys, err := auth.LoadKeySet(func(key string) string {
+		return map[string]string{"JWT_SECRET": apitest.TestSecret, "JWT_ISSUER": "iss", "JWT_AUDIENCE": "aud"}[key]
+	})
+	if err != nil || keys.Issuer != "iss" || keys.Audience != "aud" {
+		t.Fatalf("unexpected key set %+v, %v", keys, err)
+	}
+}
@@ -0,0 +1,153 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/pagination"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+type listPage struct {
+	Data []struct {
+		ID           uint   `json:"id"`
+		Date         string `json:"date"`
+		RestaurantID uint   `json:"restaurant_id"`
+	} `json:"data"`
+	Pagination pagination.Info `json:"pagination"`
+}
+
+// walk follows next cursors from path and returns the ids in the order they
+// were listed.
+func walk(env *apitest.Env, path, token string, query url.Values) ([]uint, int64) {
+	env.T.Helper()
+	var ids []uint
+	var total int64
+	for pages := 0; ; pages++ {
+		if pages > 50 {
+			env.T.Fatal("pagination did not terminate")
+		}
+		var page listPage
+		env.Expect(env.Do(http.MethodGet, path+"?"+query.Encode(), nil, token), http.StatusOK, &page)
+		for _, row := range page.Data {
+			ids = append(ids, row.ID)
+		}
+		total = page.Pagination.Total
+		if !page.Pagination.HasMore {
+			return ids, total
+		}
+		query.Set("cursor", page.Pagination.NextCursor)
+	}
+}
+
+func TestCursorPaginationVisitsEveryRowOnce(t *testing.T) {
+	env := apitest.New(t)
+	seedSessions(env, 12)
+
+	for _, sort := range []string{"-created_at", "date", "-available_slots", "name"} {
+		ids, total := walk(env, "/api/sessions", "", url.Values{"limit": {"5"}, "sort": {sort}})
+		if total != 13 || len(ids) != 13 {
+			t.Fatalf("sort %s: expected 13 sessions, got %d of total %d", sort, len(ids), total)
+		}
+		seen := map[uint]bool{}
+		for _, id := range ids {
+			if seen[id] {
+				t.Fatalf("sort %s: session %d listed twice", sort, id)
+			}
+			seen[id] = true
+		}
+	}
+
+	ids, total := walk(env, "/api/bookings", env.AdminToken(), url.Values{"limit": {"7"}, "sort": {"date"}})
+	if total != 24 || len(ids) != 24 {
+		t.Fatalf("expected 24 bookings, got %d of total %d", len(ids), total)
+	}
+
+	// Names are nullable; rows without one sort as empty and are not lost.
+	env.Must(env.DB.Exec("UPDATE sessions SET name = NULL WHERE id % 3 = 0").Error)
+	env.Must(env.DB.Exec("UPDATE bookings SET user_name = NULL WHERE id % 3 = 0").Error)
+	for _, sort := range []string{"name", "-name"} {
+		if ids, _ := walk(env, "/api/sessions", "", url.Values{"limit": {"2"}, "sort": {sort}}); len(ids) != 13 {
+			t.Fatalf("sort %s: expected 13 sessions, got %v", sort, ids)
+		}
+	}
+	for _, sort := range []string{"user_name", "-user_name"} {
+		if ids, _ := walk(env, "/api/bookings", env.AdminToken(), url.Values{"limit": {"4"}, "sort": {sort}}); len(ids) != 24 {
+			t.Fatalf("sort %s: expected 24 bookings, got %v", sort, ids)
+		}
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	env := apitest.New(t)
+	seedSessions(env, 6)
+	f := env.Fixtures
+
+	var page listPage
+	query := url.Values{"date_from": {"2031-01-02"}, "date_to": {"2031-01-04"}, "sort": {"date"}}
+	env.Expect(env.Do(http.MethodGet, "/api/sessions?"+query.Encode(), nil, ""), http.StatusOK, &page)
+	if len(page.Data) != 3 || page.Data[0].Date[:10] != "2031-01-02" {
+		t.Fatalf("unexpected date range result %+v", page.Data)
+	}
+
+	query = url.Values{"restaurant_id": {fmt.Sprint(f.Restaurant.ID)}, "time_slot_id": {fmt.Sprint(f.Lunch.ID)}}
+	env.Expect(env.Do(http.MethodGet, "/api/sessions?"+query.Encode(), nil, ""), http.StatusOK, &page)
+	if page.Pagination.Total != 2 {
+		t.Fatalf("expected 2 lunch sessions at %s, got %d", f.Restaurant.Name, page.Pagination.Total)
+	}
+
+	env.Must(env.DB.Exec("UPDATE sessions SET available_slots = 0, is_available = false WHERE id = ?", f.Session.ID).Error)
+	env.Expect(env.Do(http.MethodGet, "/api/sessions?has_availability=false", nil, ""), http.StatusOK, &page)
+	if len(page.Data) != 1 || page.Data[0].ID != f.Session.ID {
+		t.Fatalf("expected only the full session, got %+v", page.Data)
+	}
+
+	env.Expect(env.Do(http.MethodGet, "/api/bookings?status=cancelled&email=GUEST@", nil, env.AdminToken()), http.StatusOK, &page)
+	if page.Pagination.Total != 6 {
+		t.Fatalf("expected 6 cancelled bookings, got %d", page.Pagination.Total)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/bookings?email=%25", nil, env.AdminToken()), http.StatusOK, &page)
+	if page.Pagination.Total != 0 {
+		t.Fatalf("expected %% to be matched literally, got %d bookings", page.Pagination.Total)
+	}
+
+	env.Expect(env.Do(http.MethodGet, "/api/users?email=admin", nil, env.AdminToken()), http.StatusOK, &page)
+	if page.Pagination.Total != 1 {
+		t.Fatalf("expected the admin user, got %d users", page.Pagination.Total)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/restaurants?name=branch&sort=-name", nil, ""), http.StatusOK, &page)
+	if page.Pagination.Total != 2 {
+		t.Fatalf("expected 2 branches, got %d", page.Pagination.Total)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/tables?status=active", nil, ""), http.StatusOK, &page)
+	if page.Data == nil || page.Pagination.Total != 0 {
+		t.Fatalf("expected an empty page of tables, got %+v", page)
+	}
+}
+
+func TestListRejectsInvalidParameters(t *testing.T) {
+	env := apitest.New(t)
+	admin := env.AdminToken()
+
+	for _, path := range []string{
+		"/api/sessions?sort=password",
+		"/api/sessions?limit=0",
+		"/api/sessions?limit=1000",
+		"/api/sessions?cursor=garbage",
+		"/api/sessions?date_from=tomorrow",
+		"/api/sessions?date_from=2031-02-01&date_to=2031-01-01",
+		"/api/sessions?has_availability=maybe",
+		"/api/bookings?status=pending",
+		"/api/users?sort=password",
+		"/api/tables?restaurant_id=abc",
+	} {
+		env.Expect(env.Do(http.MethodGet, path, nil, admin), http.StatusBadRequest, nil)
+	}
+
+	seedSessions(env, 3)
+	var page listPage
+	env.Expect(env.Do(http.MethodGet, "/api/sessions?limit=1&sort=date", nil, ""), http.StatusOK, &page)
+	mismatched := "/api/sessions?sort=name&cursor=" + page.Pagination.NextCursor
+	env.Expect(env.Do(http.MethodGet, mismatched, nil, ""), http.StatusBadRequest, nil)
+}
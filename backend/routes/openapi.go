@@ -0,0 +1,344 @@
+package routes
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/auth"
+	"booking-backend/controllers"
+	"booking-backend/models"
+	"booking-backend/openapi"
+	"booking-backend/pagination"
+	"booking-backend/services"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var apiInfo = openapi.Info{
+	Title:   "Restaurant Booking API",
+	Version: "1.0.0",
+	Description: "Routes are versioned under /api/v1 and /api/v2; the unversioned /api paths are deprecated aliases of v1. " +
+		"Errors share one shape: a localized message in error (Accept-Language th or en), a stable code, and per-field details for invalid input.",
+}
+
+// pageParams documents the cursor pagination parameters of a list endpoint.
+func pageParams(fields pagination.Fields, defaultSort string) []openapi.Param {
+	var sorts []string
+	for name := range fields {
+		sorts = append(sorts, name, "-"+name)
+	}
+	sort.Strings(sorts)
+	return []openapi.Param{
+		{Name: "limit", Type: "integer", Description: "Page size, 1 to " + strconv.Itoa(pagination.MaxLimit) + " (default " + strconv.Itoa(pagination.DefaultLimit) + ")"},
+		{Name: "sort", Enum: sorts, Description: "Sort field, - for descending (default " + defaultSort + ")"},
+		{Name: "cursor", Description: "next_cursor from the previous page"},
+	}
+}
+
+func dateRangeParams() []openapi.Param {
+	return []openapi.Param{
+		{Name: "date_from", Format: "date"},
+		{Name: "date_to", Format: "date"},
+	}
+}
+
+func params(groups ...[]openapi.Param) []openapi.Param {
+	var all []openapi.Param
+	for _, group := range groups {
+		all = append(all, group...)
+	}
+	return all
+}
+
+var streamParams = []openapi.Param{
+	{Name: "topics", Description: "Comma separated topics to subscribe to"},
+	{Name: "last_event_id", Type: "integer", Description: "Replay events after this id"},
+	{Name: "token", Description: "Bearer token, for clients that cannot set headers"},
+}
+
+// systemOperations and v1Operations document every route registered in
+// RegisterRoutes; the openapi test fails when a route is missing. Versioned
+// paths are relative to /api/<version>.
+var systemOperations = []openapi.Operation{
+	{Method: http.MethodGet, Path: "/", Tag: "system", Summary: "API banner", Response: openapi.Object{"message": ""}},
+	{Method: http.MethodGet, Path: "/health", Tag: "system", Summary: "Health check", Response: openapi.Object{"status": ""}},
+	{Method: http.MethodGet, Path: "/ws", Tag: "events", Summary: "Live events over a websocket",
+		Description: "Upgrades to a websocket and streams the same events as /api/v1/events.",
+		Query:       streamParams, Status: http.StatusSwitchingProtocols},
+	{Method: http.MethodGet, Path: "/.well-known/jwks.json", Tag: "auth", Summary: "Public keys that verify access tokens",
+		Description: "Empty when tokens are signed with a shared HS256 secret.", Response: auth.JWKSet{}},
+	{Method: http.MethodGet, Path: "/api/openapi.json", Tag: "system", Summary: "This OpenAPI document", Response: openapi.Object{}},
+	{Method: http.MethodGet, Path: "/api/docs", Tag: "system", Summary: "Interactive API documentation", Response: "", ContentType: "text/html"},
+}
+
+var v1Operations = []openapi.Operation{
+	{Method: http.MethodPost, Path: "/signup", Tag: "auth", Summary: "Register a customer account",
+		Body: services.SignupInput{}, Status: http.StatusCreated, Response: openapi.Object{"message": "", "user": services.UserView{}}},
+	{Method: http.MethodPost, Path: "/login", Tag: "auth", Summary: "Exchange credentials for tokens",
+		Description: "token is a short-lived access token; refresh_token gets a new pair from /token/refresh. " +
+			"Repeated failures for an email or from an address are answered with 429 login_throttled and Retry-After, growing to a 15 minute lockout. " +
+			"Accounts with two-factor authentication, and roles that require it, get a services.LoginChallenge instead of tokens; see /login/two-factor.",
+		Body: controllers.LoginInput{}, Response: controllers.LoginResponse{}},
+	{Method: http.MethodPost, Path: "/login/two-factor", Tag: "auth", Summary: "Finish a login with a two-factor code",
+		Description: "code is the current code from the authenticator app, or one of the recovery codes; each works once. " +
+			"When the challenge had setup_required, the code confirms the secret from /login/two-factor/setup and the response carries the recovery codes, shown only this once. " +
+			"Wrong codes answer 400 wrong_two_factor_code and are throttled per account like passwords.",
+		Body: controllers.TwoFactorLoginInput{}, Response: controllers.TwoFactorLoginResponse{}},
+	{Method: http.MethodPost, Path: "/login/two-factor/setup", Tag: "auth", Summary: "Create the authenticator secret of a user who must enroll to log in",
+		Description: "Only for challenges with setup_required. Add otpauth_url or secret to an authenticator app, then send a code to /login/two-factor.",
+		Body:        controllers.TwoFactorTokenInput{}, Response: services.TwoFactorSetup{}},
+	{Method: http.MethodPost, Path: "/token/refresh", Tag: "auth", Summary: "Rotate a refresh token",
+		Description: "The refresh token sent stops working. Sending an already rotated token revokes every token from that login. Users whose role requires two-factor authentication they have not enabled get 401 two_factor_required and must log in again.",
+		Body:        controllers.RefreshInput{}, Response: services.Tokens{}},
+	{Method: http.MethodPost, Path: "/logout", Tag: "auth", Summary: "Revoke a refresh token and those rotated from the same login",
+		Body: controllers.RefreshInput{}, Response: openapi.Object{"message": ""}},
+	{Method: http.MethodPost, Path: "/email/verify", Tag: "auth", Summary: "Confirm an email address with the token from the signup or verification email",
+		Body: controllers.TokenInput{}, Response: openapi.Object{"message": "", "user": services.UserView{}}},
+	{Method: http.MethodPost, Path: "/email/verify/resend", Tag: "auth", Summary: "Mail a new verification link",
+		Description: "Answers 202 whether or not the email has an unverified account.",
+		Body:        controllers.EmailInput{}, Status: http.StatusAccepted, Response: openapi.Object{"message": ""}},
+	{Method: http.MethodPost, Path: "/password/forgot", Tag: "auth", Summary: "Mail a password reset link",
+		Description: "Answers 202 whether or not the email has an account. The link works once, for one hour.",
+		Body:        controllers.EmailInput{}, Status: http.StatusAccepted, Response: openapi.Object{"message": ""}},
+	{Method: http.MethodPost, Path: "/password/reset", Tag: "auth", Summary: "Set a new password with the token from the reset email",
+		Description: "Signs the user out on every device.",
+		Body:        controllers.ResetPasswordInput{}, Response: openapi.Object{"message": ""}},
+	{Method: http.MethodGet, Path: "/auth/oidc/providers", Tag: "auth", Summary: "Social login providers",
+		Description: "Configured with OIDC_PROVIDERS; the list is empty when social login is off.",
+		Response:    openapi.Object{"providers": []services.ProviderView{}}},
+	{Method: http.MethodPost, Path: "/auth/oidc/authorize", Tag: "auth", Summary: "Start a social login",
+		Description: "Send the browser to authorization_url. The provider redirects back to the frontend callback page with code and state, " +
+			"which must reach /auth/oidc/callback within " + strconv.Itoa(int(services.OIDCLoginTTL.Minutes())) + " minutes. " +
+			"Also sets the HttpOnly oidc_binding cookie, so send the request with credentials.",
+		Body: controllers.OIDCAuthorizeInput{}, Response: openapi.Object{"authorization_url": ""}},
+	{Method: http.MethodPost, Path: "/auth/oidc/callback", Tag: "auth", Summary: "Finish a social login and exchange it for tokens",
+		Description: "Uses the authorization code flow with PKCE. A provider account is linked by its verified email the first time, " +
+			"creating a customer account when the email has none; without a verified email it answers 403 oidc_email_not_verified. " +
+			"Each state works once and only with the oidc_binding cookie of the browser that started the login; " +
+			"reused, expired or unbound states answer 400 invalid_oidc_state.",
+		Body: controllers.OIDCCallbackInput{}, Response: controllers.LoginResponse{}},
+	{Method: http.MethodGet, Path: "/user", Tag: "auth", Summary: "Current user", Auth: true,
+		Response: openapi.Object{"user": services.UserView{}}},
+	{Method: http.MethodPut, Path: "/user", Tag: "auth", Summary: "Update the current user's name or phone", Auth: true,
+		Body: services.ProfileInput{}, Response: openapi.Object{"user": services.UserView{}}},
+	{Method: http.MethodDelete, Path: "/user", Tag: "auth", Summary: "Delete the current user's account", Auth: true,
+		Description: "Requires the account password. Accounts without one, made by a social login, need no password but must have logged in " +
+			"within the last 10 minutes, or answer 403 reauthentication_required; refreshed tokens keep the time of their login. " +
+			"Bookings are kept without an owner; the only admin cannot delete their account.",
+		Body: controllers.DeleteAccountInput{}, Response: openapi.Object{"message": ""}},
+	{Method: http.MethodPut, Path: "/user/password", Tag: "auth", Summary: "Change the current user's password", Auth: true,
+		Description: "Requires current_password, except for accounts without a password, which set their first one within 10 minutes " +
+			"of logging in as DELETE /user allows. Every session is revoked, including the caller's; the response carries new tokens for this device.",
+		Body: services.ChangePasswordInput{}, Response: controllers.LoginResponse{}},
+	{Method: http.MethodPost, Path: "/user/revoke-sessions", Tag: "auth", Summary: "Sign the current user out everywhere", Auth: true,
+		Response: openapi.Object{"message": ""}},
+	{Method: http.MethodPost, Path: "/user/two-factor/setup", Tag: "auth", Summary: "Create a new authenticator secret", Auth: true,
+		Description: "The secret stays pending until /user/two-factor/enable confirms a code from it.",
+		Response:    services.TwoFactorSetup{}},
+	{Method: http.MethodPost, Path: "/user/two-factor/enable", Tag: "auth", Summary: "Turn on two-factor authentication", Auth: true,
+		Description: "Recorded in the audit log as user.two_factor_enabled. The recovery codes are shown only this once.",
+		Body:        controllers.TwoFactorCodeInput{}, Response: controllers.RecoveryCodesResponse{}},
+	{Method: http.MethodPost, Path: "/user/two-factor/recovery-codes", Tag: "auth", Summary: "Replace the recovery codes", Auth: true,
+		Description: "Requires a current authenticator code; the old recovery codes stop working.",
+		Body:        controllers.TwoFactorCodeInput{}, Response: controllers.RecoveryCodesResponse{}},
+	{Method: http.MethodDelete, Path: "/user/two-factor", Tag: "auth", Summary: "Turn off two-factor authentication", Auth: true,
+		Description: "Requires an authenticator or recovery code. Roles listed in TWO_FACTOR_ROLES answer 403 two_factor_required.",
+		Body:        controllers.TwoFactorCodeInput{}, Response: openapi.Object{"message": ""}},
+	{Method: http.MethodGet, Path: "/roles", Tag: "auth", Summary: "Roles and the permissions each one holds",
+		Description: "Managers and staff hold their permissions only at the restaurants they are assigned to.",
+		Response:    openapi.Object{"roles": []string{}, "permissions": map[string][]services.Permission{}}},
+
+	{Method: http.MethodGet, Path: "/restaurants", Tag: "restaurants", Summary: "List restaurants",
+		Query: params([]openapi.Param{
+			{Name: "name", Description: "Case-insensitive substring"},
+			{Name: "is_active", Type: "boolean"},
+		}, pageParams(services.RestaurantSorts, "id")),
+		Response: pagination.Page[models.Restaurant]{}},
+	{Method: http.MethodGet, Path: "/restaurants/:id", Tag: "restaurants", Summary: "Restaurant linked to a user",
+		Description: "id is the user id; v2 serves this as /users/{id}/restaurant.", Response: openapi.Object{"id": uint(0), "name": ""}},
+	{Method: http.MethodPost, Path: "/restaurants", Tag: "restaurants", Summary: "Create a restaurant", Auth: true,
+		Description: "Super admins only.",
+		Body:        services.CreateRestaurantInput{}, Status: http.StatusCreated, Response: models.Restaurant{}},
+	{Method: http.MethodGet, Path: "/restaurants/:id/staff", Tag: "restaurants", Summary: "Managers and staff of a restaurant", Auth: true,
+		Description: "Requires staff.manage at the restaurant.",
+		Response:    []services.StaffMember{}},
+	{Method: http.MethodPut, Path: "/restaurants/:id/staff/:user_id", Tag: "restaurants", Summary: "Assign a user to a restaurant", Auth: true,
+		Description: "Requires staff.manage at the restaurant; only super admins appoint managers. The user's account role follows their assignments. Recorded in the audit log as staff.assigned.",
+		Body:        services.AssignStaffInput{}, Response: services.StaffMember{}},
+	{Method: http.MethodDelete, Path: "/restaurants/:id/staff/:user_id", Tag: "restaurants", Summary: "Remove a user from a restaurant", Auth: true,
+		Description: "Requires staff.manage at the restaurant; only super admins remove managers. Recorded in the audit log as staff.removed.",
+		Response:    openapi.Object{"message": ""}},
+	{Method: http.MethodGet, Path: "/restaurants/:id/invitations", Tag: "restaurants", Summary: "Pending staff invitations of a restaurant", Auth: true,
+		Description: "Requires staff.manage at the restaurant. Expired invitations are listed until they are revoked or replaced.",
+		Response:    []models.StaffInvitation{}},
+	{Method: http.MethodPost, Path: "/restaurants/:id/invitations", Tag: "restaurants", Summary: "Invite someone by email to join a restaurant", Auth: true,
+		Description: "Requires staff.manage at the restaurant; only super admins invite managers. The link in the email expires after " + strconv.Itoa(int(services.InvitationTTL.Hours()/24)) + " days; inviting the same email again replaces it. Users already on the staff answer 409 user_already_linked.",
+		Body:        services.InviteStaffInput{}, Status: http.StatusCreated, Response: models.StaffInvitation{}},
+	{Method: http.MethodDelete, Path: "/restaurants/:id/invitations/:invitation_id", Tag: "restaurants", Summary: "Revoke a pending invitation", Auth: true,
+		Description: "Requires staff.manage at the restaurant; only super admins revoke invitations of managers.",
+		Response:    openapi.Object{"message": ""}},
+	{Method: http.MethodGet, Path: "/restaurants/:id/api-keys", Tag: "restaurants", Summary: "API keys of a restaurant", Auth: true,
+		Description: "Requires api_keys.manage at the restaurant. Revoked keys are not listed; the keys themselves are never shown again after they are issued.",
+		Response:    []services.APIKeyView{}},
+	{Method: http.MethodPost, Path: "/restaurants/:id/api-keys", Tag: "restaurants", Summary: "Issue an API key for a partner system", Auth: true,
+		Description: "Requires api_keys.manage at the restaurant. scopes is any of " + strings.Join(services.APIScopes, ", ") + ". The key is returned once and sent as a bearer token; it acts only for this restaurant. Recorded in the audit log as api_key.created.",
+		Body:        services.CreateAPIKeyInput{}, Status: http.StatusCreated, Response: services.IssuedAPIKey{}},
+	{Method: http.MethodPost, Path: "/restaurants/:id/api-keys/:key_id/rotate", Tag: "restaurants", Summary: "Replace the secret of an API key", Auth: true,
+		Description: "Requires api_keys.manage at the restaurant. The old key keeps working until previous_expires_at (API_KEY_ROTATION_GRACE, 24 hours by default). Recorded in the audit log as api_key.rotated.",
+		Response:    services.IssuedAPIKey{}},
+	{Method: http.MethodDelete, Path: "/restaurants/:id/api-keys/:key_id", Tag: "restaurants", Summary: "Revoke an API key", Auth: true,
+		Description: "Requires api_keys.manage at the restaurant. Recorded in the audit log as api_key.revoked.",
+		Response:    openapi.Object{"message": ""}},
+	{Method: http.MethodPost, Path: "/invitations/accept", Tag: "auth", Summary: "Join a restaurant with the token from an invitation email",
+		Description: "Creates the account when the invited email has none, which requires name and password. An existing account whose email was never verified requires a new password, and its sessions are revoked. Accepting verifies the email address; invalid, expired, revoked and used tokens answer 400 invalid_invitation.",
+		Body:        services.AcceptInvitationInput{}, Response: openapi.Object{"message": "", "user": services.UserView{}}},
+	{Method: http.MethodGet, Path: "/restaurants/:id/tables", Tag: "tables", Summary: "Tables of a restaurant",
+		Response: []models.Table{}},
+
+	{Method: http.MethodGet, Path: "/time-slots", Tag: "sessions", Summary: "List time slots", Response: []models.TimeSlot{}},
+
+	{Method: http.MethodGet, Path: "/tables", Tag: "tables", Summary: "List tables",
+		Query: params([]openapi.Param{
+			{Name: "restaurant_id", Type: "integer"},
+			{Name: "status", Enum: []string{"active", "inactive"}},
+			{Name: "min_capacity", Type: "integer"},
+		}, pageParams(controllers.TableSorts, "id")),
+		Response: pagination.Page[models.Table]{}},
+	{Method: http.MethodPost, Path: "/tables", Tag: "tables", Summary: "Create a table", Auth: true,
+		Description: "Requires tables.manage at the table's restaurant.",
+		Body:        models.Table{}, Status: http.StatusCreated, Response: models.Table{}},
+
+	{Method: http.MethodGet, Path: "/users", Tag: "users", Summary: "List users", Auth: true,
+		Description: "Super admins only.",
+		Query: params([]openapi.Param{
+			{Name: "email", Description: "Case-insensitive substring"},
+			{Name: "role", Enum: services.Roles},
+		}, pageParams(services.UserSorts, "id")),
+		Response: pagination.Page[services.UserView]{}},
+	{Method: http.MethodPost, Path: "/users", Tag: "users", Summary: "Create a user", Auth: true,
+		Description: "Super admins only. role is super_admin or customer (the default); restaurant roles are given through staff assignments. The password must pass the password policy and the new user is mailed a verification link.",
+		Body:        services.CreateUserInput{}, Status: http.StatusCreated, Response: services.UserView{}},
+	{Method: http.MethodPut, Path: "/users/:id/role", Tag: "users", Summary: "Change a user's role", Auth: true,
+		Description: "Super admins only. Grants or revokes super_admin; a revoked super admin takes the role of their restaurant assignments. Recorded in the audit log as user.role_changed; demoting the only super admin answers 409 last_admin. A user granted a role in TWO_FACTOR_ROLES without two-factor authentication is signed out everywhere.",
+		Body:        controllers.RoleInput{}, Response: services.UserView{}},
+	{Method: http.MethodPost, Path: "/users/:id/revoke-sessions", Tag: "users", Summary: "Sign a user out everywhere", Auth: true,
+		Description: "Super admins only.",
+		Response:    openapi.Object{"message": ""}},
+	{Method: http.MethodDelete, Path: "/users/:id/two-factor", Tag: "users", Summary: "Reset a user's two-factor authentication", Auth: true,
+		Description: "Super admins only, for users who lost their authenticator and recovery codes. Signs the user out everywhere; roles that require two-factor authentication set it up again at their next login. Recorded in the audit log as user.two_factor_reset.",
+		Response:    services.UserView{}},
+
+	{Method: http.MethodGet, Path: "/sessions", Tag: "sessions", Summary: "List sessions with their bookings", APIKey: services.ScopeReadSessions,
+		Description: "With an API key, only sessions of the key's restaurant.",
+		Query: params([]openapi.Param{
+			{Name: "restaurant_id", Type: "integer"},
+			{Name: "time_slot_id", Type: "integer"},
+			{Name: "has_availability", Type: "boolean"},
+		}, dateRangeParams(), pageParams(services.SessionSorts, "-created_at")),
+		Response: pagination.Page[services.SessionDetails]{}},
+	{Method: http.MethodGet, Path: "/sessions/search", Tag: "sessions", Summary: "Find sessions with room for a party", APIKey: services.ScopeReadSessions,
+		Description: "Results are ranked soonest, nearest, then most free seats. When nothing matches, alternatives suggest other time slots, nearby dates and restaurants further away. With an API key, only sessions of the key's restaurant.",
+		Query: params([]openapi.Param{
+			{Name: "party_size", Type: "integer", Description: "Default 1"},
+			{Name: "date", Format: "date", Description: "Shorthand for a one-day range"},
+		}, dateRangeParams(), []openapi.Param{
+			{Name: "time_slot_id", Type: "integer"},
+			{Name: "restaurant_id", Type: "integer"},
+			{Name: "lat", Type: "number", Description: "Given together with lng"},
+			{Name: "lng", Type: "number"},
+			{Name: "radius_km", Type: "number", Description: "Default " + strconv.Itoa(services.DefaultRadiusKm) + ", at most " + strconv.Itoa(services.MaxSearchRadiusKm)},
+			{Name: "limit", Type: "integer", Description: "Default " + strconv.Itoa(services.DefaultSearchLimit) + ", at most " + strconv.Itoa(services.MaxSearchLimit)},
+		}),
+		Response: services.AvailabilityResult{}},
+	{Method: http.MethodPost, Path: "/sessions", Tag: "sessions", Summary: "Create a session", Auth: true,
+		Description: "Requires sessions.manage at the restaurant.",
+		Body:        services.CreateSessionInput{}, Status: http.StatusCreated, Response: openapi.Object{"message": "", "session": models.Session{}}},
+	{Method: http.MethodPut, Path: "/sessions/:id", Tag: "sessions", Summary: "Update a session", Auth: true,
+		Description: "Requires sessions.manage at the session's restaurant.",
+		Body:        services.UpdateSessionInput{}, Response: openapi.Object{"message": "", "session": models.Session{}}},
+	{Method: http.MethodDelete, Path: "/sessions/:id", Tag: "sessions", Summary: "Delete a session without bookings", Auth: true,
+		Description: "Requires sessions.manage at the session's restaurant.",
+		Response:    openapi.Object{"message": ""}},
+
+	{Method: http.MethodGet, Path: "/bookings", Tag: "bookings", Summary: "List bookings", Auth: true, APIKey: services.ScopeManageBookings,
+		Description: "Only bookings at restaurants where the caller holds bookings.view.",
+		Query: params([]openapi.Param{
+			{Name: "restaurant_id", Type: "integer"},
+			{Name: "session_id", Type: "integer"},
+			{Name: "time_slot_id", Type: "integer"},
+			{Name: "status", Enum: []string{"confirmed", "cancelled"}},
+			{Name: "email"},
+		}, dateRangeParams(), pageParams(services.BookingSorts, "-created_at")),
+		Response: pagination.Page[services.BookingWithRestaurant]{}},
+	{Method: http.MethodGet, Path: "/bookings/user/:email", Tag: "bookings", Summary: "Bookings made with the current user's account", Auth: true,
+		Description: "email must be the caller's own, or the request answers 403 permission_denied.",
+		Response:    []models.Booking{}},
+	{Method: http.MethodPost, Path: "/bookings", Tag: "bookings", Summary: "Book seats in a session", Auth: true, APIKey: services.ScopeCreateBookings,
+		Description: "Books for the signed in user, with their account's email; unverified emails answer 403 email_not_verified. API keys book for any email, in sessions of the key's restaurant only.",
+		Body:        services.CreateBookingInput{}, Status: http.StatusCreated, Response: openapi.Object{"message": "", "booking": models.Booking{}}},
+	{Method: http.MethodPut, Path: "/bookings/:id", Tag: "bookings", Summary: "Update a booking", Auth: true, APIKey: services.ScopeManageBookings,
+		Description: "Requires bookings.manage at the booking's restaurant. Changing number_of_guests or confirming a cancelled booking " +
+			"takes seats from the session and answers 400 not_enough_slots when too few are left; cancelling gives them back.",
+		Body: services.UpdateBookingInput{}, Response: openapi.Object{"message": "", "booking": models.Booking{}}},
+	{Method: http.MethodPost, Path: "/bookings/:id/check-in", Tag: "bookings", Summary: "Mark the guests of a booking as arrived", Auth: true, APIKey: services.ScopeManageBookings,
+		Description: "Requires bookings.check_in at the booking's restaurant. Checking in again keeps the first time; cancelled bookings cannot be checked in.",
+		Response:    openapi.Object{"message": "", "booking": models.Booking{}}},
+	{Method: http.MethodDelete, Path: "/bookings/:email/:id", Tag: "bookings", Summary: "Cancel a booking made with the current user's account", Auth: true,
+		Description: "email must be the caller's own, or the request answers 403 permission_denied; bookings of other accounts, " +
+			"and partner bookings that belong to none, answer 404 booking_not_found.",
+		Response: openapi.Object{"message": "", "booking": models.Booking{}}},
+
+	{Method: http.MethodGet, Path: "/admin/outbox", Tag: "admin", Summary: "Outbox messages", Auth: true,
+		Query:    []openapi.Param{{Name: "status", Description: "pending, sent, dead or all (default dead)"}},
+		Response: []models.OutboxMessage{}},
+	{Method: http.MethodPost, Path: "/admin/outbox/:id/retry", Tag: "admin", Summary: "Queue a message for another attempt", Auth: true,
+		Description: "Sent messages answer 409 outbox_already_sent. Pending messages that are not due yet, which a dispatcher may be " +
+			"delivering, answer 409 outbox_not_due.",
+		Response: openapi.Object{"message": "", "outbox_message": models.OutboxMessage{}}},
+	{Method: http.MethodGet, Path: "/admin/audit-events", Tag: "admin", Summary: "Latest 100 security audit events", Auth: true,
+		Query:    []openapi.Param{{Name: "type", Description: "Only events of this type, e.g. login.locked"}},
+		Response: []models.AuditEvent{}},
+
+	{Method: http.MethodGet, Path: "/events", Tag: "events", Summary: "Live events as Server-Sent Events",
+		Query:    streamParams,
+		Response: "", ContentType: "text/event-stream"},
+}
+
+// v2Operations documents the routes v2Routes adds.
+var v2Operations = []openapi.Operation{
+	{Method: http.MethodGet, Path: "/users/:id/restaurant", Tag: "restaurants", Summary: "Restaurant linked to a user",
+		Response: openapi.Object{"id": uint(0), "name": ""}},
+	{Method: http.MethodPost, Path: "/bookings/:id/cancel", Tag: "bookings", Summary: "Cancel a booking made with the current user's account", Auth: true,
+		Description: "Bookings of other accounts, and partner bookings that belong to none, answer 404 booking_not_found.",
+		Response:    openapi.Object{"message": "", "booking": models.Booking{}}},
+}
+
+// specOperations lists v1 and v2 under their prefixes, and the legacy /api
+// aliases of v1 marked deprecated.
+func specOperations() []openapi.Operation {
+	key := func(op openapi.Operation) string { return routeKey(op.Method, op.Path) }
+	v2 := inherit(v1Operations, key, v2Removed, v2Operations...)
+
+	ops := append([]openapi.Operation{}, systemOperations...)
+	ops = append(ops, prefixed("/api/v1", v1Operations)...)
+	ops = append(ops, prefixed("/api/v2", v2)...)
+	for _, op := range prefixed("/api", v1Operations) {
+		op.Deprecated = true
+		op.Description = strings.TrimSpace(op.Description + " Deprecated alias of /api/v1" + strings.TrimPrefix(op.Path, "/api") +
+			", removed after " + LegacySunset.Format("2006-01-02") + ".")
+		ops = append(ops, op)
+	}
+	return ops
+}
+
+func prefixed(prefix string, ops []openapi.Operation) []openapi.Operation {
+	out := make([]openapi.Operation, len(ops))
+	for i, op := range ops {
+		op.Path = prefix + op.Path
+		out[i] = op
+	}
+	return out
+}
+
+// Spec is the OpenAPI document served at /api/openapi.json.
+var Spec = openapi.Build(apiInfo, apierror.Body{}, specOperations())
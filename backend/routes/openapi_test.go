@@ -0,0 +1,78 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+)
+
+type specDoc struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+			Required   []string                   `json:"required"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z_]+)`)
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	env := apitest.New(t)
+	rec := env.Do(http.MethodGet, "/api/openapi.json", nil, "")
+	var spec specDoc
+	env.Expect(rec, http.StatusOK, &spec)
+
+	registered := map[string]bool{}
+	for _, route := range env.Router.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		key := route.Method + " " + path
+		registered[key] = true
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s is registered but missing from the OpenAPI spec", key)
+		}
+	}
+	var stale []string
+	for path, methods := range spec.Paths {
+		for method := range methods {
+			if key := strings.ToUpper(method) + " " + path; !registered[key] {
+				stale = append(stale, key)
+			}
+		}
+	}
+	sort.Strings(stale)
+	if len(stale) > 0 {
+		t.Errorf("documented but not registered: %v", stale)
+	}
+
+	refs := regexp.MustCompile(`"#/components/schemas/([^"]+)"`).FindAllStringSubmatch(rec.Body.String(), -1)
+	for _, ref := range refs {
+		if _, ok := spec.Components.Schemas[ref[1]]; !ok {
+			t.Errorf("dangling schema reference %s", ref[1])
+		}
+	}
+
+	input := spec.Components.Schemas["CreateSessionInput"]
+	if strings.Join(input.Required, ",") != "date,max_guests,name,restaurant_id,time_slot_id" {
+		t.Errorf("unexpected required fields %v", input.Required)
+	}
+	if _, ok := spec.Components.Schemas["BookingWithRestaurant"].Properties["user_email"]; !ok {
+		t.Error("embedded booking fields should be flattened into BookingWithRestaurant")
+	}
+	if _, ok := spec.Components.Schemas["PageSessionDetails"]; !ok {
+		t.Error("expected a schema for the sessions page")
+	}
+}
+
+func TestDocsPageLoadsSpec(t *testing.T) {
+	env := apitest.New(t)
+	rec := env.Do(http.MethodGet, "/api/docs", nil, "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `url: "openapi.json"`) {
+		t.Fatalf("unexpected docs page %d %s", rec.Code, rec.Body.String())
+	}
+}
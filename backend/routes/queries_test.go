@@ -0,0 +1,119 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// seedSessions adds n sessions spread over three restaurants, each with two
+// bookings, one of them cancelled.
+func seedSessions(env *apitest.Env, n int) {
+	restaurants := []models.Restaurant{env.Fixtures.Restaurant}
+	for i := 0; i < 2; i++ {
+		restaurant := models.Restaurant{Name: fmt.Sprintf("Branch %d", i), IsActive: true}
+		env.Must(env.DB.Create(&restaurant).Error)
+		restaurants = append(restaurants, restaurant)
+	}
+
+	start := time.Date(2031, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		session := models.Session{
+			RestaurantID:   restaurants[i%len(restaurants)].ID,
+			TimeSlotID:     env.Fixtures.Lunch.ID,
+			Name:           fmt.Sprintf("Lunch %d", i),
+			Date:           start.AddDate(0, 0, i).Format("2006-01-02"),
+			MaxGuests:      10,
+			AvailableSlots: 8,
+			IsAvailable:    true,
+		}
+		env.Must(env.DB.Create(&session).Error)
+		bookings := []models.Booking{
+			{SessionID: session.ID, UserName: "Guest", UserEmail: "guest@example.com", BookingDate: session.Date, NumberOfGuests: 2, Status: "confirmed"},
+			{SessionID: session.ID, UserName: "Guest", UserEmail: "guest@example.com", BookingDate: session.Date, NumberOfGuests: 3, Status: "cancelled"},
+		}
+		env.Must(env.DB.Create(&bookings).Error)
+	}
+}
+
+func TestListQueriesDoNotGrowWithRows(t *testing.T) {
+	for _, path := range []string{"/api/sessions", "/api/bookings"} {
+		t.Run(path, func(t *testing.T) {
+			counts := make([]int, 0, 2)
+			for _, n := range []int{2, 30} {
+				env := apitest.New(t)
+				seedSessions(env, n)
+				admin := env.AdminToken()
+				counts = append(counts, env.CountQueries(func() {
+					env.Expect(env.Do(http.MethodGet, path, nil, admin), http.StatusOK, nil)
+				}))
+			}
+			if counts[0] != counts[1] {
+				t.Fatalf("query count grew with rows: %d for 2 sessions, %d for 30", counts[0], counts[1])
+			}
+		})
+	}
+}
+
+func TestListSessionsAggregates(t *testing.T) {
+	env := apitest.New(t)
+	seedSessions(env, 3)
+
+	var sessions struct {
+		Data []struct {
+			Name           string `json:"name"`
+			BookingCount   int    `json:"booking_count"`
+			BookedGuests   int    `json:"booked_guests"`
+			Bookings       []models.Booking
+			RestaurantData models.Restaurant `json:"restaurant_data"`
+		} `json:"data"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/sessions", nil, ""), http.StatusOK, &sessions)
+	if len(sessions.Data) != 4 {
+		t.Fatalf("expected 4 sessions, got %d", len(sessions.Data))
+	}
+	for _, s := range sessions.Data {
+		if s.Name == env.Fixtures.Session.Name {
+			continue
+		}
+		if s.BookingCount != 1 || s.BookedGuests != 2 || len(s.Bookings) != 2 {
+			t.Fatalf("unexpected aggregates for %s: %+v", s.Name, s)
+		}
+		if s.RestaurantData.ID == 0 {
+			t.Fatalf("session %s is missing its restaurant", s.Name)
+		}
+	}
+
+	var bookings struct {
+		Data []struct {
+			RestaurantName string `json:"restaurant_name"`
+		} `json:"data"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/bookings", nil, env.AdminToken()), http.StatusOK, &bookings)
+	if len(bookings.Data) != 6 || bookings.Data[0].RestaurantName == "" {
+		t.Fatalf("unexpected bookings %+v", bookings)
+	}
+}
+
+func benchmarkList(b *testing.B, path string) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("sessions=%d", n), func(b *testing.B) {
+			env := apitest.New(b)
+			seedSessions(env, n)
+			admin := env.AdminToken()
+			b.ResetTimer()
+			queries := env.CountQueries(func() {
+				for i := 0; i < b.N; i++ {
+					env.Expect(env.Do(http.MethodGet, path, nil, admin), http.StatusOK, nil)
+				}
+			})
+			b.ReportMetric(float64(queries)/float64(b.N), "queries/op")
+		})
+	}
+}
+
+func BenchmarkGetSessions(b *testing.B) { benchmarkList(b, "/api/sessions") }
+func BenchmarkGetBookings(b *testing.B) { benchmarkList(b, "/api/bookings") }
@@ -0,0 +1,115 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+// signUp registers an account and returns it with an access token.
+func signUp(env *apitest.Env, name, email string) (services.UserView, string) {
+	env.T.Helper()
+	input := map[string]string{"name": name, "email": email, "phone": "0812345678", "password": "mango-sticky-rice"}
+	var body struct {
+		User services.UserView `json:"user"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/signup", input, ""), http.StatusCreated, &body)
+	return body.User, env.Login(email, "mango-sticky-rice")
+}
+
+func TestRestaurantRolesFollowThePermissionMatrix(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+	admin := env.AdminToken()
+
+	var other models.Restaurant
+	env.Expect(env.Do(http.MethodPost, "/api/v1/restaurants", map[string]string{"name": "Baan Rim Nam", "location": "Chiang Mai"}, admin), http.StatusCreated, &other)
+	otherSession := models.Session{RestaurantID: other.ID, TimeSlotID: f.Lunch.ID, Name: "Sunday lunch", Date: "2030-01-06", MaxGuests: 6, AvailableSlots: 6, IsAvailable: true}
+	env.Must(env.DB.Create(&otherSession).Error)
+	here := models.Booking{SessionID: f.Session.ID, UserName: "Guest", UserEmail: "guest@example.com", BookingDate: f.Session.Date, NumberOfGuests: 2, Status: "confirmed"}
+	there := models.Booking{SessionID: otherSession.ID, UserName: "Guest", UserEmail: "guest@example.com", BookingDate: otherSession.Date, NumberOfGuests: 2, Status: "confirmed"}
+	env.Must(env.DB.Create(&here).Error)
+	env.Must(env.DB.Create(&there).Error)
+
+	manager, managerToken := signUp(env, "Pim", "pim@example.com")
+	staff, staffToken := signUp(env, "Chai", "chai@example.com")
+	_, customer := signUp(env, "Dao", "dao@example.com")
+	staffPath := func(restaurantID, userID uint) string {
+		return fmt.Sprintf("/api/v1/restaurants/%d/staff/%d", restaurantID, userID)
+	}
+
+	env.Expect(env.Do(http.MethodPost, "/api/v1/restaurants", map[string]string{"name": "Khao", "location": "Bangkok"}, customer), http.StatusForbidden, nil)
+	var member services.StaffMember
+	env.Expect(env.Do(http.MethodPut, staffPath(f.Restaurant.ID, manager.ID), map[string]string{"role": "restaurant_manager"}, admin), http.StatusOK, &member)
+	if member.Role != services.RoleRestaurantManager || member.User.Role != services.RoleRestaurantManager {
+		t.Fatalf("unexpected assignment %+v", member)
+	}
+
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPut, staffPath(f.Restaurant.ID, staff.ID), map[string]string{"role": "restaurant_manager"}, managerToken), http.StatusForbidden, &body)
+	if body.Code != apierror.PermissionDenied {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	env.Expect(env.Do(http.MethodPut, staffPath(other.ID, staff.ID), map[string]string{"role": "staff"}, managerToken), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPut, staffPath(f.Restaurant.ID, staff.ID), map[string]string{"role": "owner"}, managerToken), http.StatusBadRequest, nil)
+	env.Expect(env.Do(http.MethodPut, staffPath(f.Restaurant.ID, staff.ID), map[string]string{"role": "staff"}, managerToken), http.StatusOK, &member)
+	if member.User.Role != services.RoleStaff {
+		t.Fatalf("unexpected assignment %+v", member)
+	}
+	var members []services.StaffMember
+	env.Expect(env.Do(http.MethodGet, fmt.Sprintf("/api/v1/restaurants/%d/staff", f.Restaurant.ID), nil, managerToken), http.StatusOK, &members)
+	if len(members) != 3 {
+		t.Fatalf("expected admin, manager and staff, got %+v", members)
+	}
+	env.Expect(env.Do(http.MethodGet, fmt.Sprintf("/api/v1/restaurants/%d/staff", f.Restaurant.ID), nil, staffToken), http.StatusForbidden, nil)
+
+	update := map[string]interface{}{"name": "Friday feast"}
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/sessions/%d", f.Session.ID), update, managerToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/sessions/%d", otherSession.ID), update, managerToken), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/sessions/%d", f.Session.ID), update, staffToken), http.StatusForbidden, nil)
+
+	var page struct {
+		Data []models.Booking `json:"data"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, staffToken), http.StatusOK, &page)
+	if len(page.Data) != 1 || page.Data[0].ID != here.ID {
+		t.Fatalf("staff should only see bookings of their restaurant, got %+v", page.Data)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, customer), http.StatusForbidden, nil)
+
+	var checkedIn struct {
+		Booking models.Booking `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, fmt.Sprintf("/api/v1/bookings/%d/check-in", here.ID), nil, staffToken), http.StatusOK, &checkedIn)
+	if checkedIn.Booking.CheckedInAt == nil {
+		t.Fatalf("booking was not checked in: %+v", checkedIn.Booking)
+	}
+	env.Expect(env.Do(http.MethodPost, fmt.Sprintf("/api/v1/bookings/%d/check-in", there.ID), nil, staffToken), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/bookings/%d", here.ID), map[string]int{"number_of_guests": 3}, staffToken), http.StatusForbidden, nil)
+
+	env.Expect(env.Do(http.MethodDelete, staffPath(f.Restaurant.ID, manager.ID), nil, managerToken), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodDelete, staffPath(f.Restaurant.ID, staff.ID), nil, managerToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodDelete, staffPath(f.Restaurant.ID, staff.ID), nil, managerToken), http.StatusNotFound, nil)
+	var stored models.User
+	env.Must(env.DB.First(&stored, staff.ID).Error)
+	if stored.Role != services.RoleCustomer {
+		t.Fatalf("expected the removed staff member to be a customer again, got %q", stored.Role)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/bookings", nil, staffToken), http.StatusForbidden, nil)
+}
+
+func TestRolesListsThePermissionMatrix(t *testing.T) {
+	env := apitest.New(t)
+	var body struct {
+		Roles       []string                         `json:"roles"`
+		Permissions map[string][]services.Permission `json:"permissions"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/roles", nil, ""), http.StatusOK, &body)
+	if len(body.Roles) != 4 || len(body.Permissions[services.RoleStaff]) != 2 || len(body.Permissions[services.RoleCustomer]) != 0 {
+		t.Fatalf("unexpected roles %+v", body)
+	}
+}
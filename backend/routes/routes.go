@@ -2,7 +2,9 @@ package routes
 
 import (
 	"booking-backend/controllers"
+	"booking-backend/services"
 	"booking-backend/websocket"
+	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -11,37 +13,109 @@ import (
 func RegisterRoutes(r *gin.Engine, DB *gorm.DB) {
 	r.GET("/", func(c *gin.Context) { controllers.Root(c) })
 	r.GET("/health", func(c *gin.Context) { controllers.Health(c) })
-	r.GET("/ws", websocket.HandleWebSocket)
+	authenticateStream := func(c *gin.Context) error { return controllers.AuthenticateStream(c, DB) }
+	r.GET("/ws", func(c *gin.Context) { websocket.HandleWebSocket(c, authenticateStream) })
+	r.GET("/.well-known/jwks.json", func(c *gin.Context) { controllers.GetJWKS(c) })
+
+	svc := services.New(services.NewGormStore(DB))
+	v1 := v1Routes(svc, DB, authenticateStream)
+	v2 := v2Routes(v1, svc, DB)
 
 	api := r.Group("/api")
-	{
-		api.POST("/signup", func(c *gin.Context) { controllers.Signup(c, DB) })
-		api.POST("/login", func(c *gin.Context) { controllers.Login(c, DB) })
+	api.GET("/openapi.json", func(c *gin.Context) { controllers.GetOpenAPI(c, Spec) })
+	api.GET("/docs", func(c *gin.Context) { controllers.GetDocs(c) })
+
+	register(api.Group("/v1"), v1)
+	register(api.Group("/v2"), v2)
+	register(api.Group("", deprecated("/api", "/api/v1")), v1)
+}
+
+func v1Routes(svc *services.Services, DB *gorm.DB, authenticateStream websocket.Authenticator) []route {
+	return []route{
+		{http.MethodPost, "/signup", func(c *gin.Context) { controllers.Signup(c, svc) }},
+		{http.MethodPost, "/login", func(c *gin.Context) { controllers.Login(c, svc) }},
+		{http.MethodPost, "/login/two-factor", func(c *gin.Context) { controllers.CompleteTwoFactorLogin(c, svc) }},
+		{http.MethodPost, "/login/two-factor/setup", func(c *gin.Context) { controllers.SetupTwoFactorLogin(c, svc) }},
+		{http.MethodPost, "/token/refresh", func(c *gin.Context) { controllers.RefreshToken(c, svc) }},
+		{http.MethodPost, "/logout", func(c *gin.Context) { controllers.Logout(c, svc) }},
+		{http.MethodPost, "/email/verify", func(c *gin.Context) { controllers.VerifyEmail(c, svc) }},
+		{http.MethodPost, "/email/verify/resend", func(c *gin.Context) { controllers.ResendVerification(c, svc) }},
+		{http.MethodPost, "/password/forgot", func(c *gin.Context) { controllers.ForgotPassword(c, svc) }},
+		{http.MethodPost, "/password/reset", func(c *gin.Context) { controllers.ResetPassword(c, svc) }},
+		{http.MethodGet, "/auth/oidc/providers", func(c *gin.Context) { controllers.GetLoginProviders(c, svc) }},
+		{http.MethodPost, "/auth/oidc/authorize", func(c *gin.Context) { controllers.StartSocialLogin(c, svc) }},
+		{http.MethodPost, "/auth/oidc/callback", func(c *gin.Context) { controllers.FinishSocialLogin(c, svc) }},
+		{http.MethodGet, "/roles", func(c *gin.Context) { controllers.GetRoles(c) }},
+
+		{http.MethodGet, "/restaurants", func(c *gin.Context) { controllers.GetRestaurants(c, svc) }},
+		{http.MethodGet, "/restaurants/:id", func(c *gin.Context) { controllers.GetRestaurantByUserId(c, svc) }},
+		{http.MethodPost, "/restaurants", func(c *gin.Context) { controllers.CreateRestaurant(c, DB, svc) }},
+		{http.MethodGet, "/restaurants/:id/staff", func(c *gin.Context) { controllers.GetRestaurantStaff(c, DB, svc) }},
+		{http.MethodPut, "/restaurants/:id/staff/:user_id", func(c *gin.Context) { controllers.AssignRestaurantStaff(c, DB, svc) }},
+		{http.MethodDelete, "/restaurants/:id/staff/:user_id", func(c *gin.Context) { controllers.RemoveRestaurantStaff(c, DB, svc) }},
+		{http.MethodGet, "/restaurants/:id/invitations", func(c *gin.Context) { controllers.GetRestaurantInvitations(c, DB, svc) }},
+		{http.MethodPost, "/restaurants/:id/invitations", func(c *gin.Context) { controllers.InviteRestaurantStaff(c, DB, svc) }},
+		{http.MethodDelete, "/restaurants/:id/invitations/:invitation_id", func(c *gin.Context) { controllers.RevokeRestaurantInvitation(c, DB, svc) }},
+		{http.MethodGet, "/restaurants/:id/api-keys", func(c *gin.Context) { controllers.GetRestaurantAPIKeys(c, DB, svc) }},
+		{http.MethodPost, "/restaurants/:id/api-keys", func(c *gin.Context) { controllers.CreateRestaurantAPIKey(c, DB, svc) }},
+		{http.MethodPost, "/restaurants/:id/api-keys/:key_id/rotate", func(c *gin.Context) { controllers.RotateRestaurantAPIKey(c, DB, svc) }},
+		{http.MethodDelete, "/restaurants/:id/api-keys/:key_id", func(c *gin.Context) { controllers.RevokeRestaurantAPIKey(c, DB, svc) }},
+		{http.MethodPost, "/invitations/accept", func(c *gin.Context) { controllers.AcceptInvitation(c, svc) }},
 
-		api.GET("/restaurants", func(c *gin.Context) { controllers.GetRestaurants(c, DB) })
-		api.GET("/restaurants/:id", func(c *gin.Context) { controllers.GetRestaurantByUserId(c, DB) })
-		api.POST("/restaurants", func(c *gin.Context) { /* implement create restaurant handler */ })
+		{http.MethodGet, "/time-slots", func(c *gin.Context) { controllers.GetTimeSlots(c, DB) }},
 
-		api.GET("/time-slots", func(c *gin.Context) { controllers.GetTimeSlots(c, DB) })
+		{http.MethodGet, "/tables", func(c *gin.Context) { controllers.GetTables(c, DB) }},
+		{http.MethodGet, "/restaurants/:id/tables", func(c *gin.Context) { controllers.GetRestaurantTables(c, DB) }},
+		{http.MethodPost, "/tables", func(c *gin.Context) { controllers.CreateTable(c, DB, svc) }},
 
-		api.GET("/tables", func(c *gin.Context) { controllers.GetTables(c, DB) })
-		api.GET("/restaurants/:id/tables", func(c *gin.Context) { controllers.GetRestaurantTables(c, DB) })
-		api.POST("/tables", func(c *gin.Context) { controllers.CreateTable(c, DB) })
+		{http.MethodGet, "/users", func(c *gin.Context) { controllers.GetUsers(c, DB, svc) }},
+		{http.MethodPost, "/users", func(c *gin.Context) { controllers.CreateUser(c, DB, svc) }},
+		{http.MethodPut, "/users/:id/role", func(c *gin.Context) { controllers.UpdateUserRole(c, DB, svc) }},
+		{http.MethodPost, "/users/:id/revoke-sessions", func(c *gin.Context) { controllers.RevokeUserSessions(c, DB, svc) }},
+		{http.MethodDelete, "/users/:id/two-factor", func(c *gin.Context) { controllers.ResetUserTwoFactor(c, DB, svc) }},
 
-		api.GET("/users", func(c *gin.Context) { controllers.GetUsers(c, DB) })
-		api.POST("/users", func(c *gin.Context) { controllers.CreateUser(c, DB) })
+		{http.MethodGet, "/sessions", func(c *gin.Context) { controllers.GetSessions(c, svc) }},
+		{http.MethodGet, "/sessions/search", func(c *gin.Context) { controllers.SearchSessions(c, svc) }},
+		{http.MethodPost, "/sessions", func(c *gin.Context) { controllers.CreateSession(c, DB, svc) }},
+		{http.MethodPut, "/sessions/:id", func(c *gin.Context) { controllers.UpdateSession(c, DB, svc) }},
+		{http.MethodDelete, "/sessions/:id", func(c *gin.Context) { controllers.DeleteSession(c, DB, svc) }},
 
-		api.GET("/sessions", func(c *gin.Context) { controllers.GetSessions(c, DB) })
-		api.POST("/sessions", func(c *gin.Context) { controllers.CreateSession(c, DB) })
-		api.PUT("/sessions/:id", func(c *gin.Context) { controllers.UpdateSession(c, DB) })
-		api.DELETE("/sessions/:id", func(c *gin.Context) { controllers.DeleteSession(c, DB) })
+		{http.MethodGet, "/bookings", func(c *gin.Context) { controllers.GetBookings(c, DB, svc) }},
+		{http.MethodGet, "/bookings/user/:email", func(c *gin.Context) { controllers.GetBookingByEmail(c, DB, svc) }},
+		{http.MethodPost, "/bookings", func(c *gin.Context) { controllers.CreateBooking(c, DB, svc) }},
+		{http.MethodPut, "/bookings/:id", func(c *gin.Context) { controllers.UpdateBooking(c, DB, svc) }},
+		{http.MethodPost, "/bookings/:id/check-in", func(c *gin.Context) { controllers.CheckInBooking(c, DB, svc) }},
+		{http.MethodDelete, "/bookings/:email/:id", func(c *gin.Context) { controllers.CancelBooking(c, DB, svc) }},
 
-		api.GET("/bookings", func(c *gin.Context) { controllers.GetBookings(c, DB) })
-		api.GET("/bookings/user/:email", func(c *gin.Context) { controllers.GetBookingByEmail(c, DB) })
-		api.POST("/bookings", func(c *gin.Context) { controllers.CreateBooking(c, DB) })
-		api.PUT("/bookings/:id", func(c *gin.Context) { controllers.UpdateBooking(c, DB) })
-		api.DELETE("/bookings/:email/:id", func(c *gin.Context) { controllers.CancelBooking(c, DB, websocket.GlobalHub) })
+		{http.MethodGet, "/user", func(c *gin.Context) { controllers.GetUser(c, DB) }},
+		{http.MethodPut, "/user", func(c *gin.Context) { controllers.UpdateProfile(c, DB, svc) }},
+		{http.MethodDelete, "/user", func(c *gin.Context) { controllers.DeleteAccount(c, DB, svc) }},
+		{http.MethodPut, "/user/password", func(c *gin.Context) { controllers.ChangePassword(c, DB, svc) }},
+		{http.MethodPost, "/user/revoke-sessions", func(c *gin.Context) { controllers.RevokeMySessions(c, DB, svc) }},
+		{http.MethodPost, "/user/two-factor/setup", func(c *gin.Context) { controllers.SetupTwoFactor(c, DB, svc) }},
+		{http.MethodPost, "/user/two-factor/enable", func(c *gin.Context) { controllers.EnableTwoFactor(c, DB, svc) }},
+		{http.MethodPost, "/user/two-factor/recovery-codes", func(c *gin.Context) { controllers.RegenerateRecoveryCodes(c, DB, svc) }},
+		{http.MethodDelete, "/user/two-factor", func(c *gin.Context) { controllers.DisableTwoFactor(c, DB, svc) }},
 
-		api.GET("/user", func(c *gin.Context) { controllers.GetUser(c, DB) })
+		{http.MethodGet, "/admin/outbox", func(c *gin.Context) { controllers.GetOutboxMessages(c, DB, svc) }},
+		{http.MethodPost, "/admin/outbox/:id/retry", func(c *gin.Context) { controllers.RetryOutboxMessage(c, DB, svc) }},
+		{http.MethodGet, "/admin/audit-events", func(c *gin.Context) { controllers.GetAuditEvents(c, DB, svc) }},
+
+		{http.MethodGet, "/events", func(c *gin.Context) { websocket.HandleEvents(c, authenticateStream) }},
 	}
 }
+
+// v2Removed are the v1 routes v2 replaces with better named ones.
+var v2Removed = []string{
+	routeKey(http.MethodGet, "/restaurants/:id"),
+	routeKey(http.MethodDelete, "/bookings/:email/:id"),
+}
+
+// v2Routes is v1 with breaking fixes. Handlers added here need a matching
+// entry in v2Operations.
+func v2Routes(v1 []route, svc *services.Services, DB *gorm.DB) []route {
+	return inherit(v1, func(r route) string { return routeKey(r.method, r.path) }, v2Removed,
+		route{http.MethodGet, "/users/:id/restaurant", func(c *gin.Context) { controllers.GetRestaurantByUserId(c, svc) }},
+		route{http.MethodPost, "/bookings/:id/cancel", func(c *gin.Context) { controllers.CancelBookingByID(c, DB, svc) }},
+	)
+}
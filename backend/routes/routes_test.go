@@ -0,0 +1,175 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSignupAndLogin(t *testing.T) {
+	env := apitest.New(t)
+
+	signup := map[string]string{"name": "Somchai", "email": "somchai@example.com", "phone": "0812345678", "password": "mango-sticky-rice"}
+	env.Expect(env.Do(http.MethodPost, "/api/signup", signup, ""), http.StatusCreated, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/signup", signup, ""), http.StatusBadRequest, nil)
+
+	bad := map[string]string{"email": "somchai@example.com", "password": "wrong"}
+	env.Expect(env.Do(http.MethodPost, "/api/login", bad, ""), http.StatusUnauthorized, nil)
+
+	token := env.Login("somchai@example.com", "mango-sticky-rice")
+	var me struct {
+		User models.User `json:"user"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/user", nil, token), http.StatusOK, &me)
+	if me.User.Email != "somchai@example.com" {
+		t.Fatalf("expected logged in user, got %+v", me.User)
+	}
+
+	if sent := env.Dispatch(); sent != 1 {
+		t.Fatalf("expected signup email to be dispatched, sent %d", sent)
+	}
+	if mail := env.Mail.Messages(); len(mail) != 1 || mail[0].To != "somchai@example.com" {
+		t.Fatalf("unexpected mail %+v", mail)
+	}
+}
+
+func TestSessionCRUD(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+	admin := env.AdminToken()
+
+	input := map[string]interface{}{
+		"restaurant_id": f.Restaurant.ID,
+		"time_slot_id":  f.Lunch.ID,
+		"name":          "Saturday lunch",
+		"date":          "2030-01-05",
+		"max_guests":    8,
+	}
+	var created struct {
+		Session models.Session `json:"session"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/sessions", input, admin), http.StatusCreated, &created)
+	if created.Session.AvailableSlots != 8 || created.Session.TimeSlot.SlotName != "Lunch" {
+		t.Fatalf("unexpected session %+v", created.Session)
+	}
+
+	input["restaurant_id"] = 9999
+	env.Expect(env.Do(http.MethodPost, "/api/sessions", input, admin), http.StatusNotFound, nil)
+
+	path := fmt.Sprintf("/api/sessions/%d", created.Session.ID)
+	update := map[string]interface{}{"time_slot_id": f.Lunch.ID, "name": "Saturday brunch", "date": "2030-01-05", "max_guests": 12}
+	var updated struct {
+		Session models.Session `json:"session"`
+	}
+	env.Expect(env.Do(http.MethodPut, path, update, admin), http.StatusOK, &updated)
+	if updated.Session.Name != "Saturday brunch" || updated.Session.AvailableSlots != 12 {
+		t.Fatalf("unexpected update %+v", updated.Session)
+	}
+
+	var sessions struct {
+		Data []map[string]interface{} `json:"data"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/sessions", nil, ""), http.StatusOK, &sessions)
+	if len(sessions.Data) != 2 {
+		t.Fatalf("expected 2 sessions, got %d", len(sessions.Data))
+	}
+
+	env.Expect(env.Do(http.MethodDelete, path, nil, admin), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodDelete, path, nil, admin), http.StatusNotFound, nil)
+}
+
+func TestBookingAndCancellation(t *testing.T) {
+	env := apitest.New(t)
+	session := env.Fixtures.Session
+	token := env.Token(env.VerifiedUser("Malee", "malee@example.com"))
+
+	booking := map[string]interface{}{
+		"session_id":       session.ID,
+		"name":             "Malee",
+		"email":            "malee@example.com",
+		"phone":            "0898765432",
+		"number_of_guests": 4,
+	}
+	var created struct {
+		Booking models.Booking `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/bookings", booking, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/bookings", booking, token), http.StatusCreated, &created)
+
+	booking["number_of_guests"] = 7
+	env.Expect(env.Do(http.MethodPost, "/api/bookings", booking, token), http.StatusBadRequest, nil)
+
+	var stored models.Session
+	env.Must(env.DB.First(&stored, session.ID).Error)
+	if stored.AvailableSlots != 6 {
+		t.Fatalf("expected 6 slots left, got %d", stored.AvailableSlots)
+	}
+
+	// Guests see and cancel only the bookings of their own account.
+	var mine []models.Booking
+	env.Expect(env.Do(http.MethodGet, "/api/bookings/user/malee@example.com", nil, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/bookings/user/malee@example.com", nil, token), http.StatusOK, &mine)
+	if len(mine) != 1 {
+		t.Fatalf("expected one booking for guest, got %d", len(mine))
+	}
+	other := env.Token(env.VerifiedUser("Somchai", "somchai@example.com"))
+	env.Expect(env.Do(http.MethodGet, "/api/bookings/user/malee@example.com", nil, other), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/bookings/user/somchai@example.com", nil, other), http.StatusOK, &mine)
+	if len(mine) != 0 {
+		t.Fatalf("expected no bookings for another guest, got %d", len(mine))
+	}
+
+	cancel := fmt.Sprintf("/api/bookings/malee@example.com/%d", created.Booking.ID)
+	env.Expect(env.Do(http.MethodDelete, cancel, nil, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodDelete, cancel, nil, other), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodDelete, fmt.Sprintf("/api/bookings/somchai@example.com/%d", created.Booking.ID), nil, other), http.StatusNotFound, nil)
+	env.Expect(env.Do(http.MethodDelete, cancel, nil, token), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodDelete, cancel, nil, token), http.StatusBadRequest, nil)
+
+	env.Must(env.DB.First(&stored, session.ID).Error)
+	if stored.AvailableSlots != 10 || !stored.IsAvailable {
+		t.Fatalf("expected seats released, got %+v", stored)
+	}
+}
+
+func TestCancellationBroadcast(t *testing.T) {
+	env := apitest.New(t)
+	session := env.Fixtures.Session
+	conn := env.DialWebSocket("topics=sessionCancelled")
+	token := env.Token(env.VerifiedUser("Niran", "niran@example.com"))
+
+	booking := map[string]interface{}{
+		"session_id":       session.ID,
+		"name":             "Niran",
+		"email":            "niran@example.com",
+		"number_of_guests": 2,
+	}
+	var created struct {
+		Booking models.Booking `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/bookings", booking, token), http.StatusCreated, &created)
+	env.Expect(env.Do(http.MethodDelete, fmt.Sprintf("/api/bookings/niran@example.com/%d", created.Booking.ID), nil, token), http.StatusOK, nil)
+
+	// Confirmation email, cancellation email and the hub event.
+	if sent := env.Dispatch(); sent != 3 {
+		t.Fatalf("expected 3 outbox messages dispatched, got %d", sent)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, message, err := conn.ReadMessage()
+	if err != nil {
+		t.Fatalf("read websocket: %v", err)
+	}
+	var event map[string]interface{}
+	env.Must(json.Unmarshal(message, &event))
+	if event["type"] != "sessionCancelled" || event["sessionName"] != session.Name || event["userName"] != "Niran" {
+		t.Fatalf("unexpected event %v", event)
+	}
+	if _, ok := event["id"].(float64); !ok {
+		t.Fatalf("expected a sequence id on %v", event)
+	}
+}
@@ -0,0 +1,93 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func searchSessions(env *apitest.Env, query string) services.AvailabilityResult {
+	env.T.Helper()
+	var result services.AvailabilityResult
+	env.Expect(env.Do(http.MethodGet, "/api/sessions/search?"+query, nil, ""), http.StatusOK, &result)
+	return result
+}
+
+func TestSearchSessionsByPartySizeAndLocation(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+
+	lat, lng := 13.7563, 100.5018
+	env.Must(env.DB.Model(&f.Restaurant).Updates(map[string]interface{}{"latitude": lat, "longitude": lng}).Error)
+	farLat, farLng := 18.7883, 98.9853
+	far := models.Restaurant{Name: "Chiang Mai branch", Location: "Chiang Mai", IsActive: true, Latitude: &farLat, Longitude: &farLng}
+	env.Must(env.DB.Create(&far).Error)
+	env.Must(env.DB.Create(&models.Session{
+		RestaurantID: far.ID, TimeSlotID: f.Dinner.ID, Name: "Northern dinner", Date: "2030-01-04",
+		MaxGuests: 20, AvailableSlots: 20, IsAvailable: true,
+	}).Error)
+
+	result := searchSessions(env, "date=2030-01-04&party_size=6")
+	if len(result.Results) != 2 {
+		t.Fatalf("expected both sessions to fit 6 guests, got %+v", result.Results)
+	}
+	if result.Results[0].Name != "Northern dinner" {
+		t.Fatalf("expected the session with more free seats first, got %s", result.Results[0].Name)
+	}
+
+	result = searchSessions(env, "date=2030-01-04&party_size=12")
+	if len(result.Results) != 1 || result.Results[0].Restaurant.ID != far.ID {
+		t.Fatalf("expected only the larger session to fit 12 guests, got %+v", result.Results)
+	}
+
+	result = searchSessions(env, fmt.Sprintf("date=2030-01-04&party_size=6&lat=%f&lng=%f&radius_km=5", lat+0.01, lng))
+	if len(result.Results) != 1 || result.Results[0].SessionID != f.Session.ID {
+		t.Fatalf("expected only the nearby session, got %+v", result.Results)
+	}
+	if d := result.Results[0].DistanceKm; d == nil || *d < 1 || *d > 1.2 {
+		t.Fatalf("expected a distance of about 1.1km, got %v", d)
+	}
+}
+
+func TestSearchSessionsSuggestsAlternatives(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+	env.Must(env.DB.Create(&models.Session{
+		RestaurantID: f.Restaurant.ID, TimeSlotID: f.Lunch.ID, Name: "Sunday lunch", Date: "2030-01-06",
+		MaxGuests: 8, AvailableSlots: 8, IsAvailable: true,
+	}).Error)
+
+	result := searchSessions(env, fmt.Sprintf("date=2030-01-04&party_size=4&time_slot_id=%d", f.Lunch.ID))
+	if len(result.Results) != 0 {
+		t.Fatalf("expected no lunch on Friday, got %+v", result.Results)
+	}
+	reasons := map[string]string{}
+	for _, alt := range result.Alternatives {
+		reasons[alt.Name] = alt.Reason
+	}
+	if reasons["Friday dinner"] != services.ReasonOtherTimeSlot || reasons["Sunday lunch"] != services.ReasonOtherDate {
+		t.Fatalf("unexpected alternatives %+v", result.Alternatives)
+	}
+
+	result = searchSessions(env, "date=2030-01-04&party_size=40")
+	if len(result.Results) != 0 || len(result.Alternatives) != 0 {
+		t.Fatalf("expected nothing for 40 guests, got %+v", result)
+	}
+}
+
+func TestSearchSessionsRejectsInvalidQueries(t *testing.T) {
+	env := apitest.New(t)
+	for _, query := range []string{
+		"party_size=0",
+		"party_size=two",
+		"lat=13.7",
+		"lat=13.7&lng=100.5&radius_km=500",
+		"date_from=2030-01-01&date_to=2030-06-01",
+		"date=01-04-2030",
+	} {
+		env.Expect(env.Do(http.MethodGet, "/api/sessions/search?"+query, nil, ""), http.StatusBadRequest, nil)
+	}
+}
@@ -0,0 +1,204 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/auth"
+	"booking-backend/controllers"
+	"booking-backend/oidc/oidctest"
+	"booking-backend/services"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// socialEnv starts a mock provider and an API configured to use it.
+func socialEnv(t *testing.T) (*apitest.Env, *oidctest.Provider) {
+	mock, err := oidctest.New("booking", "mock-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(mock.Close)
+	t.Setenv("OIDC_PROVIDERS", "mock")
+	t.Setenv("OIDC_MOCK_ISSUER", mock.Issuer())
+	t.Setenv("OIDC_MOCK_CLIENT_ID", mock.ClientID)
+	t.Setenv("OIDC_MOCK_CLIENT_SECRET", mock.ClientSecret)
+	t.Setenv("OIDC_MOCK_DISPLAY_NAME", "Mock")
+	return apitest.New(t), mock
+}
+
+// authorize starts a login and follows it through the provider, returning
+// the state and code the callback page would receive and the cookie the
+// browser kept.
+func authorize(env *apitest.Env, mock *oidctest.Provider) (map[string]string, *http.Cookie) {
+	env.T.Helper()
+	var started struct {
+		URL string `json:"authorization_url"`
+	}
+	rec := env.Do(http.MethodPost, "/api/v1/auth/oidc/authorize", map[string]string{"provider": "mock"}, "")
+	env.Expect(rec, http.StatusOK, &started)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != "oidc_binding" || !cookies[0].HttpOnly || cookies[0].Value == "" {
+		env.T.Fatalf("unexpected cookies %+v", cookies)
+	}
+	back, err := mock.Authorize(started.URL)
+	if err != nil || back.Path != "/oauth-callback" {
+		env.T.Fatalf("unexpected redirect %v: %v", back, err)
+	}
+	return map[string]string{"state": back.Query().Get("state"), "code": back.Query().Get("code")}, cookies[0]
+}
+
+func socialLogin(env *apitest.Env, mock *oidctest.Provider, identity oidctest.Identity) *httptest.ResponseRecorder {
+	env.T.Helper()
+	mock.SignIn(identity)
+	callback, cookie := authorize(env, mock)
+	return env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", cookie)
+}
+
+func TestSocialLoginCreatesAndLinksAccounts(t *testing.T) {
+	env, mock := socialEnv(t)
+
+	var providers struct {
+		Providers []services.ProviderView `json:"providers"`
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/auth/oidc/providers", nil, ""), http.StatusOK, &providers)
+	if len(providers.Providers) != 1 || providers.Providers[0].Name != "mock" || providers.Providers[0].DisplayName != "Mock" {
+		t.Fatalf("unexpected providers %+v", providers)
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/auth/oidc/authorize", map[string]string{"provider": "myspace"}, ""), http.StatusNotFound, nil)
+
+	var created controllers.LoginResponse
+	nok := oidctest.Identity{Subject: "nok-1", Email: "nok@example.com", EmailVerified: true, Name: "Nok"}
+	env.Expect(socialLogin(env, mock, nok), http.StatusOK, &created)
+	if created.User.Role != services.RoleCustomer || created.User.Name != "Nok" || created.User.EmailVerifiedAt == nil || created.RefreshToken == "" {
+		t.Fatalf("unexpected login %+v", created)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, created.AccessToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": "nok@example.com", "password": "x"}, ""), http.StatusUnauthorized, nil)
+
+	// The subject identifies the account once linked, even if the email
+	// changes at the provider.
+	var again controllers.LoginResponse
+	nok.Email = "nok@another.example.com"
+	env.Expect(socialLogin(env, mock, nok), http.StatusOK, &again)
+	if again.User.ID != created.User.ID {
+		t.Fatalf("expected user %d, got %+v", created.User.ID, again.User)
+	}
+
+	// A verified email links an existing verified account as it is.
+	pim := env.VerifiedUser("Pim", "pim@example.com")
+	var linked controllers.LoginResponse
+	env.Expect(socialLogin(env, mock, oidctest.Identity{Subject: "pim-1", Email: "pim@example.com", EmailVerified: true, Name: "Pim"}), http.StatusOK, &linked)
+	if linked.User.ID != pim.ID {
+		t.Fatalf("expected user %d to be linked, got %+v", pim.ID, linked.User)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, env.Token(pim)), http.StatusOK, nil)
+
+	// An unverified account may have been registered by someone else, so
+	// the email's owner claims it: its password stops working and its
+	// sessions end.
+	dao, squatter := signUp(env, "Dao", "dao@example.com")
+	env.Expect(socialLogin(env, mock, oidctest.Identity{Subject: "dao-1", Email: "dao@example.com", EmailVerified: true, Name: "Dao"}), http.StatusOK, &linked)
+	if linked.User.ID != dao.ID || linked.User.EmailVerifiedAt == nil {
+		t.Fatalf("expected user %d to be claimed and verified, got %+v", dao.ID, linked.User)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, squatter), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": "dao@example.com", "password": "mango-sticky-rice"}, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, linked.AccessToken), http.StatusOK, nil)
+
+	var namesake controllers.LoginResponse
+	env.Expect(socialLogin(env, mock, oidctest.Identity{Subject: "dao-2", Email: "dao2@example.com", EmailVerified: true, Name: "Dao"}), http.StatusOK, &namesake)
+	if namesake.User.ID == dao.ID || namesake.User.Name != "Dao 2" {
+		t.Fatalf("unexpected user %+v", namesake.User)
+	}
+
+	var body apierror.Body
+	env.Expect(socialLogin(env, mock, oidctest.Identity{Subject: "mallory", Email: "dao@example.com", Name: "Mallory"}), http.StatusForbidden, &body)
+	if body.Code != apierror.OIDCEmailRequired {
+		t.Fatalf("unexpected error %+v", body)
+	}
+}
+
+func TestSocialLoginRejectsReplaysAndTampering(t *testing.T) {
+	env, mock := socialEnv(t)
+	mock.SignIn(oidctest.Identity{Subject: "nok-1", Email: "nok@example.com", EmailVerified: true, Name: "Nok"})
+
+	callback, cookie := authorize(env, mock)
+	rec := env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", cookie)
+	env.Expect(rec, http.StatusOK, nil)
+	if cleared := rec.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Fatalf("the binding cookie should be cleared, got %+v", cleared)
+	}
+	var body apierror.Body
+	env.Expect(env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", cookie), http.StatusBadRequest, &body)
+	if body.Code != apierror.InvalidOIDCState {
+		t.Fatalf("unexpected error %+v", body)
+	}
+
+	// A code redeemed without the verifier of its login fails PKCE.
+	callback, cookie = authorize(env, mock)
+	env.Must(env.DB.Exec("UPDATE oidc_logins SET code_verifier = 'stolen'").Error)
+	env.Expect(env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", cookie), http.StatusUnauthorized, &body)
+	if body.Code != apierror.OIDCLoginFailed {
+		t.Fatalf("unexpected error %+v", body)
+	}
+
+	callback, cookie = authorize(env, mock)
+	callback["state"] = "forged"
+	env.Expect(env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", cookie), http.StatusBadRequest, nil)
+}
+
+// An attacker who starts a login with their own provider account cannot
+// make a victim's browser finish it, which would sign the victim in as the
+// attacker.
+func TestSocialLoginIsBoundToTheBrowserThatStartedIt(t *testing.T) {
+	env, mock := socialEnv(t)
+	mock.SignIn(oidctest.Identity{Subject: "mallory-1", Email: "mallory@example.com", EmailVerified: true, Name: "Mallory"})
+
+	callback, attackers := authorize(env, mock)
+	_, victims := authorize(env, mock)
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/auth/oidc/callback", callback, ""), http.StatusBadRequest, &body)
+	if body.Code != apierror.InvalidOIDCState {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	env.Expect(env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", victims), http.StatusBadRequest, nil)
+
+	// Refused callbacks do not spend the state.
+	env.Expect(env.DoWithCookies(http.MethodPost, "/api/v1/auth/oidc/callback", callback, "", attackers), http.StatusOK, nil)
+}
+
+// Accounts made by a social login have no password, so a login made in the
+// last few minutes stands in for it, refreshed or not.
+func TestSocialAccountsConfirmWithARecentLogin(t *testing.T) {
+	env, mock := socialEnv(t)
+	var nok controllers.LoginResponse
+	env.Expect(socialLogin(env, mock, oidctest.Identity{Subject: "nok-1", Email: "nok@example.com", EmailVerified: true, Name: "Nok"}), http.StatusOK, &nok)
+
+	var body apierror.Body
+	stale, err := auth.IssueAccessToken(nok.User.ID, nok.User.Email, 0, time.Now(), time.Now().Add(-time.Hour))
+	env.Must(err)
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user/password", map[string]string{"new_password": "green-curry-paste"}, stale), http.StatusForbidden, &body)
+	if body.Code != apierror.ReauthRequired {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	env.Must(env.DB.Exec("UPDATE refresh_tokens SET authenticated_at = ?", time.Now().Add(-time.Hour)).Error)
+	refreshed := refresh(env, nok.RefreshToken, http.StatusOK)
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user", map[string]string{}, refreshed.AccessToken), http.StatusForbidden, &body)
+	if body.Code != apierror.ReauthRequired {
+		t.Fatalf("a refresh should keep the login's time, got %+v", body)
+	}
+
+	// Logging in again allows setting a first password, which is then
+	// required like any other.
+	env.Expect(socialLogin(env, mock, oidctest.Identity{Subject: "nok-1", Email: "nok@example.com", EmailVerified: true, Name: "Nok"}), http.StatusOK, &nok)
+	var changed controllers.LoginResponse
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user/password", map[string]string{"new_password": "green-curry-paste"}, nok.AccessToken), http.StatusOK, &changed)
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user", map[string]string{}, changed.AccessToken), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Code != apierror.Required {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	token := env.Login("nok@example.com", "green-curry-paste")
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user", map[string]string{"password": "green-curry-paste"}, token), http.StatusOK, nil)
+}
@@ -0,0 +1,166 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/auth"
+	"booking-backend/controllers"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func challenge(env *apitest.Env, email, password string) services.LoginChallenge {
+	env.T.Helper()
+	var body services.LoginChallenge
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": email, "password": password}, ""), http.StatusOK, &body)
+	if !body.TwoFactorRequired || body.Token == "" {
+		env.T.Fatalf("expected a two-factor challenge, got %+v", body)
+	}
+	return body
+}
+
+// totp returns the authenticator code offset steps from now.
+func totp(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	code, err := auth.TOTPCode(secret, auth.TOTPStep(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestAdminsEnrollInTwoFactorAtLogin(t *testing.T) {
+	t.Setenv("TWO_FACTOR_ROLES", "super_admin")
+	env := apitest.New(t)
+	admin := env.Fixtures.Admin
+
+	first := challenge(env, admin.Email, apitest.AdminPassword)
+	if !first.SetupRequired {
+		t.Fatalf("expected setup to be required, got %+v", first)
+	}
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", map[string]string{"two_factor_token": first.Token, "code": "123456"}, ""), http.StatusBadRequest, &body)
+	if body.Code != apierror.TwoFactorNotEnabled {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	var setup services.TwoFactorSetup
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor/setup", map[string]string{"two_factor_token": first.Token}, ""), http.StatusOK, &setup)
+	if setup.Secret == "" || !strings.HasPrefix(setup.URI, "otpauth://totp/") {
+		t.Fatalf("unexpected setup %+v", setup)
+	}
+
+	verify := map[string]string{"two_factor_token": first.Token, "code": "abcdef"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", verify, ""), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Code != apierror.WrongCode {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	verify["code"] = totp(t, setup.Secret, 0)
+	var enrolled controllers.TwoFactorLoginResponse
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", verify, ""), http.StatusOK, &enrolled)
+	if enrolled.AccessToken == "" || !enrolled.User.TwoFactor || len(enrolled.RecoveryCodes) != services.RecoveryCodeCount {
+		t.Fatalf("unexpected login %+v", enrolled)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/users", nil, enrolled.AccessToken), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", verify, ""), http.StatusBadRequest, &body)
+	if body.Code != apierror.InvalidTwoFactor {
+		t.Fatalf("a challenge should work once, got %+v", body)
+	}
+
+	// Codes work once, whether from the authenticator or the recovery list.
+	second := challenge(env, admin.Email, apitest.AdminPassword)
+	if second.SetupRequired {
+		t.Fatalf("unexpected challenge %+v", second)
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", map[string]string{"two_factor_token": second.Token, "code": verify["code"]}, ""), http.StatusBadRequest, nil)
+	recovery := map[string]string{"two_factor_token": second.Token, "code": strings.ToUpper(enrolled.RecoveryCodes[0])}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", recovery, ""), http.StatusOK, nil)
+	recovery["two_factor_token"] = challenge(env, admin.Email, apitest.AdminPassword).Token
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", recovery, ""), http.StatusBadRequest, nil)
+	next := map[string]string{"two_factor_token": recovery["two_factor_token"], "code": totp(t, setup.Secret, 1)}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", next, ""), http.StatusOK, nil)
+
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user/two-factor", map[string]string{"code": enrolled.RecoveryCodes[1]}, enrolled.AccessToken), http.StatusForbidden, &body)
+	if body.Code != apierror.TwoFactorRequired {
+		t.Fatalf("unexpected error %+v", body)
+	}
+}
+
+// Granting a role that needs two-factor authentication ends the sessions of
+// a user who has not set it up, and refreshing cannot get around that.
+func TestGrantedRolesRequireTwoFactor(t *testing.T) {
+	t.Setenv("TWO_FACTOR_ROLES", "super_admin,restaurant_manager")
+	env := apitest.New(t)
+	admin := env.Token(env.Fixtures.Admin)
+	login := func(email string) controllers.LoginResponse {
+		var body controllers.LoginResponse
+		env.Expect(env.Do(http.MethodPost, "/api/v1/login", map[string]string{"email": email, "password": "mango-sticky-rice"}, ""), http.StatusOK, &body)
+		return body
+	}
+
+	pim, _ := signUp(env, "Pim", "pim@example.com")
+	session := login("pim@example.com")
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/restaurants/%d/staff/%d", env.Fixtures.Restaurant.ID, pim.ID), map[string]string{"role": "restaurant_manager"}, admin), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, session.AccessToken), http.StatusUnauthorized, nil)
+	var body apierror.Body
+	env.Expect(env.Do(http.MethodPost, "/api/v1/token/refresh", map[string]string{"refresh_token": session.RefreshToken}, ""), http.StatusUnauthorized, nil)
+	if !challenge(env, "pim@example.com", "mango-sticky-rice").SetupRequired {
+		t.Fatal("expected setup to be required")
+	}
+
+	// Sessions started before a role was required cannot be refreshed.
+	dao, _ := signUp(env, "Dao", "dao@example.com")
+	session = login("dao@example.com")
+	env.Must(env.DB.Exec("UPDATE users SET role = ? WHERE id = ?", services.RoleSuperAdmin, dao.ID).Error)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/token/refresh", map[string]string{"refresh_token": session.RefreshToken}, ""), http.StatusUnauthorized, &body)
+	if body.Code != apierror.TwoFactorRequired {
+		t.Fatalf("unexpected error %+v", body)
+	}
+
+	somchai, _ := signUp(env, "Somchai", "somchai@example.com")
+	session = login("somchai@example.com")
+	env.Expect(env.Do(http.MethodPut, fmt.Sprintf("/api/v1/users/%d/role", somchai.ID), map[string]string{"role": "super_admin"}, admin), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, session.AccessToken), http.StatusUnauthorized, nil)
+}
+
+func TestCustomersMayTurnOnTwoFactor(t *testing.T) {
+	env := apitest.New(t)
+	admin := env.AdminToken()
+	user, token := signUp(env, "Niran", "niran@example.com")
+
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/enable", map[string]string{"code": "123456"}, token), http.StatusBadRequest, nil)
+	var setup services.TwoFactorSetup
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/setup", nil, token), http.StatusOK, &setup)
+	env.Login("niran@example.com", "mango-sticky-rice")
+	var codes controllers.RecoveryCodesResponse
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/enable", map[string]string{"code": totp(t, setup.Secret, 0)}, token), http.StatusOK, &codes)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/setup", nil, token), http.StatusConflict, nil)
+
+	var regenerated controllers.RecoveryCodesResponse
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/recovery-codes", map[string]string{"code": codes.RecoveryCodes[0]}, token), http.StatusBadRequest, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/recovery-codes", map[string]string{"code": totp(t, setup.Secret, 1)}, token), http.StatusOK, &regenerated)
+	login := map[string]string{"two_factor_token": challenge(env, "niran@example.com", "mango-sticky-rice").Token, "code": codes.RecoveryCodes[0]}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", login, ""), http.StatusBadRequest, nil)
+	login["code"] = regenerated.RecoveryCodes[0]
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login/two-factor", login, ""), http.StatusOK, nil)
+
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user/two-factor", map[string]string{"code": regenerated.RecoveryCodes[1]}, token), http.StatusOK, nil)
+	token = env.Login("niran@example.com", "mango-sticky-rice")
+
+	// Admins reset the second factor of users who lost it, signing them out.
+	reset := fmt.Sprintf("/api/v1/users/%d/two-factor", user.ID)
+	env.Expect(env.Do(http.MethodDelete, reset, nil, admin), http.StatusBadRequest, nil)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/setup", nil, token), http.StatusOK, &setup)
+	env.Expect(env.Do(http.MethodPost, "/api/v1/user/two-factor/enable", map[string]string{"code": totp(t, setup.Secret, 0)}, token), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodDelete, reset, nil, token), http.StatusForbidden, nil)
+	var view services.UserView
+	env.Expect(env.Do(http.MethodDelete, reset, nil, admin), http.StatusOK, &view)
+	if view.TwoFactor {
+		t.Fatalf("unexpected user %+v", view)
+	}
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, token), http.StatusUnauthorized, nil)
+	env.Login("niran@example.com", "mango-sticky-rice")
+}
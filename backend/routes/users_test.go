@@ -0,0 +1,115 @@
+package routes_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/services"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserManagementIsAdminOnly(t *testing.T) {
+	env := apitest.New(t)
+	admin := env.AdminToken()
+	signup := map[string]string{"name": "Niran", "email": "niran@example.com", "phone": "0812345678", "password": "mango-sticky-rice"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/signup", signup, ""), http.StatusCreated, nil)
+	customer := env.Login("niran@example.com", "mango-sticky-rice")
+
+	env.Expect(env.Do(http.MethodGet, "/api/v1/users", nil, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/users", nil, customer), http.StatusForbidden, nil)
+	rec := env.Do(http.MethodGet, "/api/v1/users", nil, admin)
+	env.Expect(rec, http.StatusOK, nil)
+	if strings.Contains(rec.Body.String(), "password") || strings.Contains(rec.Body.String(), "$2a$") {
+		t.Fatalf("user list leaks password hashes: %s", rec.Body.String())
+	}
+
+	input := map[string]interface{}{"id": 999, "name": "Kanya", "email": "kanya@example.com", "password": "green-curry-paste", "role": "super_admin"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/users", input, customer), http.StatusForbidden, nil)
+	var created services.UserView
+	env.Expect(env.Do(http.MethodPost, "/api/v1/users", input, admin), http.StatusCreated, &created)
+	if created.ID == 999 || created.Role != "super_admin" || created.EmailVerifiedAt != nil {
+		t.Fatalf("unexpected user %+v", created)
+	}
+	var stored models.User
+	env.Must(env.DB.First(&stored, created.ID).Error)
+	if bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("green-curry-paste")) != nil {
+		t.Fatal("the password was not stored as a bcrypt hash")
+	}
+	if token := mailedToken(env, "kanya@example.com"); token == "" {
+		t.Fatal("no verification link was mailed")
+	}
+
+	var body apierror.Body
+	input["email"], input["name"], input["role"] = "malee@example.com", "Malee", "owner"
+	env.Expect(env.Do(http.MethodPost, "/api/v1/users", input, admin), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Field != "role" {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	input["role"], input["password"] = "customer", "short"
+	env.Expect(env.Do(http.MethodPost, "/api/v1/users", input, admin), http.StatusBadRequest, nil)
+
+	path := fmt.Sprintf("/api/v1/users/%d/role", created.ID)
+	env.Expect(env.Do(http.MethodPut, path, map[string]string{"role": "customer"}, customer), http.StatusForbidden, nil)
+	env.Expect(env.Do(http.MethodPut, path, map[string]string{"role": "customer"}, admin), http.StatusOK, &created)
+	if created.Role != "customer" {
+		t.Fatalf("role was not changed: %+v", created)
+	}
+	var events []models.AuditEvent
+	env.Must(env.DB.Where("type IN ?", []string{services.AuditUserCreated, services.AuditUserRoleChanged}).Order("id").Find(&events).Error)
+	if len(events) != 2 || events[1].UserID == nil || *events[1].UserID != created.ID || !strings.Contains(events[1].Details, "super_admin -> customer") {
+		t.Fatalf("unexpected audit events %+v", events)
+	}
+
+	path = fmt.Sprintf("/api/v1/users/%d/role", env.Fixtures.Admin.ID)
+	env.Expect(env.Do(http.MethodPut, path, map[string]string{"role": "customer"}, admin), http.StatusConflict, &body)
+	if body.Code != apierror.LastAdmin {
+		t.Fatalf("unexpected error %+v", body)
+	}
+}
+
+func TestUsersManageTheirOwnAccount(t *testing.T) {
+	env := apitest.New(t)
+	signup := map[string]string{"name": "Somsri", "email": "somsri@example.com", "phone": "0812345678", "password": "mango-sticky-rice"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/signup", signup, ""), http.StatusCreated, nil)
+	token := env.Login("somsri@example.com", "mango-sticky-rice")
+
+	var profile struct {
+		User services.UserView `json:"user"`
+	}
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user", map[string]string{"name": "Somsri K.", "role": "super_admin"}, token), http.StatusOK, &profile)
+	if profile.User.Name != "Somsri K." || profile.User.Phone != "0812345678" || profile.User.Role != "customer" {
+		t.Fatalf("unexpected profile %+v", profile.User)
+	}
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user", map[string]string{"name": "Admin"}, token), http.StatusBadRequest, nil)
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user", map[string]string{"phone": "12ab"}, token), http.StatusBadRequest, nil)
+
+	var body apierror.Body
+	change := map[string]string{"current_password": "wrong-password", "new_password": "green-curry-paste"}
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user/password", change, token), http.StatusBadRequest, &body)
+	if len(body.Details) != 1 || body.Details[0].Code != apierror.WrongPassword {
+		t.Fatalf("unexpected error %+v", body)
+	}
+	change["current_password"] = "mango-sticky-rice"
+	var changed struct {
+		Token string `json:"token"`
+	}
+	env.Expect(env.Do(http.MethodPut, "/api/v1/user/password", change, token), http.StatusOK, &changed)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, token), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, changed.Token), http.StatusOK, nil)
+	token = env.Login("somsri@example.com", "green-curry-paste")
+
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user", map[string]string{"password": "mango-sticky-rice"}, token), http.StatusBadRequest, nil)
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user", map[string]string{"password": "green-curry-paste"}, token), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v1/user", nil, token), http.StatusUnauthorized, nil)
+	login := map[string]string{"email": "somsri@example.com", "password": "green-curry-paste"}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/login", login, ""), http.StatusUnauthorized, nil)
+
+	// The only admin cannot delete their own account.
+	admin := env.AdminToken()
+	env.Expect(env.Do(http.MethodDelete, "/api/v1/user", map[string]string{"password": apitest.AdminPassword}, admin), http.StatusConflict, nil)
+}
@@ -0,0 +1,63 @@
+package routes
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Legacy unversioned /api paths are aliases of v1 until LegacySunset.
+var (
+	LegacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	LegacySunset       = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+type route struct {
+	method  string
+	path    string
+	handler gin.HandlerFunc
+}
+
+func routeKey(method, path string) string { return method + " " + path }
+
+// inherit builds a version from the one before it: base without the removed
+// routes, then changes, which replace base entries with the same key. A new
+// version therefore lists only what differs.
+func inherit[T any](base []T, key func(T) string, removed []string, changes ...T) []T {
+	drop := map[string]bool{}
+	for _, k := range removed {
+		drop[k] = true
+	}
+	for _, change := range changes {
+		drop[key(change)] = true
+	}
+	var out []T
+	for _, item := range base {
+		if !drop[key(item)] {
+			out = append(out, item)
+		}
+	}
+	return append(out, changes...)
+}
+
+func register(group *gin.RouterGroup, routes []route) {
+	for _, r := range routes {
+		group.Handle(r.method, r.path, r.handler)
+	}
+}
+
+// deprecated marks responses of the legacy aliases with Deprecation and
+// Sunset headers (RFC 9745, RFC 8594) and links to the v1 equivalent.
+func deprecated(prefix, successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(LegacyDeprecatedAt.Unix(), 10)
+	sunset := LegacySunset.Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunset)
+		c.Header("Link", "<"+successor+strings.TrimPrefix(c.Request.URL.Path, prefix)+`>; rel="successor-version"`)
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package routes_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/routes"
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestLegacyPathsAreDeprecatedAliasesOfV1(t *testing.T) {
+	env := apitest.New(t)
+
+	rec := env.Do(http.MethodGet, "/api/v1/time-slots", nil, "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Deprecation") != "" {
+		t.Fatalf("v1 should answer without deprecation, got %d %v", rec.Code, rec.Header())
+	}
+
+	rec = env.Do(http.MethodGet, "/api/time-slots", nil, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("legacy path should still work, got %d", rec.Code)
+	}
+	if got, want := rec.Header().Get("Deprecation"), fmt.Sprintf("@%d", routes.LegacyDeprecatedAt.Unix()); got != want {
+		t.Fatalf("Deprecation %q, want %q", got, want)
+	}
+	if got, want := rec.Header().Get("Sunset"), routes.LegacySunset.Format(http.TimeFormat); got != want {
+		t.Fatalf("Sunset %q, want %q", got, want)
+	}
+	if got := rec.Header().Get("Link"); got != `</api/v1/time-slots>; rel="successor-version"` {
+		t.Fatalf("unexpected Link %q", got)
+	}
+}
+
+func TestV2ReplacesMisnamedRoutes(t *testing.T) {
+	env := apitest.New(t)
+	f := env.Fixtures
+	guest := env.Token(env.VerifiedUser("Guest", "guest@example.com"))
+
+	var booking struct {
+		Booking struct {
+			ID uint `json:"id"`
+		} `json:"booking"`
+	}
+	env.Expect(env.Do(http.MethodPost, "/api/v1/bookings", map[string]interface{}{
+		"session_id": f.Session.ID, "name": "Guest", "email": "guest@example.com", "number_of_guests": 2,
+	}, guest), http.StatusCreated, &booking)
+	id := booking.Booking.ID
+
+	if rec := env.Do(http.MethodDelete, fmt.Sprintf("/api/v2/bookings/guest@example.com/%d", id), nil, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("v2 should not serve the v1 cancel route, got %d", rec.Code)
+	}
+	cancel := fmt.Sprintf("/api/v2/bookings/%d/cancel", id)
+	env.Expect(env.Do(http.MethodPost, cancel, nil, ""), http.StatusUnauthorized, nil)
+	env.Expect(env.Do(http.MethodPost, cancel, nil, env.Token(env.VerifiedUser("Someone", "someone@example.com"))), http.StatusNotFound, nil)
+	env.Expect(env.Do(http.MethodPost, cancel, nil, guest), http.StatusOK, nil)
+
+	if rec := env.Do(http.MethodGet, fmt.Sprintf("/api/v2/restaurants/%d", f.Admin.ID), nil, ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("v2 should not serve restaurants by user id, got %d", rec.Code)
+	}
+	env.Expect(env.Do(http.MethodGet, fmt.Sprintf("/api/v2/users/%d/restaurant", f.Admin.ID), nil, ""), http.StatusOK, nil)
+	env.Expect(env.Do(http.MethodGet, "/api/v2/sessions", nil, ""), http.StatusOK, nil)
+}
@@ -0,0 +1,212 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/utils"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	PurposeVerifyEmail   = "verify_email"
+	PurposeResetPassword = "reset_password"
+
+	VerifyEmailTTL   = 48 * time.Hour
+	ResetPasswordTTL = time.Hour
+)
+
+// AccountService mails single-use links that verify an email address or
+// reset a forgotten password.
+type AccountService struct {
+	store     Store
+	Passwords PasswordPolicy
+	Now       func() time.Time
+}
+
+func NewAccountService(store Store, passwords PasswordPolicy) *AccountService {
+	return &AccountService{store: store, Passwords: passwords, Now: time.Now}
+}
+
+// AccountLink is the data of emails carrying a verification or reset link.
+type AccountLink struct {
+	Name  string
+	Email string
+	URL   string
+	Hours int
+}
+
+// appURL builds a link to a frontend page, which posts the token back to the
+// API. APP_URL is where the frontend is served.
+func appURL(path, token string) string {
+	base := strings.TrimRight(utils.GetEnv("APP_URL", "http://localhost:3000"), "/")
+	return base + path + "?token=" + url.QueryEscape(token)
+}
+
+// queueVerification issues a verification token for user and returns the
+// email data with its link. Earlier verification links stop working.
+func queueVerification(tx Store, user *models.User, now time.Time) (AccountLink, error) {
+	raw, err := issueUserToken(tx, user.ID, PurposeVerifyEmail, now, VerifyEmailTTL)
+	if err != nil {
+		return AccountLink{}, err
+	}
+	return AccountLink{
+		Name:  user.Name,
+		Email: user.Email,
+		URL:   appURL("/verify-email", raw),
+		Hours: int(VerifyEmailTTL.Hours()),
+	}, nil
+}
+
+func issueUserToken(tx Store, userID uint, purpose string, now time.Time, ttl time.Duration) (string, error) {
+	if err := tx.UserTokens().UseAll(userID, purpose, now); err != nil {
+		return "", err
+	}
+	raw, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	return raw, tx.UserTokens().Create(&models.UserToken{
+		UserID:    userID,
+		Purpose:   purpose,
+		TokenHash: hashToken(raw),
+		ExpiresAt: now.Add(ttl),
+	})
+}
+
+// redeem marks raw and every other token of its user and purpose as used, and
+// returns the user. Unknown, expired and used tokens return invalid.
+func redeem(tx Store, raw, purpose string, now time.Time, invalid apierror.Code) (*models.User, error) {
+	token, err := tx.UserTokens().FindByHash(hashToken(raw))
+	if errors.Is(err, ErrNotFound) {
+		return nil, apierror.Invalid(invalid)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.Purpose != purpose || token.UsedAt != nil || !now.Before(token.ExpiresAt) {
+		return nil, apierror.Invalid(invalid)
+	}
+	user, err := tx.Users().Find(token.UserID)
+	if err != nil {
+		return nil, notFoundAs(err, invalid)
+	}
+	return user, tx.UserTokens().UseAll(user.ID, purpose, now)
+}
+
+// claimUnverified hands an account whose email was never verified to whoever
+// just proved they own that email. The account may have been registered by
+// someone else, so the password and two-factor setup chosen at sign up are
+// cleared and its sessions revoked before it is marked verified.
+func claimUnverified(tx Store, user *models.User, now time.Time) error {
+	user.Password = ""
+	user.EmailVerifiedAt = &now
+	if err := clearTwoFactor(tx, user, now); err != nil {
+		return err
+	}
+	return revokeSessions(tx, user, now)
+}
+
+// ResendVerification mails a new verification link. Nothing is sent for
+// unknown or already verified addresses, and the caller cannot tell the
+// difference, so the endpoint does not reveal which emails have accounts.
+func (s *AccountService) ResendVerification(email, locale string) error {
+	user, err := s.store.Users().FindByEmail(email)
+	if errors.Is(err, ErrNotFound) || err == nil && user.EmailVerifiedAt != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx Store) error {
+		link, err := queueVerification(tx, user, s.Now())
+		if err != nil {
+			return err
+		}
+		return tx.Outbox().QueueEmail("verify_email", locale, user.Email, link)
+	})
+}
+
+func (s *AccountService) VerifyEmail(raw string) (*models.User, error) {
+	var user *models.User
+	err := s.store.Transaction(func(tx Store) error {
+		now := s.Now()
+		var err error
+		user, err = redeem(tx, raw, PurposeVerifyEmail, now, apierror.InvalidVerifyToken)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt != nil {
+			return nil
+		}
+		user.EmailVerifiedAt = &now
+		return tx.Users().Save(user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RequestPasswordReset mails a reset link if email has an account, and
+// succeeds silently otherwise.
+func (s *AccountService) RequestPasswordReset(email, locale string) error {
+	user, err := s.store.Users().FindByEmail(email)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx Store) error {
+		raw, err := issueUserToken(tx, user.ID, PurposeResetPassword, s.Now(), ResetPasswordTTL)
+		if err != nil {
+			return err
+		}
+		return tx.Outbox().QueueEmail("password_reset", locale, user.Email, AccountLink{
+			Name:  user.Name,
+			Email: user.Email,
+			URL:   appURL("/reset-password", raw),
+			Hours: int(ResetPasswordTTL.Hours()),
+		})
+	})
+}
+
+// ResetPassword sets a new password and signs the user out everywhere. The
+// link reached the user's inbox, so it also verifies their email, claiming an
+// unverified account like a social login does.
+func (s *AccountService) ResetPassword(raw, password, locale string) error {
+	if err := s.Passwords.Check("password", password); err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx Store) error {
+		now := s.Now()
+		user, err := redeem(tx, raw, PurposeResetPassword, now, apierror.InvalidResetToken)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt == nil {
+			if err := claimUnverified(tx, user, now); err != nil {
+				return err
+			}
+		}
+		user.Password = string(hashed)
+		if err := revokeSessions(tx, user, now); err != nil {
+			return err
+		}
+		// The owner proved access to the mailbox, so a lockout no longer
+		// protects them.
+		if err := tx.LoginThrottles().Delete(accountThrottleKey(user.Email)); err != nil {
+			return err
+		}
+		return tx.Outbox().QueueEmail("password_changed", locale, user.Email, map[string]string{"Name": user.Name, "Email": user.Email})
+	})
+}
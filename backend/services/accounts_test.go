@@ -0,0 +1,39 @@
+package services_test
+
+import (
+	"booking-backend/models"
+	"booking-backend/services"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestResetLinkExpires(t *testing.T) {
+	store := services.NewMemoryStore()
+	svc := services.New(store)
+	user := &models.User{Name: "A", Email: "a@example.com", Password: "x"}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.Accounts.Now = func() time.Time { return now }
+
+	if err := svc.Accounts.RequestPasswordReset(user.Email, "en"); err != nil {
+		t.Fatal(err)
+	}
+	emails := store.Emails()
+	if len(emails) != 1 || emails[0].Template != "password_reset" {
+		t.Fatalf("expected a reset email, got %+v", emails)
+	}
+	link, _ := url.Parse(emails[0].Data.(services.AccountLink).URL)
+
+	now = now.Add(services.ResetPasswordTTL)
+	if err := svc.Accounts.ResetPassword(link.Query().Get("token"), "new-password", "en"); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected an expired link to be rejected, got %v", err)
+	}
+	stored, _ := store.Users().Find(user.ID)
+	if stored.Password != "x" || stored.EmailVerifiedAt != nil {
+		t.Fatalf("user changed by a rejected reset: %+v", stored)
+	}
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/utils"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	// APIKeyPrefix starts every API key, which tells keys apart from access
+	// tokens in the Authorization header.
+	APIKeyPrefix = "bk_"
+	// APIKeyTouchInterval is how stale last_used_at may get, so busy
+	// integrations do not write on every request.
+	APIKeyTouchInterval = time.Minute
+	// DefaultAPIKeyRotationGrace is how long a rotated key keeps working
+	// unless API_KEY_ROTATION_GRACE says otherwise.
+	DefaultAPIKeyRotationGrace = 24 * time.Hour
+
+	ScopeReadSessions   = "sessions:read"
+	ScopeCreateBookings = "bookings:create"
+	ScopeManageBookings = "bookings:manage"
+
+	AuditAPIKeyCreated = "api_key.created"
+	AuditAPIKeyRotated = "api_key.rotated"
+	AuditAPIKeyRevoked = "api_key.revoked"
+)
+
+// APIScopes are the scopes an API key can hold.
+var APIScopes = []string{ScopeReadSessions, ScopeCreateBookings, ScopeManageBookings}
+
+// scopePermissions are the restaurant permissions each scope grants a key.
+// Guests read sessions and book without any permission, so those scopes
+// grant none; they only limit what a key may do.
+var scopePermissions = map[string][]Permission{
+	ScopeManageBookings: {ViewBookings, ManageBookings, CheckInBookings},
+}
+
+// apiKeyPrefixLength is how much of a key is stored in clear, enough to tell
+// keys apart in a list.
+var apiKeyPrefixLength = len(APIKeyPrefix) + 8
+
+// Principal is who an authenticated request acts for: a user, or a
+// restaurant's API key.
+type Principal struct {
+	User *models.User
+	Key  *models.APIKey
+}
+
+func apiKeyScopes(key *models.APIKey) []string {
+	return strings.Fields(key.Scopes)
+}
+
+func keyGrants(key *models.APIKey, perm Permission) bool {
+	for _, scope := range apiKeyScopes(key) {
+		for _, p := range scopePermissions[scope] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type CreateAPIKeyInput struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// APIKeyView is an API key as listed to managers. The key itself is only
+// ever returned by Create and Rotate.
+type APIKeyView struct {
+	ID           uint       `json:"id"`
+	RestaurantID uint       `json:"restaurant_id"`
+	Name         string     `json:"name"`
+	Prefix       string     `json:"prefix"`
+	Scopes       []string   `json:"scopes"`
+	CreatedByID  *uint      `json:"created_by_id"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+	RotatedAt    *time.Time `json:"rotated_at"`
+	// PreviousExpiresAt is when the key replaced by the last rotation stops
+	// working.
+	PreviousExpiresAt *time.Time `json:"previous_expires_at"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+func NewAPIKeyView(key *models.APIKey) APIKeyView {
+	return APIKeyView{
+		ID:                key.ID,
+		RestaurantID:      key.RestaurantID,
+		Name:              key.Name,
+		Prefix:            key.Prefix,
+		Scopes:            apiKeyScopes(key),
+		CreatedByID:       key.CreatedByID,
+		LastUsedAt:        key.LastUsedAt,
+		RotatedAt:         key.RotatedAt,
+		PreviousExpiresAt: key.PreviousExpiresAt,
+		CreatedAt:         key.CreatedAt,
+	}
+}
+
+// IssuedAPIKey is a key that was just created or rotated, together with the
+// secret the partner system has to store.
+type IssuedAPIKey struct {
+	APIKeyView
+	Key string `json:"key"`
+}
+
+// APIKeyService manages the keys partner systems, such as a hotel concierge
+// or a POS, use to call the API for one restaurant without a user login.
+type APIKeyService struct {
+	store Store
+	// RotationGrace is how long the previous secret keeps working after a
+	// rotation, so partners can deploy the new one without downtime.
+	RotationGrace time.Duration
+	Now           func() time.Time
+}
+
+func NewAPIKeyService(store Store, rotationGrace time.Duration) *APIKeyService {
+	return &APIKeyService{store: store, RotationGrace: rotationGrace, Now: time.Now}
+}
+
+// APIKeyRotationGraceFromEnv reads API_KEY_ROTATION_GRACE, a duration such
+// as "24h" or "0" for none.
+func APIKeyRotationGraceFromEnv() time.Duration {
+	value := utils.GetEnv("API_KEY_ROTATION_GRACE", "")
+	if value == "" {
+		return DefaultAPIKeyRotationGrace
+	}
+	grace, err := time.ParseDuration(value)
+	if err != nil || grace < 0 {
+		log.Fatalf("API_KEY_ROTATION_GRACE: invalid duration %q", value)
+	}
+	return grace
+}
+
+func (s *APIKeyService) List(restaurantID uint) ([]APIKeyView, error) {
+	if _, err := s.store.Restaurants().Find(restaurantID); err != nil {
+		return nil, notFoundAs(err, apierror.RestaurantNotFound)
+	}
+	keys, err := s.store.APIKeys().List(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	views := make([]APIKeyView, len(keys))
+	for i := range keys {
+		views[i] = NewAPIKeyView(&keys[i])
+	}
+	return views, nil
+}
+
+func (s *APIKeyService) Create(actor *models.User, restaurantID uint, input CreateAPIKeyInput, ip string) (*IssuedAPIKey, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, apierror.Validation(apierror.Field("name", apierror.Required))
+	}
+	scopes, err := normalizeScopes(input.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	var issued *IssuedAPIKey
+	err = s.store.Transaction(func(tx Store) error {
+		if _, err := tx.Restaurants().Find(restaurantID); err != nil {
+			return notFoundAs(err, apierror.RestaurantNotFound)
+		}
+		raw, err := newAPIKey()
+		if err != nil {
+			return err
+		}
+		key := &models.APIKey{
+			RestaurantID: restaurantID,
+			Name:         name,
+			Prefix:       raw[:apiKeyPrefixLength],
+			KeyHash:      hashToken(raw),
+			Scopes:       strings.Join(scopes, " "),
+			CreatedByID:  &actor.ID,
+			CreatedAt:    s.Now(),
+		}
+		if err := tx.APIKeys().Create(key); err != nil {
+			return err
+		}
+		issued = &IssuedAPIKey{APIKeyView: NewAPIKeyView(key), Key: raw}
+		return auditAPIKey(tx, AuditAPIKeyCreated, actor, key, ip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+// Rotate replaces the secret of a key, keeping its name and scopes. The old
+// secret keeps working for RotationGrace; a secret replaced by an earlier
+// rotation stops working at once.
+func (s *APIKeyService) Rotate(actor *models.User, restaurantID, id uint, ip string) (*IssuedAPIKey, error) {
+	var issued *IssuedAPIKey
+	err := s.store.Transaction(func(tx Store) error {
+		key, err := findAPIKey(tx, restaurantID, id)
+		if err != nil {
+			return err
+		}
+		raw, err := newAPIKey()
+		if err != nil {
+			return err
+		}
+		now := s.Now()
+		key.PreviousHash, key.PreviousExpiresAt = "", nil
+		if s.RotationGrace > 0 {
+			expires := now.Add(s.RotationGrace)
+			key.PreviousHash, key.PreviousExpiresAt = key.KeyHash, &expires
+		}
+		key.Prefix = raw[:apiKeyPrefixLength]
+		key.KeyHash = hashToken(raw)
+		key.RotatedAt = &now
+		if err := tx.APIKeys().Save(key); err != nil {
+			return err
+		}
+		issued = &IssuedAPIKey{APIKeyView: NewAPIKeyView(key), Key: raw}
+		return auditAPIKey(tx, AuditAPIKeyRotated, actor, key, ip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return issued, nil
+}
+
+func (s *APIKeyService) Revoke(actor *models.User, restaurantID, id uint, ip string) error {
+	return s.store.Transaction(func(tx Store) error {
+		key, err := findAPIKey(tx, restaurantID, id)
+		if err != nil {
+			return err
+		}
+		now := s.Now()
+		key.RevokedAt = &now
+		if err := tx.APIKeys().Save(key); err != nil {
+			return err
+		}
+		return auditAPIKey(tx, AuditAPIKeyRevoked, actor, key, ip)
+	})
+}
+
+// Authenticate returns the key raw was issued as, recording that it was used.
+// A secret replaced by a rotation is accepted until PreviousExpiresAt.
+func (s *APIKeyService) Authenticate(raw string) (*models.APIKey, error) {
+	if !strings.HasPrefix(raw, APIKeyPrefix) {
+		return nil, apierror.Unauthorized(apierror.InvalidAPIKey)
+	}
+	hash := hashToken(raw)
+	key, err := s.store.APIKeys().FindByHash(hash)
+	if errors.Is(err, ErrNotFound) {
+		return nil, apierror.Unauthorized(apierror.InvalidAPIKey)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, apierror.Unauthorized(apierror.InvalidAPIKey)
+	}
+	now := s.Now()
+	if key.KeyHash != hash && (key.PreviousExpiresAt == nil || !now.Before(*key.PreviousExpiresAt)) {
+		return nil, apierror.Unauthorized(apierror.InvalidAPIKey)
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= APIKeyTouchInterval {
+		key.LastUsedAt = &now
+		if err := s.store.APIKeys().Save(key); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// findAPIKey loads a key that has not been revoked, answering 404 for keys
+// of other restaurants.
+func findAPIKey(tx Store, restaurantID, id uint) (*models.APIKey, error) {
+	key, err := tx.APIKeys().Find(id)
+	if err != nil {
+		return nil, notFoundAs(err, apierror.APIKeyNotFound)
+	}
+	if key.RestaurantID != restaurantID || key.RevokedAt != nil {
+		return nil, apierror.NotFound(apierror.APIKeyNotFound)
+	}
+	return key, nil
+}
+
+// normalizeScopes checks the requested scopes and returns them without
+// duplicates, in the order of APIScopes.
+func normalizeScopes(requested []string) ([]string, error) {
+	if len(requested) == 0 {
+		return nil, apierror.Validation(apierror.Field("scopes", apierror.Required))
+	}
+	for _, scope := range requested {
+		if !contains(APIScopes, scope) {
+			return nil, apierror.Validation(apierror.Field("scopes", apierror.NotAllowed))
+		}
+	}
+	var scopes []string
+	for _, scope := range APIScopes {
+		if contains(requested, scope) {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+func newAPIKey() (string, error) {
+	secret, err := randomString(32)
+	if err != nil {
+		return "", err
+	}
+	return APIKeyPrefix + secret, nil
+}
+
+func auditAPIKey(tx Store, eventType string, actor *models.User, key *models.APIKey, ip string) error {
+	details := fmt.Sprintf("%q with %s at restaurant %d", key.Name, key.Scopes, key.RestaurantID)
+	if eventType == AuditAPIKeyRotated && key.PreviousExpiresAt != nil {
+		details += fmt.Sprintf(", previous key valid until %s", key.PreviousExpiresAt.UTC().Format(time.RFC3339))
+	}
+	return tx.Audit().Record(&models.AuditEvent{
+		Type:    eventType,
+		UserID:  &actor.ID,
+		Subject: fmt.Sprintf("api_key:%d", key.ID),
+		IP:      ip,
+		Details: details,
+	})
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/auth"
+	"booking-backend/models"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+type AuthService struct {
+	store           Store
+	AccountThrottle ThrottlePolicy
+	IPThrottle      ThrottlePolicy
+	// TwoFactorRoles are the roles that must use two-factor authentication.
+	TwoFactorRoles []string
+	Now            func() time.Time
+}
+
+func NewAuthService(store Store, twoFactorRoles []string) *AuthService {
+	return &AuthService{store: store, AccountThrottle: AccountThrottle, IPThrottle: IPThrottle, TwoFactorRoles: twoFactorRoles, Now: time.Now}
+}
+
+// Tokens is returned by login and refresh. The access token keeps the
+// historic "token" name so existing clients keep working.
+type Tokens struct {
+	AccessToken  string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// Login issues tokens for an authenticated user, starting a new refresh token
+// family.
+func (s *AuthService) Login(user *models.User) (*Tokens, error) {
+	family, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	var tokens *Tokens
+	err = s.store.Transaction(func(tx Store) error {
+		tokens, _, err = s.issue(tx, user, family, s.Now())
+		return err
+	})
+	return tokens, err
+}
+
+// Refresh exchanges a refresh token for new tokens. Each refresh token works
+// once: presenting one that was already rotated means it was copied, so its
+// whole family is revoked and the holder has to log in again. Users whose
+// role requires two-factor authentication they have not enabled are refused,
+// so they set it up at their next login.
+func (s *AuthService) Refresh(raw string) (*models.User, *Tokens, error) {
+	var user *models.User
+	var tokens *Tokens
+	err := s.store.Transaction(func(tx Store) error {
+		current, err := tx.RefreshTokens().FindByHash(hashToken(raw))
+		if err != nil {
+			return err
+		}
+		now := s.Now()
+		if current.RevokedAt != nil {
+			if current.ReplacedByID != nil {
+				return tx.RefreshTokens().RevokeFamily(current.FamilyID, now)
+			}
+			return nil
+		}
+		if !now.Before(current.ExpiresAt) {
+			return nil
+		}
+		user, err = tx.Users().Find(current.UserID)
+		if err != nil {
+			return err
+		}
+		if user.TwoFactorEnabledAt == nil && contains(s.TwoFactorRoles, user.Role) {
+			return apierror.Unauthorized(apierror.TwoFactorRequired)
+		}
+
+		var next *models.RefreshToken
+		tokens, next, err = s.issue(tx, user, current.FamilyID, current.AuthenticatedAt)
+		if err != nil {
+			return err
+		}
+		current.RevokedAt = &now
+		current.ReplacedByID = &next.ID
+		return tx.RefreshTokens().Save(current)
+	})
+	if errors.Is(err, ErrNotFound) || err == nil && tokens == nil {
+		return nil, nil, apierror.Unauthorized(apierror.InvalidRefreshToken)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, tokens, nil
+}
+
+// Logout revokes the refresh token and every token rotated from the same
+// login. Unknown tokens are ignored so logout can always succeed.
+func (s *AuthService) Logout(raw string) error {
+	current, err := s.store.RefreshTokens().FindByHash(hashToken(raw))
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.store.RefreshTokens().RevokeFamily(current.FamilyID, s.Now())
+}
+
+// RevokeAll signs the user out everywhere: their refresh tokens are revoked
+// and bumping the token version invalidates access tokens already issued.
+func (s *AuthService) RevokeAll(userID uint) error {
+	return s.store.Transaction(func(tx Store) error {
+		user, err := tx.Users().Find(userID)
+		if err != nil {
+			return notFoundAs(err, apierror.UserNotFound)
+		}
+		return revokeSessions(tx, user, s.Now())
+	})
+}
+
+// revokeSessions saves user with a bumped token version and revokes their
+// refresh tokens.
+func revokeSessions(tx Store, user *models.User, now time.Time) error {
+	user.TokenVersion++
+	if err := tx.Users().Save(user); err != nil {
+		return err
+	}
+	return tx.RefreshTokens().RevokeUser(user.ID, now)
+}
+
+// issue creates a refresh token in family and an access token for a login
+// made at authenticatedAt.
+func (s *AuthService) issue(tx Store, user *models.User, family string, authenticatedAt time.Time) (*Tokens, *models.RefreshToken, error) {
+	now := s.Now()
+	raw, err := randomString(32)
+	if err != nil {
+		return nil, nil, err
+	}
+	record := &models.RefreshToken{
+		UserID:          user.ID,
+		FamilyID:        family,
+		TokenHash:       hashToken(raw),
+		AuthenticatedAt: authenticatedAt,
+		ExpiresAt:       now.Add(RefreshTokenTTL),
+	}
+	if err := tx.RefreshTokens().Create(record); err != nil {
+		return nil, nil, err
+	}
+	access, err := auth.IssueAccessToken(user.ID, user.Email, user.TokenVersion, now, authenticatedAt)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: raw,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(auth.AccessTokenTTL.Seconds()),
+	}, record, nil
+}
+
+func randomString(size int) (string, error) {
+	b := make([]byte, size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,36 @@
+package services_test
+
+import (
+	"booking-backend/apitest"
+	"booking-backend/models"
+	"booking-backend/services"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRefreshTokenExpires(t *testing.T) {
+	apitest.UseTestKeys(t)
+	store := services.NewMemoryStore()
+	svc := services.New(store)
+	user := &models.User{Name: "A", Email: "a@example.com", Password: "x"}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.Auth.Now = func() time.Time { return now }
+
+	tokens, err := svc.Auth.Login(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(services.RefreshTokenTTL - time.Second)
+	if _, tokens, err = svc.Auth.Refresh(tokens.RefreshToken); err != nil {
+		t.Fatalf("refresh before expiry: %v", err)
+	}
+
+	now = now.Add(services.RefreshTokenTTL)
+	if _, _, err := svc.Auth.Refresh(tokens.RefreshToken); !errors.Is(err, services.ErrUnauthorized) {
+		t.Fatalf("expected expired refresh token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// MaxSearchDays bounds the date range of one availability search.
+	MaxSearchDays = 62
+	// MaxSearchRadiusKm bounds the radius of a location search.
+	MaxSearchRadiusKm  = 100
+	DefaultRadiusKm    = 5
+	DefaultSearchLimit = 20
+	MaxSearchLimit     = 50
+
+	searchCandidates      = 200
+	alternativeWindowDays = 7
+	maxAlternatives       = 5
+)
+
+// Reasons an alternative was suggested instead of an exact match.
+const (
+	ReasonOtherTimeSlot = "other_time_slot"
+	ReasonOtherDate     = "other_date"
+	ReasonFurtherAway   = "further_away"
+)
+
+var bangkok = time.FixedZone("Asia/Bangkok", 7*60*60)
+
+type AvailabilityQuery struct {
+	DateFrom     string
+	DateTo       string
+	PartySize    int
+	TimeSlotID   *uint
+	RestaurantID *uint
+	Near         *GeoPoint
+	RadiusKm     float64
+	Limit        int
+}
+
+type AvailableSession struct {
+	SessionID      uint              `json:"session_id"`
+	Name           string            `json:"name"`
+	Date           string            `json:"date"`
+	AvailableSlots int               `json:"available_slots"`
+	MaxGuests      int               `json:"max_guests"`
+	TimeSlot       models.TimeSlot   `json:"time_slot"`
+	Restaurant     models.Restaurant `json:"restaurant"`
+	DistanceKm     *float64          `json:"distance_km,omitempty"`
+	Reason         string            `json:"reason,omitempty"`
+}
+
+type AvailabilityResult struct {
+	Results      []AvailableSession `json:"results"`
+	Alternatives []AvailableSession `json:"alternatives"`
+}
+
+// Search finds sessions with room for the party. Results are ranked soonest
+// first, then nearest, then by most free seats. When nothing matches, up to
+// five alternatives are suggested: other time slots on the same dates, the
+// closest dates within a week of the range, and restaurants further away.
+func (s *SessionService) Search(q AvailabilityQuery, now time.Time) (*AvailabilityResult, error) {
+	from, to, err := normalizeSearch(&q, now)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := s.findAvailable(q, q.DateFrom, q.DateTo)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) > q.Limit {
+		results = results[:q.Limit]
+	}
+	if results == nil {
+		results = []AvailableSession{}
+	}
+	result := &AvailabilityResult{Results: results, Alternatives: []AvailableSession{}}
+	if len(results) > 0 {
+		return result, nil
+	}
+
+	var groups [][]AvailableSession
+	if q.TimeSlotID != nil {
+		anySlot := q
+		anySlot.TimeSlotID = nil
+		found, err := s.findAvailable(anySlot, q.DateFrom, q.DateTo)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, withReason(found, ReasonOtherTimeSlot))
+	}
+
+	today, _ := time.Parse(dateLayout, now.In(bangkok).Format(dateLayout))
+	windowFrom := from.AddDate(0, 0, -alternativeWindowDays)
+	if windowFrom.Before(today) {
+		windowFrom = today
+	}
+	windowTo := to.AddDate(0, 0, alternativeWindowDays)
+	found, err := s.findAvailable(q, windowFrom.Format(dateLayout), windowTo.Format(dateLayout))
+	if err != nil {
+		return nil, err
+	}
+	var otherDates []AvailableSession
+	for _, session := range found {
+		if day(session.Date) < q.DateFrom || day(session.Date) > q.DateTo {
+			otherDates = append(otherDates, session)
+		}
+	}
+	sort.SliceStable(otherDates, func(i, j int) bool {
+		return daysOutside(otherDates[i].Date, from, to) < daysOutside(otherDates[j].Date, from, to)
+	})
+	groups = append(groups, withReason(otherDates, ReasonOtherDate))
+
+	if q.Near != nil && q.RadiusKm < MaxSearchRadiusKm {
+		wider := q
+		wider.RadiusKm = min(q.RadiusKm*3, MaxSearchRadiusKm)
+		found, err := s.findAvailable(wider, q.DateFrom, q.DateTo)
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(found, func(i, j int) bool { return *found[i].DistanceKm < *found[j].DistanceKm })
+		groups = append(groups, withReason(found, ReasonFurtherAway))
+	}
+
+	result.Alternatives = interleave(groups, maxAlternatives)
+	return result, nil
+}
+
+const dateLayout = "2006-01-02"
+
+// normalizeSearch validates q, fills defaults and moves a range that starts
+// in the past to start today.
+func normalizeSearch(q *AvailabilityQuery, now time.Time) (time.Time, time.Time, error) {
+	if q.PartySize < 1 {
+		return time.Time{}, time.Time{}, apierror.Validation(apierror.Field("party_size", apierror.TooSmall, "min", "1"))
+	}
+	today := now.In(bangkok).Format(dateLayout)
+	if q.DateFrom == "" || q.DateFrom < today {
+		q.DateFrom = today
+	}
+	from, err := time.Parse(dateLayout, q.DateFrom)
+	if err != nil {
+		return time.Time{}, time.Time{}, apierror.Validation(apierror.Field("date_from", apierror.InvalidDate))
+	}
+	if q.DateTo == "" {
+		q.DateTo = from.AddDate(0, 0, MaxSearchDays-1).Format(dateLayout)
+	}
+	to, err := time.Parse(dateLayout, q.DateTo)
+	if err != nil {
+		return time.Time{}, time.Time{}, apierror.Validation(apierror.Field("date_to", apierror.InvalidDate))
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, apierror.Validation(apierror.Field("date_to", apierror.BeforeStart, "start", "date_from"))
+	}
+	if to.Sub(from) >= MaxSearchDays*24*time.Hour {
+		return time.Time{}, time.Time{}, apierror.Invalid(apierror.DateRangeTooLong).With("days", strconv.Itoa(MaxSearchDays))
+	}
+	if q.Near != nil {
+		if q.Near.Lat < -90 || q.Near.Lat > 90 || q.Near.Lng < -180 || q.Near.Lng > 180 {
+			return time.Time{}, time.Time{}, apierror.Validation(apierror.Field("lat", apierror.OutOfRange, "min", "-90", "max", "90"))
+		}
+		if q.RadiusKm == 0 {
+			q.RadiusKm = DefaultRadiusKm
+		}
+		if q.RadiusKm < 0 || q.RadiusKm > MaxSearchRadiusKm {
+			return time.Time{}, time.Time{}, apierror.Validation(apierror.Field("radius_km", apierror.OutOfRange, "min", "0", "max", strconv.Itoa(MaxSearchRadiusKm)))
+		}
+	}
+	if q.Limit <= 0 {
+		q.Limit = DefaultSearchLimit
+	}
+	if q.Limit > MaxSearchLimit {
+		q.Limit = MaxSearchLimit
+	}
+	return from, to, nil
+}
+
+// findAvailable returns ranked sessions between dateFrom and dateTo at active
+// restaurants that match q.
+func (s *SessionService) findAvailable(q AvailabilityQuery, dateFrom, dateTo string) ([]AvailableSession, error) {
+	filter := SessionFilter{
+		RestaurantID: q.RestaurantID,
+		TimeSlotID:   q.TimeSlotID,
+		DateFrom:     dateFrom,
+		DateTo:       dateTo,
+		MinAvailable: q.PartySize,
+	}
+	available := true
+	filter.HasAvailability = &available
+
+	distances := map[uint]float64{}
+	if q.Near != nil {
+		box := BoxAround(*q.Near, q.RadiusKm)
+		nearby, _, err := s.store.Restaurants().Search(
+			RestaurantFilter{IsActive: &available, Within: &box},
+			pagination.First(RestaurantSorts, "id", searchCandidates),
+		)
+		if err != nil {
+			return nil, err
+		}
+		filter.RestaurantIDs = []uint{}
+		for _, r := range nearby {
+			distance := DistanceKm(*q.Near, GeoPoint{Lat: *r.Latitude, Lng: *r.Longitude})
+			if distance <= q.RadiusKm {
+				filter.RestaurantIDs = append(filter.RestaurantIDs, r.ID)
+				distances[r.ID] = distance
+			}
+		}
+	}
+
+	sessions, _, err := s.store.Sessions().Search(filter, pagination.First(SessionSorts, "date", searchCandidates))
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+	restaurantIDs := make([]uint, 0, len(sessions))
+	for _, session := range sessions {
+		restaurantIDs = append(restaurantIDs, session.RestaurantID)
+	}
+	restaurants, err := s.store.Restaurants().FindMany(restaurantIDs)
+	if err != nil {
+		return nil, err
+	}
+	restaurantsByID := make(map[uint]models.Restaurant, len(restaurants))
+	for _, r := range restaurants {
+		restaurantsByID[r.ID] = r
+	}
+
+	var found []AvailableSession
+	for _, session := range sessions {
+		restaurant, ok := restaurantsByID[session.RestaurantID]
+		if !ok || !restaurant.IsActive {
+			continue
+		}
+		item := AvailableSession{
+			SessionID:      session.ID,
+			Name:           session.Name,
+			Date:           session.Date,
+			AvailableSlots: session.AvailableSlots,
+			MaxGuests:      session.MaxGuests,
+			TimeSlot:       session.TimeSlot,
+			Restaurant:     restaurant,
+		}
+		if distance, ok := distances[session.RestaurantID]; ok {
+			item.DistanceKm = &distance
+		}
+		found = append(found, item)
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		a, b := found[i], found[j]
+		if day(a.Date) != day(b.Date) {
+			return day(a.Date) < day(b.Date)
+		}
+		if a.DistanceKm != nil && b.DistanceKm != nil && *a.DistanceKm != *b.DistanceKm {
+			return *a.DistanceKm < *b.DistanceKm
+		}
+		return a.AvailableSlots > b.AvailableSlots
+	})
+	return found, nil
+}
+
+// day cuts a DATE column value, which drivers may return as a timestamp, to
+// YYYY-MM-DD.
+func day(date string) string {
+	if len(date) > len(dateLayout) {
+		return date[:len(dateLayout)]
+	}
+	return date
+}
+
+// daysOutside is how many days date lies before from or after to.
+func daysOutside(date string, from, to time.Time) int {
+	d, err := time.Parse(dateLayout, day(date))
+	if err != nil {
+		return 1 << 30
+	}
+	if d.Before(from) {
+		return int(from.Sub(d).Hours() / 24)
+	}
+	return int(d.Sub(to).Hours() / 24)
+}
+
+func withReason(sessions []AvailableSession, reason string) []AvailableSession {
+	for i := range sessions {
+		sessions[i].Reason = reason
+	}
+	return sessions
+}
+
+// interleave takes the best remaining entry from each group in turn, so every
+// kind of alternative is represented, skipping sessions already taken.
+func interleave(groups [][]AvailableSession, limit int) []AvailableSession {
+	picked := []AvailableSession{}
+	seen := map[uint]bool{}
+	for progress := true; progress && len(picked) < limit; {
+		progress = false
+		for i := range groups {
+			for len(groups[i]) > 0 && seen[groups[i][0].SessionID] {
+				groups[i] = groups[i][1:]
+			}
+			if len(groups[i]) == 0 || len(picked) == limit {
+				continue
+			}
+			picked = append(picked, groups[i][0])
+			seen[groups[i][0].SessionID] = true
+			groups[i] = groups[i][1:]
+			progress = true
+		}
+	}
+	return picked
+}
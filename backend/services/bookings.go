@@ -0,0 +1,359 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/notifications"
+	"booking-backend/pagination"
+	"booking-backend/websocket"
+	"errors"
+	"strings"
+	"time"
+)
+
+type BookingService struct {
+	store Store
+}
+
+func NewBookingService(store Store) *BookingService {
+	return &BookingService{store: store}
+}
+
+type BookingWithRestaurant struct {
+	models.Booking
+	SessionDate    string `json:"session_date"`
+	RestaurantName string `json:"restaurant_name"`
+}
+
+type CreateBookingInput struct {
+	SessionID      uint    `json:"session_id"`
+	Name           string  `json:"name"`
+	Email          string  `json:"email"`
+	Phone          *string `json:"phone"`
+	NumberOfGuests int     `json:"number_of_guests"`
+	Notes          string  `json:"notes"`
+}
+
+type UpdateBookingInput struct {
+	UserName       *string `json:"user_name"`
+	UserEmail      *string `json:"user_email"`
+	UserPhone      *string `json:"user_phone"`
+	NumberOfGuests *int    `json:"number_of_guests"`
+	Status         *string `json:"status"`
+	Notes          *string `json:"notes"`
+}
+
+// List returns one page of bookings, each with its session date and the name
+// of the restaurant its session belongs to.
+func (s *BookingService) List(filter BookingFilter, page pagination.Params) (pagination.Page[BookingWithRestaurant], error) {
+	rows, total, err := s.store.Bookings().Search(filter, page)
+	if err != nil {
+		return pagination.Page[BookingWithRestaurant]{}, err
+	}
+	return pagination.NewPage(rows, total, page, bookingKey), nil
+}
+
+// ListForUser returns the bookings made with user's account, newest first.
+func (s *BookingService) ListForUser(user *models.User) ([]models.Booking, error) {
+	return s.store.Bookings().ListByUser(user.ID)
+}
+
+func validatePhone(phone string) error {
+	for _, ch := range phone {
+		if ch < '0' || ch > '9' {
+			return apierror.Validation(apierror.Field("phone", apierror.PhoneDigitsOnly))
+		}
+	}
+	if len(phone) != 10 || phone[0] != '0' {
+		return apierror.Validation(apierror.Field("phone", apierror.PhoneFormat))
+	}
+	return nil
+}
+
+// Create books seats in a session for a signed in user, whose email must be
+// verified. The booking belongs to their account and carries its email. The
+// capacity check, seat decrement and confirmation email are committed
+// together.
+func (s *BookingService) Create(user *models.User, input CreateBookingInput, locale string) (*models.Booking, error) {
+	if user.EmailVerifiedAt == nil {
+		return nil, apierror.Forbidden(apierror.EmailNotVerified)
+	}
+	input.Email = user.Email
+	if input.Name == "" {
+		input.Name = user.Name
+	}
+	return s.create(input, locale, &user.ID)
+}
+
+// CreateForPartner books seats on behalf of a partner system, which vouches
+// for its guests: their email needs no account. The booking is never linked
+// to an account, even one with the same email, since its owner did not make
+// it. Callers check the API key may book at the session's restaurant.
+func (s *BookingService) CreateForPartner(input CreateBookingInput, locale string) (*models.Booking, error) {
+	return s.create(input, locale, nil)
+}
+
+func (s *BookingService) create(input CreateBookingInput, locale string, userID *uint) (*models.Booking, error) {
+	if input.Email == "" {
+		return nil, apierror.Validation(apierror.Field("email", apierror.Required))
+	}
+	if err := validateGuests(input.NumberOfGuests); err != nil {
+		return nil, err
+	}
+	if input.Phone != nil && *input.Phone != "" {
+		if err := validatePhone(*input.Phone); err != nil {
+			return nil, err
+		}
+	}
+
+	booking := models.Booking{
+		SessionID:      input.SessionID,
+		UserName:       input.Name,
+		UserID:         userID,
+		UserEmail:      input.Email,
+		BookingDate:    time.Now().Format("2006-01-02"),
+		NumberOfGuests: input.NumberOfGuests,
+		Status:         "confirmed",
+		Notes:          input.Notes,
+		Locale:         locale,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if input.Phone != nil {
+		booking.UserPhone = *input.Phone
+	}
+
+	err := s.store.Transaction(func(tx Store) error {
+		session, err := tx.Sessions().FindForUpdate(input.SessionID)
+		if err != nil {
+			return notFoundAs(err, apierror.SessionNotFound)
+		}
+		if session.AvailableSlots < input.NumberOfGuests {
+			return apierror.Invalid(apierror.NotEnoughSlots)
+		}
+
+		if err := tx.Bookings().Create(&booking); err != nil {
+			return err
+		}
+		session.AvailableSlots -= input.NumberOfGuests
+		if session.AvailableSlots == 0 {
+			session.IsAvailable = false
+		}
+		if err := tx.Sessions().Save(session); err != nil {
+			return err
+		}
+		return queueBookingEmail(tx, "booking_confirmed", booking)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// Update changes a booking as staff. The session's free seats follow the
+// change: more guests, or a cancelled booking confirmed again, take seats and
+// fail with not_enough_slots when the session has too few left.
+func (s *BookingService) Update(id uint, input UpdateBookingInput) (*models.Booking, error) {
+	if input.NumberOfGuests != nil {
+		if err := validateGuests(*input.NumberOfGuests); err != nil {
+			return nil, err
+		}
+	}
+	if input.Status != nil && !contains(bookingStatuses, *input.Status) {
+		return nil, apierror.Validation(apierror.Field("status", apierror.NotAllowed, "allowed", strings.Join(bookingStatuses, ", ")))
+	}
+	var booking *models.Booking
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		booking, err = tx.Bookings().FindForUpdate(id)
+		if err != nil {
+			return notFoundAs(err, apierror.BookingNotFound)
+		}
+		oldStatus := booking.Status
+		held := seatsHeld(*booking)
+
+		if input.UserName != nil {
+			booking.UserName = *input.UserName
+		}
+		if input.UserEmail != nil {
+			booking.UserEmail = *input.UserEmail
+		}
+		if input.UserPhone != nil {
+			booking.UserPhone = *input.UserPhone
+		}
+		if input.NumberOfGuests != nil {
+			booking.NumberOfGuests = *input.NumberOfGuests
+		}
+		if input.Status != nil {
+			booking.Status = *input.Status
+		}
+		if input.Notes != nil {
+			booking.Notes = *input.Notes
+		}
+
+		cancelled := oldStatus != "cancelled" && booking.Status == "cancelled"
+		if err := holdSeats(tx, booking.SessionID, seatsHeld(*booking)-held); err != nil {
+			return err
+		}
+		if err := tx.Bookings().Save(booking); err != nil {
+			return err
+		}
+		if cancelled {
+			return queueBookingEmail(tx, "booking_cancelled", *booking)
+		} else if booking.Status != "cancelled" {
+			return queueBookingEmail(tx, "booking_modified", *booking)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+func (s *BookingService) Delete(id uint) error {
+	return s.store.Transaction(func(tx Store) error {
+		booking, err := tx.Bookings().FindForUpdate(id)
+		if err != nil {
+			return notFoundAs(err, apierror.BookingNotFound)
+		}
+		if err := holdSeats(tx, booking.SessionID, -seatsHeld(*booking)); err != nil {
+			return err
+		}
+		if booking.Status != "cancelled" {
+			if err := queueBookingEmail(tx, "booking_cancelled", *booking); err != nil {
+				return err
+			}
+		}
+		return tx.Bookings().Delete(booking)
+	})
+}
+
+// CheckIn records that the guests of a booking have arrived. Checking in
+// twice keeps the first time.
+func (s *BookingService) CheckIn(id uint, at time.Time) (*models.Booking, error) {
+	var booking *models.Booking
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		booking, err = tx.Bookings().FindForUpdate(id)
+		if err != nil {
+			return notFoundAs(err, apierror.BookingNotFound)
+		}
+		if booking.Status == "cancelled" {
+			return apierror.Invalid(apierror.BookingAlreadyCancelled)
+		}
+		if booking.CheckedInAt != nil {
+			return nil
+		}
+		booking.CheckedInAt = &at
+		return tx.Bookings().Save(booking)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// Cancel lets a guest cancel a booking made with their account; other
+// bookings, including partner bookings that belong to no account, are not
+// found. Other guests are told the seats are free again.
+func (s *BookingService) Cancel(id uint, user *models.User) (*models.Booking, error) {
+	var booking *models.Booking
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		booking, err = tx.Bookings().FindForUpdate(id)
+		if err != nil {
+			return notFoundAs(err, apierror.BookingNotFound)
+		}
+		if booking.UserID == nil || *booking.UserID != user.ID {
+			return apierror.NotFound(apierror.BookingNotFound)
+		}
+		if booking.Status == "cancelled" {
+			return apierror.Invalid(apierror.BookingAlreadyCancelled)
+		}
+		booking.Status = "cancelled"
+		if err := tx.Bookings().Save(booking); err != nil {
+			return err
+		}
+
+		session, err := tx.Sessions().FindForUpdate(booking.SessionID)
+		if err == nil {
+			session.AvailableSlots += booking.NumberOfGuests
+			session.IsAvailable = true
+			if err := tx.Sessions().Save(session); err != nil {
+				return err
+			}
+			if err := tx.Outbox().QueueEvent(websocket.SessionCancelledEvent(session.Name, booking.UserName)); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		return queueBookingEmail(tx, "booking_cancelled", *booking)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return booking, nil
+}
+
+// bookingStatuses are the statuses staff may set on a booking.
+var bookingStatuses = []string{"confirmed", "cancelled"}
+
+// validateGuests rejects parties of fewer than one guest, which would give
+// seats back instead of taking them.
+func validateGuests(guests int) error {
+	if guests < 1 {
+		return apierror.Validation(apierror.Field("number_of_guests", apierror.TooSmall, "min", "1"))
+	}
+	return nil
+}
+
+// seatsHeld is how many seats of its session a booking takes; cancelled
+// bookings take none.
+func seatsHeld(booking models.Booking) int {
+	if booking.Status == "cancelled" {
+		return 0
+	}
+	return booking.NumberOfGuests
+}
+
+// holdSeats takes delta more seats of a session, or gives -delta back. Seats
+// of a deleted session are not tracked.
+func holdSeats(tx Store, sessionID uint, delta int) error {
+	if delta == 0 {
+		return nil
+	}
+	session, err := tx.Sessions().FindForUpdate(sessionID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if delta > session.AvailableSlots {
+		return apierror.Invalid(apierror.NotEnoughSlots)
+	}
+	session.AvailableSlots -= delta
+	if delta < 0 {
+		session.IsAvailable = true
+	} else if session.AvailableSlots == 0 {
+		session.IsAvailable = false
+	}
+	return tx.Sessions().Save(session)
+}
+
+func queueBookingEmail(tx Store, template string, booking models.Booking) error {
+	session, err := tx.Sessions().Find(booking.SessionID)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var restaurant models.Restaurant
+	if found, err := tx.Restaurants().Find(session.RestaurantID); err == nil {
+		restaurant = *found
+	}
+	return tx.Outbox().QueueEmail(template, booking.Locale, booking.UserEmail, notifications.NewBookingDetails(booking, *session, restaurant))
+}
@@ -0,0 +1,227 @@
+package services_test
+
+import (
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"booking-backend/services"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newBookingFixture(t *testing.T, seats int) (*services.MemoryStore, *services.Services, models.Session) {
+	t.Helper()
+	store := services.NewMemoryStore()
+	svc := services.New(store)
+	restaurant := store.AddRestaurant(models.Restaurant{Name: "Baan Suan"})
+	slot := store.AddTimeSlot(models.TimeSlot{SlotName: "Dinner"})
+	session, err := svc.Sessions.Create(services.CreateSessionInput{
+		RestaurantID: restaurant.ID,
+		TimeSlotID:   slot.ID,
+		Name:         "Friday dinner",
+		Date:         "2030-01-04",
+		MaxGuests:    seats,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := time.Now()
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := store.Users().Create(&models.User{Name: email, Email: email, EmailVerifiedAt: &verified}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store, svc, *session
+}
+
+// guest returns the verified account newBookingFixture made for email.
+func guest(t *testing.T, store *services.MemoryStore, email string) *models.User {
+	t.Helper()
+	user, err := store.Users().FindByEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func TestCreateBookingCapacity(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 4)
+
+	if _, err := svc.Bookings.Create(guest(t, store, "a@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", NumberOfGuests: 4}, "en"); err != nil {
+		t.Fatal(err)
+	}
+	_, err := svc.Bookings.Create(guest(t, store, "b@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "B", Email: "b@example.com", NumberOfGuests: 1}, "en")
+	if !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected not enough slots, got %v", err)
+	}
+
+	full, _ := store.Sessions().Find(session.ID)
+	if full.AvailableSlots != 0 || full.IsAvailable {
+		t.Fatalf("expected full session, got %+v", full)
+	}
+	if emails := store.Emails(); len(emails) != 1 || emails[0].Template != "booking_confirmed" {
+		t.Fatalf("expected one confirmation email, got %+v", emails)
+	}
+}
+
+func TestCreateBookingRejectsBadPhone(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 4)
+	phone := "12345"
+	_, err := svc.Bookings.Create(guest(t, store, "a@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", Phone: &phone, NumberOfGuests: 1}, "th")
+	if !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected invalid phone, got %v", err)
+	}
+	if bookings, _ := store.Bookings().List(); len(bookings) != 0 {
+		t.Fatalf("expected no booking, got %d", len(bookings))
+	}
+}
+
+func TestCancelBookingOwnership(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 4)
+	owner := guest(t, store, "a@example.com")
+	booking, err := svc.Bookings.Create(owner, services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", NumberOfGuests: 3}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Bookings.Cancel(booking.ID, guest(t, store, "b@example.com")); !errors.Is(err, services.ErrNotFound) {
+		t.Fatalf("expected other guests not to find the booking, got %v", err)
+	}
+	if _, err := svc.Bookings.Cancel(booking.ID, owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Bookings.Cancel(booking.ID, owner); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected already cancelled, got %v", err)
+	}
+
+	released, _ := store.Sessions().Find(session.ID)
+	if released.AvailableSlots != 4 || !released.IsAvailable {
+		t.Fatalf("expected seats released, got %+v", released)
+	}
+	if events := store.Events(); len(events) != 1 || events[0]["type"] != "sessionCancelled" {
+		t.Fatalf("expected one cancellation event, got %+v", events)
+	}
+}
+
+func TestTransactionRollsBack(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 4)
+	err := store.Transaction(func(tx services.Store) error {
+		s, _ := tx.Sessions().Find(session.ID)
+		s.AvailableSlots = 0
+		tx.Sessions().Save(s)
+		return errors.New("boom")
+	})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	page, _ := pagination.Parse(url.Values{}, services.SessionSorts, "id")
+	after, _ := svc.Sessions.List(services.SessionFilter{}, page)
+	if after.Data[0].AvailableSlots != 4 {
+		t.Fatalf("expected rollback, got %d slots", after.Data[0].AvailableSlots)
+	}
+}
+
+func TestUpdateSessionKeepsBookedSeats(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 6)
+	if _, err := svc.Bookings.Create(guest(t, store, "a@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", NumberOfGuests: 4}, "en"); err != nil {
+		t.Fatal(err)
+	}
+
+	input := services.UpdateSessionInput{TimeSlotID: session.TimeSlotID, Name: session.Name, Date: session.Date, MaxGuests: 3}
+	if _, err := svc.Sessions.Update(session.ID, input); !errors.Is(err, services.ErrConflict) {
+		t.Fatalf("expected conflict below booked seats, got %v", err)
+	}
+	input.MaxGuests = 10
+	updated, err := svc.Sessions.Update(session.ID, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.AvailableSlots != 6 {
+		t.Fatalf("expected 6 available, got %d", updated.AvailableSlots)
+	}
+}
+
+func TestUpdateBookingMovesSeats(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 6)
+	booking, err := svc.Bookings.Create(guest(t, store, "a@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", NumberOfGuests: 2}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	available := func() int {
+		t.Helper()
+		s, err := store.Sessions().Find(session.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s.AvailableSlots
+	}
+
+	guests := 5
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{NumberOfGuests: &guests}); err != nil {
+		t.Fatal(err)
+	}
+	if available() != 1 {
+		t.Fatalf("expected 1 seat left, got %d", available())
+	}
+	guests = 8
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{NumberOfGuests: &guests}); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected not enough slots, got %v", err)
+	}
+
+	cancelled, confirmed := "cancelled", "confirmed"
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{Status: &cancelled}); err != nil {
+		t.Fatal(err)
+	}
+	if available() != 6 {
+		t.Fatalf("expected every seat back, got %d", available())
+	}
+	// Confirming it again takes the seats back, if they are still free.
+	other, err := svc.Bookings.Create(guest(t, store, "b@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "B", Email: "b@example.com", NumberOfGuests: 3}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{Status: &confirmed}); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected not enough slots, got %v", err)
+	}
+	guests = 3
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{Status: &confirmed, NumberOfGuests: &guests}); err != nil {
+		t.Fatal(err)
+	}
+	if available() != 0 {
+		t.Fatalf("expected a full session, got %d", available())
+	}
+
+	// Deleting a cancelled booking gives nothing back.
+	if _, err := svc.Bookings.Update(other.ID, services.UpdateBookingInput{Status: &cancelled}); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Bookings.Delete(other.ID); err != nil {
+		t.Fatal(err)
+	}
+	if available() != 3 {
+		t.Fatalf("expected 3 seats left, got %d", available())
+	}
+}
+
+func TestBookingsRejectEmptyPartiesAndUnknownStatuses(t *testing.T) {
+	store, svc, session := newBookingFixture(t, 4)
+	_, err := svc.Bookings.Create(guest(t, store, "a@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", NumberOfGuests: -2}, "en")
+	if !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	booking, err := svc.Bookings.Create(guest(t, store, "a@example.com"), services.CreateBookingInput{SessionID: session.ID, Name: "A", Email: "a@example.com", NumberOfGuests: 2}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	none, pending := 0, "pending"
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{NumberOfGuests: &none}); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if _, err := svc.Bookings.Update(booking.ID, services.UpdateBookingInput{Status: &pending}); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected a validation error, got %v", err)
+	}
+	if s, _ := store.Sessions().Find(session.ID); s.AvailableSlots != 2 {
+		t.Fatalf("expected 2 seats left, got %d", s.AvailableSlots)
+	}
+}
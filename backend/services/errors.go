@@ -0,0 +1,27 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"errors"
+)
+
+// Services fail with *apierror.Error for broken business rules. The kinds are
+// re-exported so callers can check them without importing apierror.
+var (
+	ErrInvalid      = apierror.ErrInvalid
+	ErrUnauthorized = apierror.ErrUnauthorized
+	ErrForbidden    = apierror.ErrForbidden
+	ErrNotFound     = apierror.ErrNotFound
+	ErrConflict     = apierror.ErrConflict
+)
+
+type Error = apierror.Error
+
+// notFoundAs replaces a repository's bare ErrNotFound with a specific code
+// and passes every other error through.
+func notFoundAs(err error, code apierror.Code) error {
+	if errors.Is(err, ErrNotFound) {
+		return apierror.NotFound(code)
+	}
+	return err
+}
@@ -0,0 +1,53 @@
+package services
+
+import (
+	"booking-backend/models"
+	"math"
+)
+
+const earthRadiusKm = 6371.0
+
+type GeoPoint struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// GeoBox is a latitude/longitude rectangle, used to narrow a radius search
+// with an indexable condition before exact distances are computed.
+type GeoBox struct {
+	MinLat, MaxLat float64
+	MinLng, MaxLng float64
+}
+
+// BoxAround returns a box containing every point within radiusKm of p.
+func BoxAround(p GeoPoint, radiusKm float64) GeoBox {
+	dLat := radiusKm / earthRadiusKm * 180 / math.Pi
+	dLng := 180.0
+	if cos := math.Cos(p.Lat * math.Pi / 180); cos > 1e-6 {
+		dLng = math.Min(dLat/cos, 180)
+	}
+	return GeoBox{
+		MinLat: math.Max(p.Lat-dLat, -90),
+		MaxLat: math.Min(p.Lat+dLat, 90),
+		MinLng: p.Lng - dLng,
+		MaxLng: p.Lng + dLng,
+	}
+}
+
+func (b GeoBox) Contains(r models.Restaurant) bool {
+	if r.Latitude == nil || r.Longitude == nil {
+		return false
+	}
+	return *r.Latitude >= b.MinLat && *r.Latitude <= b.MaxLat &&
+		*r.Longitude >= b.MinLng && *r.Longitude <= b.MaxLng
+}
+
+// DistanceKm is the great-circle distance between two points.
+func DistanceKm(a, b GeoPoint) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+	dLat := toRad(b.Lat - a.Lat)
+	dLng := toRad(b.Lng - a.Lng)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Lat))*math.Cos(toRad(b.Lat))*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
@@ -0,0 +1,550 @@
+package services
+
+import (
+	"booking-backend/models"
+	"booking-backend/outbox"
+	"booking-backend/pagination"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore implements Store on top of the application database.
+type GormStore struct {
+	DB *gorm.DB
+}
+
+func NewGormStore(DB *gorm.DB) *GormStore {
+	return &GormStore{DB: DB}
+}
+
+func (s *GormStore) Bookings() BookingRepository             { return gormBookings{s.DB} }
+func (s *GormStore) Sessions() SessionRepository             { return gormSessions{s.DB} }
+func (s *GormStore) Restaurants() RestaurantRepository       { return gormRestaurants{s.DB} }
+func (s *GormStore) Staff() StaffRepository                  { return gormStaff{s.DB} }
+func (s *GormStore) TimeSlots() TimeSlotRepository           { return gormTimeSlots{s.DB} }
+func (s *GormStore) Users() UserRepository                   { return gormUsers{s.DB} }
+func (s *GormStore) RefreshTokens() RefreshTokenRepository   { return gormRefreshTokens{s.DB} }
+func (s *GormStore) Invitations() InvitationRepository       { return gormInvitations{s.DB} }
+func (s *GormStore) UserTokens() UserTokenRepository         { return gormUserTokens{s.DB} }
+func (s *GormStore) OIDCLogins() OIDCLoginRepository         { return gormOIDCLogins{s.DB} }
+func (s *GormStore) Identities() IdentityRepository          { return gormIdentities{s.DB} }
+func (s *GormStore) RecoveryCodes() RecoveryCodeRepository   { return gormRecoveryCodes{s.DB} }
+func (s *GormStore) APIKeys() APIKeyRepository               { return gormAPIKeys{s.DB} }
+func (s *GormStore) LoginThrottles() LoginThrottleRepository { return gormLoginThrottles{s.DB} }
+func (s *GormStore) Audit() AuditLog                         { return gormAudit{s.DB} }
+func (s *GormStore) Outbox() Outbox                          { return gormOutbox{s.DB} }
+
+func (s *GormStore) Transaction(fn func(tx Store) error) error {
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		return fn(&GormStore{DB: tx})
+	})
+}
+
+func translate(err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrNotFound
+	}
+	return err
+}
+
+type gormBookings struct{ DB *gorm.DB }
+
+func (r gormBookings) List() ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.DB.Order("created_at desc").Find(&bookings).Error
+	return bookings, err
+}
+
+func (r gormBookings) ListByUser(userID uint) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&bookings).Error
+	return bookings, err
+}
+
+func (r gormBookings) Search(filter BookingFilter, page pagination.Params) ([]BookingWithRestaurant, int64, error) {
+	query := r.DB.Table("bookings").Joins("LEFT JOIN sessions ON sessions.id = bookings.session_id")
+	if filter.RestaurantID != nil {
+		query = query.Where("sessions.restaurant_id = ?", *filter.RestaurantID)
+	}
+	if filter.RestaurantIDs != nil {
+		query = query.Where("sessions.restaurant_id IN ?", filter.RestaurantIDs)
+	}
+	if filter.SessionID != nil {
+		query = query.Where("bookings.session_id = ?", *filter.SessionID)
+	}
+	if filter.TimeSlotID != nil {
+		query = query.Where("sessions.time_slot_id = ?", *filter.TimeSlotID)
+	}
+	if filter.Status != "" {
+		query = query.Where("bookings.status = ?", filter.Status)
+	}
+	if filter.Email != "" {
+		query = query.Where(`LOWER(bookings.user_email) LIKE ? ESCAPE '\'`, LikePattern(filter.Email))
+	}
+	if filter.DateFrom != "" {
+		query = query.Where("sessions.date >= ?", filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		query = query.Where("sessions.date <= ?", filter.DateTo)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var bookings []BookingWithRestaurant
+	err := page.Apply(query).
+		Select("bookings.*, sessions.date AS session_date, restaurants.name AS restaurant_name").
+		Joins("LEFT JOIN restaurants ON restaurants.id = sessions.restaurant_id").
+		Find(&bookings).Error
+	return bookings, total, err
+}
+
+func (r gormBookings) ListBySessions(sessionIDs []uint) ([]models.Booking, error) {
+	var bookings []models.Booking
+	err := r.DB.Where("session_id IN ?", sessionIDs).Order("id").Find(&bookings).Error
+	return bookings, err
+}
+
+func (r gormBookings) Find(id uint) (*models.Booking, error) {
+	var booking models.Booking
+	if err := r.DB.First(&booking, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &booking, nil
+}
+
+func (r gormBookings) FindForUpdate(id uint) (*models.Booking, error) {
+	var booking models.Booking
+	if err := forUpdate(r.DB).First(&booking, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &booking, nil
+}
+
+func (r gormBookings) Create(booking *models.Booking) error { return r.DB.Create(booking).Error }
+func (r gormBookings) Save(booking *models.Booking) error   { return r.DB.Save(booking).Error }
+func (r gormBookings) Delete(booking *models.Booking) error { return r.DB.Delete(booking).Error }
+
+type gormSessions struct{ DB *gorm.DB }
+
+func (r gormSessions) Search(filter SessionFilter, page pagination.Params) ([]models.Session, int64, error) {
+	query := r.DB.Model(&models.Session{})
+	if filter.RestaurantID != nil {
+		query = query.Where("sessions.restaurant_id = ?", *filter.RestaurantID)
+	}
+	if filter.RestaurantIDs != nil {
+		query = query.Where("sessions.restaurant_id IN ?", filter.RestaurantIDs)
+	}
+	if filter.TimeSlotID != nil {
+		query = query.Where("sessions.time_slot_id = ?", *filter.TimeSlotID)
+	}
+	if filter.DateFrom != "" {
+		query = query.Where("sessions.date >= ?", filter.DateFrom)
+	}
+	if filter.DateTo != "" {
+		query = query.Where("sessions.date <= ?", filter.DateTo)
+	}
+	if filter.MinAvailable > 0 {
+		query = query.Where("sessions.available_slots >= ?", filter.MinAvailable)
+	}
+	if filter.HasAvailability != nil {
+		if *filter.HasAvailability {
+			query = query.Where("sessions.is_available = ? AND sessions.available_slots > 0", true)
+		} else {
+			query = query.Where("NOT (sessions.is_available = ? AND sessions.available_slots > 0)", true)
+		}
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var sessions []models.Session
+	err := page.Apply(query).Preload("TimeSlot").Find(&sessions).Error
+	return sessions, total, err
+}
+
+func (r gormSessions) Find(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := r.DB.Preload("TimeSlot").First(&session, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &session, nil
+}
+
+// FindForUpdate locks the row on Postgres; SQLite transactions already
+// write one at a time.
+func (r gormSessions) FindForUpdate(id uint) (*models.Session, error) {
+	var session models.Session
+	if err := forUpdate(r.DB).Preload("TimeSlot").First(&session, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &session, nil
+}
+
+func (r gormSessions) Create(session *models.Session) error { return r.DB.Create(session).Error }
+
+// Save skips associations so a preloaded TimeSlot is never written back.
+func (r gormSessions) Save(session *models.Session) error {
+	return r.DB.Omit("TimeSlot").Save(session).Error
+}
+
+func (r gormSessions) Delete(session *models.Session) error { return r.DB.Delete(session).Error }
+
+type gormRestaurants struct{ DB *gorm.DB }
+
+func (r gormRestaurants) Search(filter RestaurantFilter, page pagination.Params) ([]models.Restaurant, int64, error) {
+	query := r.DB.Model(&models.Restaurant{})
+	if filter.Name != "" {
+		query = query.Where(`LOWER(name) LIKE ? ESCAPE '\'`, LikePattern(filter.Name))
+	}
+	if filter.IsActive != nil {
+		query = query.Where("is_active = ?", *filter.IsActive)
+	}
+	if box := filter.Within; box != nil {
+		query = query.Where("latitude BETWEEN ? AND ? AND longitude BETWEEN ? AND ?", box.MinLat, box.MaxLat, box.MinLng, box.MaxLng)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var restaurants []models.Restaurant
+	err := page.Apply(query).Find(&restaurants).Error
+	return restaurants, total, err
+}
+
+func (r gormRestaurants) Find(id uint) (*models.Restaurant, error) {
+	var restaurant models.Restaurant
+	if err := r.DB.First(&restaurant, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &restaurant, nil
+}
+
+func (r gormRestaurants) FindMany(ids []uint) ([]models.Restaurant, error) {
+	var restaurants []models.Restaurant
+	err := r.DB.Where("id IN ?", ids).Find(&restaurants).Error
+	return restaurants, err
+}
+
+func (r gormRestaurants) FindByUserID(userID uint) (*models.Restaurant, error) {
+	var link models.UserRestaurant
+	if err := r.DB.Where("user_id = ?", userID).First(&link).Error; err != nil {
+		return nil, translate(err)
+	}
+	return r.Find(link.RestaurantID)
+}
+
+func (r gormRestaurants) Create(restaurant *models.Restaurant) error {
+	return r.DB.Create(restaurant).Error
+}
+
+type gormStaff struct{ DB *gorm.DB }
+
+func (r gormStaff) ListByUser(userID uint) ([]models.UserRestaurant, error) {
+	var links []models.UserRestaurant
+	err := r.DB.Where("user_id = ?", userID).Order("id").Find(&links).Error
+	return links, err
+}
+
+func (r gormStaff) ListByRestaurant(restaurantID uint) ([]models.UserRestaurant, error) {
+	var links []models.UserRestaurant
+	err := r.DB.Preload("User").Where("restaurant_id = ?", restaurantID).Order("id").Find(&links).Error
+	return links, err
+}
+
+func (r gormStaff) Find(restaurantID, userID uint) (*models.UserRestaurant, error) {
+	var link models.UserRestaurant
+	if err := r.DB.Where("restaurant_id = ? AND user_id = ?", restaurantID, userID).First(&link).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &link, nil
+}
+
+func (r gormStaff) Save(link *models.UserRestaurant) error {
+	return r.DB.Omit("User", "Restaurant").Save(link).Error
+}
+
+func (r gormStaff) Delete(link *models.UserRestaurant) error {
+	return r.DB.Delete(&models.UserRestaurant{}, link.ID).Error
+}
+
+type gormInvitations struct{ DB *gorm.DB }
+
+func (r gormInvitations) ListPending(restaurantID uint) ([]models.StaffInvitation, error) {
+	var invitations []models.StaffInvitation
+	err := r.DB.Where("restaurant_id = ? AND accepted_at IS NULL AND revoked_at IS NULL", restaurantID).Order("id").Find(&invitations).Error
+	return invitations, err
+}
+
+func (r gormInvitations) Find(id uint) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	if err := r.DB.First(&invitation, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &invitation, nil
+}
+
+// FindByHash locks the invitation on Postgres, so a link accepted twice at
+// once is accepted once.
+func (r gormInvitations) FindByHash(hash string) (*models.StaffInvitation, error) {
+	var invitation models.StaffInvitation
+	if err := forUpdate(r.DB).Where("token_hash = ?", hash).First(&invitation).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &invitation, nil
+}
+
+func (r gormInvitations) Create(invitation *models.StaffInvitation) error {
+	return r.DB.Create(invitation).Error
+}
+
+func (r gormInvitations) Save(invitation *models.StaffInvitation) error {
+	return r.DB.Save(invitation).Error
+}
+
+func (r gormInvitations) RevokePending(restaurantID uint, email string, at time.Time) error {
+	return r.DB.Model(&models.StaffInvitation{}).
+		Where("restaurant_id = ? AND email = ? AND accepted_at IS NULL AND revoked_at IS NULL", restaurantID, email).
+		Update("revoked_at", at).Error
+}
+
+type gormTimeSlots struct{ DB *gorm.DB }
+
+func (r gormTimeSlots) Find(id uint) (*models.TimeSlot, error) {
+	var slot models.TimeSlot
+	if err := r.DB.First(&slot, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &slot, nil
+}
+
+type gormUsers struct{ DB *gorm.DB }
+
+func (r gormUsers) Search(filter UserFilter, page pagination.Params) ([]models.User, int64, error) {
+	query := r.DB.Model(&models.User{})
+	if filter.Email != "" {
+		query = query.Where(`LOWER(email) LIKE ? ESCAPE '\'`, LikePattern(filter.Email))
+	}
+	if filter.Role != "" {
+		query = query.Where("role = ?", filter.Role)
+	}
+
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var users []models.User
+	err := page.Apply(query).Find(&users).Error
+	return users, total, err
+}
+
+func (r gormUsers) Find(id uint) (*models.User, error) {
+	var user models.User
+	if err := r.DB.First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) FindForUpdate(id uint) (*models.User, error) {
+	var user models.User
+	if err := forUpdate(r.DB).First(&user, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) FindByEmail(email string) (*models.User, error) {
+	var user models.User
+	if err := r.DB.Where("email = ?", email).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) FindByName(name string) (*models.User, error) {
+	var user models.User
+	if err := r.DB.Where("name = ?", name).First(&user).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &user, nil
+}
+
+func (r gormUsers) Create(user *models.User) error { return r.DB.Create(user).Error }
+func (r gormUsers) Save(user *models.User) error   { return r.DB.Save(user).Error }
+func (r gormUsers) Delete(id uint) error           { return r.DB.Delete(&models.User{}, id).Error }
+
+type gormOIDCLogins struct{ DB *gorm.DB }
+
+func (r gormOIDCLogins) FindByState(hash string) (*models.OIDCLogin, error) {
+	var login models.OIDCLogin
+	if err := r.DB.Where("state_hash = ?", hash).First(&login).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &login, nil
+}
+
+func (r gormOIDCLogins) Create(login *models.OIDCLogin) error { return r.DB.Create(login).Error }
+func (r gormOIDCLogins) Save(login *models.OIDCLogin) error   { return r.DB.Save(login).Error }
+
+type gormIdentities struct{ DB *gorm.DB }
+
+func (r gormIdentities) Find(provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	if err := r.DB.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &identity, nil
+}
+
+func (r gormIdentities) Create(identity *models.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
+
+type gormRecoveryCodes struct{ DB *gorm.DB }
+
+// FindUnused locks the code on Postgres, so a code sent twice at once is
+// spent by one request and not found by the other.
+func (r gormRecoveryCodes) FindUnused(userID uint, hash string) (*models.RecoveryCode, error) {
+	var code models.RecoveryCode
+	if err := forUpdate(r.DB).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).First(&code).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &code, nil
+}
+
+func (r gormRecoveryCodes) Save(code *models.RecoveryCode) error { return r.DB.Save(code).Error }
+
+func (r gormRecoveryCodes) Replace(userID uint, codes []models.RecoveryCode) error {
+	if err := r.DB.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+	return r.DB.Create(&codes).Error
+}
+
+type gormAPIKeys struct{ DB *gorm.DB }
+
+func (r gormAPIKeys) List(restaurantID uint) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.DB.Where("restaurant_id = ? AND revoked_at IS NULL", restaurantID).Order("id").Find(&keys).Error
+	return keys, err
+}
+
+func (r gormAPIKeys) Find(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.DB.First(&key, id).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &key, nil
+}
+
+func (r gormAPIKeys) FindByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := r.DB.Where("key_hash = ? OR previous_hash = ?", hash, hash).First(&key).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &key, nil
+}
+
+func (r gormAPIKeys) Create(key *models.APIKey) error { return r.DB.Create(key).Error }
+
+func (r gormAPIKeys) Save(key *models.APIKey) error { return r.DB.Save(key).Error }
+
+type gormRefreshTokens struct{ DB *gorm.DB }
+
+// FindByHash locks the token on Postgres, so concurrent refreshes with it
+// rotate it once and see it revoked afterwards.
+func (r gormRefreshTokens) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := forUpdate(r.DB).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r gormRefreshTokens) Create(token *models.RefreshToken) error { return r.DB.Create(token).Error }
+func (r gormRefreshTokens) Save(token *models.RefreshToken) error   { return r.DB.Save(token).Error }
+
+func (r gormRefreshTokens) RevokeFamily(familyID string, at time.Time) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", at).Error
+}
+
+func (r gormRefreshTokens) RevokeUser(userID uint, at time.Time) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", at).Error
+}
+
+type gormUserTokens struct{ DB *gorm.DB }
+
+// FindByHash locks the token on Postgres, so a link followed twice at once is
+// redeemed once.
+func (r gormUserTokens) FindByHash(hash string) (*models.UserToken, error) {
+	var token models.UserToken
+	if err := forUpdate(r.DB).Where("token_hash = ?", hash).First(&token).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &token, nil
+}
+
+func (r gormUserTokens) Create(token *models.UserToken) error { return r.DB.Create(token).Error }
+
+func (r gormUserTokens) UseAll(userID uint, purpose string, at time.Time) error {
+	return r.DB.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", at).Error
+}
+
+type gormLoginThrottles struct{ DB *gorm.DB }
+
+// Find locks the row on Postgres so concurrent failures for the same key are
+// counted one after the other.
+func (r gormLoginThrottles) Find(key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	if err := forUpdate(r.DB).Where("key = ?", key).First(&throttle).Error; err != nil {
+		return nil, translate(err)
+	}
+	return &throttle, nil
+}
+
+func (r gormLoginThrottles) Save(throttle *models.LoginThrottle) error {
+	return r.DB.Clauses(clause.OnConflict{UpdateAll: true}).Create(throttle).Error
+}
+
+func (r gormLoginThrottles) Delete(key string) error {
+	return r.DB.Where("key = ?", key).Delete(&models.LoginThrottle{}).Error
+}
+
+type gormAudit struct{ DB *gorm.DB }
+
+func (a gormAudit) Record(event *models.AuditEvent) error { return a.DB.Create(event).Error }
+
+type gormOutbox struct{ DB *gorm.DB }
+
+func (o gormOutbox) QueueEmail(template, locale, to string, data interface{}) error {
+	return outbox.EnqueueEmail(o.DB, template, locale, to, data)
+}
+
+func (o gormOutbox) QueueEvent(data map[string]interface{}) error {
+	return outbox.EnqueueEvent(o.DB, data)
+}
+
+// forUpdate locks the rows a query reads until the transaction ends. SQLite
+// has no row locks; its transactions already write one at a time.
+func forUpdate(db *gorm.DB) *gorm.DB {
+	if db.Dialector.Name() == "postgres" {
+		return db.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	return db
+}
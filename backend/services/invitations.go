@@ -0,0 +1,276 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	InvitationTTL = 7 * 24 * time.Hour
+
+	AuditStaffInvitationRevoked = "staff.invitation_revoked"
+)
+
+type InviteStaffInput struct {
+	Email string `json:"email" binding:"required"`
+	Role  string `json:"role" binding:"required"`
+}
+
+// AcceptInvitationInput accepts an invitation. Name and password create the
+// account when the invited email has none, and an account whose email was
+// never verified needs a new password; phone is optional.
+type AcceptInvitationInput struct {
+	Token    string `json:"token" binding:"required"`
+	Name     string `json:"name"`
+	Phone    string `json:"phone"`
+	Password string `json:"password"`
+}
+
+// InvitationEmail is the data of the staff invitation email.
+type InvitationEmail struct {
+	Email      string
+	Restaurant string
+	Role       string
+	InvitedBy  string
+	URL        string
+	Days       int
+}
+
+func (s *StaffService) Invitations(restaurantID uint) ([]models.StaffInvitation, error) {
+	if _, err := s.store.Restaurants().Find(restaurantID); err != nil {
+		return nil, notFoundAs(err, apierror.RestaurantNotFound)
+	}
+	return s.store.Invitations().ListPending(restaurantID)
+}
+
+// Invite mails email a link to join restaurantID in role. Inviting the same
+// address again replaces the earlier invitation. As with Assign, only super
+// admins invite managers.
+func (s *StaffService) Invite(actor *models.User, restaurantID uint, input InviteStaffInput, ip, locale string) (*models.StaffInvitation, error) {
+	if !contains(RestaurantRoles, input.Role) {
+		return nil, apierror.Validation(apierror.Field("role", apierror.NotAllowed))
+	}
+	if !IsValidEmail(input.Email) {
+		return nil, apierror.Validation(apierror.Field("email", apierror.InvalidEmail))
+	}
+	if !canAppoint(actor, input.Role) {
+		return nil, apierror.Forbidden(apierror.PermissionDenied)
+	}
+	var invitation *models.StaffInvitation
+	err := s.store.Transaction(func(tx Store) error {
+		restaurant, err := tx.Restaurants().Find(restaurantID)
+		if err != nil {
+			return notFoundAs(err, apierror.RestaurantNotFound)
+		}
+		if err := s.checkNotStaff(tx, restaurantID, input.Email); err != nil {
+			return err
+		}
+		now := s.Now()
+		if err := tx.Invitations().RevokePending(restaurantID, input.Email, now); err != nil {
+			return err
+		}
+		raw, err := randomString(32)
+		if err != nil {
+			return err
+		}
+		invitation = &models.StaffInvitation{
+			RestaurantID: restaurantID,
+			Email:        input.Email,
+			Role:         input.Role,
+			TokenHash:    hashToken(raw),
+			InvitedByID:  &actor.ID,
+			ExpiresAt:    now.Add(InvitationTTL),
+			CreatedAt:    now,
+		}
+		if err := tx.Invitations().Create(invitation); err != nil {
+			return err
+		}
+		if err := tx.Outbox().QueueEmail("staff_invitation", locale, input.Email, InvitationEmail{
+			Email:      input.Email,
+			Restaurant: restaurant.Name,
+			Role:       input.Role,
+			InvitedBy:  actor.Name,
+			URL:        appURL("/accept-invitation", raw),
+			Days:       int(InvitationTTL.Hours() / 24),
+		}); err != nil {
+			return err
+		}
+		return auditInvitation(tx, AuditStaffInvited, actor, invitation, ip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return invitation, nil
+}
+
+// checkNotStaff refuses to invite someone already working at the restaurant;
+// their role is changed with Assign instead.
+func (s *StaffService) checkNotStaff(tx Store, restaurantID uint, email string) error {
+	user, err := tx.Users().FindByEmail(email)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Staff().Find(restaurantID, user.ID); err == nil {
+		return apierror.Conflict(apierror.UserAlreadyLinked)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// RevokeInvitation stops a pending invitation from being accepted.
+func (s *StaffService) RevokeInvitation(actor *models.User, restaurantID, id uint, ip string) error {
+	return s.store.Transaction(func(tx Store) error {
+		invitation, err := tx.Invitations().Find(id)
+		if err != nil {
+			return notFoundAs(err, apierror.InvitationNotFound)
+		}
+		if invitation.RestaurantID != restaurantID || invitation.AcceptedAt != nil || invitation.RevokedAt != nil {
+			return apierror.NotFound(apierror.InvitationNotFound)
+		}
+		if !canAppoint(actor, invitation.Role) {
+			return apierror.Forbidden(apierror.PermissionDenied)
+		}
+		now := s.Now()
+		invitation.RevokedAt = &now
+		if err := tx.Invitations().Save(invitation); err != nil {
+			return err
+		}
+		return auditInvitation(tx, AuditStaffInvitationRevoked, actor, invitation, ip)
+	})
+}
+
+// AcceptInvitation links the invited email to the restaurant, creating its
+// account first when there is none. The link reached the invitee's inbox, so
+// it also verifies their email. A manager invited as staff stays a manager.
+// Whoever registered an unverified account may not own the email, so the
+// invitee claims it with a new password and its other sessions end.
+func (s *StaffService) AcceptInvitation(input AcceptInvitationInput, ip string) (*models.User, error) {
+	var user *models.User
+	err := s.store.Transaction(func(tx Store) error {
+		now := s.Now()
+		invitation, err := tx.Invitations().FindByHash(hashToken(input.Token))
+		if errors.Is(err, ErrNotFound) {
+			return apierror.Invalid(apierror.InvalidInvitation)
+		}
+		if err != nil {
+			return err
+		}
+		if invitation.AcceptedAt != nil || invitation.RevokedAt != nil || !now.Before(invitation.ExpiresAt) {
+			return apierror.Invalid(apierror.InvalidInvitation)
+		}
+
+		user, err = tx.Users().FindByEmail(invitation.Email)
+		switch {
+		case errors.Is(err, ErrNotFound):
+			user, err = s.createInvitee(tx, invitation, input, now, ip)
+		case err == nil && user.EmailVerifiedAt == nil:
+			err = s.claimInvitee(tx, user, input.Password, now)
+		}
+		if err != nil {
+			return err
+		}
+
+		link, err := tx.Staff().Find(invitation.RestaurantID, user.ID)
+		if errors.Is(err, ErrNotFound) {
+			link = &models.UserRestaurant{RestaurantID: invitation.RestaurantID, UserID: user.ID, CreatedAt: now}
+		} else if err != nil {
+			return err
+		}
+		if link.Role != RoleRestaurantManager {
+			link.Role = invitation.Role
+		}
+		link.UpdatedAt = now
+		if err := tx.Staff().Save(link); err != nil {
+			return err
+		}
+		if err := syncRole(tx, user, s.TwoFactorRoles); err != nil {
+			return err
+		}
+
+		invitation.AcceptedAt = &now
+		if err := tx.Invitations().Save(invitation); err != nil {
+			return err
+		}
+		return audit(tx, AuditStaffAssigned, user, ip, fmt.Sprintf("%s at restaurant %d by invitation %d", link.Role, invitation.RestaurantID, invitation.ID))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *StaffService) createInvitee(tx Store, invitation *models.StaffInvitation, input AcceptInvitationInput, now time.Time, ip string) (*models.User, error) {
+	if input.Name == "" || input.Password == "" {
+		var fields []apierror.FieldError
+		if input.Name == "" {
+			fields = append(fields, apierror.Field("name", apierror.Required))
+		}
+		if input.Password == "" {
+			fields = append(fields, apierror.Field("password", apierror.Required))
+		}
+		return nil, apierror.Validation(fields...)
+	}
+	if err := checkNewAccount(tx, s.Passwords, input.Name, invitation.Email, input.Password); err != nil {
+		return nil, err
+	}
+	if input.Phone != "" {
+		if err := validatePhone(input.Phone); err != nil {
+			return nil, err
+		}
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Name:            input.Name,
+		Email:           invitation.Email,
+		Phone:           input.Phone,
+		Password:        string(hashed),
+		Role:            RoleCustomer,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := tx.Users().Create(user); err != nil {
+		return nil, err
+	}
+	return user, audit(tx, AuditUserCreated, user, ip, fmt.Sprintf("created from invitation %d", invitation.ID))
+}
+
+func (s *StaffService) claimInvitee(tx Store, user *models.User, password string, now time.Time) error {
+	if password == "" {
+		return apierror.Validation(apierror.Field("password", apierror.Required))
+	}
+	if err := s.Passwords.Check("password", password); err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := claimUnverified(tx, user, now); err != nil {
+		return err
+	}
+	user.Password = string(hashed)
+	return tx.Users().Save(user)
+}
+
+func auditInvitation(tx Store, eventType string, actor *models.User, invitation *models.StaffInvitation, ip string) error {
+	return tx.Audit().Record(&models.AuditEvent{
+		Type:    eventType,
+		UserID:  &actor.ID,
+		Subject: fmt.Sprintf("invitation:%d", invitation.ID),
+		IP:      ip,
+		Details: fmt.Sprintf("%s as %s at restaurant %d", invitation.Email, invitation.Role, invitation.RestaurantID),
+	})
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"strings"
+)
+
+// Filters for the list endpoints. Zero values match everything; dates are
+// YYYY-MM-DD and inclusive; Email and Name are case-insensitive substring
+// matches.
+
+type BookingFilter struct {
+	RestaurantID *uint
+	// RestaurantIDs limits results to these restaurants when it is not nil.
+	RestaurantIDs []uint
+	SessionID     *uint
+	TimeSlotID    *uint
+	Status        string
+	Email         string
+	DateFrom      string
+	DateTo        string
+}
+
+type SessionFilter struct {
+	RestaurantID *uint
+	// RestaurantIDs limits results to these restaurants when it is not nil;
+	// an empty slice matches nothing.
+	RestaurantIDs   []uint
+	TimeSlotID      *uint
+	DateFrom        string
+	DateTo          string
+	HasAvailability *bool
+	// MinAvailable keeps sessions with at least this many free seats.
+	MinAvailable int
+}
+
+type UserFilter struct {
+	Email string
+	Role  string
+}
+
+type RestaurantFilter struct {
+	Name     string
+	IsActive *bool
+	// Within keeps restaurants whose coordinates fall inside the box.
+	Within *GeoBox
+}
+
+// Sortable fields per endpoint. Columns are qualified because the bookings
+// query joins sessions. Nullable columns sort as empty strings, since NULL
+// compares as neither before nor after a cursor and those rows would be lost.
+
+var BookingSorts = pagination.Fields{
+	"id":               {Column: "bookings.id", IDColumn: "bookings.id", Kind: pagination.Int},
+	"created_at":       {Column: "bookings.created_at", IDColumn: "bookings.id", Kind: pagination.Time},
+	"date":             {Column: "sessions.date", IDColumn: "bookings.id", Kind: pagination.Date},
+	"number_of_guests": {Column: "bookings.number_of_guests", IDColumn: "bookings.id", Kind: pagination.Int},
+	"user_name":        {Column: "COALESCE(bookings.user_name, '')", IDColumn: "bookings.id", Kind: pagination.String},
+}
+
+var SessionSorts = pagination.Fields{
+	"id":              {Column: "sessions.id", IDColumn: "sessions.id", Kind: pagination.Int},
+	"created_at":      {Column: "sessions.created_at", IDColumn: "sessions.id", Kind: pagination.Time},
+	"date":            {Column: "sessions.date", IDColumn: "sessions.id", Kind: pagination.Date},
+	"name":            {Column: "COALESCE(sessions.name, '')", IDColumn: "sessions.id", Kind: pagination.String},
+	"max_guests":      {Column: "sessions.max_guests", IDColumn: "sessions.id", Kind: pagination.Int},
+	"available_slots": {Column: "sessions.available_slots", IDColumn: "sessions.id", Kind: pagination.Int},
+}
+
+var UserSorts = pagination.Fields{
+	"id":         {Column: "id", IDColumn: "id", Kind: pagination.Int},
+	"name":       {Column: "name", IDColumn: "id", Kind: pagination.String},
+	"email":      {Column: "email", IDColumn: "id", Kind: pagination.String},
+	"created_at": {Column: "created_at", IDColumn: "id", Kind: pagination.Time},
+}
+
+var RestaurantSorts = pagination.Fields{
+	"id":   {Column: "id", IDColumn: "id", Kind: pagination.Int},
+	"name": {Column: "name", IDColumn: "id", Kind: pagination.String},
+}
+
+// Keys give the value of a sort field for a row, used for cursors and by the
+// memory store.
+
+func bookingKey(b BookingWithRestaurant, field string) (interface{}, uint) {
+	switch field {
+	case "created_at":
+		return b.CreatedAt, b.ID
+	case "date":
+		return b.SessionDate, b.ID
+	case "number_of_guests":
+		return b.NumberOfGuests, b.ID
+	case "user_name":
+		return b.UserName, b.ID
+	}
+	return b.ID, b.ID
+}
+
+func sessionKey(s models.Session, field string) (interface{}, uint) {
+	switch field {
+	case "created_at":
+		return s.CreatedAt, s.ID
+	case "date":
+		return s.Date, s.ID
+	case "name":
+		return s.Name, s.ID
+	case "max_guests":
+		return s.MaxGuests, s.ID
+	case "available_slots":
+		return s.AvailableSlots, s.ID
+	}
+	return s.ID, s.ID
+}
+
+func userKey(u models.User, field string) (interface{}, uint) {
+	switch field {
+	case "name":
+		return u.Name, u.ID
+	case "email":
+		return u.Email, u.ID
+	case "created_at":
+		return u.CreatedAt, u.ID
+	}
+	return u.ID, u.ID
+}
+
+func restaurantKey(r models.Restaurant, field string) (interface{}, uint) {
+	if field == "name" {
+		return r.Name, r.ID
+	}
+	return r.ID, r.ID
+}
+
+// LikePattern turns a search term into a LIKE pattern matching it anywhere,
+// with wildcards in the term escaped. Use it with ESCAPE '\'.
+func LikePattern(term string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	return "%" + replacer.Replace(strings.ToLower(term)) + "%"
+}
+
+func containsFold(s, term string) bool {
+	return strings.Contains(strings.ToLower(s), strings.ToLower(term))
+}
+
+func inDateRange(date, from, to string) bool {
+	return (from == "" || date >= from) && (to == "" || date <= to)
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
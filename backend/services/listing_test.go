@@ -0,0 +1,57 @@
+package services_test
+
+import (
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"booking-backend/services"
+	"fmt"
+	"net/url"
+	"testing"
+)
+
+func TestMemoryStorePaginatesLikeSQL(t *testing.T) {
+	store := services.NewMemoryStore()
+	svc := services.New(store)
+	restaurant := store.AddRestaurant(models.Restaurant{Name: "Baan Suan"})
+	slot := store.AddTimeSlot(models.TimeSlot{SlotName: "Dinner"})
+	for i := 0; i < 7; i++ {
+		_, err := svc.Sessions.Create(services.CreateSessionInput{
+			RestaurantID: restaurant.ID,
+			TimeSlotID:   slot.ID,
+			Name:         fmt.Sprintf("Dinner %d", i%3),
+			Date:         fmt.Sprintf("2030-01-%02d", 10-i),
+			MaxGuests:    4,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	query := url.Values{"limit": {"3"}, "sort": {"name"}}
+	var names []string
+	for {
+		page, err := pagination.Parse(query, services.SessionSorts, "id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		result, err := svc.Sessions.List(services.SessionFilter{DateFrom: "2030-01-05"}, page)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, s := range result.Data {
+			names = append(names, s.Name)
+		}
+		if result.Pagination.Total != 6 {
+			t.Fatalf("expected 6 sessions from 2030-01-05, got %d", result.Pagination.Total)
+		}
+		if !result.Pagination.HasMore {
+			break
+		}
+		query.Set("cursor", result.Pagination.NextCursor)
+	}
+
+	want := []string{"Dinner 0", "Dinner 0", "Dinner 1", "Dinner 1", "Dinner 2", "Dinner 2"}
+	if fmt.Sprint(names) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, names)
+	}
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const AuditLoginLocked = "login.locked"
+
+// ThrottlePolicy slows down repeated failed logins for one key. After
+// FreeAttempts failures each further attempt has to wait BaseDelay, doubling
+// per failure up to MaxDelay; LockoutAfter failures lock the key for Lockout.
+// Failures are forgotten once Window passes without another one.
+type ThrottlePolicy struct {
+	FreeAttempts int
+	BaseDelay    time.Duration
+	MaxDelay     time.Duration
+	LockoutAfter int
+	Lockout      time.Duration
+	Window       time.Duration
+}
+
+var (
+	// AccountThrottle applies per email address, whether or not it has an
+	// account, so lockouts do not reveal which emails are registered.
+	AccountThrottle = ThrottlePolicy{
+		FreeAttempts: 3,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 10,
+		Lockout:      15 * time.Minute,
+		Window:       15 * time.Minute,
+	}
+	// IPThrottle applies per client address and allows more failures, since
+	// several people may share one address.
+	IPThrottle = ThrottlePolicy{
+		FreeAttempts: 20,
+		BaseDelay:    time.Second,
+		MaxDelay:     time.Minute,
+		LockoutAfter: 100,
+		Lockout:      15 * time.Minute,
+		Window:       15 * time.Minute,
+	}
+)
+
+// wait returns how long the key must wait before its next attempt.
+func (p ThrottlePolicy) wait(t *models.LoginThrottle, now time.Time) time.Duration {
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now)
+	}
+	over := t.Failures - p.FreeAttempts
+	if t.LockedUntil != nil || over <= 0 || now.Sub(t.LastFailureAt) >= p.Window {
+		return 0
+	}
+	delay := p.MaxDelay
+	if over <= 30 && p.BaseDelay<<(over-1) < p.MaxDelay {
+		delay = p.BaseDelay << (over - 1)
+	}
+	if ready := t.LastFailureAt.Add(delay); now.Before(ready) {
+		return ready.Sub(now)
+	}
+	return 0
+}
+
+// fail counts a failed attempt and reports whether it locked the key.
+func (p ThrottlePolicy) fail(t *models.LoginThrottle, now time.Time) bool {
+	if t.LockedUntil != nil || now.Sub(t.LastFailureAt) >= p.Window {
+		t.Failures, t.LockedUntil = 0, nil
+	}
+	t.Failures++
+	t.LastFailureAt = now
+	if t.Failures < p.LockoutAfter {
+		return false
+	}
+	until := now.Add(p.Lockout)
+	t.LockedUntil = &until
+	return true
+}
+
+func accountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+type throttleKey struct {
+	key    string
+	policy ThrottlePolicy
+}
+
+func (s *AuthService) throttleKeys(email, ip string) []throttleKey {
+	return []throttleKey{
+		{accountThrottleKey(email), s.AccountThrottle},
+		{"ip:" + ip, s.IPThrottle},
+	}
+}
+
+// dummyHash is compared against when the email is unknown, so a failed login
+// takes as long whether or not the account exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// Authenticate checks an email and password pair from a client at ip. Unknown
+// emails and wrong passwords fail the same way. Repeated failures for the
+// email or from the ip are answered with 429 and a Retry-After until their
+// delay or lockout has passed, without checking the password. The attempt is
+// counted before the password is compared, so concurrent guesses cannot all
+// get past the throttle; a correct password takes it back.
+func (s *AuthService) Authenticate(email, password, ip string) (*models.User, error) {
+	now := s.Now()
+	keys := s.throttleKeys(email, ip)
+	reserved, err := reserve(s.store, keys, now)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.store.Users().FindByEmail(email)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+	hash := dummyHash
+	if user != nil {
+		hash = []byte(user.Password)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil && user != nil {
+		err := s.store.Transaction(func(tx Store) error {
+			if err := tx.LoginThrottles().Delete(keys[0].key); err != nil {
+				return err
+			}
+			return refund(tx, reserved[1])
+		})
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	err = s.store.Transaction(func(tx Store) error {
+		return auditLockouts(tx, reserved, user, ip)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nil, apierror.Unauthorized(apierror.InvalidCredentials)
+}
+
+// reservation is an attempt counted as failed before it was checked.
+type reservation struct {
+	key throttleKey
+	// previous is the throttle before the attempt and counted the one it
+	// was saved as.
+	previous models.LoginThrottle
+	counted  models.LoginThrottle
+	locked   bool
+}
+
+// reserve checks that none of keys has to wait and counts the attempt as a
+// failure for each, in one transaction. The throttle rows are locked on
+// Postgres, so concurrent attempts are counted one after the other and each
+// sees the ones before it.
+func reserve(store Store, keys []throttleKey, now time.Time) ([]reservation, error) {
+	var reserved []reservation
+	err := store.Transaction(func(tx Store) error {
+		reserved = reserved[:0]
+		for _, k := range keys {
+			throttle, err := tx.LoginThrottles().Find(k.key)
+			if errors.Is(err, ErrNotFound) {
+				throttle, err = &models.LoginThrottle{Key: k.key}, nil
+			}
+			if err != nil {
+				return err
+			}
+			if wait := k.policy.wait(throttle, now); wait > 0 {
+				return apierror.TooManyRequests(apierror.LoginThrottled).After(wait)
+			}
+			r := reservation{key: k, previous: *throttle}
+			r.locked = k.policy.fail(throttle, now)
+			r.counted = *throttle
+			if err := tx.LoginThrottles().Save(throttle); err != nil {
+				return err
+			}
+			reserved = append(reserved, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// refund takes back the failure reserved for an attempt that succeeded. The
+// throttle is restored as it was unless other failures were counted since,
+// in which case only this one is taken off.
+func refund(tx Store, r reservation) error {
+	throttle, err := tx.LoginThrottles().Find(r.key.key)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if throttle.Failures != r.counted.Failures {
+		if throttle.Failures > 0 {
+			throttle.Failures--
+		}
+		return tx.LoginThrottles().Save(throttle)
+	}
+	if r.previous.Failures == 0 {
+		return tx.LoginThrottles().Delete(r.key.key)
+	}
+	return tx.LoginThrottles().Save(&r.previous)
+}
+
+// auditLockouts records the lockouts a failed attempt caused. Keys other than
+// ip ones belong to user, when known.
+func auditLockouts(tx Store, reserved []reservation, user *models.User, ip string) error {
+	for _, r := range reserved {
+		if !r.locked {
+			continue
+		}
+		event := &models.AuditEvent{
+			Type:    AuditLoginLocked,
+			Subject: r.key.key,
+			IP:      ip,
+			Details: fmt.Sprintf("%d failed logins, locked until %s", r.counted.Failures, r.counted.LockedUntil.UTC().Format(time.RFC3339)),
+		}
+		if user != nil && !strings.HasPrefix(r.key.key, "ip:") {
+			event.UserID = &user.ID
+		}
+		if err := tx.Audit().Record(event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
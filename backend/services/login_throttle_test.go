@@ -0,0 +1,161 @@
+package services_test
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/services"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func newLoginFixture(t *testing.T) (*services.MemoryStore, *services.Services, *time.Time) {
+	t.Helper()
+	store := services.NewMemoryStore()
+	svc := services.New(store)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("correct-horse"), bcrypt.MinCost)
+	if err := store.Users().Create(&models.User{Name: "A", Email: "a@example.com", Password: string(hash)}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.Auth.Now = func() time.Time { return now }
+	return store, svc, &now
+}
+
+func throttled(t *testing.T, err error) time.Duration {
+	t.Helper()
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) || apiErr.Code != apierror.LoginThrottled {
+		t.Fatalf("expected login_throttled, got %v", err)
+	}
+	return apiErr.RetryAfter
+}
+
+func TestFailedLoginsAreDelayedThenLocked(t *testing.T) {
+	store, svc, now := newLoginFixture(t)
+	policy := svc.Auth.AccountThrottle
+
+	for i := 0; i < policy.FreeAttempts; i++ {
+		if _, err := svc.Auth.Authenticate("a@example.com", "wrong", "10.0.0.1"); !errors.Is(err, apierror.ErrUnauthorized) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	// Over the free attempts every failure doubles the wait.
+	for i := policy.FreeAttempts; i < policy.LockoutAfter; i++ {
+		if _, err := svc.Auth.Authenticate("a@example.com", "wrong", "10.0.0.1"); !errors.Is(err, apierror.ErrUnauthorized) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+		if i == policy.LockoutAfter-1 {
+			break
+		}
+		_, err := svc.Auth.Authenticate("A@example.com", "correct-horse", "10.0.0.2")
+		wait := throttled(t, err)
+		if want := policy.BaseDelay << (i - policy.FreeAttempts); want < policy.MaxDelay && wait != want {
+			t.Fatalf("attempt %d: waited %v, want %v", i, wait, want)
+		}
+		*now = now.Add(wait)
+	}
+
+	_, err := svc.Auth.Authenticate("a@example.com", "correct-horse", "10.0.0.3")
+	if wait := throttled(t, err); wait != policy.Lockout {
+		t.Fatalf("expected a %v lockout, got %v", policy.Lockout, wait)
+	}
+	events := store.AuditEvents()
+	if len(events) != 1 || events[0].Type != services.AuditLoginLocked || events[0].UserID == nil || !strings.HasPrefix(events[0].Subject, "account:") {
+		t.Fatalf("expected one account lockout event, got %+v", events)
+	}
+
+	*now = now.Add(policy.Lockout)
+	if _, err := svc.Auth.Authenticate("a@example.com", "correct-horse", "10.0.0.3"); err != nil {
+		t.Fatalf("login after the lockout: %v", err)
+	}
+	if _, err := svc.Auth.Authenticate("a@example.com", "wrong", "10.0.0.3"); !errors.Is(err, apierror.ErrUnauthorized) {
+		t.Fatalf("a successful login should reset the count, got %v", err)
+	}
+}
+
+func TestFailedLoginsAreCountedPerAddress(t *testing.T) {
+	store, svc, _ := newLoginFixture(t)
+	svc.Auth.IPThrottle = services.ThrottlePolicy{FreeAttempts: 2, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAfter: 3, Lockout: time.Hour, Window: time.Hour}
+
+	for i, email := range []string{"x@example.com", "y@example.com", "z@example.com"} {
+		if _, err := svc.Auth.Authenticate(email, "guess", "10.0.0.9"); !errors.Is(err, apierror.ErrUnauthorized) {
+			t.Fatalf("attempt %d: expected invalid credentials, got %v", i, err)
+		}
+	}
+	_, err := svc.Auth.Authenticate("a@example.com", "correct-horse", "10.0.0.9")
+	throttled(t, err)
+	if _, err := svc.Auth.Authenticate("a@example.com", "correct-horse", "10.0.0.10"); err != nil {
+		t.Fatalf("other addresses should not be affected: %v", err)
+	}
+	if events := store.AuditEvents(); len(events) != 1 || events[0].Subject != "ip:10.0.0.9" || events[0].UserID != nil {
+		t.Fatalf("expected one address lockout event, got %+v", events)
+	}
+}
+
+// Attempts are counted before the password is compared, so guesses sent all
+// at once are throttled like guesses sent one by one.
+func TestConcurrentLoginsAreCountedBeforeChecking(t *testing.T) {
+	_, svc, _ := newLoginFixture(t)
+	policy := svc.Auth.AccountThrottle
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	checked := 0
+	for i := 0; i < policy.FreeAttempts+5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := svc.Auth.Authenticate("nobody@example.com", "guess", "10.0.0.1")
+			if errors.Is(err, apierror.ErrUnauthorized) {
+				mutex.Lock()
+				checked++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if checked != policy.FreeAttempts+1 {
+		t.Fatalf("expected %d guesses to be checked, got %d", policy.FreeAttempts+1, checked)
+	}
+}
+
+func TestSuccessfulLoginsDoNotCountAgainstTheAddress(t *testing.T) {
+	_, svc, _ := newLoginFixture(t)
+	svc.Auth.IPThrottle = services.ThrottlePolicy{FreeAttempts: 1, BaseDelay: time.Second, MaxDelay: time.Minute, LockoutAfter: 10, Lockout: time.Hour, Window: time.Hour}
+
+	if _, err := svc.Auth.Authenticate("x@example.com", "guess", "10.0.0.9"); !errors.Is(err, apierror.ErrUnauthorized) {
+		t.Fatalf("expected invalid credentials, got %v", err)
+	}
+	if _, err := svc.Auth.Authenticate("a@example.com", "correct-horse", "10.0.0.9"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := svc.Auth.Authenticate("y@example.com", "guess", "10.0.0.9"); !errors.Is(err, apierror.ErrUnauthorized) {
+		t.Fatalf("the successful login should not have counted, got %v", err)
+	}
+}
+
+func TestPasswordPolicy(t *testing.T) {
+	policy := services.NewPasswordPolicy(10)
+	cases := map[string]apierror.Code{
+		"short":                 apierror.PasswordTooShort,
+		"Password123!":          apierror.PasswordBreached,
+		"QWERTYUIOP":            apierror.PasswordBreached,
+		strings.Repeat("x", 73): apierror.PasswordTooLong,
+		"mango-sticky-rice":     "",
+	}
+	for password, want := range cases {
+		err := policy.Check("password", password)
+		var apiErr *apierror.Error
+		switch {
+		case want == "" && err != nil:
+			t.Errorf("%q: unexpected error %v", password, err)
+		case want != "" && (!errors.As(err, &apiErr) || apiErr.Fields[0].Code != want):
+			t.Errorf("%q: expected %s, got %v", password, want, err)
+		}
+	}
+}
@@ -0,0 +1,934 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"sort"
+	"sync"
+	"time"
+)
+
+type QueuedEmail struct {
+	Template string
+	Locale   string
+	To       string
+	Data     interface{}
+}
+
+type memoryData struct {
+	nextID      uint
+	bookings    map[uint]models.Booking
+	sessions    map[uint]models.Session
+	restaurants map[uint]models.Restaurant
+	timeSlots   map[uint]models.TimeSlot
+	users       map[uint]models.User
+	tokens      map[uint]models.RefreshToken
+	userTokens  map[uint]models.UserToken
+	throttles   map[string]models.LoginThrottle
+	audit       []models.AuditEvent
+	links       []models.UserRestaurant
+	invitations map[uint]models.StaffInvitation
+	oidcLogins  map[uint]models.OIDCLogin
+	identities  map[uint]models.UserIdentity
+	recovery    map[uint]models.RecoveryCode
+	apiKeys     map[uint]models.APIKey
+	emails      []QueuedEmail
+	events      []map[string]interface{}
+}
+
+func (d *memoryData) id() uint {
+	d.nextID++
+	return d.nextID
+}
+
+func (d *memoryData) clone() *memoryData {
+	c := &memoryData{
+		nextID:      d.nextID,
+		bookings:    make(map[uint]models.Booking, len(d.bookings)),
+		sessions:    make(map[uint]models.Session, len(d.sessions)),
+		restaurants: make(map[uint]models.Restaurant, len(d.restaurants)),
+		timeSlots:   make(map[uint]models.TimeSlot, len(d.timeSlots)),
+		users:       make(map[uint]models.User, len(d.users)),
+		tokens:      make(map[uint]models.RefreshToken, len(d.tokens)),
+		userTokens:  make(map[uint]models.UserToken, len(d.userTokens)),
+		throttles:   make(map[string]models.LoginThrottle, len(d.throttles)),
+		audit:       append([]models.AuditEvent(nil), d.audit...),
+		links:       append([]models.UserRestaurant(nil), d.links...),
+		invitations: make(map[uint]models.StaffInvitation, len(d.invitations)),
+		oidcLogins:  make(map[uint]models.OIDCLogin, len(d.oidcLogins)),
+		identities:  make(map[uint]models.UserIdentity, len(d.identities)),
+		recovery:    make(map[uint]models.RecoveryCode, len(d.recovery)),
+		apiKeys:     make(map[uint]models.APIKey, len(d.apiKeys)),
+		emails:      append([]QueuedEmail(nil), d.emails...),
+		events:      append([]map[string]interface{}(nil), d.events...),
+	}
+	for k, v := range d.bookings {
+		c.bookings[k] = v
+	}
+	for k, v := range d.sessions {
+		c.sessions[k] = v
+	}
+	for k, v := range d.restaurants {
+		c.restaurants[k] = v
+	}
+	for k, v := range d.timeSlots {
+		c.timeSlots[k] = v
+	}
+	for k, v := range d.users {
+		c.users[k] = v
+	}
+	for k, v := range d.tokens {
+		c.tokens[k] = v
+	}
+	for k, v := range d.userTokens {
+		c.userTokens[k] = v
+	}
+	for k, v := range d.throttles {
+		c.throttles[k] = v
+	}
+	for k, v := range d.invitations {
+		c.invitations[k] = v
+	}
+	for k, v := range d.oidcLogins {
+		c.oidcLogins[k] = v
+	}
+	for k, v := range d.identities {
+		c.identities[k] = v
+	}
+	for k, v := range d.recovery {
+		c.recovery[k] = v
+	}
+	for k, v := range d.apiKeys {
+		c.apiKeys[k] = v
+	}
+	return c
+}
+
+// MemoryStore is an in-process Store for tests and tools that should not need
+// Postgres. Transactions are serialised and roll back on error.
+type MemoryStore struct {
+	mutex *sync.Mutex
+	data  *memoryData
+	inTx  bool
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mutex: &sync.Mutex{},
+		data: &memoryData{
+			bookings:    make(map[uint]models.Booking),
+			sessions:    make(map[uint]models.Session),
+			restaurants: make(map[uint]models.Restaurant),
+			timeSlots:   make(map[uint]models.TimeSlot),
+			users:       make(map[uint]models.User),
+			tokens:      make(map[uint]models.RefreshToken),
+			userTokens:  make(map[uint]models.UserToken),
+			throttles:   make(map[string]models.LoginThrottle),
+			invitations: make(map[uint]models.StaffInvitation),
+			oidcLogins:  make(map[uint]models.OIDCLogin),
+			identities:  make(map[uint]models.UserIdentity),
+			recovery:    make(map[uint]models.RecoveryCode),
+			apiKeys:     make(map[uint]models.APIKey),
+		},
+	}
+}
+
+func (s *MemoryStore) lock() func() {
+	if s.inTx {
+		return func() {}
+	}
+	s.mutex.Lock()
+	return s.mutex.Unlock
+}
+
+func (s *MemoryStore) Bookings() BookingRepository             { return memoryBookings{s} }
+func (s *MemoryStore) Sessions() SessionRepository             { return memorySessions{s} }
+func (s *MemoryStore) Restaurants() RestaurantRepository       { return memoryRestaurants{s} }
+func (s *MemoryStore) Staff() StaffRepository                  { return memoryStaff{s} }
+func (s *MemoryStore) Invitations() InvitationRepository       { return memoryInvitations{s} }
+func (s *MemoryStore) TimeSlots() TimeSlotRepository           { return memoryTimeSlots{s} }
+func (s *MemoryStore) Users() UserRepository                   { return memoryUsers{s} }
+func (s *MemoryStore) RefreshTokens() RefreshTokenRepository   { return memoryRefreshTokens{s} }
+func (s *MemoryStore) UserTokens() UserTokenRepository         { return memoryUserTokens{s} }
+func (s *MemoryStore) OIDCLogins() OIDCLoginRepository         { return memoryOIDCLogins{s} }
+func (s *MemoryStore) Identities() IdentityRepository          { return memoryIdentities{s} }
+func (s *MemoryStore) RecoveryCodes() RecoveryCodeRepository   { return memoryRecoveryCodes{s} }
+func (s *MemoryStore) APIKeys() APIKeyRepository               { return memoryAPIKeys{s} }
+func (s *MemoryStore) LoginThrottles() LoginThrottleRepository { return memoryLoginThrottles{s} }
+func (s *MemoryStore) Audit() AuditLog                         { return memoryAudit{s} }
+func (s *MemoryStore) Outbox() Outbox                          { return memoryOutbox{s} }
+
+func (s *MemoryStore) Transaction(fn func(tx Store) error) error {
+	unlock := s.lock()
+	defer unlock()
+
+	snapshot := s.data.clone()
+	if err := fn(&MemoryStore{mutex: s.mutex, data: s.data, inTx: true}); err != nil {
+		*s.data = *snapshot
+		return err
+	}
+	return nil
+}
+
+// Seeding helpers for records the services only read.
+
+func (s *MemoryStore) AddRestaurant(restaurant models.Restaurant) models.Restaurant {
+	defer s.lock()()
+	restaurant.ID = s.data.id()
+	s.data.restaurants[restaurant.ID] = restaurant
+	return restaurant
+}
+
+func (s *MemoryStore) AddTimeSlot(slot models.TimeSlot) models.TimeSlot {
+	defer s.lock()()
+	slot.ID = s.data.id()
+	s.data.timeSlots[slot.ID] = slot
+	return slot
+}
+
+func (s *MemoryStore) LinkUserRestaurant(userID, restaurantID uint) {
+	defer s.lock()()
+	s.data.links = append(s.data.links, models.UserRestaurant{ID: s.data.id(), UserID: userID, RestaurantID: restaurantID, Role: RoleRestaurantManager})
+}
+
+func (s *MemoryStore) Emails() []QueuedEmail {
+	defer s.lock()()
+	return append([]QueuedEmail(nil), s.data.emails...)
+}
+
+// AuditEvents returns the audit events recorded so far.
+func (s *MemoryStore) AuditEvents() []models.AuditEvent {
+	defer s.lock()()
+	return append([]models.AuditEvent(nil), s.data.audit...)
+}
+
+func (s *MemoryStore) Events() []map[string]interface{} {
+	defer s.lock()()
+	return append([]map[string]interface{}(nil), s.data.events...)
+}
+
+type memoryBookings struct{ s *MemoryStore }
+
+func (r memoryBookings) filter(keep func(models.Booking) bool) []models.Booking {
+	var list []models.Booking
+	for _, b := range r.s.data.bookings {
+		if keep(b) {
+			list = append(list, b)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].CreatedAt.Equal(list[j].CreatedAt) {
+			return list[i].ID > list[j].ID
+		}
+		return list[i].CreatedAt.After(list[j].CreatedAt)
+	})
+	return list
+}
+
+func (r memoryBookings) List() ([]models.Booking, error) {
+	defer r.s.lock()()
+	return r.filter(func(models.Booking) bool { return true }), nil
+}
+
+func (r memoryBookings) ListByUser(userID uint) ([]models.Booking, error) {
+	defer r.s.lock()()
+	return r.filter(func(b models.Booking) bool { return b.UserID != nil && *b.UserID == userID }), nil
+}
+
+func (r memoryBookings) Search(filter BookingFilter, page pagination.Params) ([]BookingWithRestaurant, int64, error) {
+	defer r.s.lock()()
+	var list []BookingWithRestaurant
+	for _, b := range r.s.data.bookings {
+		session, ok := r.s.data.sessions[b.SessionID]
+		if filter.RestaurantID != nil && (!ok || session.RestaurantID != *filter.RestaurantID) ||
+			filter.RestaurantIDs != nil && (!ok || !containsID(filter.RestaurantIDs, session.RestaurantID)) ||
+			filter.SessionID != nil && b.SessionID != *filter.SessionID ||
+			filter.TimeSlotID != nil && (!ok || session.TimeSlotID != *filter.TimeSlotID) ||
+			filter.Status != "" && b.Status != filter.Status ||
+			filter.Email != "" && !containsFold(b.UserEmail, filter.Email) ||
+			(filter.DateFrom != "" || filter.DateTo != "") && (!ok || !inDateRange(session.Date, filter.DateFrom, filter.DateTo)) {
+			continue
+		}
+		item := BookingWithRestaurant{Booking: b}
+		if ok {
+			item.SessionDate = session.Date
+			item.RestaurantName = r.s.data.restaurants[session.RestaurantID].Name
+		}
+		list = append(list, item)
+	}
+	return pagination.Slice(list, page, bookingKey), int64(len(list)), nil
+}
+
+func (r memoryBookings) ListBySessions(sessionIDs []uint) ([]models.Booking, error) {
+	defer r.s.lock()()
+	wanted := make(map[uint]bool, len(sessionIDs))
+	for _, id := range sessionIDs {
+		wanted[id] = true
+	}
+	list := r.filter(func(b models.Booking) bool { return wanted[b.SessionID] })
+	sort.Slice(list, func(i, j int) bool { return list[i].ID < list[j].ID })
+	return list, nil
+}
+
+func (r memoryBookings) Find(id uint) (*models.Booking, error) {
+	defer r.s.lock()()
+	booking, ok := r.s.data.bookings[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &booking, nil
+}
+
+// FindForUpdate needs no lock of its own: memory transactions hold the store
+// lock throughout.
+func (r memoryBookings) FindForUpdate(id uint) (*models.Booking, error) {
+	return r.Find(id)
+}
+
+func (r memoryBookings) Create(booking *models.Booking) error {
+	defer r.s.lock()()
+	booking.ID = r.s.data.id()
+	r.s.data.bookings[booking.ID] = *booking
+	return nil
+}
+
+func (r memoryBookings) Save(booking *models.Booking) error {
+	defer r.s.lock()()
+	if booking.ID == 0 {
+		booking.ID = r.s.data.id()
+	}
+	r.s.data.bookings[booking.ID] = *booking
+	return nil
+}
+
+func (r memoryBookings) Delete(booking *models.Booking) error {
+	defer r.s.lock()()
+	delete(r.s.data.bookings, booking.ID)
+	return nil
+}
+
+type memorySessions struct{ s *MemoryStore }
+
+func (r memorySessions) withTimeSlot(session models.Session) models.Session {
+	session.TimeSlot = r.s.data.timeSlots[session.TimeSlotID]
+	return session
+}
+
+func (r memorySessions) Search(filter SessionFilter, page pagination.Params) ([]models.Session, int64, error) {
+	defer r.s.lock()()
+	var list []models.Session
+	for _, session := range r.s.data.sessions {
+		available := session.IsAvailable && session.AvailableSlots > 0
+		if filter.RestaurantID != nil && session.RestaurantID != *filter.RestaurantID ||
+			filter.RestaurantIDs != nil && !containsID(filter.RestaurantIDs, session.RestaurantID) ||
+			filter.TimeSlotID != nil && session.TimeSlotID != *filter.TimeSlotID ||
+			!inDateRange(session.Date, filter.DateFrom, filter.DateTo) ||
+			session.AvailableSlots < filter.MinAvailable ||
+			filter.HasAvailability != nil && available != *filter.HasAvailability {
+			continue
+		}
+		list = append(list, r.withTimeSlot(session))
+	}
+	return pagination.Slice(list, page, sessionKey), int64(len(list)), nil
+}
+
+func (r memorySessions) Find(id uint) (*models.Session, error) {
+	defer r.s.lock()()
+	session, ok := r.s.data.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	session = r.withTimeSlot(session)
+	return &session, nil
+}
+
+// FindForUpdate needs no lock of its own: memory transactions hold the store
+// lock throughout.
+func (r memorySessions) FindForUpdate(id uint) (*models.Session, error) {
+	return r.Find(id)
+}
+
+func (r memorySessions) Create(session *models.Session) error {
+	defer r.s.lock()()
+	session.ID = r.s.data.id()
+	r.s.data.sessions[session.ID] = *session
+	return nil
+}
+
+func (r memorySessions) Save(session *models.Session) error {
+	defer r.s.lock()()
+	if session.ID == 0 {
+		session.ID = r.s.data.id()
+	}
+	r.s.data.sessions[session.ID] = *session
+	return nil
+}
+
+func (r memorySessions) Delete(session *models.Session) error {
+	defer r.s.lock()()
+	delete(r.s.data.sessions, session.ID)
+	return nil
+}
+
+type memoryRestaurants struct{ s *MemoryStore }
+
+func (r memoryRestaurants) Search(filter RestaurantFilter, page pagination.Params) ([]models.Restaurant, int64, error) {
+	defer r.s.lock()()
+	var list []models.Restaurant
+	for _, restaurant := range r.s.data.restaurants {
+		if filter.Name != "" && !containsFold(restaurant.Name, filter.Name) ||
+			filter.IsActive != nil && restaurant.IsActive != *filter.IsActive ||
+			filter.Within != nil && !filter.Within.Contains(restaurant) {
+			continue
+		}
+		list = append(list, restaurant)
+	}
+	return pagination.Slice(list, page, restaurantKey), int64(len(list)), nil
+}
+
+func (r memoryRestaurants) Find(id uint) (*models.Restaurant, error) {
+	defer r.s.lock()()
+	restaurant, ok := r.s.data.restaurants[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &restaurant, nil
+}
+
+func (r memoryRestaurants) FindMany(ids []uint) ([]models.Restaurant, error) {
+	defer r.s.lock()()
+	var list []models.Restaurant
+	for _, id := range ids {
+		if restaurant, ok := r.s.data.restaurants[id]; ok {
+			list = append(list, restaurant)
+		}
+	}
+	return list, nil
+}
+
+func (r memoryRestaurants) FindByUserID(userID uint) (*models.Restaurant, error) {
+	defer r.s.lock()()
+	for _, link := range r.s.data.links {
+		if link.UserID != userID {
+			continue
+		}
+		restaurant, ok := r.s.data.restaurants[link.RestaurantID]
+		if !ok {
+			return nil, ErrNotFound
+		}
+		return &restaurant, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryRestaurants) Create(restaurant *models.Restaurant) error {
+	defer r.s.lock()()
+	restaurant.ID = r.s.data.id()
+	r.s.data.restaurants[restaurant.ID] = *restaurant
+	return nil
+}
+
+type memoryStaff struct{ s *MemoryStore }
+
+func (r memoryStaff) ListByUser(userID uint) ([]models.UserRestaurant, error) {
+	defer r.s.lock()()
+	var links []models.UserRestaurant
+	for _, link := range r.s.data.links {
+		if link.UserID == userID {
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (r memoryStaff) ListByRestaurant(restaurantID uint) ([]models.UserRestaurant, error) {
+	defer r.s.lock()()
+	var links []models.UserRestaurant
+	for _, link := range r.s.data.links {
+		if link.RestaurantID == restaurantID {
+			link.User = r.s.data.users[link.UserID]
+			links = append(links, link)
+		}
+	}
+	return links, nil
+}
+
+func (r memoryStaff) Find(restaurantID, userID uint) (*models.UserRestaurant, error) {
+	defer r.s.lock()()
+	for _, link := range r.s.data.links {
+		if link.RestaurantID == restaurantID && link.UserID == userID {
+			return &link, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryStaff) Save(link *models.UserRestaurant) error {
+	defer r.s.lock()()
+	for i, existing := range r.s.data.links {
+		if existing.ID == link.ID {
+			r.s.data.links[i] = *link
+			return nil
+		}
+	}
+	link.ID = r.s.data.id()
+	r.s.data.links = append(r.s.data.links, *link)
+	return nil
+}
+
+func (r memoryStaff) Delete(link *models.UserRestaurant) error {
+	defer r.s.lock()()
+	links := r.s.data.links[:0]
+	for _, existing := range r.s.data.links {
+		if existing.ID != link.ID {
+			links = append(links, existing)
+		}
+	}
+	r.s.data.links = links
+	return nil
+}
+
+type memoryInvitations struct{ s *MemoryStore }
+
+func pending(invitation models.StaffInvitation) bool {
+	return invitation.AcceptedAt == nil && invitation.RevokedAt == nil
+}
+
+func (r memoryInvitations) ListPending(restaurantID uint) ([]models.StaffInvitation, error) {
+	defer r.s.lock()()
+	invitations := []models.StaffInvitation{}
+	for _, invitation := range r.s.data.invitations {
+		if invitation.RestaurantID == restaurantID && pending(invitation) {
+			invitations = append(invitations, invitation)
+		}
+	}
+	sort.Slice(invitations, func(i, j int) bool { return invitations[i].ID < invitations[j].ID })
+	return invitations, nil
+}
+
+func (r memoryInvitations) Find(id uint) (*models.StaffInvitation, error) {
+	defer r.s.lock()()
+	invitation, ok := r.s.data.invitations[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &invitation, nil
+}
+
+func (r memoryInvitations) FindByHash(hash string) (*models.StaffInvitation, error) {
+	defer r.s.lock()()
+	for _, invitation := range r.s.data.invitations {
+		if invitation.TokenHash == hash {
+			return &invitation, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryInvitations) Create(invitation *models.StaffInvitation) error {
+	defer r.s.lock()()
+	invitation.ID = r.s.data.id()
+	r.s.data.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (r memoryInvitations) Save(invitation *models.StaffInvitation) error {
+	defer r.s.lock()()
+	r.s.data.invitations[invitation.ID] = *invitation
+	return nil
+}
+
+func (r memoryInvitations) RevokePending(restaurantID uint, email string, at time.Time) error {
+	defer r.s.lock()()
+	for id, invitation := range r.s.data.invitations {
+		if invitation.RestaurantID == restaurantID && invitation.Email == email && pending(invitation) {
+			invitation.RevokedAt = &at
+			r.s.data.invitations[id] = invitation
+		}
+	}
+	return nil
+}
+
+type memoryOIDCLogins struct{ s *MemoryStore }
+
+func (r memoryOIDCLogins) FindByState(hash string) (*models.OIDCLogin, error) {
+	defer r.s.lock()()
+	for _, login := range r.s.data.oidcLogins {
+		if login.StateHash == hash {
+			return &login, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryOIDCLogins) Create(login *models.OIDCLogin) error {
+	defer r.s.lock()()
+	login.ID = r.s.data.id()
+	r.s.data.oidcLogins[login.ID] = *login
+	return nil
+}
+
+func (r memoryOIDCLogins) Save(login *models.OIDCLogin) error {
+	defer r.s.lock()()
+	r.s.data.oidcLogins[login.ID] = *login
+	return nil
+}
+
+type memoryIdentities struct{ s *MemoryStore }
+
+func (r memoryIdentities) Find(provider, subject string) (*models.UserIdentity, error) {
+	defer r.s.lock()()
+	for _, identity := range r.s.data.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return &identity, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryIdentities) Create(identity *models.UserIdentity) error {
+	defer r.s.lock()()
+	identity.ID = r.s.data.id()
+	r.s.data.identities[identity.ID] = *identity
+	return nil
+}
+
+type memoryRecoveryCodes struct{ s *MemoryStore }
+
+func (r memoryRecoveryCodes) FindUnused(userID uint, hash string) (*models.RecoveryCode, error) {
+	defer r.s.lock()()
+	for _, code := range r.s.data.recovery {
+		if code.UserID == userID && code.CodeHash == hash && code.UsedAt == nil {
+			return &code, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryRecoveryCodes) Save(code *models.RecoveryCode) error {
+	defer r.s.lock()()
+	r.s.data.recovery[code.ID] = *code
+	return nil
+}
+
+func (r memoryRecoveryCodes) Replace(userID uint, codes []models.RecoveryCode) error {
+	defer r.s.lock()()
+	for key, code := range r.s.data.recovery {
+		if code.UserID == userID {
+			delete(r.s.data.recovery, key)
+		}
+	}
+	for i := range codes {
+		codes[i].ID = r.s.data.id()
+		r.s.data.recovery[codes[i].ID] = codes[i]
+	}
+	return nil
+}
+
+type memoryAPIKeys struct{ s *MemoryStore }
+
+func (r memoryAPIKeys) List(restaurantID uint) ([]models.APIKey, error) {
+	defer r.s.lock()()
+	keys := []models.APIKey{}
+	for _, key := range r.s.data.apiKeys {
+		if key.RestaurantID == restaurantID && key.RevokedAt == nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+func (r memoryAPIKeys) Find(id uint) (*models.APIKey, error) {
+	defer r.s.lock()()
+	key, ok := r.s.data.apiKeys[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &key, nil
+}
+
+func (r memoryAPIKeys) FindByHash(hash string) (*models.APIKey, error) {
+	defer r.s.lock()()
+	for _, key := range r.s.data.apiKeys {
+		if key.KeyHash == hash || key.PreviousHash == hash {
+			return &key, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryAPIKeys) Create(key *models.APIKey) error {
+	defer r.s.lock()()
+	key.ID = r.s.data.id()
+	r.s.data.apiKeys[key.ID] = *key
+	return nil
+}
+
+func (r memoryAPIKeys) Save(key *models.APIKey) error {
+	defer r.s.lock()()
+	r.s.data.apiKeys[key.ID] = *key
+	return nil
+}
+
+type memoryTimeSlots struct{ s *MemoryStore }
+
+func (r memoryTimeSlots) Find(id uint) (*models.TimeSlot, error) {
+	defer r.s.lock()()
+	slot, ok := r.s.data.timeSlots[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &slot, nil
+}
+
+type memoryUsers struct{ s *MemoryStore }
+
+func (r memoryUsers) Search(filter UserFilter, page pagination.Params) ([]models.User, int64, error) {
+	defer r.s.lock()()
+	var list []models.User
+	for _, user := range r.s.data.users {
+		if filter.Email != "" && !containsFold(user.Email, filter.Email) ||
+			filter.Role != "" && user.Role != filter.Role {
+			continue
+		}
+		list = append(list, user)
+	}
+	return pagination.Slice(list, page, userKey), int64(len(list)), nil
+}
+
+func (r memoryUsers) Find(id uint) (*models.User, error) {
+	defer r.s.lock()()
+	user, ok := r.s.data.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &user, nil
+}
+
+// FindForUpdate needs no lock of its own: memory transactions hold the store
+// lock throughout.
+func (r memoryUsers) FindForUpdate(id uint) (*models.User, error) {
+	return r.Find(id)
+}
+
+func (r memoryUsers) findBy(match func(models.User) bool) (*models.User, error) {
+	defer r.s.lock()()
+	for _, user := range r.s.data.users {
+		if match(user) {
+			return &user, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUsers) FindByEmail(email string) (*models.User, error) {
+	return r.findBy(func(u models.User) bool { return u.Email == email })
+}
+
+func (r memoryUsers) FindByName(name string) (*models.User, error) {
+	return r.findBy(func(u models.User) bool { return u.Name == name })
+}
+
+func (r memoryUsers) Create(user *models.User) error {
+	defer r.s.lock()()
+	for _, existing := range r.s.data.users {
+		if existing.Email == user.Email {
+			return apierror.Conflict(apierror.EmailTaken)
+		}
+	}
+	user.ID = r.s.data.id()
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+func (r memoryUsers) Save(user *models.User) error {
+	defer r.s.lock()()
+	if _, ok := r.s.data.users[user.ID]; !ok {
+		return ErrNotFound
+	}
+	r.s.data.users[user.ID] = *user
+	return nil
+}
+
+// Delete mirrors the schema's foreign keys: sessions, tokens and restaurant
+// links go with the user, bookings are kept without an owner.
+func (r memoryUsers) Delete(id uint) error {
+	defer r.s.lock()()
+	d := r.s.data
+	delete(d.users, id)
+	for key, token := range d.tokens {
+		if token.UserID == id {
+			delete(d.tokens, key)
+		}
+	}
+	for key, token := range d.userTokens {
+		if token.UserID == id {
+			delete(d.userTokens, key)
+		}
+	}
+	for key, identity := range d.identities {
+		if identity.UserID == id {
+			delete(d.identities, key)
+		}
+	}
+	for key, code := range d.recovery {
+		if code.UserID == id {
+			delete(d.recovery, key)
+		}
+	}
+	links := d.links[:0]
+	for _, link := range d.links {
+		if link.UserID != id {
+			links = append(links, link)
+		}
+	}
+	d.links = links
+	for key, booking := range d.bookings {
+		if booking.UserID != nil && *booking.UserID == id {
+			booking.UserID = nil
+			d.bookings[key] = booking
+		}
+	}
+	for i := range d.audit {
+		if d.audit[i].UserID != nil && *d.audit[i].UserID == id {
+			d.audit[i].UserID = nil
+		}
+	}
+	for key, invitation := range d.invitations {
+		if invitation.InvitedByID != nil && *invitation.InvitedByID == id {
+			invitation.InvitedByID = nil
+			d.invitations[key] = invitation
+		}
+	}
+	for k, key := range d.apiKeys {
+		if key.CreatedByID != nil && *key.CreatedByID == id {
+			key.CreatedByID = nil
+			d.apiKeys[k] = key
+		}
+	}
+	return nil
+}
+
+type memoryRefreshTokens struct{ s *MemoryStore }
+
+func (r memoryRefreshTokens) FindByHash(hash string) (*models.RefreshToken, error) {
+	defer r.s.lock()()
+	for _, token := range r.s.data.tokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryRefreshTokens) Create(token *models.RefreshToken) error {
+	defer r.s.lock()()
+	token.ID = r.s.data.id()
+	r.s.data.tokens[token.ID] = *token
+	return nil
+}
+
+func (r memoryRefreshTokens) Save(token *models.RefreshToken) error {
+	defer r.s.lock()()
+	r.s.data.tokens[token.ID] = *token
+	return nil
+}
+
+func (r memoryRefreshTokens) revokeWhere(match func(models.RefreshToken) bool, at time.Time) error {
+	defer r.s.lock()()
+	for id, token := range r.s.data.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &at
+			r.s.data.tokens[id] = token
+		}
+	}
+	return nil
+}
+
+func (r memoryRefreshTokens) RevokeFamily(familyID string, at time.Time) error {
+	return r.revokeWhere(func(t models.RefreshToken) bool { return t.FamilyID == familyID }, at)
+}
+
+func (r memoryRefreshTokens) RevokeUser(userID uint, at time.Time) error {
+	return r.revokeWhere(func(t models.RefreshToken) bool { return t.UserID == userID }, at)
+}
+
+type memoryUserTokens struct{ s *MemoryStore }
+
+func (r memoryUserTokens) FindByHash(hash string) (*models.UserToken, error) {
+	defer r.s.lock()()
+	for _, token := range r.s.data.userTokens {
+		if token.TokenHash == hash {
+			return &token, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (r memoryUserTokens) Create(token *models.UserToken) error {
+	defer r.s.lock()()
+	token.ID = r.s.data.id()
+	r.s.data.userTokens[token.ID] = *token
+	return nil
+}
+
+func (r memoryUserTokens) UseAll(userID uint, purpose string, at time.Time) error {
+	defer r.s.lock()()
+	for id, token := range r.s.data.userTokens {
+		if token.UserID == userID && token.Purpose == purpose && token.UsedAt == nil {
+			token.UsedAt = &at
+			r.s.data.userTokens[id] = token
+		}
+	}
+	return nil
+}
+
+type memoryLoginThrottles struct{ s *MemoryStore }
+
+func (r memoryLoginThrottles) Find(key string) (*models.LoginThrottle, error) {
+	defer r.s.lock()()
+	throttle, ok := r.s.data.throttles[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &throttle, nil
+}
+
+func (r memoryLoginThrottles) Save(throttle *models.LoginThrottle) error {
+	defer r.s.lock()()
+	r.s.data.throttles[throttle.Key] = *throttle
+	return nil
+}
+
+func (r memoryLoginThrottles) Delete(key string) error {
+	defer r.s.lock()()
+	delete(r.s.data.throttles, key)
+	return nil
+}
+
+type memoryAudit struct{ s *MemoryStore }
+
+func (a memoryAudit) Record(event *models.AuditEvent) error {
+	defer a.s.lock()()
+	event.ID = a.s.data.id()
+	a.s.data.audit = append(a.s.data.audit, *event)
+	return nil
+}
+
+type memoryOutbox struct{ s *MemoryStore }
+
+func (o memoryOutbox) QueueEmail(template, locale, to string, data interface{}) error {
+	defer o.s.lock()()
+	if to != "" {
+		o.s.data.emails = append(o.s.data.emails, QueuedEmail{Template: template, Locale: locale, To: to, Data: data})
+	}
+	return nil
+}
+
+func (o memoryOutbox) QueueEvent(data map[string]interface{}) error {
+	defer o.s.lock()()
+	o.s.data.events = append(o.s.data.events, data)
+	return nil
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/utils"
+	"bufio"
+	"crypto/sha1"
+	_ "embed"
+	"encoding/hex"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed data/common-passwords.txt
+var commonPasswords string
+
+// PasswordPolicy decides which new passwords are accepted. MaxLength is in
+// bytes because bcrypt ignores everything after the 72nd.
+type PasswordPolicy struct {
+	MinLength      int
+	MaxLength      int
+	RejectBreached bool
+	breached       map[string]bool
+}
+
+// PasswordPolicyFromEnv reads PASSWORD_MIN_LENGTH (default 10) and
+// PASSWORD_REJECT_BREACHED (default true). Breached passwords come from the
+// bundled list plus, when PASSWORD_BREACHED_LIST names a file, one password
+// or uppercase SHA-1 hash (optionally followed by ":count", as in the Have I
+// Been Pwned downloads) per line.
+func PasswordPolicyFromEnv() PasswordPolicy {
+	policy := NewPasswordPolicy(10)
+	if n, err := strconv.Atoi(utils.GetEnv("PASSWORD_MIN_LENGTH", "")); err == nil && n > 0 {
+		policy.MinLength = n
+	}
+	policy.RejectBreached = utils.GetEnv("PASSWORD_REJECT_BREACHED", "true") != "false"
+	if path := utils.GetEnv("PASSWORD_BREACHED_LIST", ""); path != "" {
+		file, err := os.Open(path)
+		if err != nil {
+			log.Fatal("Cannot read PASSWORD_BREACHED_LIST: ", err)
+		}
+		defer file.Close()
+		addPasswords(policy.breached, file)
+	}
+	return policy
+}
+
+func NewPasswordPolicy(minLength int) PasswordPolicy {
+	policy := PasswordPolicy{MinLength: minLength, MaxLength: 72, RejectBreached: true, breached: map[string]bool{}}
+	addPasswords(policy.breached, strings.NewReader(commonPasswords))
+	return policy
+}
+
+func addPasswords(set map[string]bool, r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if hash, _, _ := strings.Cut(line, ":"); len(hash) == 40 && isHex(hash) {
+			set[strings.ToUpper(hash)] = true
+		} else {
+			set[strings.ToLower(line)] = true
+		}
+	}
+}
+
+func isHex(s string) bool {
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// Check validates password as the value of field.
+func (p PasswordPolicy) Check(field, password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return apierror.Validation(apierror.Field(field, apierror.PasswordTooShort, "min", strconv.Itoa(p.MinLength)))
+	}
+	if len(password) > p.MaxLength {
+		return apierror.Validation(apierror.Field(field, apierror.PasswordTooLong, "max", strconv.Itoa(p.MaxLength)))
+	}
+	if p.RejectBreached && p.isBreached(password) {
+		return apierror.Validation(apierror.Field(field, apierror.PasswordBreached))
+	}
+	return nil
+}
+
+func (p PasswordPolicy) isBreached(password string) bool {
+	sum := sha1.Sum([]byte(password))
+	return p.breached[strings.ToLower(password)] || p.breached[strings.ToUpper(hex.EncodeToString(sum[:]))]
+}
@@ -0,0 +1,189 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"errors"
+)
+
+const (
+	RoleSuperAdmin        = "super_admin"
+	RoleRestaurantManager = "restaurant_manager"
+	RoleStaff             = "staff"
+	RoleCustomer          = "customer"
+)
+
+// Roles a user can have. Managers and staff act only for the restaurants
+// they are assigned to, with the role of each assignment.
+var Roles = []string{RoleSuperAdmin, RoleRestaurantManager, RoleStaff, RoleCustomer}
+
+// RestaurantRoles can be assigned per restaurant; AccountRoles are set on
+// the account itself.
+var (
+	RestaurantRoles = []string{RoleRestaurantManager, RoleStaff}
+	AccountRoles    = []string{RoleSuperAdmin, RoleCustomer}
+)
+
+type Permission string
+
+// Global permissions apply to the whole system; the others are held per
+// restaurant.
+const (
+	ManageRestaurants Permission = "restaurants.manage"
+	ManageUsers       Permission = "users.manage"
+	ManageSystem      Permission = "system.manage"
+
+	ManageStaff     Permission = "staff.manage"
+	ManageSessions  Permission = "sessions.manage"
+	ManageTables    Permission = "tables.manage"
+	ManageBookings  Permission = "bookings.manage"
+	ViewBookings    Permission = "bookings.view"
+	CheckInBookings Permission = "bookings.check_in"
+	ManageAPIKeys   Permission = "api_keys.manage"
+)
+
+// permissions is the matrix every authorization decision is made from.
+// Super admins hold every permission at every restaurant.
+var permissions = map[string][]Permission{
+	RoleSuperAdmin: {
+		ManageRestaurants, ManageUsers, ManageSystem,
+		ManageStaff, ManageSessions, ManageTables, ManageBookings, ViewBookings, CheckInBookings, ManageAPIKeys,
+	},
+	RoleRestaurantManager: {ManageStaff, ManageSessions, ManageTables, ManageBookings, ViewBookings, CheckInBookings, ManageAPIKeys},
+	RoleStaff:             {ViewBookings, CheckInBookings},
+	RoleCustomer:          {},
+}
+
+// Grants reports whether role holds perm.
+func Grants(role string, perm Permission) bool {
+	for _, p := range permissions[role] {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// PermissionMatrix returns the permissions of every role, for clients that
+// adapt their UI to the signed in user.
+func PermissionMatrix() map[string][]Permission {
+	matrix := make(map[string][]Permission, len(permissions))
+	for role, perms := range permissions {
+		matrix[role] = append([]Permission{}, perms...)
+	}
+	return matrix
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessService answers authorization questions from the permission matrix
+// and the user's restaurant assignments.
+type AccessService struct {
+	store Store
+}
+
+func NewAccessService(store Store) *AccessService {
+	return &AccessService{store: store}
+}
+
+// everywhere reports whether user holds perm at every restaurant. Only super
+// admins do: a manager's account role just summarizes their assignments.
+func everywhere(user *models.User, perm Permission) bool {
+	return user.Role == RoleSuperAdmin && Grants(user.Role, perm)
+}
+
+// Check returns a forbidden error unless user holds perm, either everywhere
+// or through their role at restaurantID. Pass 0 for global permissions.
+func (s *AccessService) Check(user *models.User, perm Permission, restaurantID uint) error {
+	if everywhere(user, perm) {
+		return nil
+	}
+	if restaurantID != 0 {
+		link, err := s.store.Staff().Find(restaurantID, user.ID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		if err == nil && Grants(link.Role, perm) {
+			return nil
+		}
+	}
+	return apierror.Forbidden(apierror.PermissionDenied)
+}
+
+// RestaurantsWith lists the restaurants where user holds perm. It returns nil
+// when the permission is held everywhere.
+func (s *AccessService) RestaurantsWith(user *models.User, perm Permission) ([]uint, error) {
+	if everywhere(user, perm) {
+		return nil, nil
+	}
+	links, err := s.store.Staff().ListByUser(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint{}
+	for _, link := range links {
+		if Grants(link.Role, perm) {
+			ids = append(ids, link.RestaurantID)
+		}
+	}
+	return ids, nil
+}
+
+// CheckPrincipal is Check for endpoints that also accept API keys. A key acts
+// only at its own restaurant, with the permissions its scopes grant.
+func (s *AccessService) CheckPrincipal(p Principal, perm Permission, restaurantID uint) error {
+	if p.Key == nil {
+		return s.Check(p.User, perm, restaurantID)
+	}
+	if restaurantID != 0 && restaurantID == p.Key.RestaurantID && keyGrants(p.Key, perm) {
+		return nil
+	}
+	return apierror.Forbidden(apierror.PermissionDenied)
+}
+
+// PrincipalRestaurantsWith is RestaurantsWith for endpoints that also accept
+// API keys.
+func (s *AccessService) PrincipalRestaurantsWith(p Principal, perm Permission) ([]uint, error) {
+	if p.Key == nil {
+		return s.RestaurantsWith(p.User, perm)
+	}
+	if keyGrants(p.Key, perm) {
+		return []uint{p.Key.RestaurantID}, nil
+	}
+	return []uint{}, nil
+}
+
+// CheckScope returns a forbidden error unless key has scope and, when
+// restaurantID is not 0, belongs to that restaurant. It guards endpoints that
+// are public to guests but limited for integrations.
+func (s *AccessService) CheckScope(key *models.APIKey, scope string, restaurantID uint) error {
+	if !contains(apiKeyScopes(key), scope) || (restaurantID != 0 && restaurantID != key.RestaurantID) {
+		return apierror.Forbidden(apierror.PermissionDenied)
+	}
+	return nil
+}
+
+// SessionRestaurant returns the restaurant a session belongs to, so access to
+// the session can be checked.
+func (s *AccessService) SessionRestaurant(id uint) (uint, error) {
+	session, err := s.store.Sessions().Find(id)
+	if err != nil {
+		return 0, notFoundAs(err, apierror.SessionNotFound)
+	}
+	return session.RestaurantID, nil
+}
+
+func (s *AccessService) BookingRestaurant(id uint) (uint, error) {
+	booking, err := s.store.Bookings().Find(id)
+	if err != nil {
+		return 0, notFoundAs(err, apierror.BookingNotFound)
+	}
+	return s.SessionRestaurant(booking.SessionID)
+}
@@ -0,0 +1,174 @@
+package services
+
+import (
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"time"
+)
+
+// Repositories return ErrNotFound when a single record lookup finds nothing.
+// Search methods return the rows for one page as fetched by
+// pagination.Params.Apply, plus the number of rows matching the filter.
+
+type BookingRepository interface {
+	List() ([]models.Booking, error)
+	Search(filter BookingFilter, page pagination.Params) ([]BookingWithRestaurant, int64, error)
+	ListByUser(userID uint) ([]models.Booking, error)
+	ListBySessions(sessionIDs []uint) ([]models.Booking, error)
+	Find(id uint) (*models.Booking, error)
+	// FindForUpdate is Find for a transaction that changes the booking's
+	// status or guests, locking it so two changes cannot both give its seats
+	// back.
+	FindForUpdate(id uint) (*models.Booking, error)
+	Create(booking *models.Booking) error
+	Save(booking *models.Booking) error
+	Delete(booking *models.Booking) error
+}
+
+type SessionRepository interface {
+	Search(filter SessionFilter, page pagination.Params) ([]models.Session, int64, error)
+	Find(id uint) (*models.Session, error)
+	// FindForUpdate is Find for a transaction that changes the seats left,
+	// locking the session so concurrent bookings cannot oversell it.
+	FindForUpdate(id uint) (*models.Session, error)
+	Create(session *models.Session) error
+	Save(session *models.Session) error
+	Delete(session *models.Session) error
+}
+
+type RestaurantRepository interface {
+	Search(filter RestaurantFilter, page pagination.Params) ([]models.Restaurant, int64, error)
+	Find(id uint) (*models.Restaurant, error)
+	FindMany(ids []uint) ([]models.Restaurant, error)
+	FindByUserID(userID uint) (*models.Restaurant, error)
+	Create(restaurant *models.Restaurant) error
+}
+
+// StaffRepository holds restaurant assignments: which users work at a
+// restaurant, and in what role.
+type StaffRepository interface {
+	ListByUser(userID uint) ([]models.UserRestaurant, error)
+	// ListByRestaurant returns the assignments with their users loaded.
+	ListByRestaurant(restaurantID uint) ([]models.UserRestaurant, error)
+	Find(restaurantID, userID uint) (*models.UserRestaurant, error)
+	Save(link *models.UserRestaurant) error
+	Delete(link *models.UserRestaurant) error
+}
+
+// InvitationRepository holds staff invitations. Pending invitations are
+// neither accepted nor revoked, though they may have expired.
+type InvitationRepository interface {
+	ListPending(restaurantID uint) ([]models.StaffInvitation, error)
+	Find(id uint) (*models.StaffInvitation, error)
+	FindByHash(hash string) (*models.StaffInvitation, error)
+	Create(invitation *models.StaffInvitation) error
+	Save(invitation *models.StaffInvitation) error
+	// RevokePending revokes every pending invitation of email to the
+	// restaurant, so only the newest invitation works.
+	RevokePending(restaurantID uint, email string, at time.Time) error
+}
+
+type TimeSlotRepository interface {
+	Find(id uint) (*models.TimeSlot, error)
+}
+
+type UserRepository interface {
+	Search(filter UserFilter, page pagination.Params) ([]models.User, int64, error)
+	Find(id uint) (*models.User, error)
+	// FindForUpdate is Find for a transaction that spends a second factor,
+	// locking the user so a code is accepted once.
+	FindForUpdate(id uint) (*models.User, error)
+	FindByEmail(email string) (*models.User, error)
+	FindByName(name string) (*models.User, error)
+	Create(user *models.User) error
+	Save(user *models.User) error
+	Delete(id uint) error
+}
+
+type RefreshTokenRepository interface {
+	FindByHash(hash string) (*models.RefreshToken, error)
+	Create(token *models.RefreshToken) error
+	Save(token *models.RefreshToken) error
+	// RevokeFamily and RevokeUser mark every unrevoked token of the family or
+	// user as revoked at the given time.
+	RevokeFamily(familyID string, at time.Time) error
+	RevokeUser(userID uint, at time.Time) error
+}
+
+type UserTokenRepository interface {
+	FindByHash(hash string) (*models.UserToken, error)
+	Create(token *models.UserToken) error
+	// UseAll marks every unused token of the user for purpose as used, so
+	// issuing or redeeming one token invalidates the others.
+	UseAll(userID uint, purpose string, at time.Time) error
+}
+
+// OIDCLoginRepository holds social logins in progress, found by the hash of
+// their state.
+type OIDCLoginRepository interface {
+	FindByState(hash string) (*models.OIDCLogin, error)
+	Create(login *models.OIDCLogin) error
+	Save(login *models.OIDCLogin) error
+}
+
+type IdentityRepository interface {
+	Find(provider, subject string) (*models.UserIdentity, error)
+	Create(identity *models.UserIdentity) error
+}
+
+type RecoveryCodeRepository interface {
+	FindUnused(userID uint, hash string) (*models.RecoveryCode, error)
+	Save(code *models.RecoveryCode) error
+	// Replace deletes every code of the user and stores codes instead.
+	Replace(userID uint, codes []models.RecoveryCode) error
+}
+
+// APIKeyRepository holds restaurant API keys. Revoked keys are kept for the
+// audit trail but are not listed.
+type APIKeyRepository interface {
+	List(restaurantID uint) ([]models.APIKey, error)
+	Find(id uint) (*models.APIKey, error)
+	// FindByHash matches the current or the previous hash of a key.
+	FindByHash(hash string) (*models.APIKey, error)
+	Create(key *models.APIKey) error
+	Save(key *models.APIKey) error
+}
+
+type LoginThrottleRepository interface {
+	Find(key string) (*models.LoginThrottle, error)
+	Save(throttle *models.LoginThrottle) error
+	Delete(key string) error
+}
+
+type AuditLog interface {
+	Record(event *models.AuditEvent) error
+}
+
+// Outbox queues side effects so they are committed with the change that
+// caused them.
+type Outbox interface {
+	QueueEmail(template, locale, to string, data interface{}) error
+	QueueEvent(data map[string]interface{}) error
+}
+
+type Store interface {
+	Bookings() BookingRepository
+	Sessions() SessionRepository
+	Restaurants() RestaurantRepository
+	Staff() StaffRepository
+	Invitations() InvitationRepository
+	TimeSlots() TimeSlotRepository
+	Users() UserRepository
+	RefreshTokens() RefreshTokenRepository
+	UserTokens() UserTokenRepository
+	OIDCLogins() OIDCLoginRepository
+	Identities() IdentityRepository
+	RecoveryCodes() RecoveryCodeRepository
+	APIKeys() APIKeyRepository
+	LoginThrottles() LoginThrottleRepository
+	Audit() AuditLog
+	Outbox() Outbox
+	// Transaction runs fn against a store whose writes are committed only if
+	// fn returns nil.
+	Transaction(fn func(tx Store) error) error
+}
@@ -0,0 +1,66 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/pagination"
+)
+
+type RestaurantService struct {
+	store Store
+}
+
+func NewRestaurantService(store Store) *RestaurantService {
+	return &RestaurantService{store: store}
+}
+
+type CreateRestaurantInput struct {
+	Name        string   `json:"name" binding:"required"`
+	Location    string   `json:"location" binding:"required"`
+	Description string   `json:"description"`
+	Phone       string   `json:"phone"`
+	Email       string   `json:"email"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+}
+
+func (s *RestaurantService) List(filter RestaurantFilter, page pagination.Params) (pagination.Page[models.Restaurant], error) {
+	rows, total, err := s.store.Restaurants().Search(filter, page)
+	if err != nil {
+		return pagination.Page[models.Restaurant]{}, err
+	}
+	return pagination.NewPage(rows, total, page, restaurantKey), nil
+}
+
+// ForUser returns the first restaurant a manager or staff account is
+// assigned to.
+func (s *RestaurantService) ForUser(userID uint) (*models.Restaurant, error) {
+	restaurant, err := s.store.Restaurants().FindByUserID(userID)
+	if err != nil {
+		return nil, notFoundAs(err, apierror.RestaurantNotLinked)
+	}
+	return restaurant, nil
+}
+
+func (s *RestaurantService) Create(input CreateRestaurantInput) (*models.Restaurant, error) {
+	if (input.Latitude == nil) != (input.Longitude == nil) {
+		return nil, apierror.Validation(apierror.Field("latitude", apierror.PairRequired, "other", "longitude"))
+	}
+	if input.Email != "" && !IsValidEmail(input.Email) {
+		return nil, apierror.Validation(apierror.Field("email", apierror.InvalidEmail))
+	}
+	restaurant := models.Restaurant{
+		Name:        input.Name,
+		Location:    input.Location,
+		Description: input.Description,
+		Phone:       input.Phone,
+		Email:       input.Email,
+		IsActive:    true,
+		Latitude:    input.Latitude,
+		Longitude:   input.Longitude,
+	}
+	if err := s.store.Restaurants().Create(&restaurant); err != nil {
+		return nil, err
+	}
+	return &restaurant, nil
+}
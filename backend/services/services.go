@@ -0,0 +1,46 @@
+package services
+
+import (
+	"booking-backend/oidc"
+	"log"
+	"os"
+)
+
+// Services bundles every service over one store, for wiring into handlers.
+type Services struct {
+	Store       Store
+	Bookings    *BookingService
+	Sessions    *SessionService
+	Restaurants *RestaurantService
+	Staff       *StaffService
+	Access      *AccessService
+	Users       *UserService
+	Auth        *AuthService
+	Accounts    *AccountService
+	Social      *SocialLoginService
+	TwoFactor   *TwoFactorService
+	APIKeys     *APIKeyService
+}
+
+func New(store Store) *Services {
+	passwords := PasswordPolicyFromEnv()
+	twoFactorRoles := TwoFactorRolesFromEnv()
+	providers, err := oidc.ProvidersFromEnv(os.Getenv)
+	if err != nil {
+		log.Fatal("Invalid OIDC configuration: ", err)
+	}
+	return &Services{
+		Store:       store,
+		Bookings:    NewBookingService(store),
+		Sessions:    NewSessionService(store),
+		Restaurants: NewRestaurantService(store),
+		Staff:       NewStaffService(store, passwords, twoFactorRoles),
+		Access:      NewAccessService(store),
+		Users:       NewUserService(store, passwords, twoFactorRoles),
+		Auth:        NewAuthService(store, twoFactorRoles),
+		Accounts:    NewAccountService(store, passwords),
+		Social:      NewSocialLoginService(store, providers),
+		TwoFactor:   NewTwoFactorService(store, twoFactorRoles),
+		APIKeys:     NewAPIKeyService(store, APIKeyRotationGraceFromEnv()),
+	}
+}
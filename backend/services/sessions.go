@@ -0,0 +1,175 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/pagination"
+)
+
+type SessionService struct {
+	store Store
+}
+
+func NewSessionService(store Store) *SessionService {
+	return &SessionService{store: store}
+}
+
+type SessionDetails struct {
+	ID             uint              `json:"id"`
+	RestaurantID   uint              `json:"restaurant_id"`
+	Date           string            `json:"date"`
+	TimeSlotID     uint              `json:"time_slot_id"`
+	Name           string            `json:"name"`
+	MaxGuests      int               `json:"max_guests"`
+	AvailableSlots int               `json:"available_slots"`
+	IsAvailable    bool              `json:"is_available"`
+	TimeSlot       models.TimeSlot   `json:"time_slot"`
+	Bookings       []models.Booking  `json:"bookings"`
+	BookingCount   int               `json:"booking_count"`
+	BookedGuests   int               `json:"booked_guests"`
+	RestaurantData models.Restaurant `json:"restaurant_data"`
+}
+
+type CreateSessionInput struct {
+	RestaurantID uint   `json:"restaurant_id" binding:"required"`
+	TimeSlotID   uint   `json:"time_slot_id" binding:"required"`
+	Name         string `json:"name" binding:"required"`
+	Date         string `json:"date" binding:"required"`
+	MaxGuests    int    `json:"max_guests" binding:"required"`
+}
+
+type UpdateSessionInput struct {
+	TimeSlotID uint   `json:"time_slot_id"`
+	Name       string `json:"name"`
+	Date       string `json:"date"`
+	MaxGuests  int    `json:"max_guests"`
+}
+
+// List returns one page of sessions, each with its bookings, the count of
+// confirmed bookings and guests, and its restaurant. Bookings and restaurants
+// are loaded in one query each, so the query count does not grow with the
+// page size.
+func (s *SessionService) List(filter SessionFilter, page pagination.Params) (pagination.Page[SessionDetails], error) {
+	rows, total, err := s.store.Sessions().Search(filter, page)
+	if err != nil {
+		return pagination.Page[SessionDetails]{}, err
+	}
+	sessions, info := pagination.Trim(rows, total, page, sessionKey)
+	result := pagination.Page[SessionDetails]{Data: make([]SessionDetails, 0, len(sessions)), Pagination: info}
+	if len(sessions) == 0 {
+		return result, nil
+	}
+
+	sessionIDs := make([]uint, 0, len(sessions))
+	restaurantIDs := make([]uint, 0, len(sessions))
+	for _, sess := range sessions {
+		sessionIDs = append(sessionIDs, sess.ID)
+		restaurantIDs = append(restaurantIDs, sess.RestaurantID)
+	}
+
+	bookings, err := s.store.Bookings().ListBySessions(sessionIDs)
+	if err != nil {
+		return pagination.Page[SessionDetails]{}, err
+	}
+	bookingsBySession := make(map[uint][]models.Booking, len(sessions))
+	for _, b := range bookings {
+		bookingsBySession[b.SessionID] = append(bookingsBySession[b.SessionID], b)
+	}
+
+	restaurants, err := s.store.Restaurants().FindMany(restaurantIDs)
+	if err != nil {
+		return pagination.Page[SessionDetails]{}, err
+	}
+	restaurantsByID := make(map[uint]models.Restaurant, len(restaurants))
+	for _, r := range restaurants {
+		restaurantsByID[r.ID] = r
+	}
+
+	for _, sess := range sessions {
+		details := SessionDetails{
+			ID:             sess.ID,
+			RestaurantID:   sess.RestaurantID,
+			Date:           sess.Date,
+			TimeSlotID:     sess.TimeSlotID,
+			Name:           sess.Name,
+			MaxGuests:      sess.MaxGuests,
+			AvailableSlots: sess.AvailableSlots,
+			IsAvailable:    sess.IsAvailable,
+			TimeSlot:       sess.TimeSlot,
+			Bookings:       bookingsBySession[sess.ID],
+			RestaurantData: restaurantsByID[sess.RestaurantID],
+		}
+		for _, b := range details.Bookings {
+			if b.Status != "cancelled" {
+				details.BookingCount++
+				details.BookedGuests += b.NumberOfGuests
+			}
+		}
+		result.Data = append(result.Data, details)
+	}
+	return result, nil
+}
+
+func (s *SessionService) Create(input CreateSessionInput) (*models.Session, error) {
+	if _, err := s.store.Restaurants().Find(input.RestaurantID); err != nil {
+		return nil, notFoundAs(err, apierror.RestaurantNotFound)
+	}
+	if _, err := s.store.TimeSlots().Find(input.TimeSlotID); err != nil {
+		return nil, notFoundAs(err, apierror.TimeSlotNotFound)
+	}
+	if input.MaxGuests <= 0 {
+		return nil, apierror.Validation(apierror.Field("max_guests", apierror.TooSmall, "min", "1"))
+	}
+
+	session := models.Session{
+		RestaurantID:   input.RestaurantID,
+		TimeSlotID:     input.TimeSlotID,
+		Name:           input.Name,
+		Date:           input.Date,
+		MaxGuests:      input.MaxGuests,
+		AvailableSlots: input.MaxGuests,
+		IsAvailable:    true,
+	}
+	if err := s.store.Sessions().Create(&session); err != nil {
+		return nil, err
+	}
+	return s.store.Sessions().Find(session.ID)
+}
+
+// Update keeps seats that are already booked: available slots shrink or grow
+// with max guests, which may not drop below the booked count.
+func (s *SessionService) Update(id uint, input UpdateSessionInput) (*models.Session, error) {
+	var session *models.Session
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		session, err = tx.Sessions().FindForUpdate(id)
+		if err != nil {
+			return notFoundAs(err, apierror.SessionNotFound)
+		}
+
+		booked := session.MaxGuests - session.AvailableSlots
+		if input.MaxGuests < booked {
+			return apierror.Conflict(apierror.MaxGuestsBelowBooked)
+		}
+
+		session.TimeSlotID = input.TimeSlotID
+		session.Name = input.Name
+		session.Date = input.Date
+		session.MaxGuests = input.MaxGuests
+		session.AvailableSlots = input.MaxGuests - booked
+		session.IsAvailable = session.AvailableSlots > 0
+		return tx.Sessions().Save(session)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+func (s *SessionService) Delete(id uint) error {
+	session, err := s.store.Sessions().Find(id)
+	if err != nil {
+		return notFoundAs(err, apierror.SessionNotFound)
+	}
+	return s.store.Sessions().Delete(session)
+}
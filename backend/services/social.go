@@ -0,0 +1,226 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/oidc"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	OIDCLoginTTL = 10 * time.Minute
+
+	AuditIdentityLinked = "user.identity_linked"
+)
+
+// SocialLoginService signs users in through OpenID Connect providers. A
+// provider account is matched by its subject once linked, and before that by
+// the verified email it reports; unknown emails get a new customer account.
+type SocialLoginService struct {
+	store     Store
+	Providers map[string]*oidc.Provider
+	Now       func() time.Time
+}
+
+func NewSocialLoginService(store Store, providers map[string]*oidc.Provider) *SocialLoginService {
+	return &SocialLoginService{store: store, Providers: providers, Now: time.Now}
+}
+
+type ProviderView struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+}
+
+func (s *SocialLoginService) List() []ProviderView {
+	views := []ProviderView{}
+	for _, name := range oidc.Names(s.Providers) {
+		views = append(views, ProviderView{Name: name, DisplayName: s.Providers[name].DisplayName})
+	}
+	return views
+}
+
+// StartedLogin is a social login in progress: the URL to send the browser
+// to, and the binding the browser has to keep until it comes back.
+type StartedLogin struct {
+	AuthorizationURL string
+	Binding          string
+}
+
+// Start begins a login with provider. The provider redirects back with the
+// state, which Finish redeems once and only together with the binding, so a
+// callback cannot be finished in a browser that did not start the login.
+func (s *SocialLoginService) Start(ctx context.Context, provider string) (*StartedLogin, error) {
+	p, ok := s.Providers[provider]
+	if !ok {
+		return nil, apierror.NotFound(apierror.OIDCProviderNotFound)
+	}
+	state, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	binding, err := randomString(32)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, err
+	}
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return nil, err
+	}
+	now := s.Now()
+	err = s.store.OIDCLogins().Create(&models.OIDCLogin{
+		Provider:     provider,
+		StateHash:    hashToken(state),
+		BindingHash:  hashToken(binding),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    now.Add(OIDCLoginTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &StartedLogin{AuthorizationURL: authURL, Binding: binding}, nil
+}
+
+// Finish redeems the code the provider sent back with state and returns the
+// signed in user. The state is spent before the code is exchanged, so a
+// replayed callback fails even when the first one did. A state sent without
+// the binding Start returned for it is refused and left unspent.
+func (s *SocialLoginService) Finish(ctx context.Context, state, binding, code, ip string) (*models.User, error) {
+	var login *models.OIDCLogin
+	err := s.store.Transaction(func(tx Store) error {
+		now := s.Now()
+		var err error
+		login, err = tx.OIDCLogins().FindByState(hashToken(state))
+		if errors.Is(err, ErrNotFound) {
+			return apierror.Invalid(apierror.InvalidOIDCState)
+		}
+		if err != nil {
+			return err
+		}
+		if login.UsedAt != nil || !now.Before(login.ExpiresAt) || login.BindingHash != hashToken(binding) {
+			return apierror.Invalid(apierror.InvalidOIDCState)
+		}
+		login.UsedAt = &now
+		return tx.OIDCLogins().Save(login)
+	})
+	if err != nil {
+		return nil, err
+	}
+	provider, ok := s.Providers[login.Provider]
+	if !ok {
+		return nil, apierror.Invalid(apierror.InvalidOIDCState)
+	}
+	claims, err := provider.Exchange(ctx, code, login.CodeVerifier, login.Nonce)
+	if err != nil {
+		log.Printf("oidc: %s login failed: %v", provider.Name, err)
+		return nil, apierror.Unauthorized(apierror.OIDCLoginFailed)
+	}
+
+	var user *models.User
+	err = s.store.Transaction(func(tx Store) error {
+		identity, err := tx.Identities().Find(provider.Name, claims.Subject)
+		if err == nil {
+			user, err = tx.Users().Find(identity.UserID)
+			return err
+		}
+		if !errors.Is(err, ErrNotFound) {
+			return err
+		}
+		user, err = s.linkOrCreate(tx, provider, claims, ip)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// linkOrCreate finds the account of a provider identity seen for the first
+// time by its verified email, or creates one, and links the identity to it.
+// An unverified account is claimed rather than linked as is, so whoever
+// registered the email first cannot keep signing in with their password.
+func (s *SocialLoginService) linkOrCreate(tx Store, provider *oidc.Provider, claims *oidc.Claims, ip string) (*models.User, error) {
+	email := provider.VerifiedEmail(claims)
+	if email == "" {
+		return nil, apierror.Forbidden(apierror.OIDCEmailRequired)
+	}
+	now := s.Now()
+	details := fmt.Sprintf("%s account %s", provider.Name, claims.Subject)
+	user, err := tx.Users().FindByEmail(email)
+	switch {
+	case errors.Is(err, ErrNotFound):
+		user, err = s.createUser(tx, provider, claims, email, now, ip)
+	case err == nil && user.EmailVerifiedAt == nil:
+		details += ", unverified account claimed"
+		err = claimUnverified(tx, user, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = tx.Identities().Create(&models.UserIdentity{
+		UserID:    user.ID,
+		Provider:  provider.Name,
+		Subject:   claims.Subject,
+		Email:     email,
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, audit(tx, AuditIdentityLinked, user, ip, details)
+}
+
+// createUser registers a customer without a password; they sign in through
+// the provider, or set a password with a reset link.
+func (s *SocialLoginService) createUser(tx Store, provider *oidc.Provider, claims *oidc.Claims, email string, now time.Time, ip string) (*models.User, error) {
+	name := strings.TrimSpace(claims.Name)
+	if name == "" {
+		name = email[:strings.Index(email+"@", "@")]
+	}
+	name, err := uniqueName(tx, name)
+	if err != nil {
+		return nil, err
+	}
+	user := &models.User{
+		Name:            name,
+		Email:           email,
+		Role:            RoleCustomer,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := tx.Users().Create(user); err != nil {
+		return nil, err
+	}
+	return user, audit(tx, AuditUserCreated, user, ip, "created from "+provider.Name+" login")
+}
+
+// uniqueName returns name, or name with the first free number appended, since
+// display names are unique.
+func uniqueName(tx Store, name string) (string, error) {
+	candidate := name
+	for n := 2; ; n++ {
+		_, err := tx.Users().FindByName(candidate)
+		if errors.Is(err, ErrNotFound) {
+			return candidate, nil
+		}
+		if err != nil {
+			return "", err
+		}
+		candidate = fmt.Sprintf("%s %d", name, n)
+	}
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	AuditStaffAssigned = "staff.assigned"
+	AuditStaffRemoved  = "staff.removed"
+	AuditStaffInvited  = "staff.invited"
+)
+
+// StaffService assigns users to restaurants as managers or staff, directly
+// or by invitation.
+type StaffService struct {
+	store     Store
+	Passwords PasswordPolicy
+	// TwoFactorRoles are the roles that must use two-factor authentication.
+	TwoFactorRoles []string
+	Now            func() time.Time
+}
+
+func NewStaffService(store Store, passwords PasswordPolicy, twoFactorRoles []string) *StaffService {
+	return &StaffService{store: store, Passwords: passwords, TwoFactorRoles: twoFactorRoles, Now: time.Now}
+}
+
+// StaffMember is a user together with their role at one restaurant.
+type StaffMember struct {
+	User UserView `json:"user"`
+	Role string   `json:"role"`
+}
+
+type AssignStaffInput struct {
+	Role string `json:"role" binding:"required"`
+}
+
+func (s *StaffService) List(restaurantID uint) ([]StaffMember, error) {
+	if _, err := s.store.Restaurants().Find(restaurantID); err != nil {
+		return nil, notFoundAs(err, apierror.RestaurantNotFound)
+	}
+	links, err := s.store.Staff().ListByRestaurant(restaurantID)
+	if err != nil {
+		return nil, err
+	}
+	members := make([]StaffMember, len(links))
+	for i := range links {
+		members[i] = StaffMember{User: NewUserView(&links[i].User), Role: links[i].Role}
+	}
+	return members, nil
+}
+
+// Assign gives userID a role at restaurantID, replacing any role they had
+// there. Only super admins appoint or change managers.
+func (s *StaffService) Assign(actor *models.User, restaurantID, userID uint, role, ip string) (*StaffMember, error) {
+	if !contains(RestaurantRoles, role) {
+		return nil, apierror.Validation(apierror.Field("role", apierror.NotAllowed))
+	}
+	var member *StaffMember
+	err := s.store.Transaction(func(tx Store) error {
+		user, link, err := s.load(tx, restaurantID, userID)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			link = &models.UserRestaurant{RestaurantID: restaurantID, UserID: userID, CreatedAt: time.Now()}
+		}
+		if !canAppoint(actor, link.Role) || !canAppoint(actor, role) {
+			return apierror.Forbidden(apierror.PermissionDenied)
+		}
+		link.Role = role
+		link.UpdatedAt = time.Now()
+		if err := tx.Staff().Save(link); err != nil {
+			return err
+		}
+		if err := syncRole(tx, user, s.TwoFactorRoles); err != nil {
+			return err
+		}
+		member = &StaffMember{User: NewUserView(user), Role: role}
+		return audit(tx, AuditStaffAssigned, user, ip, fmt.Sprintf("%s at restaurant %d by %s", role, restaurantID, actor.Email))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return member, nil
+}
+
+// Remove takes userID off the staff of restaurantID.
+func (s *StaffService) Remove(actor *models.User, restaurantID, userID uint, ip string) error {
+	return s.store.Transaction(func(tx Store) error {
+		user, link, err := s.load(tx, restaurantID, userID)
+		if err != nil {
+			return err
+		}
+		if link == nil {
+			return apierror.NotFound(apierror.StaffNotFound)
+		}
+		if !canAppoint(actor, link.Role) {
+			return apierror.Forbidden(apierror.PermissionDenied)
+		}
+		if err := tx.Staff().Delete(link); err != nil {
+			return err
+		}
+		if err := syncRole(tx, user, s.TwoFactorRoles); err != nil {
+			return err
+		}
+		return audit(tx, AuditStaffRemoved, user, ip, fmt.Sprintf("%s at restaurant %d by %s", link.Role, restaurantID, actor.Email))
+	})
+}
+
+// load returns the user and their assignment at the restaurant, which is nil
+// when they have none.
+func (s *StaffService) load(tx Store, restaurantID, userID uint) (*models.User, *models.UserRestaurant, error) {
+	if _, err := tx.Restaurants().Find(restaurantID); err != nil {
+		return nil, nil, notFoundAs(err, apierror.RestaurantNotFound)
+	}
+	user, err := tx.Users().Find(userID)
+	if err != nil {
+		return nil, nil, notFoundAs(err, apierror.UserNotFound)
+	}
+	link, err := tx.Staff().Find(restaurantID, userID)
+	if errors.Is(err, ErrNotFound) {
+		return user, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, link, nil
+}
+
+func canAppoint(actor *models.User, role string) bool {
+	return role != RoleRestaurantManager || actor.Role == RoleSuperAdmin
+}
+
+// deriveRole sets the account role of anyone but a super admin to the highest
+// role of their assignments, or customer without any.
+func deriveRole(tx Store, user *models.User) error {
+	if user.Role == RoleSuperAdmin {
+		return nil
+	}
+	links, err := tx.Staff().ListByUser(user.ID)
+	if err != nil {
+		return err
+	}
+	user.Role = RoleCustomer
+	for _, link := range links {
+		if link.Role == RoleRestaurantManager {
+			user.Role = RoleRestaurantManager
+			break
+		}
+		user.Role = RoleStaff
+	}
+	return nil
+}
+
+// syncRole saves user after deriving their role, if it changed, and signs
+// them out when the new role is one of twoFactorRoles and they have not set
+// up two-factor authentication.
+func syncRole(tx Store, user *models.User, twoFactorRoles []string) error {
+	previous := user.Role
+	if err := deriveRole(tx, user); err != nil || user.Role == previous {
+		return err
+	}
+	now := time.Now()
+	user.UpdatedAt = now
+	if err := tx.Users().Save(user); err != nil {
+		return err
+	}
+	return requireTwoFactor(tx, twoFactorRoles, user, now)
+}
@@ -0,0 +1,415 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/auth"
+	"booking-backend/models"
+	"booking-backend/utils"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	PurposeTwoFactorLogin = "two_factor_login"
+	TwoFactorLoginTTL     = 5 * time.Minute
+	RecoveryCodeCount     = 10
+
+	AuditTwoFactorEnabled  = "user.two_factor_enabled"
+	AuditTwoFactorDisabled = "user.two_factor_disabled"
+	AuditTwoFactorReset    = "user.two_factor_reset"
+	AuditRecoveryCodeUsed  = "user.recovery_code_used"
+)
+
+// TwoFactorThrottle applies per account to wrong codes, which are much
+// easier to guess than passwords.
+var TwoFactorThrottle = ThrottlePolicy{
+	FreeAttempts: 3,
+	BaseDelay:    time.Second,
+	MaxDelay:     time.Minute,
+	LockoutAfter: 10,
+	Lockout:      15 * time.Minute,
+	Window:       15 * time.Minute,
+}
+
+// TwoFactorService manages TOTP two-factor authentication. Any user may turn
+// it on; users whose role is in RequiredRoles must set it up at their next
+// login and cannot turn it off.
+type TwoFactorService struct {
+	store         Store
+	Issuer        string
+	RequiredRoles []string
+	Throttle      ThrottlePolicy
+	Now           func() time.Time
+}
+
+func NewTwoFactorService(store Store, requiredRoles []string) *TwoFactorService {
+	return &TwoFactorService{
+		store:         store,
+		Issuer:        utils.GetEnv("TWO_FACTOR_ISSUER", "Restaurant Booking"),
+		RequiredRoles: requiredRoles,
+		Throttle:      TwoFactorThrottle,
+		Now:           time.Now,
+	}
+}
+
+// TwoFactorRolesFromEnv reads the comma separated roles that must use
+// two-factor authentication from TWO_FACTOR_ROLES. Unset means super admins
+// and managers; set but empty means no role.
+func TwoFactorRolesFromEnv() []string {
+	value, ok := os.LookupEnv("TWO_FACTOR_ROLES")
+	if !ok {
+		return []string{RoleSuperAdmin, RoleRestaurantManager}
+	}
+	var roles []string
+	for _, role := range strings.Split(value, ",") {
+		role = strings.TrimSpace(role)
+		if role == "" {
+			continue
+		}
+		if !contains(Roles, role) {
+			log.Fatalf("TWO_FACTOR_ROLES: unknown role %q", role)
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+// LoginChallenge answers a correct password when a second factor is needed.
+// The token is sent back with a code to /login/two-factor.
+type LoginChallenge struct {
+	TwoFactorRequired bool   `json:"two_factor_required"`
+	Token             string `json:"two_factor_token"`
+	SetupRequired     bool   `json:"setup_required"`
+	ExpiresIn         int    `json:"expires_in"`
+}
+
+// TwoFactorSetup is shown once so the user can add the account to their
+// authenticator app.
+type TwoFactorSetup struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_url"`
+}
+
+func (s *TwoFactorService) Required(user *models.User) bool {
+	return contains(s.RequiredRoles, user.Role)
+}
+
+// Challenge returns the second login step user has to pass, or nil when the
+// password was enough.
+func (s *TwoFactorService) Challenge(user *models.User) (*LoginChallenge, error) {
+	enabled := user.TwoFactorEnabledAt != nil
+	if !enabled && !s.Required(user) {
+		return nil, nil
+	}
+	var raw string
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		raw, err = issueUserToken(tx, user.ID, PurposeTwoFactorLogin, s.Now(), TwoFactorLoginTTL)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &LoginChallenge{
+		TwoFactorRequired: true,
+		Token:             raw,
+		SetupRequired:     !enabled,
+		ExpiresIn:         int(TwoFactorLoginTTL.Seconds()),
+	}, nil
+}
+
+// Setup gives user a new secret, which stays pending until a code from it
+// enables two-factor authentication.
+func (s *TwoFactorService) Setup(user *models.User) (*TwoFactorSetup, error) {
+	if user.TwoFactorEnabledAt != nil {
+		return nil, apierror.Conflict(apierror.TwoFactorEnabled)
+	}
+	secret, err := auth.NewTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	user.TwoFactorSecret = secret
+	user.UpdatedAt = s.Now()
+	if err := s.store.Users().Save(user); err != nil {
+		return nil, err
+	}
+	return &TwoFactorSetup{Secret: secret, URI: auth.TOTPURI(s.Issuer, user.Email, secret)}, nil
+}
+
+// SetupForLogin is Setup for a user who has to enroll before their login can
+// finish. It does not use up the challenge.
+func (s *TwoFactorService) SetupForLogin(token string) (*TwoFactorSetup, error) {
+	user, err := s.challenged(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.Setup(user)
+}
+
+// Enable confirms the pending secret with a code from it and returns the
+// recovery codes, which are shown only this once.
+func (s *TwoFactorService) Enable(user *models.User, code, ip string) ([]string, error) {
+	return s.enable(user, code, ip, nil)
+}
+
+// enable is Enable running then in the same transaction once the code is
+// accepted.
+func (s *TwoFactorService) enable(user *models.User, code, ip string, then func(tx Store) error) ([]string, error) {
+	if user.TwoFactorEnabledAt != nil {
+		return nil, apierror.Conflict(apierror.TwoFactorEnabled)
+	}
+	if user.TwoFactorSecret == "" {
+		return nil, apierror.Invalid(apierror.TwoFactorNotEnabled)
+	}
+	var codes []string
+	err := s.verify(user, code, ip, false, func(tx Store, user *models.User) error {
+		if user.TwoFactorEnabledAt != nil {
+			return apierror.Conflict(apierror.TwoFactorEnabled)
+		}
+		now := s.Now()
+		user.TwoFactorEnabledAt = &now
+		user.UpdatedAt = now
+		if err := tx.Users().Save(user); err != nil {
+			return err
+		}
+		var err error
+		if codes, err = replaceRecoveryCodes(tx, user, now); err != nil {
+			return err
+		}
+		if err := audit(tx, AuditTwoFactorEnabled, user, ip, ""); err != nil {
+			return err
+		}
+		if then != nil {
+			return then(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// CompleteLogin checks the code sent with a login challenge. Users who had to
+// enroll confirm their new secret with it and get their recovery codes;
+// others may send a recovery code instead. The challenge is spent in the
+// transaction that accepts the code, so it works once.
+func (s *TwoFactorService) CompleteLogin(token, code, ip string) (*models.User, []string, error) {
+	user, err := s.challenged(token)
+	if err != nil {
+		return nil, nil, err
+	}
+	spend := func(tx Store) error {
+		_, err := redeem(tx, token, PurposeTwoFactorLogin, s.Now(), apierror.InvalidTwoFactor)
+		return err
+	}
+	var codes []string
+	if user.TwoFactorEnabledAt == nil {
+		codes, err = s.enable(user, code, ip, spend)
+	} else {
+		err = s.verify(user, code, ip, true, func(tx Store, _ *models.User) error { return spend(tx) })
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, codes, nil
+}
+
+// RegenerateRecoveryCodes replaces every recovery code of user, after a
+// current code confirms it is them.
+func (s *TwoFactorService) RegenerateRecoveryCodes(user *models.User, code, ip string) ([]string, error) {
+	if user.TwoFactorEnabledAt == nil {
+		return nil, apierror.Invalid(apierror.TwoFactorNotEnabled)
+	}
+	var codes []string
+	err := s.verify(user, code, ip, false, func(tx Store, user *models.User) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user, s.Now())
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable turns two-factor authentication off after a current or recovery
+// code confirms it. Roles that require it cannot.
+func (s *TwoFactorService) Disable(user *models.User, code, ip string) error {
+	if s.Required(user) {
+		return apierror.Forbidden(apierror.TwoFactorRequired)
+	}
+	if user.TwoFactorEnabledAt == nil {
+		return apierror.Invalid(apierror.TwoFactorNotEnabled)
+	}
+	return s.verify(user, code, ip, true, func(tx Store, user *models.User) error {
+		if err := clearTwoFactor(tx, user, s.Now()); err != nil {
+			return err
+		}
+		return audit(tx, AuditTwoFactorDisabled, user, ip, "")
+	})
+}
+
+// Reset turns off two-factor authentication of a user who lost both their
+// authenticator and recovery codes, and signs them out everywhere. Users
+// whose role requires it set it up again at their next login.
+func (s *TwoFactorService) Reset(admin *models.User, userID uint, ip string) (*models.User, error) {
+	var user *models.User
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		user, err = tx.Users().Find(userID)
+		if err != nil {
+			return notFoundAs(err, apierror.UserNotFound)
+		}
+		if user.TwoFactorEnabledAt == nil && user.TwoFactorSecret == "" {
+			return apierror.Invalid(apierror.TwoFactorNotEnabled)
+		}
+		now := s.Now()
+		if err := clearTwoFactor(tx, user, now); err != nil {
+			return err
+		}
+		if err := revokeSessions(tx, user, now); err != nil {
+			return err
+		}
+		return audit(tx, AuditTwoFactorReset, user, ip, "by "+admin.Email)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// challenged returns the user a pending login challenge was issued to.
+func (s *TwoFactorService) challenged(raw string) (*models.User, error) {
+	token, err := s.store.UserTokens().FindByHash(hashToken(raw))
+	if errors.Is(err, ErrNotFound) {
+		return nil, apierror.Invalid(apierror.InvalidTwoFactor)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if token.Purpose != PurposeTwoFactorLogin || token.UsedAt != nil || !s.Now().Before(token.ExpiresAt) {
+		return nil, apierror.Invalid(apierror.InvalidTwoFactor)
+	}
+	return s.store.Users().Find(token.UserID)
+}
+
+// verify checks a TOTP code, or a recovery code when recovery is set, and
+// spends it. Every attempt is counted towards the account's two-factor
+// throttle before the code is checked, and a right code clears the count.
+// The user is locked while the code is checked, and then runs in the same
+// transaction with the locked user, so concurrent requests cannot both
+// accept one code or both use what it unlocks. user is updated to match.
+func (s *TwoFactorService) verify(user *models.User, code, ip string, recovery bool, then func(tx Store, user *models.User) error) error {
+	now := s.Now()
+	key := throttleKey{fmt.Sprintf("two_factor:%d", user.ID), s.Throttle}
+	reserved, err := reserve(s.store, []throttleKey{key}, now)
+	if err != nil {
+		return err
+	}
+	wrong := false
+	err = s.store.Transaction(func(tx Store) error {
+		locked, err := tx.Users().FindForUpdate(user.ID)
+		if err != nil {
+			return err
+		}
+		matched, err := spendCode(tx, locked, code, now, recovery, ip)
+		if err != nil {
+			return err
+		}
+		if !matched {
+			wrong = true
+			return auditLockouts(tx, reserved, locked, ip)
+		}
+		if err := tx.LoginThrottles().Delete(key.key); err != nil {
+			return err
+		}
+		if err := then(tx, locked); err != nil {
+			return err
+		}
+		*user = *locked
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if wrong {
+		return apierror.Validation(apierror.Field("code", apierror.WrongCode))
+	}
+	return nil
+}
+
+// spendCode reports whether code is the user's current TOTP code or, when
+// recovery is set, one of their unused recovery codes, and marks it used.
+func spendCode(tx Store, user *models.User, code string, now time.Time, recovery bool, ip string) (bool, error) {
+	if step, ok := auth.MatchTOTP(user.TwoFactorSecret, code, now, user.TwoFactorLastStep); ok {
+		user.TwoFactorLastStep = step
+		return true, tx.Users().Save(user)
+	}
+	if !recovery || user.TwoFactorEnabledAt == nil {
+		return false, nil
+	}
+	used, err := tx.RecoveryCodes().FindUnused(user.ID, hashToken(normalizeRecoveryCode(code)))
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	used.UsedAt = &now
+	if err := tx.RecoveryCodes().Save(used); err != nil {
+		return false, err
+	}
+	return true, audit(tx, AuditRecoveryCodeUsed, user, ip, "")
+}
+
+// recoveryAlphabet leaves out characters that are easy to misread.
+const recoveryAlphabet = "abcdefghijkmnpqrstuvwxyz23456789"
+
+// replaceRecoveryCodes issues a fresh set of codes like "k7qmz-4wbxa".
+func replaceRecoveryCodes(tx Store, user *models.User, now time.Time) ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	records := make([]models.RecoveryCode, RecoveryCodeCount)
+	buf := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		for j, b := range buf {
+			buf[j] = recoveryAlphabet[b%byte(len(recoveryAlphabet))]
+		}
+		codes[i] = string(buf[:5]) + "-" + string(buf[5:])
+		records[i] = models.RecoveryCode{UserID: user.ID, CodeHash: hashToken(normalizeRecoveryCode(codes[i])), CreatedAt: now}
+	}
+	return codes, tx.RecoveryCodes().Replace(user.ID, records)
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+// requireTwoFactor revokes the sessions of a user whose role is one of roles
+// but who has not enabled two-factor authentication, so a role granted to a
+// logged in user takes effect only after they set it up at their next login.
+func requireTwoFactor(tx Store, roles []string, user *models.User, now time.Time) error {
+	if user.TwoFactorEnabledAt != nil || !contains(roles, user.Role) {
+		return nil
+	}
+	return revokeSessions(tx, user, now)
+}
+
+func clearTwoFactor(tx Store, user *models.User, now time.Time) error {
+	user.TwoFactorSecret = ""
+	user.TwoFactorEnabledAt = nil
+	user.TwoFactorLastStep = 0
+	user.UpdatedAt = now
+	if err := tx.Users().Save(user); err != nil {
+		return err
+	}
+	return tx.RecoveryCodes().Replace(user.ID, nil)
+}
@@ -0,0 +1,66 @@
+package services_test
+
+import (
+	"booking-backend/auth"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTwoFactorLoginsSpendCodeAndChallengeOnce(t *testing.T) {
+	store, svc, _ := newLoginFixture(t)
+	user, err := store.Users().FindByEmail("a@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup, err := svc.TwoFactor.Setup(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step := auth.TOTPStep(time.Now())
+	code, _ := auth.TOTPCode(setup.Secret, step)
+	if _, err := svc.TwoFactor.Enable(user, code, "10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	challenge, err := svc.TwoFactor.Challenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	next, _ := auth.TOTPCode(setup.Secret, step+1)
+	// The logins look at the clock when they check the challenge and again
+	// when they start checking the code. Hold them at each until all have
+	// arrived, so every one has read the user before any code is checked.
+	const logins = 8
+	var arrived atomic.Int32
+	rounds := []chan struct{}{make(chan struct{}), make(chan struct{})}
+	svc.TwoFactor.Now = func() time.Time {
+		n := int(arrived.Add(1)) - 1
+		if round := n / logins; round < len(rounds) {
+			if n%logins == logins-1 {
+				close(rounds[round])
+			}
+			<-rounds[round]
+		}
+		return time.Now()
+	}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	accepted := 0
+	for i := 0; i < logins; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := svc.TwoFactor.CompleteLogin(challenge.Token, next, "10.0.0.1"); err == nil {
+				mutex.Lock()
+				accepted++
+				mutex.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Fatalf("expected one login to finish, got %d", accepted)
+	}
+}
@@ -0,0 +1,391 @@
+package services
+
+import (
+	"booking-backend/apierror"
+	"booking-backend/models"
+	"booking-backend/pagination"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var emailPattern = regexp.MustCompile(`^[\w\.-]+@[\w\.-]+\.[a-zA-Z]{2,}$`)
+
+const (
+	AuditUserCreated         = "user.created"
+	AuditUserRoleChanged     = "user.role_changed"
+	AuditUserPasswordChanged = "user.password_changed"
+	AuditUserDeleted         = "user.deleted"
+)
+
+type UserService struct {
+	store     Store
+	Passwords PasswordPolicy
+	// TwoFactorRoles are the roles that must use two-factor authentication.
+	TwoFactorRoles []string
+}
+
+func NewUserService(store Store, passwords PasswordPolicy, twoFactorRoles []string) *UserService {
+	return &UserService{store: store, Passwords: passwords, TwoFactorRoles: twoFactorRoles}
+}
+
+type SignupInput struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required"`
+	Phone    string `json:"phone" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// CreateUserInput is an admin creating an account on someone's behalf.
+type CreateUserInput struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required"`
+	Phone    string `json:"phone"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role"`
+}
+
+// ProfileInput changes the caller's own profile; omitted fields are kept.
+type ProfileInput struct {
+	Name  *string `json:"name"`
+	Phone *string `json:"phone"`
+}
+
+type ChangePasswordInput struct {
+	// CurrentPassword is required of accounts that have a password.
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// UserView is how users appear in API responses. The password hash and token
+// version never leave the server.
+type UserView struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Phone           string     `json:"phone"`
+	Role            string     `json:"role"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	TwoFactor       bool       `json:"two_factor_enabled"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+}
+
+func NewUserView(user *models.User) UserView {
+	return UserView{
+		ID:              user.ID,
+		Name:            user.Name,
+		Email:           user.Email,
+		Phone:           user.Phone,
+		Role:            user.Role,
+		EmailVerifiedAt: user.EmailVerifiedAt,
+		TwoFactor:       user.TwoFactorEnabledAt != nil,
+		CreatedAt:       user.CreatedAt,
+		UpdatedAt:       user.UpdatedAt,
+	}
+}
+
+func IsValidEmail(email string) bool {
+	return emailPattern.MatchString(email)
+}
+
+func (s *UserService) List(filter UserFilter, page pagination.Params) (pagination.Page[UserView], error) {
+	rows, total, err := s.store.Users().Search(filter, page)
+	if err != nil {
+		return pagination.Page[UserView]{}, err
+	}
+	data, info := pagination.Trim(rows, total, page, userKey)
+	views := make([]UserView, len(data))
+	for i := range data {
+		views[i] = NewUserView(&data[i])
+	}
+	return pagination.Page[UserView]{Data: views, Pagination: info}, nil
+}
+
+func (s *UserService) Get(id uint) (*models.User, error) {
+	user, err := s.store.Users().Find(id)
+	if err != nil {
+		return nil, notFoundAs(err, apierror.UserNotFound)
+	}
+	return user, nil
+}
+
+// Create registers an account for an admin. The password goes through the
+// same policy as signup and the new user is mailed a verification link.
+func (s *UserService) Create(admin *models.User, input CreateUserInput, ip, locale string) (*models.User, error) {
+	if input.Role == "" {
+		input.Role = RoleCustomer
+	}
+	if !contains(AccountRoles, input.Role) {
+		return nil, apierror.Validation(apierror.Field("role", apierror.NotAllowed))
+	}
+	if err := checkNewAccount(s.store, s.Passwords, input.Name, input.Email, input.Password); err != nil {
+		return nil, err
+	}
+	if input.Phone != "" {
+		if err := validatePhone(input.Phone); err != nil {
+			return nil, err
+		}
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	user := models.User{
+		Name:      input.Name,
+		Email:     input.Email,
+		Phone:     input.Phone,
+		Password:  string(hashed),
+		Role:      input.Role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err = s.store.Transaction(func(tx Store) error {
+		if err := tx.Users().Create(&user); err != nil {
+			return err
+		}
+		link, err := queueVerification(tx, &user, now)
+		if err != nil {
+			return err
+		}
+		if err := tx.Outbox().QueueEmail("verify_email", locale, user.Email, link); err != nil {
+			return err
+		}
+		return audit(tx, AuditUserCreated, &user, ip, fmt.Sprintf("created as %s by %s", user.Role, admin.Email))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetRole makes a user a super admin or takes that away. Without it their
+// role follows their restaurant assignments. It is refused when it would
+// leave no super admin, and signs the user out when the new role needs
+// two-factor authentication they have not set up.
+func (s *UserService) SetRole(admin *models.User, id uint, role, ip string) (*models.User, error) {
+	if !contains(AccountRoles, role) {
+		return nil, apierror.Validation(apierror.Field("role", apierror.NotAllowed))
+	}
+	var user *models.User
+	err := s.store.Transaction(func(tx Store) error {
+		var err error
+		user, err = tx.Users().Find(id)
+		if err != nil {
+			return notFoundAs(err, apierror.UserNotFound)
+		}
+		if user.Role == role || role == RoleCustomer && user.Role != RoleSuperAdmin {
+			return nil
+		}
+		if err := keepAnAdmin(tx, user); err != nil {
+			return err
+		}
+		previous := user.Role
+		user.Role = role
+		if err := deriveRole(tx, user); err != nil {
+			return err
+		}
+		now := time.Now()
+		user.UpdatedAt = now
+		if err := tx.Users().Save(user); err != nil {
+			return err
+		}
+		if err := requireTwoFactor(tx, s.TwoFactorRoles, user, now); err != nil {
+			return err
+		}
+		return audit(tx, AuditUserRoleChanged, user, ip, fmt.Sprintf("%s -> %s by %s", previous, user.Role, admin.Email))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// UpdateProfile changes the caller's name and phone.
+func (s *UserService) UpdateProfile(user *models.User, input ProfileInput) (*models.User, error) {
+	if input.Name != nil {
+		if *input.Name == "" {
+			return nil, apierror.Validation(apierror.Field("name", apierror.Required))
+		}
+		if other, err := s.store.Users().FindByName(*input.Name); err == nil && other.ID != user.ID {
+			return nil, apierror.Invalid(apierror.NameTaken)
+		} else if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		user.Name = *input.Name
+	}
+	if input.Phone != nil {
+		if *input.Phone != "" {
+			if err := validatePhone(*input.Phone); err != nil {
+				return nil, err
+			}
+		}
+		user.Phone = *input.Phone
+	}
+	user.UpdatedAt = time.Now()
+	if err := s.store.Users().Save(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// RecentLoginWindow is how long after logging in users without a password,
+// who signed up through a login provider, may change or delete their account.
+const RecentLoginWindow = 10 * time.Minute
+
+// confirmIdentity checks the caller is the account's owner: by its password,
+// or for accounts without one by a login made in the last RecentLoginWindow.
+// authTime is when the caller's session logged in.
+func confirmIdentity(user *models.User, field, password string, authTime time.Time) error {
+	if user.Password == "" {
+		if time.Since(authTime) > RecentLoginWindow {
+			return apierror.Forbidden(apierror.ReauthRequired)
+		}
+		return nil
+	}
+	if password == "" {
+		return apierror.Validation(apierror.Field(field, apierror.Required))
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return apierror.Validation(apierror.Field(field, apierror.WrongPassword))
+	}
+	return nil
+}
+
+// ChangePassword replaces the caller's password after confirming their
+// identity, and revokes every session so a stolen token stops working.
+// Accounts without a password set their first one this way.
+func (s *UserService) ChangePassword(user *models.User, input ChangePasswordInput, authTime time.Time, ip, locale string) error {
+	if err := confirmIdentity(user, "current_password", input.CurrentPassword, authTime); err != nil {
+		return err
+	}
+	if err := s.Passwords.Check("new_password", input.NewPassword); err != nil {
+		return err
+	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(input.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx Store) error {
+		now := time.Now()
+		user.Password = string(hashed)
+		user.UpdatedAt = now
+		if err := revokeSessions(tx, user, now); err != nil {
+			return err
+		}
+		if err := tx.Outbox().QueueEmail("password_changed", locale, user.Email, map[string]string{"Name": user.Name, "Email": user.Email}); err != nil {
+			return err
+		}
+		return audit(tx, AuditUserPasswordChanged, user, ip, "")
+	})
+}
+
+// Delete removes the caller's account once they confirm their identity as
+// ChangePassword does. Their bookings stay, detached from the account.
+func (s *UserService) Delete(user *models.User, password string, authTime time.Time, ip string) error {
+	if err := confirmIdentity(user, "password", password, authTime); err != nil {
+		return err
+	}
+	return s.store.Transaction(func(tx Store) error {
+		if err := keepAnAdmin(tx, user); err != nil {
+			return err
+		}
+		if err := tx.Users().Delete(user.ID); err != nil {
+			return err
+		}
+		return tx.Audit().Record(&models.AuditEvent{
+			Type:    AuditUserDeleted,
+			Subject: fmt.Sprintf("user:%d", user.ID),
+			IP:      ip,
+			Details: user.Email,
+		})
+	})
+}
+
+// keepAnAdmin refuses to demote or delete user if they are the only super
+// admin.
+func keepAnAdmin(tx Store, user *models.User) error {
+	if user.Role != RoleSuperAdmin {
+		return nil
+	}
+	_, admins, err := tx.Users().Search(UserFilter{Role: RoleSuperAdmin}, pagination.First(UserSorts, "id", 1))
+	if err != nil {
+		return err
+	}
+	if admins <= 1 {
+		return apierror.Conflict(apierror.LastAdmin)
+	}
+	return nil
+}
+
+func audit(tx Store, eventType string, user *models.User, ip, details string) error {
+	return tx.Audit().Record(&models.AuditEvent{
+		Type:    eventType,
+		UserID:  &user.ID,
+		Subject: fmt.Sprintf("user:%d", user.ID),
+		IP:      ip,
+		Details: details,
+	})
+}
+
+// checkNewAccount validates the fields every new account needs. Email and
+// display name must both be unused.
+func checkNewAccount(store Store, passwords PasswordPolicy, name, email, password string) error {
+	if !IsValidEmail(email) {
+		return apierror.Validation(apierror.Field("email", apierror.InvalidEmail))
+	}
+	if err := passwords.Check("password", password); err != nil {
+		return err
+	}
+	if _, err := store.Users().FindByEmail(email); err == nil {
+		return apierror.Invalid(apierror.EmailTaken)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	if _, err := store.Users().FindByName(name); err == nil {
+		return apierror.Invalid(apierror.NameTaken)
+	} else if !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Signup registers a guest account. The welcome email, which carries the
+// verification link, is queued with the new row.
+func (s *UserService) Signup(input SignupInput, locale string) (*models.User, error) {
+	if err := checkNewAccount(s.store, s.Passwords, input.Name, input.Email, input.Password); err != nil {
+		return nil, err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("Failed to hash password")
+	}
+	user := models.User{
+		Name:      input.Name,
+		Email:     input.Email,
+		Phone:     input.Phone,
+		Password:  string(hashedPassword),
+		Role:      RoleCustomer,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+	err = s.store.Transaction(func(tx Store) error {
+		if err := tx.Users().Create(&user); err != nil {
+			return err
+		}
+		link, err := queueVerification(tx, &user, user.CreatedAt)
+		if err != nil {
+			return err
+		}
+		return tx.Outbox().QueueEmail("signup", locale, user.Email, link)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
@@ -0,0 +1,42 @@
+package services_test
+
+import (
+	"booking-backend/models"
+	"booking-backend/services"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDeletedAccountsLoseTheirSessions(t *testing.T) {
+	store := services.NewMemoryStore()
+	svc := services.New(store)
+	hash, _ := bcrypt.GenerateFromPassword([]byte("mango-sticky-rice"), bcrypt.MinCost)
+	user := &models.User{Name: "A", Email: "a@example.com", Password: string(hash), Role: services.RoleCustomer}
+	if err := store.Users().Create(user); err != nil {
+		t.Fatal(err)
+	}
+	tokens, err := svc.Auth.Login(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.Users.Delete(user, "wrong-password", time.Now(), "192.0.2.1"); !errors.Is(err, services.ErrInvalid) {
+		t.Fatalf("expected the wrong password to be rejected, got %v", err)
+	}
+	if err := svc.Users.Delete(user, "mango-sticky-rice", time.Now(), "192.0.2.1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Users().Find(user.ID); !errors.Is(err, services.ErrNotFound) {
+		t.Fatalf("expected the user to be gone, got %v", err)
+	}
+	if _, _, err := svc.Auth.Refresh(tokens.RefreshToken); err == nil {
+		t.Fatal("a deleted user's refresh token still works")
+	}
+	events := store.AuditEvents()
+	if len(events) != 1 || events[0].Type != services.AuditUserDeleted || events[0].UserID != nil {
+		t.Fatalf("unexpected audit events %+v", events)
+	}
+}
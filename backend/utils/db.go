@@ -7,6 +7,7 @@ import (
 
 	"github.com/joho/godotenv"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -16,10 +17,14 @@ func InitDB() *gorm.DB {
 		log.Println("No .env file found, using system environment variables")
 	}
 
+	driver := GetEnv("DB_DRIVER", "postgres")
 	var dsn string
 	databaseURL := os.Getenv("DATABASE_URL")
 
-	if databaseURL != "" {
+	if driver == "sqlite" {
+		dsn = GetEnv("DB_PATH", "booking.db")
+		log.Printf("Using SQLite database at %s", dsn)
+	} else if databaseURL != "" {
 		dsn = databaseURL
 		log.Println("Using DATABASE_URL for connection")
 	} else {
@@ -35,7 +40,7 @@ func InitDB() *gorm.DB {
 		log.Println("Using individual DB config for connection")
 	}
 
-	DB, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	DB, err := OpenDB(driver, dsn)
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -44,6 +49,29 @@ func InitDB() *gorm.DB {
 	return DB
 }
 
+// OpenDB connects with the given driver: "postgres" for deployments, or
+// "sqlite" for local tooling and the test suite.
+func OpenDB(driver, dsn string) (*gorm.DB, error) {
+	switch driver {
+	case "postgres":
+		return gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	case "sqlite":
+		DB, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+		if err != nil {
+			return nil, err
+		}
+		// SQLite allows one writer; a single connection avoids SQLITE_BUSY.
+		sqlDB, err := DB.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		return DB, nil
+	default:
+		return nil, fmt.Errorf("unsupported DB_DRIVER %q", driver)
+	}
+}
+
 func GetEnv(key, defaultValue string) string {
 	value := os.Getenv(key)
 	if value == "" {
@@ -1,6 +1,7 @@
 package websocket
 
 import (
+	"booking-backend/apierror"
 	"log"
 	"net/http"
 
@@ -14,18 +15,39 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-func HandleWebSocket(c *gin.Context) {
+func HandleWebSocket(c *gin.Context, authenticate Authenticator) {
+	if err := authenticate(c); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	sub, err := parseSubscription(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
 	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade connection: %v", err)
 		return
 	}
 
-	GlobalHub.register <- conn
+	client := GlobalHub.NewClient(sub.lastEventID, sub.topics)
+	GlobalHub.register <- client
+
+	go func() {
+		defer conn.Close()
+		for event := range client.send {
+			if err := conn.WriteMessage(websocket.TextMessage, event.Message); err != nil {
+				log.Printf("Error sending message: %v", err)
+				break
+			}
+		}
+	}()
 
 	go func() {
 		defer func() {
-			GlobalHub.unregister <- conn
+			GlobalHub.unregister <- client
 		}()
 
 		for {
@@ -3,24 +3,62 @@ package websocket
 import (
 	"encoding/json"
 	"log"
+	"strconv"
 	"sync"
+	"time"
 
-	"github.com/gorilla/websocket"
+	"booking-backend/utils"
 )
 
+const clientSendBuffer = 64
+
+type Event struct {
+	ID        uint64
+	Type      string
+	Message   []byte
+	CreatedAt time.Time
+}
+
+type Client struct {
+	send        chan Event
+	lastEventID uint64
+	topics      map[string]bool
+}
+
+func (c *Client) wants(eventType string) bool {
+	return len(c.topics) == 0 || eventType == "resync" || c.topics[eventType]
+}
+
 type Hub struct {
-	clients    map[*websocket.Conn]bool
-	broadcast  chan []byte
-	register   chan *websocket.Conn
-	unregister chan *websocket.Conn
+	clients    map[*Client]bool
+	broadcast  chan map[string]interface{}
+	register   chan *Client
+	unregister chan *Client
 	mutex      sync.RWMutex
+
+	lastID     uint64
+	history    []Event
+	maxHistory int
 }
 
-var GlobalHub = &Hub{
-	clients:    make(map[*websocket.Conn]bool),
-	broadcast:  make(chan []byte),
-	register:   make(chan *websocket.Conn),
-	unregister: make(chan *websocket.Conn),
+var GlobalHub = NewHub(historySizeFromEnv())
+
+func NewHub(maxHistory int) *Hub {
+	return &Hub{
+		clients:    make(map[*Client]bool),
+		broadcast:  make(chan map[string]interface{}),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		maxHistory: maxHistory,
+	}
+}
+
+func historySizeFromEnv() int {
+	size, err := strconv.Atoi(utils.GetEnv("WS_EVENT_BUFFER", "256"))
+	if err != nil || size <= 0 {
+		return 256
+	}
+	return size
 }
 
 func (h *Hub) Run() {
@@ -28,6 +66,7 @@ func (h *Hub) Run() {
 		select {
 		case client := <-h.register:
 			h.mutex.Lock()
+			h.replay(client)
 			h.clients[client] = true
 			h.mutex.Unlock()
 			log.Println("New WebSocket client connected")
@@ -36,36 +75,114 @@ func (h *Hub) Run() {
 			h.mutex.Lock()
 			if _, ok := h.clients[client]; ok {
 				delete(h.clients, client)
-				client.Close()
+				close(client.send)
 			}
 			h.mutex.Unlock()
 			log.Println("WebSocket client disconnected")
 
-		case message := <-h.broadcast:
-			h.mutex.RLock()
+		case data := <-h.broadcast:
+			event, err := h.record(data)
+			if err != nil {
+				log.Printf("Error marshaling data: %v", err)
+				continue
+			}
+			h.mutex.Lock()
 			for client := range h.clients {
-				err := client.WriteMessage(websocket.TextMessage, message)
-				if err != nil {
-					log.Printf("Error sending message: %v", err)
-					client.Close()
+				if !client.wants(event.Type) {
+					continue
+				}
+				select {
+				case client.send <- event:
+				default:
+					log.Printf("Dropping slow client after event %d", event.ID)
 					delete(h.clients, client)
+					close(client.send)
 				}
 			}
-			h.mutex.RUnlock()
+			h.mutex.Unlock()
 		}
 	}
 }
 
+// record stamps the next sequence ID on an event and keeps it in the bounded
+// history so reconnecting clients can catch up.
+func (h *Hub) record(data map[string]interface{}) (Event, error) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	data["id"] = h.lastID + 1
+	message, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, err
+	}
+	h.lastID++
+	eventType, _ := data["type"].(string)
+	event := Event{ID: h.lastID, Type: eventType, Message: message, CreatedAt: time.Now()}
+
+	h.history = append(h.history, event)
+	if len(h.history) > h.maxHistory {
+		h.history = h.history[len(h.history)-h.maxHistory:]
+	}
+	return event, nil
+}
+
+// replay queues every buffered event newer than the client's last_event_id.
+// If the client fell further behind than the buffer reaches, it is told to
+// resync from the REST API instead; the same happens after a server restart,
+// when the client's ID is ahead of ours. Callers must hold h.mutex.
+func (h *Hub) replay(client *Client) {
+	if client.lastEventID == 0 || client.lastEventID == h.lastID {
+		return
+	}
+	if client.lastEventID > h.lastID || len(h.history) == 0 || h.history[0].ID > client.lastEventID+1 {
+		message, _ := json.Marshal(map[string]interface{}{
+			"type": "resync",
+			"id":   h.lastID,
+		})
+		client.send <- Event{ID: h.lastID, Type: "resync", Message: message, CreatedAt: time.Now()}
+		return
+	}
+	for _, event := range h.history {
+		if event.ID <= client.lastEventID || !client.wants(event.Type) {
+			continue
+		}
+		client.send <- event
+	}
+}
+
+func (h *Hub) LastEventID() uint64 {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+	return h.lastID
+}
+
+// NewClient sizes the send queue so a full history replay fits ahead of live
+// events.
+func (h *Hub) NewClient(lastEventID uint64, topics []string) *Client {
+	client := &Client{
+		send:        make(chan Event, h.maxHistory+clientSendBuffer),
+		lastEventID: lastEventID,
+		topics:      make(map[string]bool),
+	}
+	for _, topic := range topics {
+		client.topics[topic] = true
+	}
+	return client
+}
+
+// Publish sequences and fans out an event. data must carry a "type" key.
+func (h *Hub) Publish(data map[string]interface{}) {
+	h.broadcast <- data
+}
+
 func (h *Hub) BroadcastSessionCancelled(sessionName, userName string) {
-	data := map[string]interface{}{
+	h.Publish(SessionCancelledEvent(sessionName, userName))
+}
+
+func SessionCancelledEvent(sessionName, userName string) map[string]interface{} {
+	return map[string]interface{}{
 		"type":        "sessionCancelled",
 		"sessionName": sessionName,
 		"userName":    userName,
 	}
-	jsonData, err := json.Marshal(data)
-	if err != nil {
-		log.Printf("Error marshaling data: %v", err)
-		return
-	}
-	h.broadcast <- jsonData
 }
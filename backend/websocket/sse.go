@@ -0,0 +1,62 @@
+package websocket
+
+import (
+	"booking-backend/apierror"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const sseHeartbeatInterval = 25 * time.Second
+
+// HandleEvents serves the hub stream as Server-Sent Events for clients and
+// proxies that cannot hold a websocket. Each message carries the same JSON as
+// the websocket transport, with the event ID repeated in the SSE id field so
+// the browser resumes from it automatically.
+func HandleEvents(c *gin.Context, authenticate Authenticator) {
+	if err := authenticate(c); err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+	sub, err := parseSubscription(c)
+	if err != nil {
+		apierror.Respond(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	client := GlobalHub.NewClient(sub.lastEventID, sub.topics)
+	GlobalHub.register <- client
+	log.Println("New SSE client connected")
+	defer func() {
+		GlobalHub.unregister <- client
+		for range client.send {
+		}
+	}()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-client.send:
+			if !ok {
+				return false
+			}
+			fmt.Fprintf(w, "id: %d\ndata: %s\n\n", event.ID, event.Message)
+			return true
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			return true
+		}
+	})
+}
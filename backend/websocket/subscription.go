@@ -0,0 +1,44 @@
+package websocket
+
+import (
+	"booking-backend/apierror"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authenticator decides whether a request may subscribe to the event stream.
+// Both transports run it before the connection is accepted.
+type Authenticator func(c *gin.Context) error
+
+type subscription struct {
+	lastEventID uint64
+	topics      []string
+}
+
+// parseSubscription reads the resume point and topic filter shared by /ws and
+// /api/events. SSE clients send the resume point as the Last-Event-ID header
+// on automatic reconnects, so that is accepted as well as the query string.
+func parseSubscription(c *gin.Context) (subscription, error) {
+	var sub subscription
+
+	raw := c.Query("last_event_id")
+	if raw == "" {
+		raw = c.GetHeader("Last-Event-ID")
+	}
+	if raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return sub, apierror.Validation(apierror.Field("last_event_id", apierror.InvalidNumber))
+		}
+		sub.lastEventID = id
+	}
+
+	for _, topic := range strings.Split(c.Query("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			sub.topics = append(sub.topics, topic)
+		}
+	}
+	return sub, nil
+}
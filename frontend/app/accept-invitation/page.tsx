@@ -0,0 +1,97 @@
+"use client";
+import React, { Suspense, useState } from "react";
+import Link from "next/link";
+import { useSearchParams } from "next/navigation";
+import axios from "axios";
+
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+function AcceptInvitation() {
+  const token = useSearchParams().get("token") || "";
+  const [name, setName] = useState<string>("");
+  const [phone, setPhone] = useState<string>("");
+  const [password, setPassword] = useState<string>("");
+  const [loading, setLoading] = useState<boolean>(false);
+  const [error, setError] = useState<string>("");
+  const [done, setDone] = useState<boolean>(false);
+
+  const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setLoading(true);
+    setError("");
+    try {
+      await axios.post(`${API_URL}/invitations/accept`, { token, name, phone, password });
+      setDone(true);
+    } catch (err: any) {
+      setError(err?.response?.data?.error || "Could not accept the invitation");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen bg-gray-50">
+      <div className="bg-white p-8 rounded shadow-md w-full max-w-md">
+        <h1 className="text-2xl font-bold mb-6 text-center">Join the Restaurant</h1>
+        {done ? (
+          <p className="text-green-600 text-center">
+            You joined the restaurant.{" "}
+            <Link href="/login" className="text-blue-600 hover:underline">
+              Log in
+            </Link>
+          </p>
+        ) : (
+          <form onSubmit={handleSubmit}>
+            <p className="text-gray-600 mb-4">
+              Already have an account with the invited email? Leave the fields empty and accept. If you
+              never verified its email, enter a new password.
+            </p>
+            <div className="mb-4">
+              <label className="block mb-1 font-medium">Name</label>
+              <input
+                type="text"
+                className="w-full border px-3 py-2 rounded"
+                value={name}
+                onChange={(e) => setName(e.target.value)}
+              />
+            </div>
+            <div className="mb-4">
+              <label className="block mb-1 font-medium">Phone</label>
+              <input
+                type="tel"
+                className="w-full border px-3 py-2 rounded"
+                value={phone}
+                onChange={(e) => setPhone(e.target.value)}
+              />
+            </div>
+            <div className="mb-6">
+              <label className="block mb-1 font-medium">Password</label>
+              <input
+                type="password"
+                className="w-full border px-3 py-2 rounded"
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+              />
+            </div>
+            {error && <div className="text-red-600 mb-4 text-center">{error}</div>}
+            <button
+              type="submit"
+              className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+              disabled={loading}
+            >
+              {loading ? "Joining..." : "Accept invitation"}
+            </button>
+          </form>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default function AcceptInvitationPage() {
+  return (
+    <Suspense>
+      <AcceptInvitation />
+    </Suspense>
+  );
+}
@@ -5,7 +5,18 @@ import axios from "axios";
 import { useAuth } from "../contexts/AuthContext";
 import SessionList from "../components/SessionList";
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+interface Page<T> {
+  data: T[];
+  pagination: {
+    limit: number;
+    total: number;
+    sort: string;
+    has_more: boolean;
+    next_cursor?: string;
+  };
+}
 
 interface Session {
   id: number;
@@ -37,6 +48,11 @@ interface TimeSlot {
   slot_name: string;
 }
 
+// Managing sessions and reading bookings needs the signed in user's token.
+const authHeaders = () => ({
+  headers: { Authorization: `Bearer ${localStorage.getItem("token") || ""}` },
+});
+
 interface CreateSessionForm {
   time_slot_id: number;
   name: string;
@@ -71,7 +87,7 @@ export default function AdminDashboard() {
   const handleDeleteSession = async (id: number) => {
     if (confirm("คุณต้องการลบ Session นี้หรือไม่?")) {
       try {
-        await axios.delete(`${API_URL}/sessions/${id}`);
+        await axios.delete(`${API_URL}/sessions/${id}`, authHeaders());
         fetchSessions();
         alert("ลบ Session สำเร็จ!");
       } catch (error) {
@@ -103,7 +119,7 @@ export default function AdminDashboard() {
         name: editForm.name,
         date: editForm.date,
         max_guests: editForm.max_guests,
-      });
+      }, authHeaders());
       alert("แก้ไข Session สำเร็จ!");
       setShowEditModal(false);
       setEditSession(null);
@@ -153,7 +169,7 @@ export default function AdminDashboard() {
         name: createForm.name,
         date: createForm.date,
         max_guests: createForm.max_guests,
-      });
+      }, authHeaders());
       alert("สร้าง Session สำเร็จ!");
       setShowCreateModal(false);
       setCreateForm({
@@ -170,8 +186,10 @@ export default function AdminDashboard() {
 
   const fetchSessions = async () => {
     try {
-      const response = await axios.get<Session[]>(`${API_URL}/sessions`);
-      setSessions(response.data || []);
+      const response = await axios.get<Page<Session>>(
+        `${API_URL}/sessions?limit=200`
+      );
+      setSessions(response.data.data || []);
       setSessionLoading(false);
     } catch (error) {
       setSessionLoading(false);
@@ -180,8 +198,11 @@ export default function AdminDashboard() {
 
   const fetchBookings = async () => {
     try {
-      const response = await axios.get<Booking[]>(`${API_URL}/bookings`);
-      setBookings(response.data || []);
+      const response = await axios.get<Page<Booking>>(
+        `${API_URL}/bookings?limit=200`,
+        authHeaders()
+      );
+      setBookings(response.data.data || []);
       setLoading(false);
     } catch (error) {
       setLoading(false);
@@ -15,10 +15,17 @@ export default function Header() {
   const [showNotifications, setShowNotifications] = useState<boolean>(false);
   const [showProfilePanel, setShowProfilePanel] = useState<boolean>(false);
   const wsRef = useRef<WebSocket | null>(null);
+  const lastEventIdRef = useRef<number>(0);
+  const reconnectTimerRef = useRef<ReturnType<typeof setTimeout> | null>(null);
 
   useEffect(() => {
-    if (!wsRef.current) {
-      const ws = new WebSocket("ws://localhost:8080/ws");
+    let closedByUnmount = false;
+
+    const connect = () => {
+      const query = lastEventIdRef.current
+        ? `?last_event_id=${lastEventIdRef.current}`
+        : "";
+      const ws = new WebSocket(`ws://localhost:8080/ws${query}`);
 
       ws.onopen = () => {
         console.log("ws connected");
@@ -27,6 +34,9 @@ export default function Header() {
       ws.onmessage = (event) => {
         try {
           const data = JSON.parse(event.data);
+          if (typeof data.id === "number") {
+            lastEventIdRef.current = data.id;
+          }
           if (data.type === "sessionCancelled") {
             setNotifications((prev) => [
               {
@@ -48,12 +58,23 @@ export default function Header() {
       ws.onclose = () => {
         console.log("WebSocket disconnected");
         wsRef.current = null;
+        if (!closedByUnmount) {
+          reconnectTimerRef.current = setTimeout(connect, 2000);
+        }
       };
 
       wsRef.current = ws;
+    };
+
+    if (!wsRef.current) {
+      connect();
     }
 
     return () => {
+      closedByUnmount = true;
+      if (reconnectTimerRef.current) {
+        clearTimeout(reconnectTimerRef.current);
+      }
       if (wsRef.current) {
         wsRef.current.close();
         wsRef.current = null;
@@ -143,7 +164,7 @@ export default function Header() {
           />
           <div className="absolute top-16 right-8 w-64 bg-white shadow-lg rounded-lg z-50 p-4">
             <h3 className="text-lg font-bold mb-4">เมนู</h3>
-            {user.role === "admin" ? (
+            {user.role !== "customer" ? (
               <Link href="/adminDashboard">
                 <button className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 mb-2">
                   แดชบอร์ด
@@ -0,0 +1,125 @@
+"use client";
+import React, { useEffect, useState } from "react";
+import axios from "axios";
+
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+export interface LoginChallenge {
+  two_factor_required: boolean;
+  two_factor_token: string;
+  setup_required: boolean;
+}
+
+interface TwoFactorStepProps {
+  challenge: LoginChallenge;
+}
+
+// TwoFactorStep finishes a login that needs an authenticator code, enrolling
+// the user first when their role requires it.
+export default function TwoFactorStep({ challenge }: TwoFactorStepProps) {
+  const [secret, setSecret] = useState<string>("");
+  const [otpauthUrl, setOtpauthUrl] = useState<string>("");
+  const [code, setCode] = useState<string>("");
+  const [recoveryCodes, setRecoveryCodes] = useState<string[]>([]);
+  const [loading, setLoading] = useState<boolean>(false);
+  const [error, setError] = useState<string>("");
+
+  useEffect(() => {
+    if (!challenge.setup_required) {
+      return;
+    }
+    axios
+      .post<{ secret: string; otpauth_url: string }>(`${API_URL}/login/two-factor/setup`, {
+        two_factor_token: challenge.two_factor_token,
+      })
+      .then((res) => {
+        setSecret(res.data.secret);
+        setOtpauthUrl(res.data.otpauth_url);
+      })
+      .catch((err: any) => setError(err?.response?.data?.error || "Could not set up two-factor authentication"));
+  }, [challenge]);
+
+  const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setLoading(true);
+    setError("");
+    try {
+      const res = await axios.post<{ token: string; refresh_token: string; recovery_codes?: string[] }>(
+        `${API_URL}/login/two-factor`,
+        { two_factor_token: challenge.two_factor_token, code },
+      );
+      localStorage.setItem("token", res.data.token);
+      localStorage.setItem("refresh_token", res.data.refresh_token);
+      if (res.data.recovery_codes?.length) {
+        setRecoveryCodes(res.data.recovery_codes);
+      } else {
+        window.location.href = "/";
+      }
+    } catch (err: any) {
+      setError(err?.response?.data?.error || "Verification failed");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  if (recoveryCodes.length > 0) {
+    return (
+      <div>
+        <p className="mb-4">
+          Save these recovery codes somewhere safe. Each one signs you in once if you lose your authenticator; they are
+          not shown again.
+        </p>
+        <ul className="grid grid-cols-2 gap-2 font-mono mb-6">
+          {recoveryCodes.map((c) => (
+            <li key={c}>{c}</li>
+          ))}
+        </ul>
+        <button
+          type="button"
+          className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+          onClick={() => (window.location.href = "/")}
+        >
+          Continue
+        </button>
+      </div>
+    );
+  }
+
+  return (
+    <form onSubmit={handleSubmit}>
+      {challenge.setup_required ? (
+        <div className="mb-4">
+          <p className="mb-2">Your account requires two-factor authentication. Add this key to your authenticator app:</p>
+          <p className="font-mono break-all bg-gray-100 p-2 rounded">{secret}</p>
+          {otpauthUrl && (
+            <a href={otpauthUrl} className="text-sm text-blue-600 hover:underline">
+              Open in authenticator app
+            </a>
+          )}
+        </div>
+      ) : (
+        <p className="mb-4">Enter the code from your authenticator app, or a recovery code.</p>
+      )}
+      <div className="mb-6">
+        <label className="block mb-1 font-medium">Code</label>
+        <input
+          type="text"
+          inputMode="numeric"
+          autoComplete="one-time-code"
+          className="w-full border px-3 py-2 rounded"
+          required
+          value={code}
+          onChange={(e) => setCode(e.target.value)}
+        />
+      </div>
+      {error && <div className="text-red-600 mb-4 text-center">{error}</div>}
+      <button
+        type="submit"
+        className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+        disabled={loading}
+      >
+        {loading ? "Verifying..." : "Verify"}
+      </button>
+    </form>
+  );
+}
@@ -9,7 +9,7 @@ import {
 } from "react";
 import axios from "axios";
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
 
 interface User {
   id: string;
@@ -32,18 +32,52 @@ export function AuthProvider({ children }: { children: ReactNode }) {
   const [user, setUser] = useState<User | null>(null);
   const [loading, setLoading] = useState<boolean>(true);
 
+  const clearTokens = () => {
+    localStorage.removeItem("token");
+    localStorage.removeItem("refresh_token");
+  };
+
+  // Access tokens are short-lived; when one is rejected, trade the refresh
+  // token for a new pair once before giving up.
+  const renewTokens = async (): Promise<string | null> => {
+    const refreshToken = localStorage.getItem("refresh_token");
+    if (!refreshToken) return null;
+    try {
+      const response = await axios.post<{ token: string; refresh_token: string }>(
+        `${API_URL}/token/refresh`,
+        { refresh_token: refreshToken }
+      );
+      localStorage.setItem("token", response.data.token);
+      localStorage.setItem("refresh_token", response.data.refresh_token);
+      return response.data.token;
+    } catch (error) {
+      return null;
+    }
+  };
+
+  const fetchUser = (token: string) =>
+    axios.get<{ user: User }>(`${API_URL}/user`, {
+      headers: { Authorization: `Bearer ${token}` },
+    });
+
   const refreshUser = async () => {
     const token =
       typeof window !== "undefined" ? localStorage.getItem("token") : null;
     if (token) {
       try {
-        const response = await axios.get<{ user: User }>(`${API_URL}/user`, {
-          headers: { Authorization: `Bearer ${token}` },
-        });
+        const response = await fetchUser(token);
         setUser(response.data.user);
       } catch (error) {
+        const renewed = await renewTokens();
+        if (renewed) {
+          try {
+            const response = await fetchUser(renewed);
+            setUser(response.data.user);
+            return;
+          } catch (retryError) {}
+        }
         setUser(null);
-        localStorage.removeItem("token");
+        clearTokens();
       } finally {
         setLoading(false);
       }
@@ -56,8 +90,14 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     refreshUser();
   }, []);
 
-  const logout = () => {
-    localStorage.removeItem("token");
+  const logout = async () => {
+    const refreshToken = localStorage.getItem("refresh_token");
+    if (refreshToken) {
+      try {
+        await axios.post(`${API_URL}/logout`, { refresh_token: refreshToken });
+      } catch (error) {}
+    }
+    clearTokens();
     setUser(null);
     window.location.href = "/";
   };
@@ -0,0 +1,66 @@
+"use client";
+import React, { useState } from "react";
+import Link from "next/link";
+import axios from "axios";
+
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+export default function ForgotPassword() {
+  const [email, setEmail] = useState<string>("");
+  const [loading, setLoading] = useState<boolean>(false);
+  const [error, setError] = useState<string>("");
+  const [sent, setSent] = useState<boolean>(false);
+
+  const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    setLoading(true);
+    setError("");
+    try {
+      await axios.post(`${API_URL}/password/forgot`, { email });
+      setSent(true);
+    } catch (err: any) {
+      setError(err?.response?.data?.error || "Request failed");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen bg-gray-50">
+      <div className="bg-white p-8 rounded shadow-md w-full max-w-md">
+        <h1 className="text-2xl font-bold mb-6 text-center">Forgot Password</h1>
+        {sent ? (
+          <p className="text-green-600 text-center">
+            If an account uses {email}, a reset link is on its way. It works once, within an hour.
+          </p>
+        ) : (
+          <form onSubmit={handleSubmit}>
+            <div className="mb-6">
+              <label className="block mb-1 font-medium">Email</label>
+              <input
+                type="email"
+                className="w-full border px-3 py-2 rounded"
+                required
+                value={email}
+                onChange={(e) => setEmail(e.target.value)}
+              />
+            </div>
+            {error && <div className="text-red-600 mb-4 text-center">{error}</div>}
+            <button
+              type="submit"
+              className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+              disabled={loading}
+            >
+              {loading ? "Sending..." : "Send reset link"}
+            </button>
+          </form>
+        )}
+        <div className="mt-4 text-center">
+          <Link href="/login" className="text-blue-600 hover:underline">
+            Back to login
+          </Link>
+        </div>
+      </div>
+    </div>
+  );
+}
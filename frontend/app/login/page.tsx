@@ -1,27 +1,57 @@
 "use client";
-import React, { useState } from "react";
+import React, { useEffect, useState } from "react";
 import Link from "next/link";
 import axios from "axios";
+import TwoFactorStep, { LoginChallenge } from "../components/TwoFactorStep";
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+type Provider = { name: string; display_name: string };
 
 export default function Login() {
   const [email, setEmail] = useState<string>("");
   const [password, setPassword] = useState<string>("");
   const [loading, setLoading] = useState<boolean>(false);
   const [error, setError] = useState<string>("");
+  const [providers, setProviders] = useState<Provider[]>([]);
+  const [challenge, setChallenge] = useState<LoginChallenge | null>(null);
+
+  useEffect(() => {
+    axios
+      .get<{ providers: Provider[] }>(`${API_URL}/auth/oidc/providers`)
+      .then((res) => setProviders(res.data.providers))
+      .catch(() => setProviders([]));
+  }, []);
+
+  const handleSocialLogin = async (provider: string) => {
+    setError("");
+    try {
+      const res = await axios.post<{ authorization_url: string }>(
+        `${API_URL}/auth/oidc/authorize`,
+        { provider },
+        // The API keeps a cookie that ties the login to this browser.
+        { withCredentials: true }
+      );
+      window.location.href = res.data.authorization_url;
+    } catch (err: any) {
+      setError(err?.response?.data?.error || "Login failed");
+    }
+  };
 
   const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
     e.preventDefault();
     setLoading(true);
     setError("");
     try {
-      const res = await axios.post<{ token: string }>(`${API_URL}/login`, {
-        email,
-        password,
-      });
-      if (res.data.token) {
+      const res = await axios.post<{ token?: string; refresh_token?: string } & Partial<LoginChallenge>>(
+        `${API_URL}/login`,
+        { email, password },
+      );
+      if (res.data.two_factor_required) {
+        setChallenge(res.data as LoginChallenge);
+      } else if (res.data.token && res.data.refresh_token) {
         localStorage.setItem("token", res.data.token);
+        localStorage.setItem("refresh_token", res.data.refresh_token);
         window.location.href = "/";
       }
     } catch (err: any) {
@@ -35,44 +65,69 @@ export default function Login() {
     <div className="flex flex-col items-center justify-center min-h-screen bg-gray-50">
       <div className="bg-white p-8 rounded shadow-md w-full max-w-md">
         <h1 className="text-2xl font-bold mb-6 text-center">Login</h1>
-        <form onSubmit={handleSubmit}>
-          <div className="mb-4">
-            <label className="block mb-1 font-medium">Email</label>
-            <input
-              type="email"
-              className="w-full border px-3 py-2 rounded"
-              required
-              value={email}
-              onChange={(e) => setEmail(e.target.value)}
-            />
-          </div>
-          <div className="mb-6">
-            <label className="block mb-1 font-medium">Password</label>
-            <input
-              type="password"
-              className="w-full border px-3 py-2 rounded"
-              required
-              value={password}
-              onChange={(e) => setPassword(e.target.value)}
-            />
-          </div>
-          {error && (
-            <div className="text-red-600 mb-4 text-center">{error}</div>
-          )}
-          <button
-            type="submit"
-            className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
-            disabled={loading}
-          >
-            {loading ? "Logging in..." : "Login"}
-          </button>
-        </form>
-        <div className="mt-4 text-center">
-          <span>Dont have an account? </span>
-          <Link href="/signup" className="text-blue-600 hover:underline">
-            Sign up
-          </Link>
-        </div>
+        {challenge ? (
+          <TwoFactorStep challenge={challenge} />
+        ) : (
+          <>
+            <form onSubmit={handleSubmit}>
+              <div className="mb-4">
+                <label className="block mb-1 font-medium">Email</label>
+                <input
+                  type="email"
+                  className="w-full border px-3 py-2 rounded"
+                  required
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                />
+              </div>
+              <div className="mb-6">
+                <label className="block mb-1 font-medium">Password</label>
+                <input
+                  type="password"
+                  className="w-full border px-3 py-2 rounded"
+                  required
+                  value={password}
+                  onChange={(e) => setPassword(e.target.value)}
+                />
+              </div>
+              <div className="mb-4 text-right">
+                <Link href="/forgot-password" className="text-sm text-blue-600 hover:underline">
+                  Forgot password?
+                </Link>
+              </div>
+              {error && (
+                <div className="text-red-600 mb-4 text-center">{error}</div>
+              )}
+              <button
+                type="submit"
+                className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+                disabled={loading}
+              >
+                {loading ? "Logging in..." : "Login"}
+              </button>
+            </form>
+            {providers.length > 0 && (
+              <div className="mt-4 space-y-2">
+                {providers.map((p) => (
+                  <button
+                    key={p.name}
+                    type="button"
+                    className="w-full border py-2 rounded font-semibold hover:bg-gray-100 transition"
+                    onClick={() => handleSocialLogin(p.name)}
+                  >
+                    Continue with {p.display_name}
+                  </button>
+                ))}
+              </div>
+            )}
+            <div className="mt-4 text-center">
+              <span>Dont have an account? </span>
+              <Link href="/signup" className="text-blue-600 hover:underline">
+                Sign up
+              </Link>
+            </div>
+          </>
+        )}
       </div>
     </div>
   );
@@ -4,7 +4,7 @@ import { useState, useEffect } from "react";
 import axios from "axios";
 import { useAuth } from "../contexts/AuthContext";
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
 
 interface Booking {
   id: number;
@@ -18,6 +18,11 @@ interface Booking {
   status: string;
 }
 
+// Guests list and cancel only their own bookings, so both need their token.
+const authHeaders = () => ({
+  headers: { Authorization: `Bearer ${localStorage.getItem("token") || ""}` },
+});
+
 export default function MyBooking() {
   const { user } = useAuth();
   const [bookings, setBookings] = useState<Booking[]>([]);
@@ -32,7 +37,8 @@ export default function MyBooking() {
   const fetchBookings = async (email: string) => {
     try {
       const response = await axios.get<Booking[]>(
-        `${API_URL}/bookings/user/${email}`
+        `${API_URL}/bookings/user/${email}`,
+        authHeaders()
       );
       setBookings(response.data || []);
       setLoading(false);
@@ -45,7 +51,7 @@ export default function MyBooking() {
     if (!user?.email) return;
     if (confirm("คุณต้องการลบการจองนี้หรือไม่?")) {
       try {
-        await axios.delete(`${API_URL}/bookings/${user.email}/${id}`);
+        await axios.delete(`${API_URL}/bookings/${user.email}/${id}`, authHeaders());
         fetchBookings(user.email);
         alert("ลบสำเร็จ!");
       } catch (error) {
@@ -0,0 +1,68 @@
+"use client";
+import React, { Suspense, useEffect, useState } from "react";
+import Link from "next/link";
+import { useSearchParams } from "next/navigation";
+import axios from "axios";
+import TwoFactorStep, { LoginChallenge } from "../components/TwoFactorStep";
+
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+function OAuthCallback() {
+  const params = useSearchParams();
+  const state = params.get("state") || "";
+  const code = params.get("code") || "";
+  const denied = params.get("error");
+  const [error, setError] = useState<string>("");
+  const [challenge, setChallenge] = useState<LoginChallenge | null>(null);
+
+  useEffect(() => {
+    if (denied || !code) {
+      setError("Login was cancelled");
+      return;
+    }
+    axios
+      .post<{ token?: string; refresh_token?: string } & Partial<LoginChallenge>>(
+        `${API_URL}/auth/oidc/callback`,
+        { state, code },
+        { withCredentials: true }
+      )
+      .then((res) => {
+        if (res.data.two_factor_required) {
+          setChallenge(res.data as LoginChallenge);
+          return;
+        }
+        localStorage.setItem("token", res.data.token || "");
+        localStorage.setItem("refresh_token", res.data.refresh_token || "");
+        window.location.href = "/";
+      })
+      .catch((err: any) => setError(err?.response?.data?.error || "Login failed"));
+  }, [state, code, denied]);
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen bg-gray-50">
+      <div className="bg-white p-8 rounded shadow-md w-full max-w-md text-center">
+        <h1 className="text-2xl font-bold mb-6">Login</h1>
+        {challenge ? (
+          <TwoFactorStep challenge={challenge} />
+        ) : error ? (
+          <>
+            <div className="text-red-600 mb-4">{error}</div>
+            <Link href="/login" className="text-blue-600 hover:underline">
+              Back to login
+            </Link>
+          </>
+        ) : (
+          <p>Logging in...</p>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default function OAuthCallbackPage() {
+  return (
+    <Suspense>
+      <OAuthCallback />
+    </Suspense>
+  );
+}
@@ -5,7 +5,18 @@ import axios from "axios";
 import { useAuth } from "./contexts/AuthContext";
 import SessionList from "./components/SessionList";
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+interface Page<T> {
+  data: T[];
+  pagination: {
+    limit: number;
+    total: number;
+    sort: string;
+    has_more: boolean;
+    next_cursor?: string;
+  };
+}
 
 interface Session {
   id: number;
@@ -54,8 +65,10 @@ export default function Home() {
 
   const fetchSessions = async () => {
     try {
-      const response = await axios.get<Session[]>(`${API_URL}/sessions`);
-      setSessions(response.data || []);
+      const response = await axios.get<Page<Session>>(
+        `${API_URL}/sessions?limit=200`
+      );
+      setSessions(response.data.data || []);
       setSessionLoading(false);
     } catch (error) {
       setSessionLoading(false);
@@ -77,14 +90,24 @@ export default function Home() {
         alert("จำนวนคนเกินที่ว่าง");
         return;
       }
-      const response = await axios.post(`${API_URL}/bookings`, {
-        session_id: selectedSession.id,
-        name: formData.name,
-        email: formData.email,
-        phone: formData.phone ? formData.phone : undefined,
-        number_of_guests: formData.number_of_guests,
-        notes: formData.notes,
-      });
+      // Bookings belong to the signed in account, which must have a verified email.
+      const token = localStorage.getItem("token");
+      if (!token) {
+        alert("กรุณาเข้าสู่ระบบก่อนจอง");
+        return;
+      }
+      const response = await axios.post(
+        `${API_URL}/bookings`,
+        {
+          session_id: selectedSession.id,
+          name: formData.name,
+          email: formData.email,
+          phone: formData.phone ? formData.phone : undefined,
+          number_of_guests: formData.number_of_guests,
+          notes: formData.notes,
+        },
+        { headers: { Authorization: `Bearer ${token}` } }
+      );
       setShowBookingForm(false);
       setFormData({
         name: "",
@@ -170,11 +193,9 @@ export default function Home() {
                 <input
                   type="email"
                   required
+                  readOnly
                   value={formData.email}
-                  onChange={(e) =>
-                    setFormData({ ...formData, email: e.target.value })
-                  }
-                  className="w-full px-3 py-2 border border-gray-300 rounded-md"
+                  className="w-full px-3 py-2 border border-gray-300 rounded-md bg-gray-100"
                   placeholder="กรอกอีเมล"
                 />
               </div>
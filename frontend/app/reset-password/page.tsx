@@ -0,0 +1,91 @@
+"use client";
+import React, { Suspense, useState } from "react";
+import Link from "next/link";
+import { useSearchParams } from "next/navigation";
+import axios from "axios";
+
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+function ResetPassword() {
+  const token = useSearchParams().get("token") || "";
+  const [password, setPassword] = useState<string>("");
+  const [confirm, setConfirm] = useState<string>("");
+  const [loading, setLoading] = useState<boolean>(false);
+  const [error, setError] = useState<string>("");
+  const [done, setDone] = useState<boolean>(false);
+
+  const handleSubmit = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    if (password !== confirm) {
+      setError("Passwords do not match");
+      return;
+    }
+    setLoading(true);
+    setError("");
+    try {
+      await axios.post(`${API_URL}/password/reset`, { token, password });
+      localStorage.removeItem("token");
+      localStorage.removeItem("refresh_token");
+      setDone(true);
+    } catch (err: any) {
+      setError(err?.response?.data?.error || "Reset failed");
+    } finally {
+      setLoading(false);
+    }
+  };
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen bg-gray-50">
+      <div className="bg-white p-8 rounded shadow-md w-full max-w-md">
+        <h1 className="text-2xl font-bold mb-6 text-center">Choose a New Password</h1>
+        {done ? (
+          <p className="text-green-600 text-center">
+            Your password was changed and every device was signed out.{" "}
+            <Link href="/login" className="text-blue-600 hover:underline">
+              Log in
+            </Link>
+          </p>
+        ) : (
+          <form onSubmit={handleSubmit}>
+            <div className="mb-4">
+              <label className="block mb-1 font-medium">New password</label>
+              <input
+                type="password"
+                className="w-full border px-3 py-2 rounded"
+                required
+                value={password}
+                onChange={(e) => setPassword(e.target.value)}
+              />
+            </div>
+            <div className="mb-6">
+              <label className="block mb-1 font-medium">Confirm password</label>
+              <input
+                type="password"
+                className="w-full border px-3 py-2 rounded"
+                required
+                value={confirm}
+                onChange={(e) => setConfirm(e.target.value)}
+              />
+            </div>
+            {error && <div className="text-red-600 mb-4 text-center">{error}</div>}
+            <button
+              type="submit"
+              className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+              disabled={loading}
+            >
+              {loading ? "Saving..." : "Change password"}
+            </button>
+          </form>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default function ResetPasswordPage() {
+  return (
+    <Suspense>
+      <ResetPassword />
+    </Suspense>
+  );
+}
@@ -3,7 +3,7 @@ import React, { useState } from "react";
 import Link from "next/link";
 import axios from "axios";
 
-const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api";
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
 
 export default function Signup() {
   const [name, setName] = useState<string>("");
@@ -29,7 +29,7 @@ export default function Signup() {
       setSuccess(true);
       setTimeout(() => {
         window.location.href = "/login";
-      }, 1200);
+      }, 2500);
     } catch (err: any) {
       setError(err?.response?.data?.error || "Signup failed");
     } finally {
@@ -87,7 +87,7 @@ export default function Signup() {
           )}
           {success && (
             <div className="text-green-600 mb-4 text-center">
-              Signup successful! Redirecting to login...
+              Signup successful! Check your email for the verification link. Redirecting to login...
             </div>
           )}
           <button
@@ -0,0 +1,82 @@
+"use client";
+import React, { Suspense, useEffect, useState } from "react";
+import Link from "next/link";
+import { useSearchParams } from "next/navigation";
+import axios from "axios";
+
+const API_URL = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080/api/v1";
+
+function VerifyEmail() {
+  const token = useSearchParams().get("token") || "";
+  const [status, setStatus] = useState<"pending" | "verified" | "failed">("pending");
+  const [error, setError] = useState<string>("");
+  const [email, setEmail] = useState<string>("");
+  const [resent, setResent] = useState<boolean>(false);
+
+  useEffect(() => {
+    axios
+      .post(`${API_URL}/email/verify`, { token })
+      .then(() => setStatus("verified"))
+      .catch((err: any) => {
+        setError(err?.response?.data?.error || "Verification failed");
+        setStatus("failed");
+      });
+  }, [token]);
+
+  const handleResend = async (e: React.FormEvent<HTMLFormElement>) => {
+    e.preventDefault();
+    await axios.post(`${API_URL}/email/verify/resend`, { email }).catch(() => undefined);
+    setResent(true);
+  };
+
+  return (
+    <div className="flex flex-col items-center justify-center min-h-screen bg-gray-50">
+      <div className="bg-white p-8 rounded shadow-md w-full max-w-md text-center">
+        <h1 className="text-2xl font-bold mb-6">Verify Email</h1>
+        {status === "pending" && <p>Verifying...</p>}
+        {status === "verified" && (
+          <p className="text-green-600">
+            Your email is verified. You can now{" "}
+            <Link href="/" className="text-blue-600 hover:underline">
+              book a session
+            </Link>
+            .
+          </p>
+        )}
+        {status === "failed" && (
+          <form onSubmit={handleResend}>
+            <div className="text-red-600 mb-4">{error}</div>
+            {resent ? (
+              <p className="text-green-600">If the account needs it, a new link is on its way.</p>
+            ) : (
+              <>
+                <input
+                  type="email"
+                  className="w-full border px-3 py-2 rounded mb-4"
+                  placeholder="Email"
+                  required
+                  value={email}
+                  onChange={(e) => setEmail(e.target.value)}
+                />
+                <button
+                  type="submit"
+                  className="w-full bg-blue-600 text-white py-2 rounded font-semibold hover:bg-blue-700 transition"
+                >
+                  Send a new link
+                </button>
+              </>
+            )}
+          </form>
+        )}
+      </div>
+    </div>
+  );
+}
+
+export default function VerifyEmailPage() {
+  return (
+    <Suspense>
+      <VerifyEmail />
+    </Suspense>
+  );
+}